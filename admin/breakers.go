@@ -0,0 +1,59 @@
+package admin
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/alloykh/tracer-demo/remote"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// BreakersPath - the circuit breakers page, POST BreakersPath/reset?name=<name> resets the breakers of name
+const BreakersPath = "/debug/breakers"
+
+type breakerState struct {
+	Name  string    `json:"name"`
+	State string    `json:"state"`
+	Since time.Time `json:"since"`
+}
+
+// MountBreakers serves the state of the circuit breakers of the clients and their reset
+func (s *Server) MountBreakers() {
+
+	s.HandleFunc(BreakersPath, func(w http.ResponseWriter, r *http.Request) {
+
+		states := make([]breakerState, 0)
+		for _, b := range remote.Breakers() {
+			states = append(states, breakerState{Name: b.Name(), State: b.State().String(), Since: b.Changed()})
+		}
+
+		writeJSON(w, http.StatusOK, states)
+	})
+
+	s.HandleFunc(BreakersPath+"/reset", func(w http.ResponseWriter, r *http.Request) {
+
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", "POST")
+			writeError(w, http.StatusMethodNotAllowed, errors.Errorf("method %s not allowed", r.Method))
+			return
+		}
+
+		name := r.FormValue("name")
+		reset := 0
+		for _, b := range remote.Breakers() {
+			if b.Name() == name {
+				b.Reset()
+				reset++
+			}
+		}
+
+		if reset == 0 {
+			writeError(w, http.StatusNotFound, errors.Errorf("no circuit breaker %q", name))
+			return
+		}
+
+		s.changed(r, "circuit breaker reset from admin", zap.String("name", name), zap.Int("breakers", reset))
+		writeJSON(w, http.StatusOK, map[string]interface{}{"name": name, "reset": reset})
+	})
+}
@@ -0,0 +1,17 @@
+package admin
+
+import (
+	"net/http"
+
+	"github.com/alloykh/tracer-demo/remote"
+)
+
+// HTTPPoolsPath - the connections of the HTTP clients per host
+const HTTPPoolsPath = "/debug/httppools"
+
+// MountHTTPPools serves the connections of the HTTP clients per host: open, in use, idle, dials and reuse
+func (s *Server) MountHTTPPools() {
+	s.HandleFunc(HTTPPoolsPath, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, remote.HTTPPools())
+	})
+}
@@ -0,0 +1,123 @@
+package admin
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/alloykh/tracer-demo/tracing/profiling"
+	"github.com/gin-gonic/gin"
+	"github.com/opentracing/opentracing-go"
+	"google.golang.org/grpc"
+)
+
+// InFlightPath - the requests in flight page
+const InFlightPath = "/debug/inflight"
+
+// InFlight follows the requests being served, put its middleware and interceptors after the tracing ones
+// so the requests of the sampled traces show their trace id
+type InFlight struct {
+	mu       sync.Mutex
+	next     uint64
+	requests map[uint64]*Request
+}
+
+// Request - a request being served
+type Request struct {
+	// Kind - http or grpc
+	Kind string `json:"kind"`
+	// Name - the method and the path of HTTP, the full method of gRPC
+	Name     string    `json:"name"`
+	TraceID  string    `json:"trace_id,omitempty"`
+	Started  time.Time `json:"started"`
+	Duration string    `json:"duration"`
+}
+
+// NewInFlight -
+func NewInFlight() *InFlight {
+	return &InFlight{requests: make(map[uint64]*Request)}
+}
+
+// Start records a request, call done once it is served
+func (f *InFlight) Start(ctx context.Context, kind, name string) (done func()) {
+
+	req := &Request{Kind: kind, Name: name, Started: time.Now()}
+	if span := opentracing.SpanFromContext(ctx); span != nil {
+		req.TraceID = profiling.TraceID(span)
+	}
+
+	f.mu.Lock()
+	f.next++
+	id := f.next
+	f.requests[id] = req
+	f.mu.Unlock()
+
+	return func() {
+		f.mu.Lock()
+		delete(f.requests, id)
+		f.mu.Unlock()
+	}
+}
+
+// Len returns the number of requests in flight
+func (f *InFlight) Len() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.requests)
+}
+
+// Requests returns the requests in flight, the oldest first
+func (f *InFlight) Requests() []Request {
+
+	now := time.Now()
+
+	f.mu.Lock()
+	out := make([]Request, 0, len(f.requests))
+	for _, req := range f.requests {
+		r := *req
+		r.Duration = now.Sub(r.Started).String()
+		out = append(out, r)
+	}
+	f.mu.Unlock()
+
+	sort.Slice(out, func(i, j int) bool { return out[i].Started.Before(out[j].Started) })
+
+	return out
+}
+
+// Middleware records the HTTP requests
+func (f *InFlight) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		done := f.Start(c.Request.Context(), "http", c.Request.Method+" "+c.Request.URL.Path)
+		defer done()
+		c.Next()
+	}
+}
+
+// UnaryServerInterceptor records the unary gRPC calls
+func (f *InFlight) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		done := f.Start(ctx, "grpc", info.FullMethod)
+		defer done()
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor records the gRPC streams
+func (f *InFlight) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		done := f.Start(ss.Context(), "grpc", info.FullMethod)
+		defer done()
+		return handler(srv, ss)
+	}
+}
+
+// MountInFlight serves the requests in flight of f
+func (s *Server) MountInFlight(f *InFlight) {
+	s.HandleFunc(InFlightPath, func(w http.ResponseWriter, r *http.Request) {
+		requests := f.Requests()
+		writeJSON(w, http.StatusOK, map[string]interface{}{"count": len(requests), "requests": requests})
+	})
+}
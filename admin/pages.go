@@ -0,0 +1,102 @@
+package admin
+
+import (
+	"expvar"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"runtime/debug"
+	"time"
+
+	"github.com/alloykh/tracer-demo/log"
+	"go.uber.org/zap"
+)
+
+// pages mounted by the Mount methods
+const (
+	PprofPath    = "/debug/pprof/"
+	VarsPath     = "/debug/vars"
+	LogLevelPath = "/debug/loglevel"
+	BuildPath    = "/debug/build"
+)
+
+// build of the service, set with -ldflags "-X github.com/alloykh/tracer-demo/admin.Version=v1.2.0 ..."
+var (
+	Version   = "dev"
+	Commit    = ""
+	BuildTime = ""
+)
+
+// started is the start time reported by the build info
+var started = time.Now()
+
+// MountPprof serves the runtime profiles of net/http/pprof, e.g. go tool pprof http://host:port/debug/pprof/heap?token=...
+func (s *Server) MountPprof() {
+	s.HandleFunc(PprofPath, pprof.Index)
+	s.HandleFunc(PprofPath+"cmdline", pprof.Cmdline)
+	s.HandleFunc(PprofPath+"profile", pprof.Profile)
+	s.HandleFunc(PprofPath+"symbol", pprof.Symbol)
+	s.HandleFunc(PprofPath+"trace", pprof.Trace)
+}
+
+// MountVars serves the expvar variables, the memory stats and the command line among them
+func (s *Server) MountVars() {
+	s.Handle(VarsPath, expvar.Handler())
+}
+
+// MountLogLevel serves the log level of logr, PUT level=debug changes it until the next config reload
+func (s *Server) MountLogLevel(logr *log.Factory) {
+
+	levels := logr.LevelHandler()
+
+	s.HandleFunc(LogLevelPath, func(w http.ResponseWriter, r *http.Request) {
+
+		before := logr.Level()
+		levels.ServeHTTP(w, r)
+
+		if after := logr.Level(); after != before {
+			s.changed(r, "log level changed", zap.String("from", before.String()), zap.String("to", after.String()))
+		}
+	})
+}
+
+// BuildInfo - the build of the service and its process
+type BuildInfo struct {
+	Service   string    `json:"service"`
+	Version   string    `json:"version"`
+	Commit    string    `json:"commit,omitempty"`
+	BuildTime string    `json:"build_time,omitempty"`
+	GoVersion string    `json:"go_version"`
+	Path      string    `json:"path,omitempty"`
+	Module    string    `json:"module,omitempty"`
+	Started   time.Time `json:"started"`
+	Uptime    string    `json:"uptime"`
+}
+
+// Build returns the build info of the service
+func Build(service string) BuildInfo {
+
+	info := BuildInfo{
+		Service:   service,
+		Version:   Version,
+		Commit:    Commit,
+		BuildTime: BuildTime,
+		GoVersion: runtime.Version(),
+		Started:   started,
+		Uptime:    time.Since(started).Round(time.Second).String(),
+	}
+
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		info.Path = bi.Path
+		info.Module = bi.Main.Path + "@" + bi.Main.Version
+	}
+
+	return info
+}
+
+// MountBuildInfo serves the build info of the service
+func (s *Server) MountBuildInfo(service string) {
+	s.HandleFunc(BuildPath, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, Build(service))
+	})
+}
@@ -0,0 +1,57 @@
+package admin
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/alloykh/tracer-demo/tracing"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// SamplerPath - the sampler page
+const SamplerPath = "/debug/sampler"
+
+type samplerState struct {
+	Strategy   tracing.SamplingStrategy `json:"strategy"`
+	Configured tracing.SamplingStrategy `json:"configured"`
+	Overridden bool                     `json:"overridden"`
+}
+
+// MountSampler serves the strategy of sampler. PUT type=probabilistic&param=0.1 overrides the configured
+// strategy, DELETE clears the override.
+func (s *Server) MountSampler(sampler *tracing.Sampler) {
+
+	state := func() samplerState {
+		configured, overridden := sampler.Configured()
+		return samplerState{Strategy: sampler.Strategy(), Configured: configured, Overridden: overridden}
+	}
+
+	s.HandleFunc(SamplerPath, func(w http.ResponseWriter, r *http.Request) {
+
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut, http.MethodPost:
+			typ := r.FormValue("type")
+			param, err := strconv.ParseFloat(r.FormValue("param"), 64)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, errors.Wrap(err, "param"))
+				return
+			}
+			if err := sampler.Override(typ, param); err != nil {
+				writeError(w, http.StatusBadRequest, err)
+				return
+			}
+			s.changed(r, "sampler overridden", zap.String("type", typ), zap.Float64("param", param))
+		case http.MethodDelete:
+			sampler.ClearOverride()
+			s.changed(r, "sampler override cleared")
+		default:
+			w.Header().Set("Allow", "GET, PUT, POST, DELETE")
+			writeError(w, http.StatusMethodNotAllowed, errors.Errorf("method %s not allowed", r.Method))
+			return
+		}
+
+		writeJSON(w, http.StatusOK, state())
+	})
+}
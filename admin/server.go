@@ -0,0 +1,177 @@
+// Package admin is the admin listener of a service: pprof, expvar, the log level, the sampler, the circuit
+// breakers, the requests in flight, the build info and whatever the service mounts, behind a token.
+// It runs on a port of its own, so it is there for gRPC-only services too.
+package admin
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/alloykh/tracer-demo/log"
+	"go.uber.org/zap"
+)
+
+// TokenParam is the query parameter carrying the token for the clients which cannot set a header, e.g. go tool pprof
+const TokenParam = "token"
+
+// Server is the handler of the admin listener. With a token, every path but the public ones requires
+// "Authorization: Bearer <token>" or ?token=<token>.
+type Server struct {
+	mux   *http.ServeMux
+	token string
+	logr  *log.Factory
+
+	mu     sync.RWMutex
+	public map[string]bool
+	paths  []string
+}
+
+// Option controls the behavior of the Server.
+type Option func(*Server)
+
+// WithToken requires token on the paths which are not public, "" disables the auth
+func WithToken(token string) Option {
+	return func(s *Server) {
+		s.token = token
+	}
+}
+
+// WithLogger logs the requests refused and the changes made from the admin pages
+func WithLogger(logr *log.Factory) Option {
+	return func(s *Server) {
+		s.logr = logr
+	}
+}
+
+// New returns a Server serving the index of its pages on /
+func New(opts ...Option) *Server {
+
+	s := &Server{
+		mux:    http.NewServeMux(),
+		public: make(map[string]bool),
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	s.mux.HandleFunc("/", s.index)
+
+	return s
+}
+
+// Mux returns the mux of the server, for the packages mounting their pages on a mux
+func (s *Server) Mux() *http.ServeMux {
+	return s.mux
+}
+
+// Handle registers h on path
+func (s *Server) Handle(path string, h http.Handler) {
+	s.mux.Handle(path, h)
+	s.addPath(path)
+}
+
+// HandleFunc registers fn on path
+func (s *Server) HandleFunc(path string, fn func(w http.ResponseWriter, r *http.Request)) {
+	s.Handle(path, http.HandlerFunc(fn))
+}
+
+// List adds paths mounted on Mux to the index
+func (s *Server) List(paths ...string) {
+	for _, p := range paths {
+		s.addPath(p)
+	}
+}
+
+// Public serves paths without the token, e.g. the probes of the orchestrator
+func (s *Server) Public(paths ...string) {
+	s.mu.Lock()
+	for _, p := range paths {
+		s.public[p] = true
+	}
+	s.mu.Unlock()
+}
+
+func (s *Server) addPath(path string) {
+	s.mu.Lock()
+	s.paths = append(s.paths, path)
+	s.mu.Unlock()
+}
+
+// ServeHTTP checks the token then serves the page
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	if !s.authorized(r) {
+		if s.logr != nil {
+			s.logr.Default().Info("admin request refused", zap.String("path", r.URL.Path), zap.String("remote", r.RemoteAddr))
+		}
+		w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) authorized(r *http.Request) bool {
+
+	if s.token == "" {
+		return true
+	}
+
+	s.mu.RLock()
+	public := s.public[r.URL.Path]
+	s.mu.RUnlock()
+
+	if public {
+		return true
+	}
+
+	token := r.URL.Query().Get(TokenParam)
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		token = strings.TrimPrefix(auth, "Bearer ")
+	}
+
+	return subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) == 1
+}
+
+// index lists the pages, the other unknown paths are not found
+func (s *Server) index(w http.ResponseWriter, r *http.Request) {
+
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+
+	s.mu.RLock()
+	paths := append([]string(nil), s.paths...)
+	s.mu.RUnlock()
+
+	sort.Strings(paths)
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"pages": paths})
+}
+
+// changed logs a change made from an admin page
+func (s *Server) changed(r *http.Request, msg string, fields ...zap.Field) {
+	if s.logr != nil {
+		s.logr.Default().Info(msg, append(fields, zap.String("remote", r.RemoteAddr))...)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, map[string]string{"error": err.Error()})
+}
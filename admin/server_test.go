@@ -0,0 +1,68 @@
+package admin_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alloykh/tracer-demo/admin"
+)
+
+func TestServerToken(t *testing.T) {
+
+	s := admin.New(admin.WithToken("s3cret"))
+	s.HandleFunc("/debug/sampler", func(w http.ResponseWriter, r *http.Request) {})
+	s.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {})
+	s.Public("/readyz")
+
+	tests := []struct {
+		name   string
+		target string
+		header string
+		want   int
+	}{
+		{name: "no token", target: "/debug/sampler", want: http.StatusUnauthorized},
+		{name: "bearer header", target: "/debug/sampler", header: "Bearer s3cret", want: http.StatusOK},
+		{name: "wrong header", target: "/debug/sampler", header: "Bearer guess", want: http.StatusUnauthorized},
+		{name: "not a bearer header", target: "/debug/sampler", header: "Basic s3cret", want: http.StatusUnauthorized},
+		{name: "query param", target: "/debug/sampler?" + admin.TokenParam + "=s3cret", want: http.StatusOK},
+		{name: "wrong query param", target: "/debug/sampler?" + admin.TokenParam + "=guess", want: http.StatusUnauthorized},
+		{name: "header wins over query param", target: "/debug/sampler?" + admin.TokenParam + "=s3cret", header: "Bearer guess", want: http.StatusUnauthorized},
+		{name: "public path", target: "/readyz", want: http.StatusOK},
+		{name: "index", target: "/", want: http.StatusUnauthorized},
+		{name: "unknown path", target: "/nope?" + admin.TokenParam + "=s3cret", want: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			r := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			if tt.header != "" {
+				r.Header.Set("Authorization", tt.header)
+			}
+
+			rec := httptest.NewRecorder()
+			s.ServeHTTP(rec, r)
+
+			if rec.Code != tt.want {
+				t.Errorf("%s: %d, want %d", tt.target, rec.Code, tt.want)
+			}
+			if rec.Code == http.StatusUnauthorized && rec.Header().Get("WWW-Authenticate") == "" {
+				t.Error("refused without WWW-Authenticate")
+			}
+		})
+	}
+}
+
+func TestServerWithoutToken(t *testing.T) {
+
+	s := admin.New()
+	s.HandleFunc("/debug/sampler", func(w http.ResponseWriter, r *http.Request) {})
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/sampler", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("%d, want 200 without a token", rec.Code)
+	}
+}
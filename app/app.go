@@ -0,0 +1,418 @@
+// Package app is the bootstrap of the services: it builds the logger, the metrics, the tracer,
+// the health checks and the HTTP and gRPC servers from a Config, runs them with the components
+// of the service until SIGINT or SIGTERM and shuts everything down in order:
+// stop the traffic, drain the background work, close the clients and flush the tracer last.
+package app
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/alloykh/tracer-demo/admin"
+	"github.com/alloykh/tracer-demo/config"
+	"github.com/alloykh/tracer-demo/health"
+	"github.com/alloykh/tracer-demo/log"
+	"github.com/alloykh/tracer-demo/remote"
+	"github.com/alloykh/tracer-demo/tracing"
+	"github.com/alloykh/tracer-demo/tracing/depgraph"
+	"github.com/alloykh/tracer-demo/tracing/grpctrace"
+	"github.com/alloykh/tracer-demo/tracing/profiling"
+	"github.com/alloykh/tracer-demo/tracing/traceview"
+	"github.com/gin-gonic/gin"
+	"github.com/opentracing/opentracing-go"
+	"github.com/pkg/errors"
+	"github.com/uber/jaeger-lib/metrics"
+	jprom "github.com/uber/jaeger-lib/metrics/prometheus"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+)
+
+type options struct {
+	logOpts     []log.FactoryOption
+	tracerOpts  []func(logr *log.Factory) []tracing.JaegerOption
+	httpTracing []tracing.MWOption
+	grpcTracing []grpctrace.Option
+	grpcServer  []grpc.ServerOption
+
+	loader *config.Loader
+	loaded Configurable
+}
+
+// Option controls how New builds the service.
+type Option func(*options)
+
+// WithLogOptions adds options to the logger factory
+func WithLogOptions(opts ...log.FactoryOption) Option {
+	return func(o *options) {
+		o.logOpts = append(o.logOpts, opts...)
+	}
+}
+
+// WithTracerOptions adds the tracer options returned by f, given the logger of the service
+func WithTracerOptions(f func(logr *log.Factory) []tracing.JaegerOption) Option {
+	return func(o *options) {
+		o.tracerOpts = append(o.tracerOpts, f)
+	}
+}
+
+// WithHTTPTracing adds options to the tracing middleware of the HTTP server
+func WithHTTPTracing(opts ...tracing.MWOption) Option {
+	return func(o *options) {
+		o.httpTracing = append(o.httpTracing, opts...)
+	}
+}
+
+// WithGRPCTracing adds options to the tracing interceptors of the gRPC server
+func WithGRPCTracing(opts ...grpctrace.Option) Option {
+	return func(o *options) {
+		o.grpcTracing = append(o.grpcTracing, opts...)
+	}
+}
+
+// WithGRPCServerOptions adds options to the gRPC server
+func WithGRPCServerOptions(opts ...grpc.ServerOption) Option {
+	return func(o *options) {
+		o.grpcServer = append(o.grpcServer, opts...)
+	}
+}
+
+// WithConfig reloads the config file of loader while the service runs, cfg is the config loaded from it.
+// The reloaded settings of Config are applied by the app, the others by the OnReload hooks.
+func WithConfig(loader *config.Loader, cfg Configurable) Option {
+	return func(o *options) {
+		o.loader = loader
+		o.loaded = cfg
+	}
+}
+
+// adminWriteTimeout - the admin pages stream profiles, the labelled CPU profile runs up to 60s and
+// /debug/pprof/profile refuses captures longer than the write timeout
+const adminWriteTimeout = time.Second * 90
+
+// App is a service built from a Config. Register the routes on Router, the services on GRPC,
+// the background work with Go and the shutdown hooks with OnDrain and OnClose, then Run it.
+type App struct {
+	// Config the app was built with, the reloaded settings are in CurrentConfig
+	Config Config
+
+	Logr     *log.Factory
+	Metrics  metrics.Factory
+	Tracer   opentracing.Tracer
+	Health   *health.Health
+	Recorder *traceview.Recorder
+	Deps     *depgraph.Collector
+	Profiler *profiling.SlowProfiler
+	// Sampler of the tracer, its strategy follows Config.Sampling
+	Sampler *tracing.Sampler
+	// InFlight - the requests being served by the HTTP and gRPC servers
+	InFlight *admin.InFlight
+
+	// Router of the HTTP server, nil without HTTP.Addr
+	Router *gin.Engine
+	// GRPC server, nil without GRPC.Addr
+	GRPC *grpc.Server
+	// Admin listener, nil without AdminAddr
+	Admin *admin.Server
+
+	closeTracer func()
+
+	httpServer  *http.Server
+	adminServer *http.Server
+
+	// cancelled at the drain step
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	components sync.WaitGroup
+	failed     chan error
+	failOnce   sync.Once
+
+	// the Configurable in use, replaced on reload
+	current atomic.Value
+
+	mu      sync.Mutex
+	traffic []hook
+	drain   []hook
+	clients []hook
+	reload  []reloadHook
+}
+
+type hook struct {
+	name string
+	fn   func(ctx context.Context) error
+}
+
+type reloadHook struct {
+	name string
+	fn   func(cfg Configurable) error
+}
+
+// New builds the service described by cfg, nothing is listening before Run
+func New(cfg Config, opts ...Option) (*App, error) {
+
+	if cfg.Name == "" {
+		return nil, errors.New("app: the service has no name")
+	}
+
+	cfg = cfg.withDefaults()
+
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	a := &App{
+		Config:   cfg,
+		InFlight: admin.NewInFlight(),
+		failed:   make(chan error, 1),
+	}
+	a.ctx, a.cancel = context.WithCancel(context.Background())
+
+	if o.loaded != nil {
+		a.current.Store(o.loaded)
+	} else {
+		a.current.Store(Configurable(&cfg))
+	}
+
+	a.Logr = log.NewFactory("zap", cfg.LogLevel, o.logOpts...)
+	a.Metrics = jprom.New().Namespace(metrics.NSOptions{Name: cfg.Name, Tags: nil})
+
+	remote.SetBreakerThresholds(cfg.Breaker.breakerThresholds())
+
+	sampler, err := tracing.NewSampler(cfg.Sampling.Type, cfg.Sampling.Param)
+	if err != nil {
+		return nil, errors.Wrap(err, "app: sampling")
+	}
+	a.Sampler = sampler
+
+	// recent traces for /debug/traces, calls made by the service for /debug/dependencies
+	tracerOpts := []tracing.JaegerOption{tracing.WithDynamicSampler(sampler)}
+	if cfg.DebugTraces > 0 {
+		a.Recorder = traceview.NewRecorder(cfg.DebugTraces)
+		a.Deps = depgraph.NewCollector()
+		tracerOpts = append(tracerOpts, tracing.WithSpanReporter(a.Recorder), tracing.WithSpanReporter(a.Deps))
+	}
+	for _, f := range o.tracerOpts {
+		tracerOpts = append(tracerOpts, f(a.Logr)...)
+	}
+
+	// readiness follows the reporter of the tracer
+	stats := &tracing.ReporterStats{}
+	tracerOpts = append(tracerOpts, tracing.WithReporterStats(stats))
+
+	// InitJaeger sets the global tracer as well
+	a.Tracer, a.closeTracer = tracing.InitJaeger(cfg.Name, a.Metrics, a.Logr, tracerOpts...)
+
+	a.Health = health.New(health.WithLogger(a.Logr))
+	a.Health.Ready("tracer reporter", health.ReporterQueue(stats, cfg.ReporterQueueRatio))
+
+	if cfg.SlowRequest > 0 {
+		a.Profiler = profiling.NewSlowProfiler(cfg.SlowRequest, cfg.SlowProfile, profiling.NewStore(cfg.SlowProfiles))
+	}
+
+	if cfg.HTTP.Addr != "" {
+		a.newRouter(o.httpTracing)
+	}
+
+	if cfg.GRPC.Addr != "" {
+		a.newGRPCServer(o.grpcTracing, o.grpcServer)
+	}
+
+	if cfg.AdminAddr != "" {
+		a.Admin = admin.New(admin.WithToken(cfg.AdminToken), admin.WithLogger(a.Logr))
+		a.adminServer = &http.Server{
+			Addr:         cfg.AdminAddr,
+			Handler:      a.Admin,
+			ReadTimeout:  cfg.HTTP.ReadTimeout,
+			WriteTimeout: adminWriteTimeout,
+		}
+	}
+
+	a.mountDebug()
+
+	if o.loader != nil && o.loader.File() != "" {
+		loader := o.loader
+		config.WithLogger(a.Logr)(loader)
+		a.Go("config watch", func(ctx context.Context) error {
+			loader.Watch(ctx, 0, a.CurrentConfig(), a.applyConfig)
+			return nil
+		})
+	}
+
+	return a, nil
+}
+
+// CurrentConfig returns the config in use, the one given to WithConfig as last reloaded, Config otherwise
+func (a *App) CurrentConfig() Configurable {
+	return a.current.Load().(Configurable)
+}
+
+// OnReload registers a hook applying the reloaded settings of the service, e.g. the timeouts of its clients.
+// The hooks run in the order they were registered, with the config of the type given to WithConfig.
+func (a *App) OnReload(name string, fn func(cfg Configurable) error) {
+	a.mu.Lock()
+	a.reload = append(a.reload, reloadHook{name: name, fn: fn})
+	a.mu.Unlock()
+}
+
+// applyConfig applies the reloadable settings of next, then runs the reload hooks
+func (a *App) applyConfig(next interface{}) {
+
+	cfg, ok := next.(Configurable)
+	if !ok {
+		a.Logr.Default().Error("config reload", zap.String("err", fmt.Sprintf("%T is not Configurable", next)))
+		return
+	}
+
+	c := cfg.AppConfig().withDefaults()
+
+	a.Logr.SetLevel(c.LogLevel)
+	remote.SetBreakerThresholds(c.Breaker.breakerThresholds())
+	if err := a.Sampler.Update(c.Sampling.Type, c.Sampling.Param); err != nil {
+		a.Logr.Default().Error("config reload", zap.String("setting", "sampling"), zap.String("err", err.Error()))
+	}
+
+	a.current.Store(cfg)
+
+	a.mu.Lock()
+	hooks := a.reload
+	a.mu.Unlock()
+
+	for _, h := range hooks {
+		if err := h.fn(cfg); err != nil {
+			a.Logr.Default().Error("config reload hook", zap.String("hook", h.name), zap.String("err", err.Error()))
+		}
+	}
+}
+
+func (a *App) newRouter(tracingOpts []tracing.MWOption) {
+
+	router := gin.New()
+
+	mwOpts := []tracing.MWOption{
+		tracing.MWSpanFilter(func(r *http.Request) bool { return traceview.SkipDebug(r) && health.SkipProbes(r) }),
+		tracing.MWProfileLabels(a.Config.Name, false),
+		tracing.MWSlowProfile(a.Profiler),
+	}
+
+	router.Use(gin.Recovery())
+	router.Use(tracing.Tracer(a.Tracer, append(mwOpts, tracingOpts...)...))
+	router.Use(a.InFlight.Middleware())
+
+	a.Router = router
+	a.httpServer = &http.Server{
+		Addr:         a.Config.HTTP.Addr,
+		Handler:      router,
+		ReadTimeout:  a.Config.HTTP.ReadTimeout,
+		WriteTimeout: a.Config.HTTP.WriteTimeout,
+	}
+}
+
+func (a *App) newGRPCServer(tracingOpts []grpctrace.Option, serverOpts []grpc.ServerOption) {
+
+	traceOpts := grpctrace.ServerOptions(append([]grpctrace.Option{
+		grpctrace.WithLogger(a.Logr),
+		grpctrace.WithFilter(health.SkipGRPC),
+		grpctrace.WithProfileLabels(a.Config.Name, false),
+		grpctrace.WithSlowProfile(a.Profiler),
+	}, tracingOpts...)...)
+
+	// inside the tracing interceptors, the requests in flight know their trace
+	traceOpts = append(traceOpts,
+		grpc.ChainUnaryInterceptor(a.InFlight.UnaryServerInterceptor()),
+		grpc.ChainStreamInterceptor(a.InFlight.StreamServerInterceptor()),
+	)
+	traceOpts = append(traceOpts, remote.KeepaliveServerOptions()...)
+
+	a.GRPC = grpc.NewServer(append(traceOpts, serverOpts...)...)
+}
+
+// mountAdmin serves the probes, open to the orchestrator, the debug pages and the admin pages on the admin listener
+func (a *App) mountAdmin(current func() interface{}) {
+
+	mux := a.Admin.Mux()
+
+	health.Mount(mux, a.Health)
+	a.Admin.List(health.LivePath, health.ReadyPath)
+	a.Admin.Public(health.LivePath, health.ReadyPath)
+
+	a.Admin.Handle(config.Path, config.Handler(current))
+
+	a.Admin.MountPprof()
+	a.Admin.MountVars()
+	a.Admin.MountLogLevel(a.Logr)
+	a.Admin.MountSampler(a.Sampler)
+	a.Admin.MountBreakers()
+	a.Admin.MountHTTPPools()
+	a.Admin.MountInFlight(a.InFlight)
+	a.Admin.MountBuildInfo(a.Config.Name)
+
+	profiling.Mount(mux, a.Profiler.Store())
+	a.Admin.List(profiling.Path)
+	if a.Profiler.Store() != nil {
+		a.Admin.List(profiling.SpanPath)
+	}
+
+	if a.Recorder != nil {
+		traceview.Mount(mux, a.Recorder)
+		depgraph.Mount(mux, a.Deps)
+		a.Admin.List(traceview.Path, depgraph.Path)
+	}
+
+	if a.Config.AdminToken == "" {
+		a.Logr.Default().Info("admin listener without token", zap.String("addr", a.Config.AdminAddr))
+	}
+}
+
+// mountDebug serves the probes and the debug pages on the admin listener, on the router otherwise
+func (a *App) mountDebug() {
+
+	current := func() interface{} { return a.CurrentConfig() }
+
+	if a.Admin != nil {
+		a.mountAdmin(current)
+		return
+	}
+
+	if a.Router == nil {
+		return
+	}
+
+	a.Router.GET(config.Path, gin.WrapH(config.Handler(current)))
+	a.Router.GET(health.LivePath, gin.WrapH(health.LiveHandler(a.Health)))
+	a.Router.GET(health.ReadyPath, gin.WrapH(health.ReadyHandler(a.Health)))
+
+	// CPU profile of a pprof label, e.g. ?label=route=/order, and the profiles of the slow requests
+	a.Router.GET(profiling.Path, gin.WrapH(profiling.Handler()))
+	if store := a.Profiler.Store(); store != nil {
+		a.Router.GET(profiling.SpanPath+"*id", gin.WrapH(profiling.SpanHandler(store, profiling.SpanPath)))
+	}
+
+	if a.Recorder != nil {
+		a.Router.GET(traceview.Path+"/*id", gin.WrapH(traceview.Handler(a.Recorder, traceview.Path)))
+		a.Router.GET(depgraph.Path, gin.WrapH(depgraph.Handler(a.Deps)))
+	}
+}
+
+// DialGRPC connects to the service name at target, see remote.DialGRPC. The connection state and its
+// circuit breaker are readiness checks, the connection is closed with the clients.
+func (a *App) DialGRPC(name, target string, opts ...remote.GRPCOption) (*remote.GRPCConn, error) {
+
+	conn, err := remote.DialGRPC(a.Logr, name, target, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	a.Health.Ready("grpc "+name, health.GRPCConn(conn.ClientConn))
+	a.Health.Ready("breaker grpc "+name, health.Breaker(conn.Breaker()))
+
+	a.OnClose("grpc client "+name, func(ctx context.Context) error {
+		return conn.Close()
+	})
+
+	return conn, nil
+}
@@ -0,0 +1,256 @@
+package app
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"time"
+
+	"github.com/alloykh/tracer-demo/config"
+	"github.com/alloykh/tracer-demo/remote"
+	"github.com/alloykh/tracer-demo/tracing"
+	"go.uber.org/zap/zapcore"
+)
+
+// Config of a service, the zero values of the optional fields are replaced by the defaults.
+// It loads with the config package: LogLevel, Sampling and Breaker are reloaded while the service runs.
+type Config struct {
+	// Name of the service, of its tracer and of its metrics namespace
+	Name string `yaml:"name" required:"true"`
+	// LogLevel, info by default
+	LogLevel zapcore.Level `yaml:"log_level" reload:"true"`
+
+	HTTP HTTPConfig `yaml:"http"`
+	GRPC GRPCConfig `yaml:"grpc"`
+
+	// AdminAddr serves the probes, the debug pages and the admin pages on a separate listener,
+	// "" serves the probes and the debug pages on the HTTP server, if any, and no admin pages
+	AdminAddr string `yaml:"admin_addr"`
+	// AdminToken is required by the admin listener but on the probes, "" leaves it open
+	AdminToken string `yaml:"admin_token" secret:"true"`
+
+	// DebugTraces is the number of traces kept in memory for /debug/traces, 0 disables the viewer
+	// and /debug/dependencies
+	DebugTraces int `yaml:"debug_traces"`
+
+	// SlowRequest is the latency above which a request gets a CPU profile of up to SlowProfile
+	// attached to its span, SlowProfiles are kept in memory. 0 disables the slow request profiles.
+	SlowRequest  time.Duration `yaml:"slow_request"`
+	SlowProfile  time.Duration `yaml:"slow_profile"`
+	SlowProfiles int           `yaml:"slow_profiles"`
+
+	// ReporterQueueRatio is the filling of the span reporter queue above which the service is not ready
+	ReporterQueueRatio float64 `yaml:"reporter_queue_ratio"`
+
+	// Sampling of the traces started by the service, unless the tracer options set another sampler
+	Sampling SamplingConfig `yaml:"sampling" reload:"true"`
+	// Breaker - the thresholds of the circuit breakers of the clients
+	Breaker BreakerConfig `yaml:"breaker" reload:"true"`
+
+	Shutdown ShutdownConfig `yaml:"shutdown"`
+}
+
+// HTTPConfig - the HTTP server, served when Addr is set
+type HTTPConfig struct {
+	Addr         string        `yaml:"addr"`
+	ReadTimeout  time.Duration `yaml:"read_timeout"`
+	WriteTimeout time.Duration `yaml:"write_timeout"`
+}
+
+// GRPCConfig - the gRPC server, served when Addr is set
+type GRPCConfig struct {
+	Addr string `yaml:"addr"`
+}
+
+// SamplingConfig - the strategy of tracing.Sampler: const, probabilistic or ratelimiting
+type SamplingConfig struct {
+	Type  string  `yaml:"type"`
+	Param float64 `yaml:"param"`
+}
+
+// BreakerConfig - a breaker trips when at least FailureRatio of MinRequests or more requests failed
+type BreakerConfig struct {
+	MinRequests  uint32  `yaml:"min_requests"`
+	FailureRatio float64 `yaml:"failure_ratio"`
+}
+
+// ClientConfig - a service called by the service
+type ClientConfig struct {
+	// Target - host:port, a comma separated list of them for gRPC, a URL for HTTP
+	Target string `yaml:"target" required:"true"`
+	// Timeout of a call, reloaded while the service runs
+	Timeout time.Duration `yaml:"timeout" reload:"true"`
+}
+
+// ShutdownConfig - the time given to each step of the graceful shutdown
+type ShutdownConfig struct {
+	// Delay between the readiness failing and the servers closing their listeners,
+	// the time the load balancers need to notice
+	Delay time.Duration `yaml:"delay"`
+	// Traffic - the servers stop accepting requests and finish the ones in flight
+	Traffic time.Duration `yaml:"traffic"`
+	// Drain - the components stop and the drain hooks finish the background work
+	Drain time.Duration `yaml:"drain"`
+	// Clients - the connections to the other services are closed
+	Clients time.Duration `yaml:"clients"`
+	// Tracer - the spans still in the reporter are flushed, last so that the shutdown is traced
+	Tracer time.Duration `yaml:"tracer"`
+}
+
+// Configurable is the config of a service embedding Config, the app reloads it
+type Configurable interface {
+	AppConfig() *Config
+}
+
+// AppConfig implements Configurable
+func (c *Config) AppConfig() *Config {
+	return c
+}
+
+var defaultConfig = Config{
+	HTTP: HTTPConfig{
+		ReadTimeout:  time.Second * 7,
+		WriteTimeout: time.Second * 5,
+	},
+	SlowProfile:        time.Millisecond * 500,
+	SlowProfiles:       20,
+	ReporterQueueRatio: 0.9,
+	Sampling: SamplingConfig{
+		Type:  "const",
+		Param: 1,
+	},
+	Breaker: BreakerConfig{
+		MinRequests:  3,
+		FailureRatio: 0.6,
+	},
+	Shutdown: ShutdownConfig{
+		Traffic: time.Second * 10,
+		Drain:   time.Second * 5,
+		Clients: time.Second * 5,
+		Tracer:  time.Second * 5,
+	},
+}
+
+// DefaultConfig returns the config of the service name with the defaults set, the addresses are left empty
+func DefaultConfig(name string) Config {
+	c := defaultConfig
+	c.Name = name
+	return c
+}
+
+// withDefaults returns the config with the defaults of the fields not set
+func (c Config) withDefaults() Config {
+
+	d := defaultConfig
+
+	if c.HTTP.ReadTimeout <= 0 {
+		c.HTTP.ReadTimeout = d.HTTP.ReadTimeout
+	}
+	if c.HTTP.WriteTimeout <= 0 {
+		c.HTTP.WriteTimeout = d.HTTP.WriteTimeout
+	}
+	if c.SlowProfile <= 0 {
+		c.SlowProfile = d.SlowProfile
+	}
+	if c.SlowProfiles <= 0 {
+		c.SlowProfiles = d.SlowProfiles
+	}
+	if c.ReporterQueueRatio <= 0 {
+		c.ReporterQueueRatio = d.ReporterQueueRatio
+	}
+	if c.Sampling.Type == "" {
+		c.Sampling = d.Sampling
+	}
+	if c.Breaker.MinRequests == 0 {
+		c.Breaker.MinRequests = d.Breaker.MinRequests
+	}
+	if c.Breaker.FailureRatio <= 0 {
+		c.Breaker.FailureRatio = d.Breaker.FailureRatio
+	}
+	if c.Shutdown.Traffic <= 0 {
+		c.Shutdown.Traffic = d.Shutdown.Traffic
+	}
+	if c.Shutdown.Drain <= 0 {
+		c.Shutdown.Drain = d.Shutdown.Drain
+	}
+	if c.Shutdown.Clients <= 0 {
+		c.Shutdown.Clients = d.Shutdown.Clients
+	}
+	if c.Shutdown.Tracer <= 0 {
+		c.Shutdown.Tracer = d.Shutdown.Tracer
+	}
+
+	return c
+}
+
+// Validate implements config.Validator, the zero values are valid: they are replaced by the defaults
+func (c *Config) Validate() error {
+
+	var problems []string
+	add := func(path string, format string, args ...interface{}) {
+		problems = append(problems, path+": "+fmt.Sprintf(format, args...))
+	}
+
+	for path, addr := range map[string]string{"http.addr": c.HTTP.Addr, "grpc.addr": c.GRPC.Addr, "admin_addr": c.AdminAddr} {
+		if addr == "" {
+			continue
+		}
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			add(path, "want host:port, %v", err)
+		}
+	}
+
+	if c.DebugTraces < 0 {
+		add("debug_traces", "must not be negative")
+	}
+
+	if c.ReporterQueueRatio < 0 || c.ReporterQueueRatio > 1 {
+		add("reporter_queue_ratio", "must be in [0, 1], got %v", c.ReporterQueueRatio)
+	}
+
+	if c.Sampling.Type != "" {
+		if err := tracing.ValidateSampler(c.Sampling.Type, c.Sampling.Param); err != nil {
+			add("sampling", "%v", err)
+		}
+	}
+
+	if c.Breaker.FailureRatio < 0 || c.Breaker.FailureRatio > 1 {
+		add("breaker.failure_ratio", "must be in [0, 1], got %v", c.Breaker.FailureRatio)
+	}
+
+	for path, d := range map[string]time.Duration{
+		"http.read_timeout":  c.HTTP.ReadTimeout,
+		"http.write_timeout": c.HTTP.WriteTimeout,
+		"slow_request":       c.SlowRequest,
+		"slow_profile":       c.SlowProfile,
+		"shutdown.delay":     c.Shutdown.Delay,
+		"shutdown.traffic":   c.Shutdown.Traffic,
+		"shutdown.drain":     c.Shutdown.Drain,
+		"shutdown.clients":   c.Shutdown.Clients,
+		"shutdown.tracer":    c.Shutdown.Tracer,
+	} {
+		if d < 0 {
+			add(path, "must not be negative, got %s", d)
+		}
+	}
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return &config.Error{Problems: problems}
+	}
+
+	return nil
+}
+
+// Validate implements config.Validator
+func (c *ClientConfig) Validate() error {
+	if c.Timeout < 0 {
+		return fmt.Errorf("timeout: must not be negative, got %s", c.Timeout)
+	}
+	return nil
+}
+
+// breakerThresholds - the thresholds of the remote package
+func (c BreakerConfig) breakerThresholds() remote.BreakerThresholds {
+	return remote.BreakerThresholds{MinRequests: c.MinRequests, FailureRatio: c.FailureRatio}
+}
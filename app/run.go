@@ -0,0 +1,357 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/alloykh/tracer-demo/health"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/reflection"
+)
+
+// Go runs fn concurrently until the drain step of the shutdown cancels its context.
+// An error returned before the shutdown stops the service, which then exits with a failure.
+func (a *App) Go(name string, fn func(ctx context.Context) error) {
+
+	a.components.Add(1)
+
+	go func() {
+		defer a.components.Done()
+
+		err := fn(a.ctx)
+		if err != nil && a.ctx.Err() == nil {
+			a.fail(errors.Wrap(err, name))
+			return
+		}
+
+		a.Logr.Default().Debug("component stopped", zap.String("component", name))
+	}()
+}
+
+// OnStopTraffic registers a hook of the stop traffic step, run before the servers stop: it ends the
+// long-lived requests, e.g. streams, which would hold the graceful stop. The hooks run in the reverse order.
+func (a *App) OnStopTraffic(name string, fn func(ctx context.Context) error) {
+	a.mu.Lock()
+	a.traffic = append(a.traffic, hook{name: name, fn: fn})
+	a.mu.Unlock()
+}
+
+// OnDrain registers a hook of the drain step, after the servers stopped: background work not run
+// with Go, e.g. consumers, schedulers or subscriptions. The hooks run in the reverse order.
+func (a *App) OnDrain(name string, fn func(ctx context.Context) error) {
+	a.mu.Lock()
+	a.drain = append(a.drain, hook{name: name, fn: fn})
+	a.mu.Unlock()
+}
+
+// OnClose registers a hook of the close clients step: connections to other services, brokers, databases.
+// The hooks run in the reverse order.
+func (a *App) OnClose(name string, fn func(ctx context.Context) error) {
+	a.mu.Lock()
+	a.clients = append(a.clients, hook{name: name, fn: fn})
+	a.mu.Unlock()
+}
+
+// fail stops the service, the first failure is the one reported
+func (a *App) fail(err error) {
+	a.failOnce.Do(func() {
+		a.failed <- err
+	})
+}
+
+// Run serves until SIGINT, SIGTERM or a failure, then shuts the service down.
+// The error reports a failure to start or to run, or a shutdown step that failed or timed out.
+func (a *App) Run() error {
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var runErr error
+
+	if err := a.serve(); err != nil {
+		runErr = err
+	} else {
+		a.Logr.Default().Info("service running", zap.String("service", a.Config.Name))
+
+		select {
+		case <-ctx.Done():
+			a.Logr.Default().Info("shutdown requested", zap.String("service", a.Config.Name))
+		case runErr = <-a.failed:
+		}
+	}
+
+	if runErr != nil {
+		a.Logr.Default().Error("service failed", zap.String("service", a.Config.Name), zap.String("err", runErr.Error()))
+	}
+
+	// a second signal does not wait for the graceful shutdown
+	stop()
+
+	shutdownErr := a.Shutdown()
+
+	if runErr != nil {
+		return runErr
+	}
+
+	return shutdownErr
+}
+
+// serve starts listening, a listener that cannot be opened fails the start
+func (a *App) serve() error {
+
+	if a.GRPC != nil {
+		lis, err := net.Listen("tcp", a.Config.GRPC.Addr)
+		if err != nil {
+			return errors.Wrap(err, "grpc listen")
+		}
+
+		// after the services of the app, so the health service knows them
+		health.RegisterGRPC(a.GRPC, a.Health)
+		reflection.Register(a.GRPC)
+
+		a.serveLoop("grpc server", a.Config.GRPC.Addr, func() error { return a.GRPC.Serve(lis) })
+	}
+
+	for name, serv := range map[string]*http.Server{"http server": a.httpServer, "admin server": a.adminServer} {
+		if serv == nil {
+			continue
+		}
+
+		lis, err := net.Listen("tcp", serv.Addr)
+		if err != nil {
+			return errors.Wrap(err, name+" listen")
+		}
+
+		serv := serv
+		a.serveLoop(name, serv.Addr, func() error { return serv.Serve(lis) })
+	}
+
+	return nil
+}
+
+func (a *App) serveLoop(name, addr string, serve func() error) {
+
+	a.Logr.Default().Info(name+" listening", zap.String("addr", addr))
+
+	go func() {
+		if err := serve(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			a.fail(errors.Wrap(err, name))
+		}
+	}()
+}
+
+// Shutdown runs the steps of the graceful shutdown, each within its timeout:
+//
+//	stop traffic   readiness fails, the stop traffic hooks run, the servers stop accepting requests
+//	               and finish the ones in flight
+//	drain          the components are cancelled and awaited, the drain hooks run
+//	close clients  the close hooks run and the admin listener stops
+//	flush tracer   the spans left in the reporter are sent
+//
+// A step running late does not hold the next ones, it makes Shutdown fail.
+// Run calls it, call it only for an App that is not run.
+func (a *App) Shutdown() error {
+
+	start := time.Now()
+	var failed []string
+
+	step := func(name string, timeout time.Duration, fn func(ctx context.Context) []error) {
+
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+
+		stepStart := time.Now()
+
+		done := make(chan []error, 1)
+		go func() {
+			done <- fn(ctx)
+		}()
+
+		var errs []error
+		select {
+		case errs = <-done:
+		case <-ctx.Done():
+			errs = []error{errors.Errorf("timed out after %s", timeout)}
+		}
+
+		for _, err := range errs {
+			failed = append(failed, name+": "+err.Error())
+			a.Logr.Default().Error("shutdown step", zap.String("step", name), zap.String("err", err.Error()))
+		}
+
+		a.Logr.Default().Info("shutdown step done", zap.String("step", name), zap.Duration("duration", time.Since(stepStart)))
+	}
+
+	step("stop traffic", a.Config.Shutdown.Delay+a.Config.Shutdown.Traffic, a.stopTraffic)
+	step("drain", a.Config.Shutdown.Drain, a.drainWork)
+	step("close clients", a.Config.Shutdown.Clients, a.closeClients)
+	step("flush tracer", a.Config.Shutdown.Tracer, func(ctx context.Context) []error {
+		a.closeTracer()
+		return nil
+	})
+
+	a.Logr.Default().Info("graceful shutdown", zap.Duration("duration", time.Since(start)), zap.Int("failures", len(failed)))
+	a.Logr.Sync()
+
+	if len(failed) > 0 {
+		return errors.Errorf("shutdown: %v", failed)
+	}
+
+	return nil
+}
+
+func (a *App) stopTraffic(ctx context.Context) []error {
+
+	a.Health.Shutdown()
+
+	if d := a.Config.Shutdown.Delay; d > 0 {
+		select {
+		case <-time.After(d):
+		case <-ctx.Done():
+		}
+	}
+
+	a.mu.Lock()
+	hooks := a.traffic
+	a.mu.Unlock()
+
+	hookErrs := runHooks(ctx, hooks)
+
+	errs := make(chan error, 2)
+
+	go func() {
+		if a.httpServer == nil {
+			errs <- nil
+			return
+		}
+		if err := a.httpServer.Shutdown(ctx); err != nil {
+			_ = a.httpServer.Close()
+			errs <- errors.Wrap(err, "http server")
+			return
+		}
+		errs <- nil
+	}()
+
+	go func() {
+		if a.GRPC == nil {
+			errs <- nil
+			return
+		}
+
+		stopped := make(chan struct{})
+		go func() {
+			a.GRPC.GracefulStop()
+			close(stopped)
+		}()
+
+		select {
+		case <-stopped:
+			errs <- nil
+		case <-ctx.Done():
+			a.GRPC.Stop()
+			errs <- errors.Wrap(ctx.Err(), "grpc server")
+		}
+	}()
+
+	return append(hookErrs, collect(errs, 2)...)
+}
+
+func (a *App) drainWork(ctx context.Context) []error {
+
+	a.cancel()
+
+	a.mu.Lock()
+	hooks := a.drain
+	a.mu.Unlock()
+
+	errs := runHooks(ctx, hooks)
+
+	stopped := make(chan struct{})
+	go func() {
+		a.components.Wait()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		errs = append(errs, errors.Wrap(ctx.Err(), "components"))
+	}
+
+	return errs
+}
+
+func (a *App) closeClients(ctx context.Context) []error {
+
+	a.mu.Lock()
+	hooks := a.clients
+	a.mu.Unlock()
+
+	errs := runHooks(ctx, hooks)
+
+	if a.adminServer != nil {
+		if err := a.adminServer.Shutdown(ctx); err != nil {
+			_ = a.adminServer.Close()
+			errs = append(errs, errors.Wrap(err, "admin server"))
+		}
+	}
+
+	return errs
+}
+
+// runHooks runs the hooks in the reverse order
+func runHooks(ctx context.Context, hooks []hook) []error {
+
+	var errs []error
+
+	for i := len(hooks) - 1; i >= 0; i-- {
+		if err := hooks[i].fn(ctx); err != nil {
+			errs = append(errs, errors.Wrap(err, hooks[i].name))
+		}
+	}
+
+	return errs
+}
+
+func collect(errs chan error, n int) []error {
+
+	var out []error
+
+	for i := 0; i < n; i++ {
+		if err := <-errs; err != nil {
+			out = append(out, err)
+		}
+	}
+
+	return out
+}
+
+// Main builds the service, lets setup register its routes, services and components, runs it
+// and exits: 1 when it failed to start, to run or to shut down, 0 otherwise
+func Main(cfg Config, setup func(a *App) error, opts ...Option) {
+
+	a, err := New(cfg, opts...)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	if err := setup(a); err != nil {
+		a.Logr.Default().Error("service setup", zap.String("service", cfg.Name), zap.String("err", err.Error()))
+		_ = a.Shutdown()
+		os.Exit(1)
+	}
+
+	if err := a.Run(); err != nil {
+		os.Exit(1)
+	}
+
+	os.Exit(0)
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/alloykh/tracer-demo/log"
+	"github.com/alloykh/tracer-demo/tracing/baggage"
+	"github.com/alloykh/tracer-demo/tracing/sampling"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Sampling strategy server - serves the jaeger /sampling API from a strategies file,
+// and the /baggageRestrictions API from a restrictions file when one is given.
+// Point the services to it with tracing.WithRemoteSampler("http://localhost:5778/sampling", ...)
+// and tracing.WithBaggageRestrictions("localhost:5778", ...).
+
+func main() {
+
+	addr := flag.String("addr", ":5778", "listen address")
+	path := flag.String("strategies", "strategies.json", "strategies file (.json, .yaml or .yml)")
+	baggagePath := flag.String("baggage", "", "baggage restrictions file (.json, .yaml or .yml), none by default")
+	reload := flag.Duration("reload", time.Second*5, "how often the strategies file is checked for changes")
+	flag.Parse()
+
+	logr := log.NewFactory("zap", zapcore.DebugLevel)
+
+	store, err := sampling.NewStore(*path, logr)
+	if err != nil {
+		logr.Default().Fatal("sampling strategies load", zap.String("path", *path), zap.String("err", err.Error()))
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	opts := []sampling.ServerOption{sampling.WithReloadInterval(*reload)}
+
+	if *baggagePath != "" {
+		restrictions, err := baggage.NewStore(*baggagePath, logr)
+		if err != nil {
+			logr.Default().Fatal("baggage restrictions load", zap.String("path", *baggagePath), zap.String("err", err.Error()))
+		}
+		go restrictions.Watch(ctx, *reload)
+		opts = append(opts, sampling.WithHandler(baggage.Path, baggage.Handler(restrictions, logr)))
+	}
+
+	serv := sampling.NewServer(*addr, store, logr, opts...)
+
+	if err = serv.Run(); err != nil {
+		logr.Default().Fatal("sampling server run", zap.String("err", err.Error()))
+	}
+
+	<-ctx.Done()
+
+	ctxShutDown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err = serv.Shutdown(ctxShutDown); err != nil {
+		logr.Default().Error("sampling server shutdown", zap.String("err", err.Error()))
+	}
+
+	logr.Default().Info("graceful shutdown")
+}
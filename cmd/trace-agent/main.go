@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/alloykh/tracer-demo/log"
+	"github.com/alloykh/tracer-demo/tracing/agent"
+	"github.com/alloykh/tracer-demo/tracing/depgraph"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Local stand-in for jaeger-agent - run it instead of the jaegertracing/all-in-one container.
+// The demo services report to localhost:6831 by default, traces can then be listed with
+//
+//	curl localhost:16686/api/traces?service=frontend
+//	curl localhost:16686/debug/dependencies?format=mermaid
+
+func main() {
+
+	udpAddr := flag.String("udp", ":6831", "UDP address receiving thrift compact batches")
+	httpAddr := flag.String("http", ":16686", "HTTP address of the query API and the dependency graph, empty to disable")
+	file := flag.String("file", "", "append the received spans to this JSON-lines file")
+	memory := flag.Bool("memory", true, "keep the received spans in memory (required by the query API)")
+	maxTraces := flag.Int("max-traces", 10000, "traces kept in memory")
+	deps := flag.Bool("deps", true, "build the service dependency graph served on "+depgraph.Path)
+	flag.Parse()
+
+	logr := log.NewFactory("zap", zapcore.DebugLevel)
+
+	var sinks []agent.Sink
+
+	store := agent.NewStore(*maxTraces)
+	if *memory {
+		sinks = append(sinks, store)
+	}
+
+	if *file != "" {
+		fileSink, err := agent.NewFileSink(*file)
+		if err != nil {
+			logr.Default().Fatal("span file", zap.String("err", err.Error()))
+		}
+		defer func() {
+			if err := fileSink.Close(); err != nil {
+				logr.Default().Error("span file close", zap.String("err", err.Error()))
+			}
+		}()
+		sinks = append(sinks, fileSink)
+	}
+
+	var collector *depgraph.Collector
+	if *deps {
+		collector = depgraph.NewCollector()
+		sinks = append(sinks, collector)
+	}
+
+	a := agent.New(*udpAddr, logr, sinks...)
+
+	if err := a.Run(); err != nil {
+		logr.Default().Fatal("agent run", zap.String("err", err.Error()))
+	}
+
+	var serv *http.Server
+
+	if *httpAddr != "" && (*memory || collector != nil) {
+		mux := http.NewServeMux()
+		if *memory {
+			mux.Handle("/api/", agent.Handler(store))
+		}
+		if collector != nil {
+			depgraph.Mount(mux, collector)
+		}
+
+		serv = &http.Server{
+			Addr:         *httpAddr,
+			Handler:      mux,
+			ReadTimeout:  time.Second * 5,
+			WriteTimeout: time.Second * 10,
+		}
+		go func() {
+			if err := serv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				logr.Default().Error("http listen and serve", zap.String("err", err.Error()))
+			}
+		}()
+		logr.Default().Debug("QUERY API RUNNING...", zap.String("ADDR", *httpAddr))
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	<-ctx.Done()
+
+	if serv != nil {
+		ctxShutDown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := serv.Shutdown(ctxShutDown); err != nil {
+			logr.Default().Error("http server shutdown", zap.String("err", err.Error()))
+		}
+	}
+
+	if err := a.Close(); err != nil {
+		logr.Default().Error("agent close", zap.String("err", err.Error()))
+	}
+
+	logr.Default().Info("graceful shutdown")
+}
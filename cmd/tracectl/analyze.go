@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/alloykh/tracer-demo/tracing/agent"
+	"github.com/alloykh/tracer-demo/tracing/analysis"
+)
+
+func analyzeCmd(args []string) error {
+
+	fs := flag.NewFlagSet("analyze", flag.ExitOnError)
+	queryURL := fs.String("query", "", "jaeger query API, files given as arguments are analyzed when empty")
+	inFormat := fs.String("in", "auto", "input format of files: auto, jaeger, jsonl or chrome")
+	timeout := fs.Duration("timeout", time.Second*10, "request timeout")
+	lookback := fs.Duration("lookback", time.Hour, "search window of the query API")
+	aggregate := fs.Bool("aggregate", false, "print one latency attribution report per endpoint instead of one per trace")
+	asJSON := fs.Bool("json", false, "print the reports as JSON")
+	top := fs.Int("top", 5, "rows per table")
+
+	var opts analysis.Options
+	fs.BoolVar(&opts.AdjustSkew, "adjust-skew", true, "shift spans of remote services into their callers before the analysis")
+	fs.DurationVar(&opts.MinGap, "min-gap", time.Millisecond, "ignore idle gaps shorter than this")
+
+	var q agent.Query
+	tags := tagFlag{}
+	fs.StringVar(&q.Service, "service", "", "service name")
+	fs.StringVar(&q.Operation, "operation", "", "operation name")
+	fs.Var(tags, "tag", "key=value, can be repeated")
+	fs.IntVar(&q.Limit, "limit", 100, "maximum number of traces")
+	_ = fs.Parse(args)
+
+	q.Tags = tags
+
+	traces, err := findTraces(*queryURL, q, *lookback, *timeout, fs.Args(), *inFormat)
+	if err != nil {
+		return err
+	}
+
+	reports := make([]*analysis.Report, 0, len(traces))
+	for _, t := range traces {
+		reports = append(reports, analysis.Analyze(t, opts))
+	}
+
+	if *aggregate {
+		endpoints := analysis.Aggregate(reports)
+		if *asJSON {
+			return printJSON(endpoints)
+		}
+		for _, e := range endpoints {
+			printEndpoint(os.Stdout, e, *top)
+		}
+		return nil
+	}
+
+	if *asJSON {
+		return printJSON(reports)
+	}
+	for _, r := range reports {
+		printReport(os.Stdout, r, *top)
+	}
+
+	return nil
+}
+
+func printJSON(v interface{}) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func printReport(w io.Writer, r *analysis.Report, top int) {
+
+	fmt.Fprintf(w, "Trace %s  %s  %s  max concurrency %d  idle %s\n",
+		r.TraceID, r.Endpoint, fmtDur(r.Duration), r.MaxConcurrency, fmtDur(r.IdleTime))
+
+	for _, a := range r.Skew {
+		fmt.Fprintf(w, "  clock skew: %s span %s shifted by %s into %s\n", a.Service, a.SpanID, fmtDur(a.Delta), a.Parent)
+	}
+
+	fmt.Fprintln(w, "  critical path:")
+	for _, s := range r.CriticalPath {
+		fmt.Fprintf(w, "    %10s  %s: %s\n", fmtDur(s.Duration()), s.Service, s.Operation)
+	}
+
+	fmt.Fprintf(w, "  %-50s %10s %10s %6s %6s\n", "service", "critical", "self", "spans", "errors")
+	for i, s := range r.Services {
+		if i == top {
+			break
+		}
+		fmt.Fprintf(w, "  %-50s %10s %10s %6d %6d\n", s.Name, fmtDur(s.CriticalTime), fmtDur(s.SelfTime), s.Spans, s.Errors)
+	}
+
+	fmt.Fprintf(w, "  %-50s %10s %10s %6s %6s\n", "operation", "critical", "self", "spans", "errors")
+	for i, s := range r.Operations {
+		if i == top {
+			break
+		}
+		fmt.Fprintf(w, "  %-50s %10s %10s %6d %6d\n", s.Name, fmtDur(s.CriticalTime), fmtDur(s.SelfTime), s.Spans, s.Errors)
+	}
+
+	for i, g := range r.Gaps {
+		if i == top {
+			break
+		}
+		fmt.Fprintf(w, "  idle gap: %s at +%s in %s: %s\n", fmtDur(g.Duration), fmtDur(g.Offset), g.Service, g.Operation)
+	}
+
+	for _, rt := range r.Retries {
+		fmt.Fprintf(w, "  retry: %s: %s %d attempts, %d failed, overhead %s\n",
+			rt.Service, rt.Operation, rt.Attempts, rt.Failed, fmtDur(rt.Overhead))
+	}
+
+	fmt.Fprintln(w)
+}
+
+func printEndpoint(w io.Writer, e analysis.EndpointReport, top int) {
+
+	fmt.Fprintf(w, "%s  %d traces  %d with errors  p50 %s  p95 %s  max %s\n",
+		e.Endpoint, e.Traces, e.Errors, fmtDur(e.P50), fmtDur(e.P95), fmtDur(e.Max))
+	fmt.Fprintf(w, "  mean idle %s  mean retry overhead %s\n", fmtDur(e.MeanIdle), fmtDur(e.MeanRetryOverhead))
+
+	for _, table := range []struct {
+		title string
+		rows  []analysis.Attribution
+	}{{"service", e.Services}, {"operation", e.Operations}} {
+
+		fmt.Fprintf(w, "  %-50s %10s %10s %7s\n", table.title, "critical", "self", "share")
+		for i, a := range table.rows {
+			if i == top {
+				break
+			}
+			fmt.Fprintf(w, "  %-50s %10s %10s %6.1f%%\n", a.Name, fmtDur(a.MeanCriticalTime), fmtDur(a.MeanSelfTime), a.Percent)
+		}
+	}
+
+	fmt.Fprintln(w)
+}
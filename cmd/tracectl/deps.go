@@ -0,0 +1,40 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"os"
+	"time"
+
+	"github.com/alloykh/tracer-demo/tracing/agent"
+	"github.com/alloykh/tracer-demo/tracing/depgraph"
+)
+
+func depsCmd(args []string) (err error) {
+
+	fs := flag.NewFlagSet("deps", flag.ExitOnError)
+	queryURL := fs.String("query", "", "jaeger query API, files given as arguments are read when empty")
+	inFormat := fs.String("in", "auto", "input format of files: auto, jaeger, jsonl or chrome")
+	timeout := fs.Duration("timeout", time.Second*10, "request timeout")
+	lookback := fs.Duration("lookback", time.Hour, "search window of the query API")
+	format := fs.String("format", depgraph.FormatMermaid, "output: mermaid, dot or json")
+
+	var q agent.Query
+	fs.StringVar(&q.Service, "service", "", "service name, required by the query API")
+	fs.IntVar(&q.Limit, "limit", 1000, "maximum number of traces")
+	_ = fs.Parse(args)
+
+	traces, err := findTraces(*queryURL, q, *lookback, *timeout, fs.Args(), *inFormat)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(os.Stdout)
+	defer func() {
+		if ferr := w.Flush(); err == nil {
+			err = ferr
+		}
+	}()
+
+	return depgraph.Write(w, *format, depgraph.Build(traces).Snapshot())
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/alloykh/tracer-demo/tracing/analysis"
+)
+
+// diffCmd compares traces captured against two builds, it fails when a threshold is exceeded:
+//
+//	tracectl diff -base main.jsonl -head branch.jsonl -max-p95-increase 0.25 -fail-on added,n+1
+func diffCmd(args []string) error {
+
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	var baseFiles, headFiles listFlag
+	fs.Var(&baseFiles, "base", "baseline trace files (glob), can be repeated")
+	fs.Var(&headFiles, "head", "trace files (glob) of the build under test, can be repeated")
+	inFormat := fs.String("in", "auto", "input format: auto, jaeger, jsonl or chrome")
+	nPlusOne := fs.Int("n-plus-one", 3, "calls of an operation by one parent span reported as N+1")
+	asJSON := fs.Bool("json", false, "print the diff as JSON")
+	all := fs.Bool("all", false, "print unchanged calls too")
+
+	var th analysis.Thresholds
+	fs.Float64Var(&th.MaxP95Increase, "max-p95-increase", 0, "tolerated relative p95 increase per call, 0.2 is +20%, 0 disables")
+	fs.DurationVar(&th.MinLatencyDelta, "min-delta", time.Millisecond, "ignore latency changes below this")
+	fs.Float64Var(&th.MaxCallsIncrease, "max-calls-increase", 0, "tolerated relative increase of calls per trace, 0 disables")
+	fs.IntVar(&th.MinTraces, "min-traces", 5, "skip latency checks of calls seen in fewer traces")
+	failOn := fs.String("fail-on", "", "structural changes failing the diff: added, removed, n+1 (comma separated)")
+	_ = fs.Parse(args)
+
+	if len(baseFiles) == 0 || len(headFiles) == 0 {
+		return fmt.Errorf("diff: -base and -head are required")
+	}
+
+	for _, f := range strings.Split(*failOn, ",") {
+		switch strings.TrimSpace(f) {
+		case "":
+		case "added":
+			th.FailOnAdded = true
+		case "removed":
+			th.FailOnRemoved = true
+		case "n+1":
+			th.FailOnNPlusOne = true
+		default:
+			return fmt.Errorf("diff: unknown -fail-on %q", f)
+		}
+	}
+
+	base, err := readTraces(baseFiles, *inFormat)
+	if err != nil {
+		return err
+	}
+	head, err := readTraces(headFiles, *inFormat)
+	if err != nil {
+		return err
+	}
+
+	d := analysis.Compare(base, head, analysis.DiffOptions{NPlusOne: *nPlusOne})
+	violations := d.Check(th)
+
+	if *asJSON {
+		if err = printJSON(struct {
+			*analysis.Diff
+			Violations []analysis.Violation `json:"violations"`
+		}{d, violations}); err != nil {
+			return err
+		}
+	} else {
+		printDiff(os.Stdout, d, *all)
+		for _, v := range violations {
+			fmt.Printf("FAIL %s\n", v)
+		}
+	}
+
+	if len(violations) > 0 {
+		return fmt.Errorf("diff: %d regressions", len(violations))
+	}
+
+	return nil
+}
+
+// printDiff prints the canonical trees with a marker per call:
+// '+' added, '-' removed, '~' calls per trace changed, 'N' new N+1
+func printDiff(w io.Writer, d *analysis.Diff, all bool) {
+
+	for _, e := range d.Endpoints {
+
+		fmt.Fprintf(w, "%s  base %d traces  head %d traces\n", e.Endpoint, e.BaseTraces, e.HeadTraces)
+		if e.BaseTraces == 0 || e.HeadTraces == 0 {
+			fmt.Fprintln(w)
+			continue
+		}
+
+		fmt.Fprintf(w, "  %-60s %13s %21s %21s\n", "call", "calls/trace", "p50", "p95")
+
+		for _, n := range e.Nodes {
+
+			marker := " "
+			switch {
+			case n.Added:
+				marker = "+"
+			case n.Removed:
+				marker = "-"
+			case n.NPlusOne:
+				marker = "N"
+			case n.CallsChanged():
+				marker = "~"
+			}
+
+			if !all && marker == " " && n.P95Delta == 0 && n.P50Delta == 0 {
+				continue
+			}
+
+			label := strings.Repeat("  ", n.Depth) + n.Service + ": " + n.Operation
+			if len(label) > 60 {
+				label = label[:59] + "~"
+			}
+
+			fmt.Fprintf(w, "%s %-60s %13s %21s %21s\n", marker, label,
+				callsCell(n.Base, n.Head),
+				durationCell(n.Base, n.Head, func(s *analysis.NodeStats) time.Duration { return s.P50 }),
+				durationCell(n.Base, n.Head, func(s *analysis.NodeStats) time.Duration { return s.P95 }))
+		}
+
+		fmt.Fprintln(w)
+	}
+}
+
+func callsCell(base, head *analysis.NodeStats) string {
+	return side(base, func(s *analysis.NodeStats) string { return fmt.Sprintf("%.1f", s.CallsPerTrace) }) + " -> " +
+		side(head, func(s *analysis.NodeStats) string { return fmt.Sprintf("%.1f", s.CallsPerTrace) })
+}
+
+func durationCell(base, head *analysis.NodeStats, get func(*analysis.NodeStats) time.Duration) string {
+	return side(base, func(s *analysis.NodeStats) string { return fmtDur(get(s)) }) + " -> " +
+		side(head, func(s *analysis.NodeStats) string { return fmtDur(get(s)) })
+}
+
+func side(s *analysis.NodeStats, format func(*analysis.NodeStats) string) string {
+	if s == nil {
+		return "-"
+	}
+	return format(s)
+}
+
+// listFlag collects repeated file flags, quoted glob patterns are expanded
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *listFlag) Set(s string) error {
+
+	matches, err := filepath.Glob(s)
+	if err != nil {
+		return err
+	}
+	if len(matches) == 0 {
+		// not a pattern or nothing matched, reading the file reports the error
+		matches = []string{s}
+	}
+
+	*l = append(*l, matches...)
+	return nil
+}
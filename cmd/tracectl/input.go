@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/alloykh/tracer-demo/tracing/model"
+	"github.com/pkg/errors"
+)
+
+// file formats understood by tracectl
+const (
+	formatJaeger = "jaeger" // jaeger query API / UI download
+	formatJSONL  = "jsonl"  // one span per line with its process, written by our exporters
+	formatChrome = "chrome" // Chrome trace-event format, open it in ui.perfetto.dev
+)
+
+// readTraces loads traces from files ("-" is stdin), format "auto" detects it from the content
+func readTraces(paths []string, format string) ([]*model.Trace, error) {
+
+	if len(paths) == 0 {
+		paths = []string{"-"}
+	}
+
+	spans := make([]*model.Span, 0)
+
+	for _, path := range paths {
+		data, err := readFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		f := format
+		if f == "" || f == "auto" {
+			f = detectFormat(data)
+		}
+
+		s, err := decode(data, f)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s", path)
+		}
+
+		spans = append(spans, s...)
+	}
+
+	return model.GroupTraces(spans), nil
+}
+
+func readFile(path string) ([]byte, error) {
+	if path == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(path)
+}
+
+func decode(data []byte, format string) ([]*model.Span, error) {
+	switch format {
+	case formatJaeger:
+		traces, err := model.ReadJaegerJSON(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		return model.Flatten(traces), nil
+	case formatJSONL:
+		return model.ReadJSONLines(bytes.NewReader(data))
+	case formatChrome:
+		return model.ReadChrome(bytes.NewReader(data))
+	}
+	return nil, fmt.Errorf("unknown format %q", format)
+}
+
+// detectFormat looks at the keys of the first JSON value
+func detectFormat(data []byte) string {
+
+	data = bytes.TrimSpace(data)
+
+	if bytes.HasPrefix(data, []byte("[")) {
+		return formatJaeger
+	}
+
+	// a complete object on the first line is either a JSON-lines span or a compact document
+	line, _, _ := bufio.NewReader(bytes.NewReader(data)).ReadLine()
+
+	var keys map[string]json.RawMessage
+	if json.Unmarshal(line, &keys) == nil {
+		switch {
+		case keys["traceEvents"] != nil:
+			return formatChrome
+		case keys["spanID"] != nil:
+			return formatJSONL
+		}
+		return formatJaeger
+	}
+
+	if bytes.Contains(data, []byte(`"traceEvents"`)) {
+		return formatChrome
+	}
+
+	return formatJaeger
+}
+
+// writeTraces encodes traces to path ("-" is stdout)
+func writeTraces(path, format string, traces []*model.Trace) (err error) {
+
+	var w io.Writer = os.Stdout
+
+	if path != "" && path != "-" {
+		f, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+		}()
+		w = f
+	}
+
+	bw := bufio.NewWriter(w)
+	defer func() {
+		if ferr := bw.Flush(); err == nil {
+			err = ferr
+		}
+	}()
+
+	switch strings.ToLower(format) {
+	case formatJaeger:
+		return model.WriteJaegerJSON(bw, traces)
+	case formatJSONL:
+		return model.WriteJSONLines(bw, model.Flatten(traces))
+	case formatChrome:
+		return model.WriteChrome(bw, traces)
+	}
+
+	return fmt.Errorf("unknown format %q", format)
+}
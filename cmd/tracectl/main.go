@@ -0,0 +1,283 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/alloykh/tracer-demo/tracing/agent"
+	"github.com/alloykh/tracer-demo/tracing/model"
+	"github.com/alloykh/tracer-demo/tracing/query"
+)
+
+// tracectl - terminal trace inspection
+//
+//	tracectl get     -query http://localhost:16686 <trace-id>...
+//	tracectl search  -query http://localhost:16686 -service frontend -min-duration 100ms
+//	tracectl search  -tag http.status_code=500 traces.json
+//	tracectl show    -logs traces.json
+//	tracectl convert -to chrome -out trace.perfetto.json spans.jsonl
+//	tracectl analyze -aggregate -query http://localhost:16686 -service frontend -limit 200
+//	tracectl diff    -base 'main/*.jsonl' -head 'branch/*.jsonl' -max-p95-increase 0.2 -fail-on n+1
+//	tracectl deps    -format dot spans.jsonl | dot -Tsvg > deps.svg
+//
+// Files can be jaeger JSON (query API, UI download), JSON lines (our exporters) or Chrome trace events.
+
+const defaultQueryURL = "http://localhost:16686"
+
+var commands = map[string]func(args []string) error{
+	"get":     getCmd,
+	"search":  searchCmd,
+	"show":    showCmd,
+	"convert": convertCmd,
+	"analyze": analyzeCmd,
+	"diff":    diffCmd,
+	"deps":    depsCmd,
+}
+
+func main() {
+
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		usage()
+		os.Exit(2)
+	}
+
+	if err := cmd(os.Args[2:]); err != nil {
+		fmt.Fprintln(os.Stderr, "tracectl:", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, `usage: tracectl <command> [flags] [args]
+
+commands:
+  get      fetch traces by id from a jaeger query API
+  search   find traces by service, operation, tags and duration (query API or files)
+  show     render traces of files as waterfalls
+  convert  convert between jaeger JSON, JSON lines and Chrome trace-event files
+  analyze  critical path and latency breakdown of traces, per trace or aggregated per endpoint
+  diff     compare the call trees and latencies of two sets of traces, fails on regressions
+  deps     service dependency graph with calls, errors and latency per edge (mermaid, dot or json)
+
+run "tracectl <command> -h" for the flags of a command`)
+}
+
+// outputFlags are shared by the commands printing traces
+type outputFlags struct {
+	format string
+	out    string
+	render renderOptions
+}
+
+func (o *outputFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&o.format, "format", "waterfall", "output: waterfall, jaeger, jsonl or chrome")
+	fs.StringVar(&o.out, "out", "-", "output file for the non-waterfall formats")
+	fs.IntVar(&o.render.width, "width", 60, "timeline width in characters")
+	fs.BoolVar(&o.render.showLogs, "logs", false, "print span logs")
+	fs.BoolVar(&o.render.showTags, "tags", false, "print span tags")
+}
+
+func (o *outputFlags) print(traces []*model.Trace) error {
+
+	if o.format != "waterfall" {
+		return writeTraces(o.out, o.format, traces)
+	}
+
+	for _, t := range traces {
+		renderWaterfall(os.Stdout, t, o.render)
+	}
+
+	return nil
+}
+
+func getCmd(args []string) error {
+
+	fs := flag.NewFlagSet("get", flag.ExitOnError)
+	queryURL := fs.String("query", defaultQueryURL, "jaeger query API")
+	timeout := fs.Duration("timeout", time.Second*10, "request timeout")
+	var out outputFlags
+	out.register(fs)
+	_ = fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		return fmt.Errorf("get: trace id required")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	client := query.NewClient(*queryURL)
+
+	traces := make([]*model.Trace, 0, fs.NArg())
+	for _, id := range fs.Args() {
+		t, err := client.Trace(ctx, id)
+		if err != nil {
+			return err
+		}
+		traces = append(traces, t)
+	}
+
+	return out.print(traces)
+}
+
+func searchCmd(args []string) error {
+
+	fs := flag.NewFlagSet("search", flag.ExitOnError)
+	queryURL := fs.String("query", "", "jaeger query API, files given as arguments are searched when empty")
+	inFormat := fs.String("in", "auto", "input format of files: auto, jaeger, jsonl or chrome")
+	timeout := fs.Duration("timeout", time.Second*10, "request timeout")
+	lookback := fs.Duration("lookback", time.Hour, "search window of the query API")
+	list := fs.Bool("list", false, "print one line per trace instead of waterfalls")
+
+	var q agent.Query
+	tags := tagFlag{}
+	fs.StringVar(&q.Service, "service", "", "service name")
+	fs.StringVar(&q.Operation, "operation", "", "operation name")
+	fs.Var(tags, "tag", "key=value, can be repeated")
+	fs.DurationVar(&q.MinDuration, "min-duration", 0, "minimum span duration")
+	fs.DurationVar(&q.MaxDuration, "max-duration", 0, "maximum span duration")
+	fs.IntVar(&q.Limit, "limit", 20, "maximum number of traces")
+
+	var out outputFlags
+	out.register(fs)
+	_ = fs.Parse(args)
+
+	q.Tags = tags
+
+	traces, err := findTraces(*queryURL, q, *lookback, *timeout, fs.Args(), *inFormat)
+	if err != nil {
+		return err
+	}
+
+	if *list {
+		for _, t := range traces {
+			printSummary(t)
+		}
+		return nil
+	}
+
+	return out.print(traces)
+}
+
+// findTraces searches the query API, or the files when queryURL is empty
+func findTraces(queryURL string, q agent.Query, lookback, timeout time.Duration, files []string, format string) ([]*model.Trace, error) {
+
+	if queryURL != "" {
+		if q.Service == "" {
+			return nil, fmt.Errorf("-service is required by the query API")
+		}
+		q.Start = time.Now().Add(-lookback)
+		q.End = time.Now()
+
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+
+		return query.NewClient(queryURL).Search(ctx, q)
+	}
+
+	all, err := readTraces(files, format)
+	if err != nil {
+		return nil, err
+	}
+
+	traces := make([]*model.Trace, 0)
+	for _, t := range all {
+		if q.Matches(t) {
+			traces = append(traces, t)
+		}
+		if q.Limit > 0 && len(traces) >= q.Limit {
+			break
+		}
+	}
+
+	return traces, nil
+}
+
+func showCmd(args []string) error {
+
+	fs := flag.NewFlagSet("show", flag.ExitOnError)
+	inFormat := fs.String("in", "auto", "input format: auto, jaeger, jsonl or chrome")
+	traceID := fs.String("trace", "", "only show this trace")
+	var out outputFlags
+	out.register(fs)
+	_ = fs.Parse(args)
+
+	traces, err := readTraces(fs.Args(), *inFormat)
+	if err != nil {
+		return err
+	}
+
+	if *traceID != "" {
+		filtered := traces[:0]
+		for _, t := range traces {
+			if strings.TrimLeft(t.TraceID, "0") == strings.TrimLeft(*traceID, "0") {
+				filtered = append(filtered, t)
+			}
+		}
+		traces = filtered
+	}
+
+	return out.print(traces)
+}
+
+func convertCmd(args []string) error {
+
+	fs := flag.NewFlagSet("convert", flag.ExitOnError)
+	from := fs.String("from", "auto", "input format: auto, jaeger, jsonl or chrome")
+	to := fs.String("to", formatJaeger, "output format: jaeger, jsonl or chrome")
+	out := fs.String("out", "-", "output file")
+	_ = fs.Parse(args)
+
+	traces, err := readTraces(fs.Args(), *from)
+	if err != nil {
+		return err
+	}
+
+	return writeTraces(*out, *to, traces)
+}
+
+func printSummary(t *model.Trace) {
+
+	root := t.Root()
+	if root == nil {
+		return
+	}
+
+	status := "ok"
+	if t.HasError() {
+		status = "error"
+	}
+
+	fmt.Printf("%s  %s  %-40s %10s %4d spans  %s\n", t.TraceID, root.Start().Format("15:04:05.000"),
+		root.ServiceName()+": "+root.OperationName, fmtDur(t.Elapsed()), len(t.Spans), status)
+}
+
+// tagFlag collects repeated -tag key=value flags
+type tagFlag map[string]string
+
+func (t tagFlag) String() string {
+	pairs := make([]string, 0, len(t))
+	for k, v := range t {
+		pairs = append(pairs, k+"="+v)
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (t tagFlag) Set(s string) error {
+	kv := strings.SplitN(s, "=", 2)
+	if len(kv) != 2 || kv[0] == "" {
+		return fmt.Errorf("tag must be key=value, got %q", s)
+	}
+	t[kv[0]] = kv[1]
+	return nil
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/alloykh/tracer-demo/tracing/agent"
+	"github.com/alloykh/tracer-demo/tracing/model"
+)
+
+func queryServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	start := time.Now().Add(-time.Minute).UnixNano() / int64(time.Microsecond)
+	frontend := &model.Process{ServiceName: "frontend"}
+	order := &model.Process{ServiceName: "order"}
+
+	store := agent.NewStore(0)
+	err := store.Add([]*model.Span{
+		{TraceID: "00000000000000a1", SpanID: "0000000000000001", OperationName: "HTTP GET /order", StartTime: start, Duration: 300000, Process: frontend},
+		{TraceID: "00000000000000a1", SpanID: "0000000000000002", OperationName: "Create", StartTime: start + 1000, Duration: 250000, Process: order,
+			References: []model.Reference{{RefType: "CHILD_OF", TraceID: "00000000000000a1", SpanID: "0000000000000001"}}},
+		{TraceID: "00000000000000b2", SpanID: "0000000000000003", OperationName: "HTTP GET /health", StartTime: start, Duration: 2000, Process: frontend},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(agent.Handler(store))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+// spanIDs reads a file written by tracectl and returns its sorted span ids
+func spanIDs(t *testing.T, path string) []string {
+	t.Helper()
+
+	traces, err := readTraces([]string{path}, "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ids []string
+	for _, span := range model.Flatten(traces) {
+		ids = append(ids, span.TraceID+"/"+span.SpanID+"/"+span.ServiceName())
+	}
+	sort.Strings(ids)
+
+	return ids
+}
+
+func TestQueryCommands(t *testing.T) {
+
+	srv := queryServer(t)
+	dir := t.TempDir()
+
+	all := []string{
+		"00000000000000a1/0000000000000001/frontend",
+		"00000000000000a1/0000000000000002/order",
+		"00000000000000b2/0000000000000003/frontend",
+	}
+
+	get := filepath.Join(dir, "get.jsonl")
+	if err := getCmd([]string{"-query", srv.URL, "-format", formatJSONL, "-out", get, "a1"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := spanIDs(t, get); !equal(got, all[:2]) {
+		t.Errorf("get: spans %v, want %v", got, all[:2])
+	}
+
+	search := filepath.Join(dir, "search.json")
+	if err := searchCmd([]string{"-query", srv.URL, "-service", "frontend", "-format", formatJaeger, "-out", search}); err != nil {
+		t.Fatal(err)
+	}
+	if got := spanIDs(t, search); !equal(got, all) {
+		t.Errorf("search: spans %v, want %v", got, all)
+	}
+
+	if err := searchCmd([]string{"-query", srv.URL}); err == nil {
+		t.Error("search of the query API without a service succeeded")
+	}
+
+	// jaeger -> chrome -> jsonl keeps the spans and their services
+	chrome := filepath.Join(dir, "trace.chrome.json")
+	if err := convertCmd([]string{"-to", formatChrome, "-out", chrome, search}); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(chrome); !bytes.Contains(data, []byte(`"traceEvents"`)) {
+		t.Errorf("convert: %s is not a Chrome trace", chrome)
+	}
+
+	jsonl := filepath.Join(dir, "spans.jsonl")
+	if err := convertCmd([]string{"-to", formatJSONL, "-out", jsonl, chrome}); err != nil {
+		t.Fatal(err)
+	}
+	if got := spanIDs(t, jsonl); !equal(got, all) {
+		t.Errorf("convert: spans %v, want %v", got, all)
+	}
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/alloykh/tracer-demo/tracing/analysis"
+	"github.com/alloykh/tracer-demo/tracing/model"
+)
+
+type renderOptions struct {
+	width    int  // characters of the timeline
+	showLogs bool // print span logs under the span
+	showTags bool // print span tags under the span
+}
+
+// renderWaterfall prints a trace as a tree with a timeline:
+//
+//	   span                              duration    self  |0--------12.10ms|
+//	*  frontend: HTTP GET /order          12.10ms   1.20ms |################|
+//	     frontend: SearchClient            3.00ms   3.00ms | ===            |
+//	*!   order_service: HTTP GET /order    8.00ms   8.00ms |     ########## |
+//
+// '*' and '#' mark the critical path, '!' marks spans tagged with error=true.
+func renderWaterfall(w io.Writer, t *model.Trace, opts renderOptions) {
+
+	start, end := t.Bounds()
+	total := end - start
+	if total <= 0 {
+		total = 1
+	}
+
+	critical := analysis.OnCriticalPath(analysis.CriticalPath(t))
+	self := analysis.SelfTimes(t)
+
+	type row struct {
+		span  *model.Span
+		depth int
+	}
+
+	rows := make([]row, 0, len(t.Spans))
+	children := t.Children()
+	visited := make(map[string]bool, len(t.Spans))
+
+	var walk func(s *model.Span, depth int)
+	walk = func(s *model.Span, depth int) {
+		if visited[s.SpanID] {
+			return
+		}
+		visited[s.SpanID] = true
+		rows = append(rows, row{span: s, depth: depth})
+		for _, c := range children[s.SpanID] {
+			walk(c, depth+1)
+		}
+	}
+
+	if root := t.Root(); root != nil {
+		walk(root, 0)
+	}
+	for _, s := range t.Spans {
+		walk(s, 0)
+	}
+
+	nameWidth := 0
+	for _, r := range rows {
+		if n := len(spanLabel(r.span)) + 2*r.depth; n > nameWidth {
+			nameWidth = n
+		}
+	}
+	if nameWidth > 70 {
+		nameWidth = 70
+	}
+
+	fmt.Fprintf(w, "Trace %s  %s  %d spans  services: %s\n", t.TraceID, fmtDur(time.Duration(total)*time.Microsecond), len(t.Spans), strings.Join(t.Services(), ", "))
+	for _, warning := range t.Warnings {
+		fmt.Fprintf(w, "warning: %s\n", warning)
+	}
+
+	fmt.Fprintf(w, "%-3s%-*s %10s %10s  |%s|\n", "", nameWidth, "span", "duration", "self", axis(opts.width, total))
+
+	for _, r := range rows {
+		s := r.span
+
+		marker := " "
+		if critical[s.SpanID] {
+			marker = "*"
+		}
+		errMark := " "
+		if s.HasError() {
+			errMark = "!"
+		}
+
+		label := strings.Repeat("  ", r.depth) + spanLabel(s)
+		if len(label) > nameWidth {
+			label = label[:nameWidth-1] + "~"
+		}
+
+		fmt.Fprintf(w, "%s%s %-*s %10s %10s  |%s|\n", marker, errMark, nameWidth, label,
+			fmtDur(s.Elapsed()), fmtDur(self[s.SpanID]), bar(s, start, total, opts.width, critical[s.SpanID]))
+
+		indent := strings.Repeat(" ", 3+2*r.depth+2)
+
+		if opts.showTags {
+			for _, kv := range s.Tags {
+				fmt.Fprintf(w, "%s%s=%s\n", indent, kv.Key, kv.String())
+			}
+		}
+
+		if opts.showLogs {
+			for _, l := range s.Logs {
+				fields := make([]string, 0, len(l.Fields))
+				for _, kv := range l.Fields {
+					fields = append(fields, kv.Key+"="+kv.String())
+				}
+				offset := time.Duration(l.Timestamp-start) * time.Microsecond
+				fmt.Fprintf(w, "%slog +%s %s\n", indent, fmtDur(offset), strings.Join(fields, " "))
+			}
+		}
+	}
+
+	fmt.Fprintln(w)
+}
+
+func spanLabel(s *model.Span) string {
+	return s.ServiceName() + ": " + s.OperationName
+}
+
+func bar(s *model.Span, start, total int64, width int, critical bool) string {
+
+	from := int(float64(s.StartTime-start) / float64(total) * float64(width))
+	to := int(float64(s.StartTime+s.Duration-start) / float64(total) * float64(width))
+
+	if to <= from {
+		to = from + 1
+	}
+	if to > width {
+		to = width
+	}
+	if from >= width {
+		from = width - 1
+	}
+
+	fill := "="
+	if critical {
+		fill = "#"
+	}
+
+	return strings.Repeat(" ", from) + strings.Repeat(fill, to-from) + strings.Repeat(" ", width-to)
+}
+
+func axis(width int, total int64) string {
+
+	end := fmtDur(time.Duration(total) * time.Microsecond)
+	if width < len(end)+2 {
+		return strings.Repeat("-", width)
+	}
+
+	return "0" + strings.Repeat("-", width-1-len(end)) + end
+}
+
+func fmtDur(d time.Duration) string {
+	switch {
+	case d >= time.Second:
+		return d.Round(time.Millisecond).String()
+	case d >= time.Millisecond:
+		return fmt.Sprintf("%.2fms", float64(d)/float64(time.Millisecond))
+	}
+	return d.String()
+}
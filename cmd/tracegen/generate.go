@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+const decoratePath = "github.com/alloykh/tracer-demo/tracing/decorate"
+
+var decoratorTemplate = template.Must(template.New("decorator").Parse(`// Code generated by tracegen -type {{.Name}}. DO NOT EDIT.
+
+package {{.Pkg}}
+
+import (
+{{- range .Imports}}
+	{{.}}
+{{- end}}
+)
+
+// {{.Type}} traces, logs and times the calls to a {{.Name}}
+type {{.Type}} struct {
+	next {{.Name}}
+	dec  *decorate.Decorator
+}
+
+// New{{.Exported}} decorates next, spans are named {{.Name}}.Method
+func New{{.Exported}}(next {{.Name}}, opts ...decorate.Option) {{.Name}} {
+	return &{{.Type}}{
+		next: next,
+		dec:  decorate.New("{{.Name}}", []string{ {{- .MethodNames -}} }, opts...),
+	}
+}
+{{range .Methods}}
+{{.}}
+{{end}}`))
+
+func generate(it *iface) ([]byte, error) {
+
+	imports := []string{fmt.Sprintf("%q", decoratePath)}
+	for name, p := range it.imports {
+		if p == decoratePath {
+			continue
+		}
+		if name == path.Base(p) {
+			imports = append(imports, fmt.Sprintf("%q", p))
+		} else {
+			imports = append(imports, fmt.Sprintf("%s %q", name, p))
+		}
+	}
+	sort.Strings(imports)
+
+	var names, methods []string
+	for _, m := range it.methods {
+		if m.traced {
+			names = append(names, fmt.Sprintf("%q", m.name))
+		}
+		methods = append(methods, generateMethod(it, m))
+	}
+
+	typ := "traced" + it.name
+
+	var buf bytes.Buffer
+	err := decoratorTemplate.Execute(&buf, map[string]interface{}{
+		"Name":        it.name,
+		"Pkg":         it.pkg,
+		"Type":        typ,
+		"Exported":    "Traced" + it.name,
+		"Imports":     imports,
+		"MethodNames": strings.Join(names, ", "),
+		"Methods":     methods,
+	})
+
+	return buf.Bytes(), err
+}
+
+func generateMethod(it *iface, m *method) string {
+
+	var params, args, results []string
+	for _, p := range m.params {
+		params = append(params, p.name+" "+p.typ)
+		if p.variadic {
+			args = append(args, p.name+"...")
+		} else {
+			args = append(args, p.name)
+		}
+	}
+	for i, r := range m.results {
+		results = append(results, fmt.Sprintf("r%d %s", i, r))
+	}
+
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "func (d *traced%s) %s(%s) ", it.name, m.name, strings.Join(params, ", "))
+	if len(results) > 0 {
+		fmt.Fprintf(&b, "(%s) ", strings.Join(results, ", "))
+	}
+	b.WriteString("{\n")
+
+	call := fmt.Sprintf("d.next.%s(%s)", m.name, strings.Join(args, ", "))
+
+	if !m.traced {
+		if len(m.results) > 0 {
+			fmt.Fprintf(&b, "\treturn %s\n}", call)
+		} else {
+			fmt.Fprintf(&b, "\t%s\n}", call)
+		}
+		return b.String()
+	}
+
+	ctx := m.params[0].name
+
+	fmt.Fprintf(&b, "\tcall, %s := d.dec.Start(%s, %q)\n", ctx, ctx, m.name)
+
+	for _, t := range m.tags {
+		if t.nilable != "" {
+			fmt.Fprintf(&b, "\tif %s != nil {\n\t\tcall.Tag(%q, %s)\n\t}\n", t.nilable, t.tag, t.expr)
+		} else {
+			fmt.Fprintf(&b, "\tcall.Tag(%q, %s)\n", t.tag, t.expr)
+		}
+	}
+
+	if m.err {
+		fmt.Fprintf(&b, "\tdefer func() { call.Finish(r%d) }()\n", len(m.results)-1)
+	} else {
+		b.WriteString("\tdefer call.Finish(nil)\n")
+	}
+
+	if len(m.results) > 0 {
+		fmt.Fprintf(&b, "\treturn %s\n}", call)
+	} else {
+		fmt.Fprintf(&b, "\t%s\n}", call)
+	}
+
+	return b.String()
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/format"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// tracegen - generates a decorator tracing, logging and timing the methods of an interface
+//
+//	//go:generate go run github.com/alloykh/tracer-demo/cmd/tracegen -type Store
+//
+// The decorator, traced<Type>, is written to <type>_traced.go next to the interface and built with
+// NewTraced<Type>(next, decorate options...). Every method taking a context.Context first gets a span
+// named Type.Method, the other methods are passed through. Arguments are tagged on the span:
+//
+//   - by a comment of the method: // trace: id=product.id quantity
+//     tags the id argument as product.id and the quantity argument as quantity
+//   - by struct tags: the fields of a struct argument tagged `trace:"order.id"` are tagged as order.id
+//
+// A returned error marks the span as failed with its gRPC code, see tracing/decorate.
+
+var (
+	typeName = flag.String("type", "", "interface to decorate, required")
+	dir      = flag.String("dir", ".", "directory of the package declaring the interface")
+	out      = flag.String("out", "", "output file, <type>_traced.go by default, a relative path is relative to -dir")
+)
+
+func main() {
+
+	flag.Parse()
+
+	if *typeName == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := run(); err != nil {
+		fmt.Fprintln(os.Stderr, "tracegen:", err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
+
+	output := outputPath(*dir, *typeName, *out)
+
+	src, err := render(*dir, *typeName, filepath.Base(output))
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(output, src, 0644)
+}
+
+// outputPath returns the path of the generated file, out is joined onto dir unless it is absolute
+func outputPath(dir, typeName, out string) string {
+
+	if out == "" {
+		out = strings.ToLower(typeName) + "_traced.go"
+	}
+
+	if filepath.IsAbs(out) {
+		return out
+	}
+
+	return filepath.Join(dir, out)
+}
+
+// render returns the formatted decorator of the interface typeName declared in dir, skipping the file output
+func render(dir, typeName, output string) ([]byte, error) {
+
+	iface, err := parseInterface(dir, typeName, output)
+	if err != nil {
+		return nil, err
+	}
+
+	src, err := generate(iface)
+	if err != nil {
+		return nil, err
+	}
+
+	formatted, err := format.Source(src)
+	if err != nil {
+		return nil, fmt.Errorf("format generated code: %v\n%s", err, src)
+	}
+
+	return formatted, nil
+}
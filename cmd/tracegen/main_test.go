@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files")
+
+func TestGenerateGolden(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		dir      string
+		typeName string
+		golden   string
+	}{
+		{name: "fixture", dir: "testdata/orders", typeName: "Service", golden: "testdata/orders/service_traced.go.golden"},
+		// the decorator of the demo must be regenerated with the generator
+		{name: "inventory", dir: "../../demo/inventory", typeName: "Store", golden: "../../demo/inventory/store_traced.go"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			got, err := render(tt.dir, tt.typeName, filepath.Base(outputPath(tt.dir, tt.typeName, "")))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if *update {
+				if err := os.WriteFile(tt.golden, got, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			want, err := os.ReadFile(tt.golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(got, want) {
+				t.Errorf("generated code differs from %s, run go test ./cmd/tracegen -update:\n%s", tt.golden, got)
+			}
+		})
+	}
+}
+
+func TestOutputPath(t *testing.T) {
+
+	tests := []struct {
+		dir, out string
+		want     string
+	}{
+		{dir: "demo/inventory", want: "demo/inventory/store_traced.go"},
+		{dir: "demo/inventory", out: "gen/store.go", want: "demo/inventory/gen/store.go"},
+		{dir: "demo/inventory", out: "/tmp/store.go", want: "/tmp/store.go"},
+	}
+
+	for _, tt := range tests {
+		if got := outputPath(tt.dir, "Store", tt.out); got != filepath.FromSlash(tt.want) {
+			t.Errorf("outputPath(%q, %q) = %q, want %q", tt.dir, tt.out, got, tt.want)
+		}
+	}
+}
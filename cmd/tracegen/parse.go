@@ -0,0 +1,334 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// commentPrefix starts the comment of a method listing the arguments to tag
+const commentPrefix = "trace:"
+
+// structTag is the struct tag naming the span tag of a field
+const structTag = "trace"
+
+type iface struct {
+	pkg     string
+	name    string
+	imports map[string]string // name -> path, of the imports used by the methods
+	methods []*method
+}
+
+type method struct {
+	name    string
+	params  []*param
+	results []string // types
+	traced  bool     // the first parameter is a context.Context
+	err     bool     // the last result is an error
+	tags    []argTag
+}
+
+type param struct {
+	name     string // in the decorator
+	orig     string // in the interface
+	typ      string
+	variadic bool
+}
+
+// argTag tags the span with expr, an argument or a field of an argument
+type argTag struct {
+	tag     string
+	expr    string
+	nilable string // argument to check against nil before reading a field of it
+}
+
+// parseInterface parses the package in dir, but for the file skip, and returns the interface name
+func parseInterface(dir, name, skip string) (*iface, error) {
+
+	fset := token.NewFileSet()
+
+	pkgs, err := parser.ParseDir(fset, dir, func(fi os.FileInfo) bool {
+		return fi.Name() != skip && !strings.HasSuffix(fi.Name(), "_test.go")
+	}, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, pkg := range pkgs {
+
+		structs := packageStructs(pkg)
+
+		for _, file := range pkg.Files {
+
+			spec := findType(file, name)
+			if spec == nil {
+				continue
+			}
+
+			it, ok := spec.Type.(*ast.InterfaceType)
+			if !ok {
+				return nil, fmt.Errorf("%s is not an interface", name)
+			}
+
+			res := &iface{pkg: pkg.Name, name: name, imports: usedImports(it, fileImports(file))}
+
+			for _, field := range it.Methods.List {
+
+				ft, ok := field.Type.(*ast.FuncType)
+				if !ok || len(field.Names) == 0 {
+					return nil, fmt.Errorf("%s: embedded interfaces are not supported, list their methods", name)
+				}
+
+				m, err := parseMethod(field.Names[0].Name, ft, field.Doc, structs)
+				if err != nil {
+					return nil, fmt.Errorf("%s.%s: %v", name, field.Names[0].Name, err)
+				}
+
+				res.methods = append(res.methods, m)
+			}
+
+			return res, nil
+		}
+	}
+
+	return nil, fmt.Errorf("interface %s not found in %s", name, dir)
+}
+
+func findType(file *ast.File, name string) *ast.TypeSpec {
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			if ts := spec.(*ast.TypeSpec); ts.Name.Name == name {
+				return ts
+			}
+		}
+	}
+	return nil
+}
+
+func packageStructs(pkg *ast.Package) map[string]*ast.StructType {
+
+	structs := make(map[string]*ast.StructType)
+
+	for _, file := range pkg.Files {
+		for _, decl := range file.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				ts := spec.(*ast.TypeSpec)
+				if st, ok := ts.Type.(*ast.StructType); ok {
+					structs[ts.Name.Name] = st
+				}
+			}
+		}
+	}
+
+	return structs
+}
+
+// fileImports returns the imports of file by the name they are used with. The name of an import
+// without an explicit one is guessed from its path: the last element, without a go- prefix or a -go suffix.
+func fileImports(file *ast.File) map[string]string {
+
+	imports := make(map[string]string)
+
+	for _, spec := range file.Imports {
+
+		p, _ := strconv.Unquote(spec.Path.Value)
+
+		name := ""
+		if spec.Name != nil {
+			name = spec.Name.Name
+		} else {
+			name = path.Base(p)
+			name = strings.TrimPrefix(name, "go-")
+			name = strings.TrimSuffix(name, "-go")
+			name = strings.ReplaceAll(name, "-", "_")
+		}
+
+		imports[name] = p
+	}
+
+	return imports
+}
+
+// usedImports keeps the imports of the packages referred to by the methods of it
+func usedImports(it *ast.InterfaceType, imports map[string]string) map[string]string {
+
+	used := make(map[string]string)
+
+	ast.Inspect(it, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if id, ok := sel.X.(*ast.Ident); ok {
+				if p, ok := imports[id.Name]; ok {
+					used[id.Name] = p
+				}
+			}
+		}
+		return true
+	})
+
+	return used
+}
+
+func parseMethod(name string, ft *ast.FuncType, doc *ast.CommentGroup, structs map[string]*ast.StructType) (*method, error) {
+
+	m := &method{name: name}
+
+	for i, field := range ft.Params.List {
+
+		typ := types.ExprString(field.Type)
+		_, variadic := field.Type.(*ast.Ellipsis)
+
+		names := field.Names
+		if len(names) == 0 {
+			names = []*ast.Ident{{Name: "_"}}
+		}
+
+		for _, n := range names {
+			p := &param{name: n.Name, orig: n.Name, typ: typ, variadic: variadic}
+			if p.name == "_" {
+				p.name = fmt.Sprintf("a%d", len(m.params))
+			} else if reserved(p.name) {
+				p.name += "_"
+			}
+			m.params = append(m.params, p)
+		}
+
+		if i == 0 && typ == "context.Context" {
+			m.traced = true
+		}
+	}
+
+	if ft.Results != nil {
+		for _, field := range ft.Results.List {
+			n := len(field.Names)
+			if n == 0 {
+				n = 1
+			}
+			for i := 0; i < n; i++ {
+				m.results = append(m.results, types.ExprString(field.Type))
+			}
+		}
+	}
+
+	m.err = len(m.results) > 0 && m.results[len(m.results)-1] == "error"
+
+	if !m.traced {
+		return m, nil
+	}
+
+	tags, err := commentTags(doc, m.params)
+	if err != nil {
+		return nil, err
+	}
+	m.tags = append(tags, structTags(m.params, structs)...)
+
+	return m, nil
+}
+
+// commentTags reads the "trace: arg=tag arg" line of the method comment, an argument without =tag is tagged with its name
+func commentTags(doc *ast.CommentGroup, params []*param) ([]argTag, error) {
+
+	if doc == nil {
+		return nil, nil
+	}
+
+	var tags []argTag
+
+	for _, line := range strings.Split(doc.Text(), "\n") {
+
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, commentPrefix) {
+			continue
+		}
+
+		for _, item := range strings.Fields(strings.TrimPrefix(line, commentPrefix)) {
+
+			arg, tag := item, item
+			if i := strings.Index(item, "="); i >= 0 {
+				arg, tag = item[:i], item[i+1:]
+			}
+
+			p := findParam(params, arg)
+			if p == nil {
+				return nil, fmt.Errorf("%s%s: no argument %s", commentPrefix, item, arg)
+			}
+
+			tags = append(tags, argTag{tag: tag, expr: p.name})
+		}
+	}
+
+	return tags, nil
+}
+
+// structTags tags the fields with a trace struct tag of the arguments whose type is a struct of the package
+func structTags(params []*param, structs map[string]*ast.StructType) []argTag {
+
+	var tags []argTag
+
+	for _, p := range params {
+
+		name := strings.TrimPrefix(p.typ, "*")
+		st, ok := structs[name]
+		if !ok || p.variadic {
+			continue
+		}
+
+		for _, field := range st.Fields.List {
+
+			if field.Tag == nil || len(field.Names) == 0 {
+				continue
+			}
+
+			tagValue, _ := strconv.Unquote(field.Tag.Value)
+			tag := reflect.StructTag(tagValue).Get(structTag)
+			if tag == "" || tag == "-" {
+				continue
+			}
+
+			for _, n := range field.Names {
+				t := argTag{tag: tag, expr: p.name + "." + n.Name}
+				if strings.HasPrefix(p.typ, "*") {
+					t.nilable = p.name
+				}
+				tags = append(tags, t)
+			}
+		}
+	}
+
+	return tags
+}
+
+func findParam(params []*param, name string) *param {
+	for _, p := range params {
+		if p.orig == name {
+			return p
+		}
+	}
+	return nil
+}
+
+// reserved tells the names used by the generated methods: the receiver, the call and the results
+func reserved(name string) bool {
+	if name == "d" || name == "call" {
+		return true
+	}
+	if strings.HasPrefix(name, "r") {
+		_, err := strconv.Atoi(name[1:])
+		return err == nil
+	}
+	return false
+}
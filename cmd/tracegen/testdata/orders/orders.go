@@ -0,0 +1,23 @@
+package orders
+
+import (
+	"context"
+	"time"
+)
+
+type Order struct {
+	ID       string `trace:"order.id"`
+	Customer string `trace:"customer.id"`
+	Note     string
+}
+
+// Service is the interface of the golden test of tracegen
+type Service interface {
+	// trace: id=order.id
+	Get(ctx context.Context, id string) (*Order, error)
+	Create(ctx context.Context, order *Order) error
+	// trace: limit
+	Expire(ctx context.Context, before time.Time, limit int, ids ...string) (int, error)
+	Count(ctx context.Context) int
+	Name() string
+}
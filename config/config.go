@@ -0,0 +1,304 @@
+// Package config loads the typed config of a service: the defaults of the struct are overridden by a YAML file,
+// then by environment variables, then by flags, and the result is validated.
+//
+// The settings are the fields of the struct named by their yaml tag, http.addr for Addr of the HTTP field,
+// read from the environment as PREFIX_HTTP_ADDR and from the flag -http.addr. Struct tags add:
+//
+//	required:"true"  the setting must not be empty
+//	secret:"true"    the value is redacted when the config is shown
+//	reload:"true"    the setting, or the settings of the struct, can change while the service runs
+//	help:"..."       the usage of the flag
+//
+// Structs implementing Validator are checked once loaded. The file can be watched: changes to the settings that can
+// be reloaded are applied, the others are logged and wait for a restart.
+package config
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/alloykh/tracer-demo/log"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// FileFlag is the flag naming the config file when the loader parses flags
+const FileFlag = "config"
+
+// Validator is implemented by the config structs checking their settings
+type Validator interface {
+	Validate() error
+}
+
+// Error lists the problems of an invalid config
+type Error struct {
+	Problems []string
+}
+
+func (e *Error) Error() string {
+	return "invalid config: " + strings.Join(e.Problems, "; ")
+}
+
+// Loader loads a config struct from its sources, it keeps them to reload the config
+type Loader struct {
+	file      string
+	envPrefix string
+	flags     *flag.FlagSet
+	args      []string
+	logr      *log.Factory
+
+	// values of the flags set on the command line, by setting
+	flagValues map[string]string
+	// the config before the first load, reloads start from it
+	defaults reflect.Value
+
+	polled *PolledFile
+}
+
+// Option controls the behavior of the Loader.
+type Option func(*Loader)
+
+// WithFile reads the settings from the YAML file at path, unknown settings are errors
+func WithFile(path string) Option {
+	return func(l *Loader) {
+		l.file = path
+	}
+}
+
+// WithEnvPrefix reads the settings from the environment variables PREFIX_SETTING
+func WithEnvPrefix(prefix string) Option {
+	return func(l *Loader) {
+		l.envPrefix = strings.ToUpper(prefix)
+	}
+}
+
+// WithFlags defines a flag on fs for every setting, and -config naming the file, and parses args
+func WithFlags(fs *flag.FlagSet, args []string) Option {
+	return func(l *Loader) {
+		l.flags = fs
+		l.args = args
+	}
+}
+
+// WithLogger logs the reloads of the file with logr
+func WithLogger(logr *log.Factory) Option {
+	return func(l *Loader) {
+		l.logr = logr
+	}
+}
+
+// NewLoader -
+func NewLoader(opts ...Option) *Loader {
+	l := &Loader{}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+// File returns the path of the config file, "" without one
+func (l *Loader) File() string {
+	return l.file
+}
+
+// Load fills cfg, a pointer to a struct holding the defaults, from the sources and validates it
+func (l *Loader) Load(cfg interface{}) error {
+
+	fs, err := fields(cfg)
+	if err != nil {
+		return err
+	}
+
+	if !l.defaults.IsValid() {
+		l.defaults = reflect.New(reflect.TypeOf(cfg).Elem())
+		l.defaults.Elem().Set(reflect.ValueOf(cfg).Elem())
+	}
+
+	if l.flags != nil && l.flagValues == nil {
+		if err := l.parseFlags(fs); err != nil {
+			return err
+		}
+	}
+
+	if l.file == "" {
+		return l.load(cfg, fs, nil)
+	}
+
+	l.polled = NewPolledFile("config", l.file)
+
+	return l.polled.Load(func(data []byte) error {
+		return l.load(cfg, fs, data)
+	})
+}
+
+// load fills cfg with data, the content of the file, then with the environment and the flags
+func (l *Loader) load(cfg interface{}, fs []field, data []byte) error {
+
+	if l.file != "" {
+		if err := yaml.UnmarshalStrict(data, cfg); err != nil {
+			return errors.Wrapf(err, "config file %s", l.file)
+		}
+	}
+
+	var problems []string
+
+	for _, f := range fs {
+
+		if l.envPrefix != "" {
+			name := envName(l.envPrefix, f.path)
+			if s, ok := os.LookupEnv(name); ok {
+				if err := set(f.value, s); err != nil {
+					problems = append(problems, fmt.Sprintf("%s: env %s: %v", f.path, name, err))
+				}
+			}
+		}
+
+		if s, ok := l.flagValues[f.path]; ok {
+			if err := set(f.value, s); err != nil {
+				problems = append(problems, fmt.Sprintf("%s: flag -%s: %v", f.path, f.path, err))
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return &Error{Problems: problems}
+	}
+
+	return Validate(cfg)
+}
+
+// parseFlags defines the flags and keeps the values set, they are applied on every load
+func (l *Loader) parseFlags(fs []field) error {
+
+	file := l.flags.String(FileFlag, l.file, "YAML config file")
+
+	values := make(map[string]*flagValue, len(fs))
+	for _, f := range fs {
+		v := &flagValue{}
+		if !f.secret {
+			v.value = text(f.value)
+		}
+		values[f.path] = v
+		help := f.help
+		if help == "" {
+			help = f.path
+		}
+		if l.envPrefix != "" {
+			help += " (env " + envName(l.envPrefix, f.path) + ")"
+		}
+		l.flags.Var(v, f.path, help)
+	}
+
+	if err := l.flags.Parse(l.args); err != nil {
+		return err
+	}
+
+	l.file = *file
+	l.flagValues = make(map[string]string)
+	for path, v := range values {
+		if v.set {
+			l.flagValues[path] = v.value
+		}
+	}
+
+	return nil
+}
+
+// envName - PREFIX_HTTP_ADDR for http.addr
+func envName(prefix, path string) string {
+	return prefix + "_" + strings.ToUpper(strings.ReplaceAll(path, ".", "_"))
+}
+
+// flagValue keeps the text of a flag, it is parsed into the config on load
+type flagValue struct {
+	value string
+	set   bool
+}
+
+func (v *flagValue) String() string {
+	if v == nil {
+		return ""
+	}
+	return v.value
+}
+
+func (v *flagValue) Set(s string) error {
+	v.value = s
+	v.set = true
+	return nil
+}
+
+// Validate checks the required settings of cfg, a pointer to a struct, then calls the Validators it holds
+func Validate(cfg interface{}) error {
+
+	fs, err := fields(cfg)
+	if err != nil {
+		return err
+	}
+
+	var problems []string
+
+	for _, f := range fs {
+		if f.required && f.value.IsZero() {
+			problems = append(problems, f.path+": required")
+		}
+	}
+
+	validate(reflect.ValueOf(cfg).Elem(), "", true, &problems)
+
+	if len(problems) > 0 {
+		return &Error{Problems: problems}
+	}
+
+	return nil
+}
+
+// validate calls the Validators of the nested structs first. The Validator of an embedded struct is left
+// to the outer struct implementing Validator too: the method is promoted, or overridden.
+func validate(v reflect.Value, path string, self bool, problems *[]string) {
+
+	t := v.Type()
+	_, outer := v.Addr().Interface().(Validator)
+
+	for i := 0; i < t.NumField(); i++ {
+
+		sf := t.Field(i)
+		fv := v.Field(i)
+		if sf.PkgPath != "" || fv.Kind() != reflect.Struct || isText(fv) {
+			continue
+		}
+
+		name, inline := yamlName(sf)
+		if name == "-" {
+			continue
+		}
+
+		fieldPath := path
+		if !inline {
+			fieldPath = join(path, name)
+		}
+
+		validate(fv, fieldPath, !(sf.Anonymous && outer), problems)
+	}
+
+	if !self || !outer {
+		return
+	}
+
+	if err := v.Addr().Interface().(Validator).Validate(); err != nil {
+		prefix := ""
+		if path != "" {
+			prefix = path + ": "
+		}
+		if e, ok := err.(*Error); ok {
+			for _, p := range e.Problems {
+				*problems = append(*problems, prefix+p)
+			}
+			return
+		}
+		*problems = append(*problems, prefix+err.Error())
+	}
+}
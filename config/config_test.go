@@ -0,0 +1,164 @@
+package config_test
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/alloykh/tracer-demo/config"
+)
+
+type testConfig struct {
+	Addr     string        `yaml:"addr"`
+	LogLevel string        `yaml:"log_level" reload:"true"`
+	Timeout  time.Duration `yaml:"timeout"`
+	Client   struct {
+		Target  string        `yaml:"target"`
+		Timeout time.Duration `yaml:"timeout"`
+	} `yaml:"client" reload:"true"`
+}
+
+func defaults() *testConfig {
+	cfg := &testConfig{Addr: ":8080", LogLevel: "info", Timeout: time.Second}
+	cfg.Client.Target = "localhost:7050"
+	return cfg
+}
+
+func writeFile(t *testing.T, path, content string, modTime time.Time) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+
+	tests := []struct {
+		name  string
+		file  string
+		env   map[string]string
+		flags []string
+		want  func(cfg *testConfig)
+	}{
+		{
+			name: "defaults",
+			want: func(cfg *testConfig) {},
+		},
+		{
+			name: "file over defaults",
+			file: "addr: :9090\nclient:\n  timeout: 3s\n",
+			want: func(cfg *testConfig) {
+				cfg.Addr = ":9090"
+				cfg.Client.Timeout = 3 * time.Second
+			},
+		},
+		{
+			name: "env over file",
+			file: "addr: :9090\nlog_level: debug\n",
+			env:  map[string]string{"TEST_ADDR": ":9191", "TEST_CLIENT_TARGET": "order:7050"},
+			want: func(cfg *testConfig) {
+				cfg.Addr = ":9191"
+				cfg.LogLevel = "debug"
+				cfg.Client.Target = "order:7050"
+			},
+		},
+		{
+			name:  "flags over env",
+			file:  "addr: :9090\ntimeout: 2s\n",
+			env:   map[string]string{"TEST_ADDR": ":9191", "TEST_TIMEOUT": "4s"},
+			flags: []string{"-addr", ":9292"},
+			want: func(cfg *testConfig) {
+				cfg.Addr = ":9292"
+				cfg.Timeout = 4 * time.Second
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			opts := []config.Option{config.WithEnvPrefix("test"), config.WithFlags(flag.NewFlagSet("test", flag.ContinueOnError), tt.flags)}
+			if tt.file != "" {
+				path := filepath.Join(t.TempDir(), "config.yaml")
+				writeFile(t, path, tt.file, time.Now())
+				opts = append(opts, config.WithFile(path))
+			}
+
+			cfg := defaults()
+			if err := config.NewLoader(opts...).Load(cfg); err != nil {
+				t.Fatal(err)
+			}
+
+			want := defaults()
+			tt.want(want)
+
+			if !reflect.DeepEqual(cfg, want) {
+				t.Errorf("config %+v, want %+v", cfg, want)
+			}
+		})
+	}
+}
+
+func TestReloadKeepsTheSettingsNeedingARestart(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	now := time.Now()
+	writeFile(t, path, "addr: :9090\nlog_level: info\n", now)
+
+	t.Setenv("TEST_TIMEOUT", "4s")
+
+	loader := config.NewLoader(config.WithFile(path), config.WithEnvPrefix("test"))
+
+	current := defaults()
+	if err := loader.Load(current); err != nil {
+		t.Fatal(err)
+	}
+
+	next, restart, err := loader.Reload(current)
+	if err != nil || next != nil {
+		t.Fatalf("reload of an unchanged file: %v %v, want nothing", next, err)
+	}
+
+	writeFile(t, path, "addr: :9191\nlog_level: debug\nclient:\n  target: order:7050\n", now.Add(time.Second))
+
+	next, restart, err = loader.Reload(current)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next == nil {
+		t.Fatal("reload of a changed file: no config")
+	}
+
+	got := next.(*testConfig)
+	if got.LogLevel != "debug" || got.Client.Target != "order:7050" {
+		t.Errorf("reloadable settings %q %q, want the ones of the file", got.LogLevel, got.Client.Target)
+	}
+	if got.Addr != ":9090" {
+		t.Errorf("addr %q, want :9090 until a restart", got.Addr)
+	}
+	if got.Timeout != 4*time.Second {
+		t.Errorf("timeout %s, want the 4s of the environment", got.Timeout)
+	}
+	if !reflect.DeepEqual(restart, []string{"addr"}) {
+		t.Errorf("restart %v, want [addr]", restart)
+	}
+	if changed := config.Diff(current, got); !reflect.DeepEqual(changed, []string{"log_level", "client.target"}) {
+		t.Errorf("diff %v, want [log_level client.target]", changed)
+	}
+
+	// a broken file keeps the current config
+	writeFile(t, path, "addr: [\n", now.Add(2*time.Second))
+	if next, _, err := loader.Reload(current); err == nil || next != nil {
+		t.Errorf("reload of a broken file: %v %v, want an error", next, err)
+	}
+}
@@ -0,0 +1,219 @@
+package config
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	durationType        = reflect.TypeOf(time.Duration(0))
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// field is a setting of a config struct: a value that is not a struct, or one decoded from text
+type field struct {
+	// path of the field in the YAML file, e.g. http.addr
+	path  string
+	value reflect.Value
+	help  string
+
+	secret   bool
+	reload   bool
+	required bool
+}
+
+// fields returns the settings of the struct pointed to by cfg
+func fields(cfg interface{}) ([]field, error) {
+
+	v := reflect.ValueOf(cfg)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return nil, errors.Errorf("config: %T is not a pointer to a struct", cfg)
+	}
+
+	var out []field
+	walk(v.Elem(), "", false, &out)
+
+	return out, nil
+}
+
+func walk(v reflect.Value, prefix string, reload bool, out *[]field) {
+
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+
+		sf := t.Field(i)
+		if sf.PkgPath != "" {
+			continue
+		}
+
+		name, inline := yamlName(sf)
+		if name == "-" {
+			continue
+		}
+
+		path := prefix
+		if !inline {
+			path = join(prefix, name)
+		}
+
+		fv := v.Field(i)
+		fieldReload := reload || sf.Tag.Get("reload") == "true"
+
+		if fv.Kind() == reflect.Struct && !isText(fv) {
+			walk(fv, path, fieldReload, out)
+			continue
+		}
+
+		if !settable(fv) {
+			continue
+		}
+
+		*out = append(*out, field{
+			path:     path,
+			value:    fv,
+			help:     sf.Tag.Get("help"),
+			secret:   sf.Tag.Get("secret") == "true",
+			reload:   fieldReload,
+			required: sf.Tag.Get("required") == "true",
+		})
+	}
+}
+
+// yamlName returns the name of the field in the file as yaml.v2 does: the tag name or the lower-cased field name
+func yamlName(sf reflect.StructField) (name string, inline bool) {
+
+	tag := sf.Tag.Get("yaml")
+	parts := strings.Split(tag, ",")
+
+	for _, flag := range parts[1:] {
+		if flag == "inline" {
+			return "", true
+		}
+	}
+
+	if parts[0] != "" {
+		return parts[0], false
+	}
+
+	return strings.ToLower(sf.Name), false
+}
+
+func join(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "." + name
+}
+
+func isText(v reflect.Value) bool {
+	return v.CanAddr() && v.Addr().Type().Implements(textUnmarshalerType)
+}
+
+func settable(v reflect.Value) bool {
+
+	if isText(v) {
+		return true
+	}
+
+	switch v.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	case reflect.Slice:
+		return v.Type().Elem().Kind() == reflect.String
+	}
+
+	return false
+}
+
+// set parses s into v, lists of strings are comma separated
+func set(v reflect.Value, s string) error {
+
+	if isText(v) {
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+	}
+
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		var items []string
+		for _, item := range strings.Split(s, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items).Convert(v.Type()))
+	default:
+		return errors.Errorf("unsupported type %s", v.Type())
+	}
+
+	return nil
+}
+
+// text returns the value of a field as set from the environment or a flag
+func text(v reflect.Value) string {
+	if v.Kind() == reflect.Slice {
+		return strings.Join(v.Convert(reflect.TypeOf([]string(nil))).Interface().([]string), ",")
+	}
+	return fmt.Sprint(format(v))
+}
+
+// format returns the value of a field as shown to people: durations and levels as text
+func format(v reflect.Value) interface{} {
+
+	if v.Type() == durationType {
+		return time.Duration(v.Int()).String()
+	}
+
+	if v.CanAddr() && v.Addr().Type().Implements(textMarshalerType) {
+		if text, err := v.Addr().Interface().(encoding.TextMarshaler).MarshalText(); err == nil {
+			return string(text)
+		}
+	}
+
+	return v.Interface()
+}
@@ -0,0 +1,140 @@
+package config
+
+import (
+	"context"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/alloykh/tracer-demo/log"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// DefaultPollInterval - time between two checks of a polled file when no interval is given
+const DefaultPollInterval = time.Second * 5
+
+type fileStat struct {
+	modTime time.Time
+	size    int64
+}
+
+// PolledFile is a file loaded again when its modification time or size changes. Polling works
+// the same on every file system, mounted config maps included, for the cost of a stat per check.
+type PolledFile struct {
+	name string
+	path string
+
+	mu     sync.Mutex
+	stat   fileStat
+	loaded bool
+}
+
+// NewPolledFile - name says what the file holds in errors and logs, e.g. "sampling strategies".
+// Nothing is read before the first Load or Reload.
+func NewPolledFile(name, path string) *PolledFile {
+	return &PolledFile{name: name, path: path}
+}
+
+// Path returns the path of the file
+func (f *PolledFile) Path() string {
+	return f.path
+}
+
+// Load reads the file and hands its content to load
+func (f *PolledFile) Load(load func(data []byte) error) error {
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	stat, err := f.statFile()
+	if err != nil {
+		return err
+	}
+
+	return f.load(stat, load)
+}
+
+// Reload hands the content of the file to load when the file changed since the last load, it reports
+// whether it did. A content load fails on is not recorded: a broken file never replaces a valid one,
+// it is read again, and fails again, on every reload until it is fixed.
+func (f *PolledFile) Reload(load func(data []byte) error) (bool, error) {
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	stat, err := f.statFile()
+	if err != nil {
+		return false, err
+	}
+
+	if f.loaded && stat == f.stat {
+		return false, nil
+	}
+
+	if err := f.load(stat, load); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Watch reloads the file every interval until ctx is done and logs the reloads, use it in a separate goroutine
+func (f *PolledFile) Watch(ctx context.Context, interval time.Duration, logr *log.Factory, load func(data []byte) error) {
+	Poll(ctx, interval, func() {
+		changed, err := f.Reload(load)
+		if err != nil {
+			logr.Default().Error(f.name+" reload", zap.String("path", f.path), zap.String("err", err.Error()))
+			return
+		}
+		if changed {
+			logr.Default().Info(f.name+" reloaded", zap.String("path", f.path))
+		}
+	})
+}
+
+// the file is read after its stat, a change in between is seen by the next reload
+func (f *PolledFile) load(stat fileStat, load func(data []byte) error) error {
+
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		return errors.Wrapf(err, "%s file read", f.name)
+	}
+
+	if err := load(data); err != nil {
+		return err
+	}
+
+	f.stat = stat
+	f.loaded = true
+
+	return nil
+}
+
+func (f *PolledFile) statFile() (fileStat, error) {
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return fileStat{}, errors.Wrapf(err, "%s file stat", f.name)
+	}
+	return fileStat{modTime: info.ModTime(), size: info.Size()}, nil
+}
+
+// Poll calls check every interval, DefaultPollInterval when interval <= 0, until ctx is done
+func Poll(ctx context.Context, interval time.Duration, check func()) {
+
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			check()
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alloykh/tracer-demo/config"
+	"github.com/pkg/errors"
+)
+
+func TestPolledFileReload(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "file")
+	write := func(content string, modTime time.Time) {
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var loaded string
+	load := func(data []byte) error {
+		if string(data) == "broken" {
+			return errors.New("broken")
+		}
+		loaded = string(data)
+		return nil
+	}
+
+	now := time.Now()
+	write("v1", now)
+
+	f := config.NewPolledFile("test", path)
+	if err := f.Load(load); err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		name    string
+		content string
+		modTime time.Time
+		changed bool
+		fails   bool
+		loaded  string
+	}{
+		{name: "unchanged", content: "v1", modTime: now, loaded: "v1"},
+		{name: "new content", content: "v2", modTime: now.Add(time.Second), changed: true, loaded: "v2"},
+		{name: "broken", content: "broken", modTime: now.Add(2 * time.Second), fails: true, loaded: "v2"},
+		{name: "still broken", content: "broken", modTime: now.Add(2 * time.Second), fails: true, loaded: "v2"},
+		{name: "fixed", content: "v3", modTime: now.Add(3 * time.Second), changed: true, loaded: "v3"},
+	}
+
+	for _, step := range steps {
+		write(step.content, step.modTime)
+
+		changed, err := f.Reload(load)
+		if (err != nil) != step.fails {
+			t.Errorf("%s: error %v, want failure %v", step.name, err, step.fails)
+		}
+		if changed != step.changed {
+			t.Errorf("%s: changed %v, want %v", step.name, changed, step.changed)
+		}
+		if loaded != step.loaded {
+			t.Errorf("%s: loaded %q, want %q", step.name, loaded, step.loaded)
+		}
+	}
+
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Reload(load); err == nil {
+		t.Error("reload of a missing file succeeded")
+	}
+}
@@ -0,0 +1,61 @@
+package config
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// Path of the effective config on the admin server
+const Path = "/debug/config"
+
+// Redacted is the value shown in place of the secrets which are set
+const Redacted = "[redacted]"
+
+// Effective returns the settings of cfg, a pointer to a struct, nested by section as in the file, secrets redacted
+func Effective(cfg interface{}) map[string]interface{} {
+
+	fs, err := fields(cfg)
+	if err != nil {
+		return nil
+	}
+
+	out := make(map[string]interface{})
+
+	for _, f := range fs {
+
+		var value interface{}
+		switch {
+		case f.secret && !f.value.IsZero():
+			value = Redacted
+		case f.secret:
+			value = ""
+		default:
+			value = format(f.value)
+		}
+
+		section := out
+		names := strings.Split(f.path, ".")
+		for _, name := range names[:len(names)-1] {
+			next, ok := section[name].(map[string]interface{})
+			if !ok {
+				next = make(map[string]interface{})
+				section[name] = next
+			}
+			section = next
+		}
+		section[names[len(names)-1]] = value
+	}
+
+	return out
+}
+
+// Handler serves the effective config returned by current as JSON, secrets redacted
+func Handler(current func() interface{}) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(Effective(current()))
+	})
+}
@@ -0,0 +1,119 @@
+package config
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Reload loads the config again when the file changed since the last load, next is nil otherwise.
+// The settings of current that cannot be reloaded are kept in next, restart lists the ones the file changes.
+func (l *Loader) Reload(current interface{}) (next interface{}, restart []string, err error) {
+
+	if l.polled == nil || !l.defaults.IsValid() {
+		return nil, nil, nil
+	}
+
+	_, err = l.polled.Reload(func(data []byte) error {
+
+		v := reflect.New(l.defaults.Type().Elem())
+		v.Elem().Set(l.defaults.Elem())
+		cfg := v.Interface()
+
+		fs, err := fields(cfg)
+		if err != nil {
+			return err
+		}
+
+		if err := l.load(cfg, fs, data); err != nil {
+			return err
+		}
+
+		currentFields, err := fields(current)
+		if err != nil {
+			return err
+		}
+
+		for i, f := range fs {
+			if f.reload {
+				continue
+			}
+			old := currentFields[i].value
+			if !reflect.DeepEqual(f.value.Interface(), old.Interface()) {
+				restart = append(restart, f.path)
+				f.value.Set(old)
+			}
+		}
+
+		next = cfg
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return next, restart, nil
+}
+
+// Watch polls the file every interval until ctx is done, use it in a separate goroutine.
+// current is the loaded config, apply is called with the next one when reloadable settings change.
+func (l *Loader) Watch(ctx context.Context, interval time.Duration, current interface{}, apply func(next interface{})) {
+
+	if l.polled == nil {
+		return
+	}
+
+	Poll(ctx, interval, func() {
+		next, restart, err := l.Reload(current)
+		if err != nil {
+			l.logError("config reload", zap.String("path", l.file), zap.String("err", err.Error()))
+			return
+		}
+		if next == nil {
+			return
+		}
+		if len(restart) > 0 {
+			l.logError("config changes need a restart", zap.String("path", l.file), zap.String("settings", strings.Join(restart, ",")))
+		}
+		changed := Diff(current, next)
+		if len(changed) == 0 {
+			return
+		}
+		apply(next)
+		current = next
+		if l.logr != nil {
+			l.logr.Default().Info("config reloaded", zap.String("path", l.file), zap.String("settings", strings.Join(changed, ",")))
+		}
+	})
+}
+
+func (l *Loader) logError(msg string, fields ...zap.Field) {
+	if l.logr != nil {
+		l.logr.Default().Error(msg, fields...)
+	}
+}
+
+// Diff returns the settings of a and b, pointers to structs of the same type, with different values
+func Diff(a, b interface{}) []string {
+
+	af, err := fields(a)
+	if err != nil {
+		return nil
+	}
+	bf, err := fields(b)
+	if err != nil || len(af) != len(bf) {
+		return nil
+	}
+
+	var changed []string
+	for i := range af {
+		if !reflect.DeepEqual(af[i].value.Interface(), bf[i].value.Interface()) {
+			changed = append(changed, af[i].path)
+		}
+	}
+
+	return changed
+}
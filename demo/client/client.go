@@ -1,157 +1,28 @@
 package main
 
 import (
-	"context"
-	"fmt"
+	"github.com/alloykh/tracer-demo/app"
+	"github.com/alloykh/tracer-demo/demo/helpers"
 	"github.com/alloykh/tracer-demo/demo/protos/genproto/client_service"
-	"github.com/alloykh/tracer-demo/log"
-	"github.com/alloykh/tracer-demo/tracing"
-
-	GRPCMiddleware "github.com/grpc-ecosystem/go-grpc-middleware"        // grpc interceptors https://github.com/grpc-ecosystem/go-grpc-middleware
-	GRPCRecovery "github.com/grpc-ecosystem/go-grpc-middleware/recovery" // grpc interceptors https://github.com/grpc-ecosystem/go-grpc-middleware
-	GRPCCtxTags "github.com/grpc-ecosystem/go-grpc-middleware/tags"      // grpc interceptors https://github.com/grpc-ecosystem/go-grpc-middleware
-	GRPCOpenTracing "github.com/grpc-ecosystem/go-grpc-middleware/tracing/opentracing"
-	"github.com/opentracing/opentracing-go"
-	"github.com/uber/jaeger-lib/metrics"
-	JProm "github.com/uber/jaeger-lib/metrics/prometheus"
-	"go.uber.org/zap"
-	"go.uber.org/zap/zapcore"
-	"google.golang.org/grpc"
-	"google.golang.org/grpc/reflection"
-	"net"
-	"os"
-	"os/signal"
 )
 
-var tearDowns []func()
-
 var serviceName = "client_service"
 
-var defaultGrpcPort = ":7050"
-
-type server struct {
-	gRPCServer *grpc.Server
-	logr       *log.Factory
-	listener   net.Listener
-}
-
 func main() {
 
-	ctx := getDefaultContext()
-
-	metricsFactory := JProm.New().Namespace(metrics.NSOptions{Name: serviceName, Tags: nil})
-
-	// logger
-	logr := log.NewFactory("zap", zapcore.DebugLevel)
-
-	tracer, tr := tracing.InitJaeger(serviceName, metricsFactory, logr)
-
-	tearDowns = append(tearDowns, tr)
-
-	opentracing.SetGlobalTracer(tracer)
-
-	// setup grpc server
-	serv, tr := newGrpcServer(logr)
-
-	tearDowns = append(tearDowns, tr)
+	cfg := helpers.Config(serviceName)
+	cfg.GRPC.Addr = ":7050"
+	// admin listener serving the admin pages, /debug/traces, /debug/dependencies and the health probes
+	cfg.AdminAddr = ":7060"
 
-	if err := serv.setListener(); err != nil {
-		logr.Default().Fatal("server set listener", zap.Any("err", err.Error()))
-		return
-	}
-
-	go func() {
-
-		if err := serv.run(); err != nil {
-			logr.Default().Info(fmt.Sprintf("error while running grpc server: %v", err.Error()))
-		}
-
-	}()
-
-	<-ctx.Done()
-
-	//_, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	//defer func() {
-	//	cancel()
-	//}()
-
-	for _, f := range tearDowns {
-		f()
-	}
-
-	logr.Default().Info("graceful shutdown")
-
-	os.Exit(0)
+	loader := helpers.LoadConfig(serviceName, &cfg)
 
+	app.Main(cfg, setup, append(helpers.Options(serviceName), app.WithConfig(loader, &cfg))...)
 }
 
-func newGrpcServer(logr *log.Factory) (*server, func()) {
-
-	s := grpc.NewServer(
-		GRPCMiddleware.WithUnaryServerChain(
-			GRPCRecovery.UnaryServerInterceptor(),
-			GRPCCtxTags.UnaryServerInterceptor(),
-			GRPCOpenTracing.UnaryServerInterceptor(), // we can later add those interceptors -
-			//GRPCZap.UnaryServerInterceptor(logr.Default()),
-			// prometheus.UnaryServerInterceptor,  // - for authentication and monitoring purposes
-			// auth.UnaryServerInterceptor(myAuthFunction),
-		),
-	)
-
-	teardown := func() {
-		s.GracefulStop()
-		logr.Default().Info("grpc server has been shut down")
-	}
-
-	return &server{
-		gRPCServer: s,
-		logr:       logr,
-	}, teardown
-
-}
-
-func (s *server) setListener() (err error) {
-
-	listener, err := net.Listen("tcp", defaultGrpcPort)
-
-	s.listener = listener
-
-	return
-}
-
-func (s *server) run() (err error) {
-
-	service := NewService(s.logr)
-
-	client_service.RegisterClientServiceServer(s.gRPCServer, service)
-
-	reflection.Register(s.gRPCServer)
-
-	s.logr.Default().Info(fmt.Sprintf("GRPC Server started at port %v", defaultGrpcPort))
-
-	err = s.gRPCServer.Serve(s.listener)
-
-	if err != nil {
-
-		s.logr.Default().Error("grpc server serve", zap.Any("err", err.Error()))
-
-	}
-
-	return
-}
-
-func getDefaultContext() context.Context {
-
-	ctx, cancel := context.WithCancel(context.Background())
-
-	closeChan := make(chan os.Signal, 1)
-
-	signal.Notify(closeChan, os.Interrupt, os.Kill)
+func setup(a *app.App) error {
 
-	go func() {
-		<-closeChan
-		cancel()
-	}()
+	client_service.RegisterClientServiceServer(a.GRPC, NewService(a.Logr))
 
-	return ctx
+	return nil
 }
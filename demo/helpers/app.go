@@ -0,0 +1,62 @@
+package helpers
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/alloykh/tracer-demo/app"
+	"github.com/alloykh/tracer-demo/config"
+	"github.com/alloykh/tracer-demo/log"
+	"github.com/alloykh/tracer-demo/tracing"
+	"go.uber.org/zap/zapcore"
+)
+
+// Config returns the config shared by the demo services, the addresses are left to each service
+func Config(serviceName string) app.Config {
+
+	cfg := app.DefaultConfig(serviceName)
+	cfg.LogLevel = zapcore.DebugLevel
+
+	// traces kept in memory for /debug/traces
+	cfg.DebugTraces = 100
+
+	// requests running longer get a CPU profile attached to their span
+	cfg.SlowRequest = time.Second
+
+	return cfg
+}
+
+// LoadConfig loads cfg, the config of the service holding its defaults, from the YAML file named by -config
+// or <SERVICE>_CONFIG, then from the <SERVICE>_ environment variables and the flags, e.g. ORDER_SERVICE_HTTP_ADDR
+// or -http.addr. It exits on an invalid config.
+func LoadConfig(serviceName string, cfg app.Configurable) *config.Loader {
+
+	prefix := strings.ToUpper(serviceName)
+
+	loader := config.NewLoader(
+		config.WithFile(os.Getenv(prefix+"_CONFIG")),
+		config.WithEnvPrefix(prefix),
+		config.WithFlags(flag.CommandLine, os.Args[1:]),
+	)
+
+	if err := loader.Load(cfg); err != nil {
+		fmt.Fprintln(os.Stderr, serviceName+":", err)
+		os.Exit(2)
+	}
+
+	return loader
+}
+
+// Options returns the app options shared by the demo services: baggage in the logs and on the spans,
+// span capture and baggage restrictions from the environment
+func Options(serviceName string) []app.Option {
+	return []app.Option{
+		app.WithLogOptions(log.WithBaggageFields(BaggageKeys...)),
+		app.WithTracerOptions(func(logr *log.Factory) []tracing.JaegerOption {
+			return append(TraceCapture(serviceName, logr), Baggage(logr)...)
+		}),
+	}
+}
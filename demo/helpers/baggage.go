@@ -0,0 +1,37 @@
+package helpers
+
+import (
+	"os"
+	"time"
+
+	"github.com/alloykh/tracer-demo/log"
+	"github.com/alloykh/tracer-demo/tracing"
+	"go.uber.org/zap"
+)
+
+// BaggageKeys - baggage items added to the log lines of log.Factory.For and tagged on every span
+var BaggageKeys = []string{"tenant", "user", "session"}
+
+// BaggageHeaders - request headers the frontend turns into baggage items, header name to baggage key
+var BaggageHeaders = map[string]string{
+	"X-Tenant-ID":  "tenant",
+	"X-User-ID":    "user",
+	"X-Session-ID": "session",
+}
+
+// BaggageRestrictionsEnv - when set, the host:port the services poll their allowed baggage keys from,
+// e.g. localhost:5778 for cmd/sampling-server started with -baggage; any key is allowed otherwise
+const BaggageRestrictionsEnv = "BAGGAGE_RESTRICTIONS_HOSTPORT"
+
+// Baggage returns the tracer options tagging spans with BaggageKeys and restricting baggage
+func Baggage(logr *log.Factory) []tracing.JaegerOption {
+
+	opts := []tracing.JaegerOption{tracing.WithBaggageTags(BaggageKeys...)}
+
+	if hostPort := os.Getenv(BaggageRestrictionsEnv); hostPort != "" {
+		logr.Default().Info("restricting baggage", zap.String("agent", hostPort))
+		opts = append(opts, tracing.WithBaggageRestrictions(hostPort, time.Minute, false))
+	}
+
+	return opts
+}
@@ -0,0 +1,30 @@
+package helpers
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/alloykh/tracer-demo/log"
+	"github.com/alloykh/tracer-demo/tracing"
+	"github.com/alloykh/tracer-demo/tracing/capture"
+	"go.uber.org/zap"
+)
+
+// CaptureDirEnv - when set, every demo service keeps the spans it produces in memory and writes them
+// to <dir>/<service>.jsonl when it shuts down, CI compares the files of two builds with tracectl diff
+const CaptureDirEnv = "TRACE_CAPTURE_DIR"
+
+// TraceCapture returns the tracer options capturing the spans of the service, none when CaptureDirEnv is not set
+func TraceCapture(serviceName string, logr *log.Factory) []tracing.JaegerOption {
+
+	dir := os.Getenv(CaptureDirEnv)
+	if dir == "" {
+		return nil
+	}
+
+	path := filepath.Join(dir, serviceName+".jsonl")
+
+	logr.Default().Info("capturing spans", zap.String("file", path))
+
+	return []tracing.JaegerOption{tracing.WithSpanReporter(capture.NewExporter(capture.WithFile(path), capture.WithLogger(logr)))}
+}
@@ -0,0 +1,14 @@
+package helpers
+
+import "os"
+
+// ForceTraceHeader - request header forcing the sampling of a request at the edge, its value must be the shared secret
+const ForceTraceHeader = "X-Force-Trace"
+
+// ForceTraceSecretEnv - the shared secret of ForceTraceHeader, the header is ignored when it is not set
+const ForceTraceSecretEnv = "FORCE_TRACE_SECRET"
+
+// ForceTraceSecret returns the shared secret of ForceTraceHeader
+func ForceTraceSecret() string {
+	return os.Getenv(ForceTraceSecretEnv)
+}
@@ -2,147 +2,65 @@ package main
 
 import (
 	"context"
-	"fmt"
+	"time"
+
+	"github.com/alloykh/tracer-demo/app"
+	"github.com/alloykh/tracer-demo/demo/helpers"
 	"github.com/alloykh/tracer-demo/demo/protos/genproto/inventory_service"
-	"github.com/alloykh/tracer-demo/log"
-	"github.com/alloykh/tracer-demo/tracing"
-	GRPCMiddleware "github.com/grpc-ecosystem/go-grpc-middleware"
-	GRPCRecovery "github.com/grpc-ecosystem/go-grpc-middleware/recovery"
-	GRPCCtxTags "github.com/grpc-ecosystem/go-grpc-middleware/tags"
-	GRPCOpenTracing "github.com/grpc-ecosystem/go-grpc-middleware/tracing/opentracing"
-	"github.com/opentracing/opentracing-go"
-	"github.com/uber/jaeger-lib/metrics"
-	JProm "github.com/uber/jaeger-lib/metrics/prometheus"
-	"go.uber.org/zap"
-	"go.uber.org/zap/zapcore"
-	"google.golang.org/grpc"
-	"google.golang.org/grpc/reflection"
-	"net"
-	"os"
-	"os/signal"
+	"github.com/alloykh/tracer-demo/tracing/async"
+	"github.com/alloykh/tracer-demo/tracing/decorate"
 )
 
-var tearDowns []func()
-
 var serviceName = "inventory_service"
 
-var defaultGrpcPort = ":7051"
+type serviceConfig struct {
+	app.Config `yaml:",inline"`
 
-type server struct {
-	gRPCServer *grpc.Server
-	logr       *log.Factory
-	listener   net.Listener
+	// period of the stock report job, 0 disables it
+	StockReportInterval time.Duration `yaml:"stock_report_interval"`
 }
 
 func main() {
 
-	ctx := getDefaultContext()
-
-	metricsFactory := JProm.New().Namespace(metrics.NSOptions{Name: serviceName, Tags: nil})
-
-	// logger
-	logr := log.NewFactory("zap", zapcore.DebugLevel)
-
-	tracer, tr := tracing.InitJaeger(serviceName, metricsFactory, logr)
-
-	tearDowns = append(tearDowns, tr)
-
-	opentracing.SetGlobalTracer(tracer)
-
-	serv, tr := newGrpcServer(logr)
-
-	tearDowns = append(tearDowns, tr)
-
-	if err := serv.setListener(); err != nil {
-		logr.Default().Fatal("server set listener", zap.Any("err", err.Error()))
-		return
+	cfg := &serviceConfig{
+		Config:              helpers.Config(serviceName),
+		StockReportInterval: time.Minute,
 	}
+	cfg.GRPC.Addr = ":7051"
+	// admin listener serving the admin pages, /debug/traces, /debug/dependencies, the labelled CPU profiles
+	// and the health probes
+	cfg.AdminAddr = ":7061"
 
-	go func() {
-
-		if err := serv.run(); err != nil {
-			logr.Default().Info(fmt.Sprintf("error while running grpc server: %v", err.Error()))
-		}
-
-	}()
-
-	<-ctx.Done()
-
-	for _, f := range tearDowns {
-		f()
-	}
-
-	logr.Default().Info("graceful shutdown")
-
-	os.Exit(0)
+	loader := helpers.LoadConfig(serviceName, cfg)
 
+	app.Main(cfg.Config, func(a *app.App) error {
+		return setup(a, cfg)
+	}, append(helpers.Options(serviceName), app.WithConfig(loader, cfg))...)
 }
 
-func newGrpcServer(logr *log.Factory) (*server, func()) {
-
-	s := grpc.NewServer(
-		GRPCMiddleware.WithUnaryServerChain(
-			GRPCRecovery.UnaryServerInterceptor(),
-			GRPCCtxTags.UnaryServerInterceptor(),
-			GRPCOpenTracing.UnaryServerInterceptor(), // we can later add those interceptors -
-			//GRPCZap.UnaryServerInterceptor(logr.Default()),
-			// prometheus.UnaryServerInterceptor,  // - for authentication and monitoring purposes
-			// auth.UnaryServerInterceptor(myAuthFunction),
-		),
-	)
-
-	teardown := func() {
-		s.GracefulStop()
-		logr.Default().Info("grpc server has been shut down")
-	}
+func setup(a *app.App, cfg *serviceConfig) error {
 
-	return &server{
-		gRPCServer: s,
-		logr:       logr,
-	}, teardown
+	repo := NewRepo(a.Logr)
+	a.Health.Ready("repository", repo.Ping)
 
-}
-
-func (s *server) setListener() (err error) {
-
-	listener, err := net.Listen("tcp", defaultGrpcPort)
-
-	s.listener = listener
-
-	return
-}
-
-func (s *server) run() (err error) {
-
-	service := NewService(s.logr, NewRepo(s.logr))
-
-	inventory_service.RegisterInventoryServiceServer(s.gRPCServer, service)
+	// open stock watches would keep the graceful stop waiting
+	a.OnStopTraffic("stock subscriptions", func(ctx context.Context) error {
+		repo.CloseSubscriptions()
+		return nil
+	})
 
-	reflection.Register(s.gRPCServer)
+	store := NewTracedStore(repo, decorate.WithLogger(a.Logr), decorate.WithMetrics(a.Metrics))
 
-	s.logr.Default().Info(fmt.Sprintf("GRPC Server started at port %v", defaultGrpcPort))
+	inventory_service.RegisterInventoryServiceServer(a.GRPC, NewService(a.Logr, store))
 
-	err = s.gRPCServer.Serve(s.listener)
-
-	if err != nil {
-		s.logr.Default().Error("grpc server serve", zap.Any("err", err.Error()))
+	if cfg.StockReportInterval > 0 {
+		scheduler := async.NewScheduler(async.WithLogger(a.Logr))
+		scheduler.Every(context.Background(), "stock report", cfg.StockReportInterval, reportStock(store, a.Logr))
+		a.OnDrain("stock report", func(ctx context.Context) error {
+			scheduler.Stop()
+			return nil
+		})
 	}
 
-	return
-}
-
-func getDefaultContext() context.Context {
-
-	ctx, cancel := context.WithCancel(context.Background())
-
-	closeChan := make(chan os.Signal, 1)
-
-	signal.Notify(closeChan, os.Interrupt, os.Kill)
-
-	go func() {
-		<-closeChan
-		cancel()
-	}()
-
-	return ctx
+	return nil
 }
@@ -3,7 +3,8 @@ package main
 import (
 	"context"
 	"github.com/alloykh/tracer-demo/log"
-	"github.com/alloykh/tracer-demo/tracing"
+	"github.com/alloykh/tracer-demo/tracing/semconv"
+	"github.com/opentracing/opentracing-go"
 	"go.uber.org/zap"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
@@ -17,10 +18,41 @@ type Product struct {
 	Quantity uint64
 }
 
+// StockUpdate - quantity of a product after an allocation
+type StockUpdate struct {
+	ID        string
+	Quantity  uint64
+	Allocated uint64
+}
+
+// stock updates buffered per subscriber, a subscriber falling further behind misses updates
+var subscriptionBuffer = 16
+
+type subscription struct {
+	ids     map[string]bool
+	updates chan StockUpdate
+}
+
+//go:generate go run github.com/alloykh/tracer-demo/cmd/tracegen -type Store
+
+// Store - the products of the inventory, traced by NewTracedStore
+type Store interface {
+	// trace: id=product.id
+	Get(ctx context.Context, id string) (*Product, error)
+	List(ctx context.Context) ([]Product, error)
+	// trace: id=product.id quantity=product.quantity
+	Allocate(ctx context.Context, id string, quantity uint64) error
+	Subscribe(ids ...string) (<-chan StockUpdate, func())
+}
+
 type Repository struct {
 	logr *log.Factory
 	data map[string]*Product
 	sync.RWMutex
+
+	subsMu  sync.Mutex
+	subs    map[int]*subscription
+	nextSub int
 }
 
 func NewRepo(logr *log.Factory) *Repository {
@@ -41,14 +73,12 @@ func NewRepo(logr *log.Factory) *Repository {
 	return &Repository{
 		data: data,
 		logr: logr,
+		subs: make(map[int]*subscription),
 	}
 }
 
 func (r *Repository) Get(ctx context.Context, id string) (p *Product, err error) {
 
-	span, ctx := tracing.NewDBSpanFromContext(ctx)
-	defer span.Finish()
-
 	p, ok := r.data[id]
 
 	if !ok {
@@ -59,11 +89,42 @@ func (r *Repository) Get(ctx context.Context, id string) (p *Product, err error)
 	return
 }
 
+// Ping checks the products can be read, a repository stuck behind a writer times out
+func (r *Repository) Ping(ctx context.Context) error {
+
+	readable := make(chan struct{})
+	go func() {
+		r.RLock()
+		r.RUnlock()
+		close(readable)
+	}()
+
+	select {
+	case <-readable:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// List returns a copy of every product
+func (r *Repository) List(ctx context.Context) (products []Product, err error) {
+
+	tagQuery(ctx, "select uid, name, quantity from products")
+
+	r.RLock()
+	defer r.RUnlock()
+
+	for _, p := range r.data {
+		products = append(products, *p)
+	}
+
+	return
+}
+
 func (r *Repository) Allocate(ctx context.Context, id string, quantity uint64) (err error) {
 
-	span, ctx := tracing.NewDBSpanFromContext(ctx, id, quantity)
-	defer span.Finish()
-	tracing.WrapWithTags(span, "psql", "update set quantity = $1 where uid = $2") // or some update operation
+	tagQuery(ctx, "update set quantity = $1 where uid = $2") // or some update operation
 
 	p, ok := r.data[id]
 
@@ -86,5 +147,108 @@ func (r *Repository) Allocate(ctx context.Context, id string, quantity uint64) (
 
 	p.Quantity -= quantity
 
+	// still under the lock, subscribers see the updates of a product in order
+	r.publish(ctx, StockUpdate{ID: id, Quantity: p.Quantity, Allocated: quantity})
+
 	return
 }
+
+// Subscribe returns the stock updates of the products, of every product when ids is empty,
+// and the function ending the subscription. The channel is closed when the subscription ends.
+func (r *Repository) Subscribe(ids ...string) (<-chan StockUpdate, func()) {
+
+	sub := &subscription{
+		updates: make(chan StockUpdate, subscriptionBuffer),
+	}
+
+	if len(ids) > 0 {
+		sub.ids = make(map[string]bool, len(ids))
+		for _, id := range ids {
+			sub.ids[id] = true
+		}
+	}
+
+	r.subsMu.Lock()
+	key := r.nextSub
+	r.nextSub++
+	r.subs[key] = sub
+	r.subsMu.Unlock()
+
+	var once sync.Once
+
+	unsubscribe := func() {
+		once.Do(func() {
+			r.subsMu.Lock()
+			defer r.subsMu.Unlock()
+			if _, ok := r.subs[key]; ok {
+				delete(r.subs, key)
+				close(sub.updates)
+			}
+		})
+	}
+
+	return sub.updates, unsubscribe
+}
+
+// CloseSubscriptions ends every subscription, so streams watching the stock let the server stop
+func (r *Repository) CloseSubscriptions() {
+
+	r.subsMu.Lock()
+	defer r.subsMu.Unlock()
+
+	for key, sub := range r.subs {
+		delete(r.subs, key)
+		close(sub.updates)
+	}
+}
+
+// publish never blocks an allocation on a slow subscriber
+func (r *Repository) publish(ctx context.Context, update StockUpdate) {
+
+	r.subsMu.Lock()
+	defer r.subsMu.Unlock()
+
+	for _, sub := range r.subs {
+
+		if sub.ids != nil && !sub.ids[update.ID] {
+			continue
+		}
+
+		select {
+		case sub.updates <- update:
+		default:
+			r.logr.For(ctx).Error("stock update dropped, subscriber is too slow", zap.String("id", update.ID))
+		}
+	}
+}
+
+// database the store would query
+const (
+	dbSystem   = "postgresql"
+	dbInstance = "inventory"
+)
+
+// tagQuery tags the span of the store call with the query
+func tagQuery(ctx context.Context, query string) {
+	if span := opentracing.SpanFromContext(ctx); span != nil {
+		semconv.DB(span, dbSystem, query)
+		semconv.DBInstance(span, dbInstance)
+	}
+}
+
+// reportStock returns the stock report job, logging the stock of every product
+func reportStock(store Store, logr *log.Factory) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+
+		products, err := store.List(ctx)
+		if err != nil {
+			return err
+		}
+
+		for _, p := range products {
+			logr.For(ctx).Info("stock report", zap.String("id", p.ID), zap.String("name", p.Name), zap.Uint64("quantity", p.Quantity))
+		}
+
+		return nil
+	}
+}
@@ -5,16 +5,22 @@ import (
 	"github.com/alloykh/tracer-demo/demo/protos/genproto/inventory_service"
 	"github.com/alloykh/tracer-demo/log"
 	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"time"
 
 	"github.com/golang/protobuf/ptypes/empty"
 )
 
+// a stock watch ends after stockWatchWindow, so its spans are finished and reported
+var stockWatchWindow = time.Minute
+
 type Service struct {
 	logr *log.Factory
-	repo *Repository
+	repo Store
 }
 
-func NewService(logr *log.Factory, repo *Repository) *Service {
+func NewService(logr *log.Factory, repo Store) *Service {
 	return &Service{
 		logr: logr,
 		repo: repo,
@@ -31,3 +37,55 @@ func (s *Service) AllocateProduct(ctx context.Context, req *inventory_service.Al
 
 	return
 }
+
+// WatchStock streams the quantity of the requested products, of every product when none is given,
+// after each allocation until the client cancels, the service shuts down or stockWatchWindow is over.
+// The stream spans are reported once finished, the client watches again after the window.
+func (s *Service) WatchStock(req *inventory_service.WatchStockRequest, stream inventory_service.InventoryService_WatchStockServer) error {
+
+	ctx := stream.Context()
+
+	for _, id := range req.Uids {
+		if _, err := s.repo.Get(ctx, id); err != nil {
+			return err
+		}
+	}
+
+	updates, unsubscribe := s.repo.Subscribe(req.Uids...)
+	defer unsubscribe()
+
+	s.logr.For(ctx).Info("stock watch started", zap.Strings("uids", req.Uids))
+
+	window := time.NewTimer(stockWatchWindow)
+	defer window.Stop()
+
+	for {
+		select {
+
+		case <-window.C:
+			s.logr.For(ctx).Info("stock watch ended", zap.String("reason", "window over"))
+			return nil
+
+		case <-ctx.Done():
+			// the client is gone, the tracing interceptor records the cancellation
+			s.logr.For(ctx).Info("stock watch ended", zap.String("reason", ctx.Err().Error()))
+			return nil
+
+		case u, ok := <-updates:
+			if !ok {
+				s.logr.For(ctx).Info("stock watch closed by the service")
+				return status.Error(codes.Unavailable, "inventory service is shutting down")
+			}
+
+			err := stream.Send(&inventory_service.StockUpdate{
+				Uid:       u.ID,
+				Quantity:  u.Quantity,
+				Allocated: uint32(u.Allocated),
+			})
+			if err != nil {
+				s.logr.For(ctx).Error("stock update send", zap.String("err", err.Error()))
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,47 @@
+// Code generated by tracegen -type Store. DO NOT EDIT.
+
+package main
+
+import (
+	"context"
+	"github.com/alloykh/tracer-demo/tracing/decorate"
+)
+
+// tracedStore traces, logs and times the calls to a Store
+type tracedStore struct {
+	next Store
+	dec  *decorate.Decorator
+}
+
+// NewTracedStore decorates next, spans are named Store.Method
+func NewTracedStore(next Store, opts ...decorate.Option) Store {
+	return &tracedStore{
+		next: next,
+		dec:  decorate.New("Store", []string{"Get", "List", "Allocate"}, opts...),
+	}
+}
+
+func (d *tracedStore) Get(ctx context.Context, id string) (r0 *Product, r1 error) {
+	call, ctx := d.dec.Start(ctx, "Get")
+	call.Tag("product.id", id)
+	defer func() { call.Finish(r1) }()
+	return d.next.Get(ctx, id)
+}
+
+func (d *tracedStore) List(ctx context.Context) (r0 []Product, r1 error) {
+	call, ctx := d.dec.Start(ctx, "List")
+	defer func() { call.Finish(r1) }()
+	return d.next.List(ctx)
+}
+
+func (d *tracedStore) Allocate(ctx context.Context, id string, quantity uint64) (r0 error) {
+	call, ctx := d.dec.Start(ctx, "Allocate")
+	call.Tag("product.id", id)
+	call.Tag("product.quantity", quantity)
+	defer func() { call.Finish(r0) }()
+	return d.next.Allocate(ctx, id, quantity)
+}
+
+func (d *tracedStore) Subscribe(ids ...string) (r0 <-chan StockUpdate, r1 func()) {
+	return d.next.Subscribe(ids...)
+}
@@ -5,151 +5,136 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"github.com/alloykh/tracer-demo/app"
 	"github.com/alloykh/tracer-demo/demo/helpers"
 	"github.com/alloykh/tracer-demo/demo/protos/genproto/client_service"
+	"github.com/alloykh/tracer-demo/health"
 	"github.com/alloykh/tracer-demo/remote"
-	"net"
 	"net/http"
 	"os"
-	"os/signal"
 	"time"
 
-	"github.com/pkg/errors"
-
 	"go.uber.org/zap"
-	"go.uber.org/zap/zapcore"
 
 	"github.com/alloykh/tracer-demo/log"
 	"github.com/alloykh/tracer-demo/tracing"
 	"github.com/gin-gonic/gin"
-
-	"github.com/opentracing/opentracing-go"
-	"github.com/uber/jaeger-lib/metrics"
-
-	jprom "github.com/uber/jaeger-lib/metrics/prometheus"
 )
 
 // Front end - service
 
 var serviceName = "frontend"
 
-var host = "localhost"
-var port = 8077
+type serviceConfig struct {
+	app.Config `yaml:",inline"`
 
-var tearDowns []func()
+	ClientService app.ClientConfig `yaml:"client_service"`
+	// OrderService - Target is the URL of the order route
+	OrderService app.ClientConfig `yaml:"order_service"`
 
-func main() {
+	// time the order route has for all its downstream calls, under the write timeout of the server
+	OrderBudget time.Duration `yaml:"order_budget"`
 
-	ctx := getDefaultContext()
-
-	metricsFactory := jprom.New().Namespace(metrics.NSOptions{Name: serviceName, Tags: nil})
-
-	// logger
-	logr := log.NewFactory("zap", zapcore.DebugLevel)
+	Trust trustConfig `yaml:"trust"`
+}
 
-	//	initialize jaeger tracer
-	tracer, tr := tracing.InitJaeger(serviceName, metricsFactory, logr)
-	tearDowns = append(tearDowns, tr)
+// trustConfig - the callers whose trace is continued at the edge
+type trustConfig struct {
+	// callers continuing their trace, the others start a new one
+	Networks []string `yaml:"networks"`
+	// header trusting a caller wherever it comes from, its value must be the secret,
+	// set with FRONTEND_TRUST_SECRET
+	Header string `yaml:"header"`
+	Secret string `yaml:"secret" secret:"true"`
+	// baggage items of untrusted callers kept at the edge
+	UntrustedBaggage []string `yaml:"untrusted_baggage"`
+}
 
-	// Set tracer as global
-	opentracing.SetGlobalTracer(tracer)
+func main() {
 
-	// init grpc clients
-	grpclients, err := NewGRPClients()
-	if err != nil {
-		logr.Default().Fatal("grpc clients init", zap.Any("err", err.Error()))
+	cfg := &serviceConfig{
+		Config: helpers.Config(serviceName),
+		// comma separated addresses are balanced round robin
+		ClientService: app.ClientConfig{Target: "localhost:7050", Timeout: time.Second * 10},
+		OrderService:  app.ClientConfig{Target: "http://localhost:8078/order", Timeout: time.Second * 30},
+		OrderBudget:   time.Second * 4,
+		Trust: trustConfig{
+			Networks:         []string{"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16"},
+			Header:           "X-Trace-Trust",
+			UntrustedBaggage: []string{"session"},
+		},
 	}
+	cfg.HTTP.Addr = "localhost:8077"
+	// admin listener serving the admin pages, /debug/traces, /debug/dependencies and the health probes
+	cfg.AdminAddr = "localhost:7063"
 
-	httpServer := NewServer(host, port, logr, tracer, grpclients)
-
-	err = httpServer.Run()
+	loader := helpers.LoadConfig(serviceName, cfg)
 
+	trust, err := tracing.NewTrustPolicy(
+		tracing.TrustNetworks(cfg.Trust.Networks...),
+		tracing.TrustHeader(cfg.Trust.Header, cfg.Trust.Secret),
+		tracing.TrustBaggage(cfg.Trust.UntrustedBaggage...),
+	)
 	if err != nil {
-		logr.Default().Fatal("http server run", zap.Any("err", err.Error()))
+		fmt.Fprintln(os.Stderr, "trust policy:", err)
+		os.Exit(1)
 	}
 
-	// interruption signal - graceful shutdown
-	<-ctx.Done()
+	opts := append(helpers.Options(serviceName), app.WithHTTPTracing(
+		tracing.MWTrustPolicy(trust),
+		tracing.MWBaggageHeaders(helpers.BaggageHeaders),
+		tracing.MWIdentityBaggage("user", "tenant"),
+		tracing.MWForceSampling(helpers.ForceTraceHeader, helpers.ForceTraceSecret()),
+	), app.WithConfig(loader, cfg))
 
-	ctxShutDown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer func() {
-		cancel()
-	}()
+	app.Main(cfg.Config, func(a *app.App) error {
+		return setup(a, cfg)
+	}, opts...)
+}
 
-	for _, f := range tearDowns {
-		f()
-	}
+func setup(a *app.App, cfg *serviceConfig) error {
 
-	for _, f := range grpclients.TearDowns {
-		f(logr)
+	grpclients, err := NewGRPClients(a, cfg.ClientService)
+	if err != nil {
+		return err
 	}
 
-	if err = httpServer.shutdown(ctxShutDown); err != nil {
-		logr.Default().Error("http server shutdown", zap.Any("err", err.Error()))
+	client := remote.NewClient(a.Logr,
+		remote.WithTimeOut(cfg.OrderService.Timeout),
+		remote.WithMetrics(a.Metrics),
+		remote.WithPeerService("order_service"),
+	)
+	a.Health.Ready("breaker HTTP", health.Breaker(client.Breaker()))
+	a.OnClose("order client", func(ctx context.Context) error {
+		return client.Close()
+	})
+
+	a.OnReload("order_service timeout", func(c app.Configurable) error {
+		client.SetTimeout(c.(*serviceConfig).OrderService.Timeout)
+		return nil
+	})
+
+	s := &server{
+		logr:       a.Logr,
+		grpclients: grpclients,
+		client:     client,
+		orderURL:   cfg.OrderService.Target,
 	}
 
-	logr.Default().Info("graceful shutdown")
+	a.Router.Use(tracing.Deadline(tracing.BudgetRoute(http.MethodGet, "/order", cfg.OrderBudget)))
+	a.Router.GET("/order", s.orderHandler)
 
-	os.Exit(0)
+	return nil
 }
 
 type server struct {
-	router     *gin.Engine
 	logr       *log.Factory
-	serv       *http.Server
 	grpclients *Clients
 
-	client *remote.HTTPService
+	client   *remote.HTTPService
+	orderURL string
 }
 
-func NewServer(host string, port int, logr *log.Factory, tracer opentracing.Tracer, grpclients *Clients) *server {
-
-	ginRouter := gin.New()
-
-	ginRouter.Use(gin.Recovery())
-	ginRouter.Use(tracing.Tracer(tracer))
-
-	serv := &http.Server{
-		Addr:         fmt.Sprintf("%s:%d", host, port),
-		Handler:      ginRouter,
-		ReadTimeout:  time.Second * 7,
-		WriteTimeout: time.Second * 5,
-	}
-
-	return &server{
-		router: ginRouter,
-		logr:   logr,
-		serv:   serv,
-
-		grpclients: grpclients,
-
-		client: remote.NewClient(logr, remote.WithTimeOut(time.Second*30)),
-	}
-}
-
-func (s *server) Run() (err error) {
-
-	s.router.GET("/order", s.orderHandler)
-
-	go func() {
-		if err = s.serv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
-			s.logr.Default().Error("http listen and serve", zap.Any("err", err.Error()))
-		}
-		s.logr.Default().Info("HTTP SERVER SHUTDOWN", zap.Any("OUTCOME", "successful"))
-	}()
-
-	s.logr.Default().Debug("HTTP SERVER RUNNING...", zap.Any("ADDR", s.serv.Addr))
-
-	return
-}
-
-func (s *server) shutdown(ctx context.Context) (err error) {
-	return s.serv.Shutdown(ctx)
-}
-
-var orderServicePort = "8078"
-var endpoint = "order"
-
 type Order struct {
 	ClientUUID  string `json:"client_uuid" binding:"required"`
 	ProductUUID string `json:"product_uuid" binding:"required"`
@@ -177,6 +162,10 @@ func (s *server) orderHandler(c *gin.Context) {
 
 	if err != nil {
 		s.logr.For(ctx).Error("search client call", zap.String("err", err.Error()))
+		if remote.IsTimeout(err) {
+			c.JSON(http.StatusGatewayTimeout, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -185,11 +174,7 @@ func (s *server) orderHandler(c *gin.Context) {
 
 	data, _ := json.Marshal(order)
 
-	hostPort := net.JoinHostPort(host, orderServicePort)
-
-	url := fmt.Sprintf("http://%s/%s", hostPort, endpoint)
-
-	req, err := http.NewRequestWithContext(ctx, "GET", url, bytes.NewReader(data))
+	req, err := http.NewRequestWithContext(ctx, "GET", s.orderURL, bytes.NewReader(data))
 
 	if err != nil {
 		s.logr.For(ctx).Error("order request create", zap.String("err", err.Error()))
@@ -202,6 +187,11 @@ func (s *server) orderHandler(c *gin.Context) {
 
 	err = s.client.Do(ctx, req, &rawResp)
 
+	if remote.IsTimeout(err) {
+		helpers.RespondError(c, http.StatusGatewayTimeout, err.Error())
+		return
+	}
+
 	if err != nil {
 		helpers.RespondError(c, http.StatusInternalServerError, err.Error())
 		return
@@ -222,28 +212,8 @@ func (s *server) orderHandler(c *gin.Context) {
 	}
 
 	// custom spanning
-	span, _ := opentracing.StartSpanFromContext(c.Request.Context(), "handler response c.Json")
-	defer span.Finish()
-
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"result": err.Error()})
-		return
-	}
-
-	c.JSON(http.StatusOK, resp)
-}
-
-func getDefaultContext() context.Context {
-
-	ctx, cancel := context.WithCancel(context.Background())
-
-	closeChan := make(chan os.Signal, 1)
-	signal.Notify(closeChan, os.Interrupt, os.Kill)
-
-	go func() {
-		<-closeChan
-		cancel()
-	}()
-
-	return ctx
+	_ = tracing.Trace(c.Request.Context(), "handler response c.Json", func(ctx context.Context) error {
+		c.JSON(http.StatusOK, resp)
+		return nil
+	})
 }
@@ -3,134 +3,79 @@ package main
 import (
 	"context"
 	"fmt"
+	"github.com/alloykh/tracer-demo/app"
 	"github.com/alloykh/tracer-demo/demo/helpers"
 	"github.com/alloykh/tracer-demo/demo/protos/genproto/inventory_service"
 	"github.com/alloykh/tracer-demo/log"
 	"github.com/alloykh/tracer-demo/tracing"
+	"github.com/alloykh/tracer-demo/tracing/async"
 	"github.com/gin-gonic/gin"
-	"github.com/opentracing/opentracing-go"
-	"github.com/pkg/errors"
-	"github.com/uber/jaeger-lib/metrics"
-	JProm "github.com/uber/jaeger-lib/metrics/prometheus"
 	"go.uber.org/zap"
-	"go.uber.org/zap/zapcore"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 	"net/http"
-	"os"
-	"os/signal"
 	"time"
 )
 
-var tearDowns []func()
-
 var serviceName = "order_service"
-var orderServicePort = 8078
-
-func main() {
-
-	ctx := getDefaultContext()
-
-	metricsFactory := JProm.New().Namespace(metrics.NSOptions{Name: serviceName, Tags: nil})
-
-	// logger
-	logr := log.NewFactory("zap", zapcore.DebugLevel)
 
-	tracer, tr := tracing.InitJaeger(serviceName, metricsFactory, logr)
+type serviceConfig struct {
+	app.Config `yaml:",inline"`
 
-	tearDowns = append(tearDowns, tr)
+	InventoryService app.ClientConfig `yaml:"inventory_service"`
 
-	opentracing.SetGlobalTracer(tracer)
+	// budget of requests coming without one, callers sending tracing.BudgetHeader get theirs
+	DefaultBudget time.Duration `yaml:"default_budget"`
+}
 
-	grpclients, err := NewGRPClients()
+func main() {
 
-	if err != nil {
-		logr.Default().Fatal("grpc clients init", zap.Any("err", err.Error()))
+	cfg := &serviceConfig{
+		Config: helpers.Config(serviceName),
+		// comma separated addresses are balanced round robin
+		InventoryService: app.ClientConfig{Target: "localhost:7051", Timeout: time.Second * 10},
+		DefaultBudget:    time.Second * 3,
 	}
+	cfg.HTTP.Addr = "localhost:8078"
+	// admin listener serving the admin pages, /debug/traces, /debug/dependencies and the health probes
+	cfg.AdminAddr = "localhost:7062"
 
-	httpServer := NewServer("localhost", orderServicePort, logr, tracer, grpclients)
-
-	err = httpServer.Run()
-
-	if err != nil {
-		logr.Default().Fatal("http server run", zap.Any("err", err.Error()))
-	}
+	loader := helpers.LoadConfig(serviceName, cfg)
 
-	// interruption signal - graceful shutdown
-	<-ctx.Done()
+	app.Main(cfg.Config, func(a *app.App) error {
+		return setup(a, cfg)
+	}, append(helpers.Options(serviceName), app.WithConfig(loader, cfg))...)
+}
 
-	ctxShutDown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer func() {
-		cancel()
-	}()
+func setup(a *app.App, cfg *serviceConfig) error {
 
-	for _, f := range tearDowns {
-		f()
+	grpclients, err := NewGRPClients(a, cfg.InventoryService)
+	if err != nil {
+		return err
 	}
 
-	for _, f := range grpclients.TearDowns {
-		f(logr)
-	}
+	a.Go("stock watch", func(ctx context.Context) error {
+		watchStock(ctx, a.Logr, grpclients.InventoryClient)
+		return nil
+	})
 
-	if err = httpServer.shutdown(ctxShutDown); err != nil {
-		logr.Default().Error("http server shutdown", zap.Any("err", err.Error()))
+	s := &server{
+		logr:       a.Logr,
+		grpclients: grpclients,
 	}
 
-	logr.Default().Info("graceful shutdown")
+	a.Router.Use(tracing.Deadline(tracing.BudgetDefault(cfg.DefaultBudget)))
+	a.Router.GET("/order", s.orderHandler)
 
-	os.Exit(0)
+	return nil
 }
 
 type server struct {
-	router *gin.Engine
-	logr   *log.Factory
-	serv   *http.Server
+	logr *log.Factory
 
 	grpclients *Clients
 }
 
-func NewServer(host string, port int, logr *log.Factory, tracer opentracing.Tracer, grpclients *Clients) *server {
-
-	ginRouter := gin.New()
-
-	ginRouter.Use(gin.Recovery())
-	ginRouter.Use(tracing.Tracer(tracer))
-
-	serv := &http.Server{
-		Addr:         fmt.Sprintf("%s:%d", host, port),
-		Handler:      ginRouter,
-		ReadTimeout:  time.Second * 7,
-		WriteTimeout: time.Second * 5,
-	}
-
-	return &server{
-		router:     ginRouter,
-		logr:       logr,
-		serv:       serv,
-		grpclients: grpclients,
-	}
-}
-
-func (s *server) Run() (err error) {
-
-	s.router.GET("/order", s.orderHandler)
-
-	go func() {
-		if err = s.serv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
-			s.logr.Default().Error("http listen and serve", zap.Any("err", err.Error()))
-		}
-		s.logr.Default().Info("HTTP SERVER SHUTDOWN", zap.Any("OUTCOME", "successful"))
-	}()
-
-	s.logr.Default().Debug("HTTP SERVER RUNNING...", zap.Any("ADDR", s.serv.Addr))
-
-	return
-}
-
-func (s *server) shutdown(ctx context.Context) (err error) {
-	return s.serv.Shutdown(ctx)
-}
-
 type orderResponse struct {
 	OrderUID string `json:"order_uid"`
 }
@@ -160,6 +105,8 @@ func (s *server) orderHandler(c *gin.Context) {
 		sts := status.Convert(err)
 
 		switch sts.Code() {
+		case codes.DeadlineExceeded:
+			helpers.RespondError(c, http.StatusGatewayTimeout, sts.Message())
 		case codes.InvalidArgument:
 			helpers.RespondError(c, http.StatusBadRequest, sts.Message())
 		case codes.NotFound:
@@ -173,23 +120,13 @@ func (s *server) orderHandler(c *gin.Context) {
 
 	s.logr.For(ctx).Debug("to order request", zap.Any("input", m))
 
+	// the confirmation is sent once the client has its answer, in the same trace
+	async.Go(ctx, "send order confirmation", func(ctx context.Context) error {
+		s.logr.For(ctx).Info("order confirmation sent", zap.String("client", m.ClientUUID), zap.String("product", m.ProductUUID))
+		return nil
+	}, async.WithLogger(s.logr))
+
 	helpers.RespondOK(c, &orderResponse{
 		OrderUID: "uid-order",
 	})
 }
-
-func getDefaultContext() context.Context {
-
-	ctx, cancel := context.WithCancel(context.Background())
-
-	closeChan := make(chan os.Signal, 1)
-
-	signal.Notify(closeChan, os.Interrupt, os.Kill)
-
-	go func() {
-		<-closeChan
-		cancel()
-	}()
-
-	return ctx
-}
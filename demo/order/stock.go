@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/alloykh/tracer-demo/demo/protos/genproto/inventory_service"
+	"github.com/alloykh/tracer-demo/log"
+	"github.com/alloykh/tracer-demo/tracing"
+	"github.com/opentracing/opentracing-go"
+	"go.uber.org/zap"
+)
+
+// products watched on the inventory service, every product when empty
+var watchedProducts []string
+
+// quantity under which a stock update is logged as low stock
+var lowStockThreshold uint64 = 10
+
+// wait before watching again once a watch failed
+var stockWatchRetry = time.Second * 5
+
+// watchStock follows the stock of the inventory service until ctx is done. The inventory service ends
+// a watch after a while and the next one starts: every watch is a trace of its own, every update a span
+// following from the span of its watch.
+func watchStock(ctx context.Context, logr *log.Factory, client inventory_service.InventoryServiceClient) {
+
+	for {
+		err := watchStockOnce(ctx, logr, client)
+		if err == nil {
+			if ctx.Err() != nil {
+				return
+			}
+			// the watch ended, the next one starts right away
+			continue
+		}
+
+		logr.Default().Error("stock watch", zap.String("err", err.Error()))
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(stockWatchRetry):
+		}
+	}
+}
+
+func watchStockOnce(ctx context.Context, logr *log.Factory, client inventory_service.InventoryServiceClient) (err error) {
+
+	span, ctx := tracing.StartSpan(ctx, "WatchStock")
+	defer tracing.FinishSpan(span, &err)
+
+	stream, err := client.WatchStock(ctx, &inventory_service.WatchStockRequest{Uids: watchedProducts})
+	if err != nil {
+		return err
+	}
+
+	logr.For(ctx).Info("watching stock", zap.Strings("uids", watchedProducts))
+
+	for {
+		update, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				// shutting down
+				return nil
+			}
+			return err
+		}
+
+		stockUpdate(span, logr, update)
+	}
+}
+
+// stockUpdate logs an update on a span of its own, the span of the watch lives as long as the watch
+func stockUpdate(watch opentracing.Span, logr *log.Factory, update *inventory_service.StockUpdate) {
+
+	span, ctx := tracing.StartSpan(context.Background(), "StockUpdate", tracing.SpanFollowsFrom(watch))
+	defer span.Finish()
+
+	fields := []zap.Field{
+		zap.String("uid", update.Uid),
+		zap.Uint64("quantity", update.Quantity),
+		zap.Uint32("allocated", update.Allocated),
+	}
+
+	if update.Quantity < lowStockThreshold {
+		logr.For(ctx).Info("low stock", fields...)
+	} else {
+		logr.For(ctx).Info("stock update", fields...)
+	}
+}
@@ -20,6 +20,7 @@ const _ = grpc.SupportPackageIsVersion7
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type InventoryServiceClient interface {
 	AllocateProduct(ctx context.Context, in *AllocProductRequest, opts ...grpc.CallOption) (*empty.Empty, error)
+	WatchStock(ctx context.Context, in *WatchStockRequest, opts ...grpc.CallOption) (InventoryService_WatchStockClient, error)
 }
 
 type inventoryServiceClient struct {
@@ -39,11 +40,44 @@ func (c *inventoryServiceClient) AllocateProduct(ctx context.Context, in *AllocP
 	return out, nil
 }
 
+func (c *inventoryServiceClient) WatchStock(ctx context.Context, in *WatchStockRequest, opts ...grpc.CallOption) (InventoryService_WatchStockClient, error) {
+	stream, err := c.cc.NewStream(ctx, &InventoryService_ServiceDesc.Streams[0], "/protos.InventoryService/WatchStock", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &inventoryServiceWatchStockClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type InventoryService_WatchStockClient interface {
+	Recv() (*StockUpdate, error)
+	grpc.ClientStream
+}
+
+type inventoryServiceWatchStockClient struct {
+	grpc.ClientStream
+}
+
+func (x *inventoryServiceWatchStockClient) Recv() (*StockUpdate, error) {
+	m := new(StockUpdate)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 // InventoryServiceServer is the server API for InventoryService service.
 // All implementations should embed UnimplementedInventoryServiceServer
 // for forward compatibility
 type InventoryServiceServer interface {
 	AllocateProduct(context.Context, *AllocProductRequest) (*empty.Empty, error)
+	WatchStock(*WatchStockRequest, InventoryService_WatchStockServer) error
 }
 
 // UnimplementedInventoryServiceServer should be embedded to have forward compatible implementations.
@@ -53,6 +87,9 @@ type UnimplementedInventoryServiceServer struct {
 func (UnimplementedInventoryServiceServer) AllocateProduct(context.Context, *AllocProductRequest) (*empty.Empty, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method AllocateProduct not implemented")
 }
+func (UnimplementedInventoryServiceServer) WatchStock(*WatchStockRequest, InventoryService_WatchStockServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchStock not implemented")
+}
 
 // UnsafeInventoryServiceServer may be embedded to opt out of forward compatibility for this service.
 // Use of this interface is not recommended, as added methods to InventoryServiceServer will
@@ -83,6 +120,27 @@ func _InventoryService_AllocateProduct_Handler(srv interface{}, ctx context.Cont
 	return interceptor(ctx, in, info, handler)
 }
 
+func _InventoryService_WatchStock_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchStockRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(InventoryServiceServer).WatchStock(m, &inventoryServiceWatchStockServer{stream})
+}
+
+type InventoryService_WatchStockServer interface {
+	Send(*StockUpdate) error
+	grpc.ServerStream
+}
+
+type inventoryServiceWatchStockServer struct {
+	grpc.ServerStream
+}
+
+func (x *inventoryServiceWatchStockServer) Send(m *StockUpdate) error {
+	return x.ServerStream.SendMsg(m)
+}
+
 // InventoryService_ServiceDesc is the grpc.ServiceDesc for InventoryService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -95,6 +153,12 @@ var InventoryService_ServiceDesc = grpc.ServiceDesc{
 			Handler:    _InventoryService_AllocateProduct_Handler,
 		},
 	},
-	Streams:  []grpc.StreamDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchStock",
+			Handler:       _InventoryService_WatchStock_Handler,
+			ServerStreams: true,
+		},
+	},
 	Metadata: "inventory_service_protos/service.proto",
 }
@@ -0,0 +1,62 @@
+package health
+
+import (
+	"context"
+
+	"github.com/alloykh/tracer-demo/tracing"
+	"github.com/pkg/errors"
+	"github.com/sony/gobreaker"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+)
+
+// ReporterQueue fails when the queue of the span reporter is filled above ratio (0 to 1),
+// spans are about to be dropped: the agent or the collector does not keep up
+func ReporterQueue(stats *tracing.ReporterStats, ratio float64) Check {
+	return func(ctx context.Context) error {
+
+		size := stats.QueueSize()
+		if size <= 0 {
+			return nil
+		}
+
+		length := stats.QueueLength()
+		if float64(length) > ratio*float64(size) {
+			return errors.Errorf("reporter queue saturated: %d/%d spans, %d dropped", length, size, stats.Dropped())
+		}
+
+		return nil
+	}
+}
+
+// GRPCConn fails while the connection is in transient failure or shut down,
+// an idle connection is fine: it connects on the next call
+func GRPCConn(conn *grpc.ClientConn) Check {
+	return func(ctx context.Context) error {
+
+		switch state := conn.GetState(); state {
+		case connectivity.TransientFailure, connectivity.Shutdown:
+			return errors.Errorf("connection %s", state)
+		}
+
+		return nil
+	}
+}
+
+// CircuitBreaker is a gobreaker.CircuitBreaker or a remote.Breaker
+type CircuitBreaker interface {
+	Name() string
+	State() gobreaker.State
+}
+
+// Breaker fails while the circuit breaker is open, the calls it guards are rejected
+func Breaker(cb CircuitBreaker) Check {
+	return func(ctx context.Context) error {
+
+		if state := cb.State(); state == gobreaker.StateOpen {
+			return errors.Errorf("circuit breaker %s %s", cb.Name(), state)
+		}
+
+		return nil
+	}
+}
@@ -0,0 +1,103 @@
+package health
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// interval between two readiness runs of a Watch stream
+var defaultWatchInterval = time.Second * 5
+
+// grpcServer implements grpc.health.v1 on the readiness of Health: the service "", the whole server,
+// and every service registered on it are serving when the service is ready
+type grpcServer struct {
+	health   *Health
+	services map[string]bool
+	interval time.Duration
+}
+
+// RegisterGRPC registers the grpc.health.v1 service on s, after the other services.
+// Watch streams end once Health shuts down, after sending NOT_SERVING, so they do not hold the graceful stop.
+func RegisterGRPC(s *grpc.Server, h *Health) {
+
+	srv := &grpcServer{
+		health:   h,
+		services: map[string]bool{"": true},
+		interval: defaultWatchInterval,
+	}
+
+	for name := range s.GetServiceInfo() {
+		srv.services[name] = true
+	}
+
+	healthpb.RegisterHealthServer(s, srv)
+}
+
+func (s *grpcServer) status(ctx context.Context) healthpb.HealthCheckResponse_ServingStatus {
+	if s.health.Readiness(ctx).OK() {
+		return healthpb.HealthCheckResponse_SERVING
+	}
+	return healthpb.HealthCheckResponse_NOT_SERVING
+}
+
+func (s *grpcServer) Check(ctx context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+
+	if !s.services[req.GetService()] {
+		return nil, status.Errorf(codes.NotFound, "unknown service %q", req.GetService())
+	}
+
+	return &healthpb.HealthCheckResponse{Status: s.status(ctx)}, nil
+}
+
+func (s *grpcServer) Watch(req *healthpb.HealthCheckRequest, stream healthpb.Health_WatchServer) error {
+
+	ctx := stream.Context()
+
+	current := healthpb.HealthCheckResponse_SERVICE_UNKNOWN
+	if s.services[req.GetService()] {
+		current = s.status(ctx)
+	}
+
+	if err := stream.Send(&healthpb.HealthCheckResponse{Status: current}); err != nil {
+		return err
+	}
+
+	if current == healthpb.HealthCheckResponse_SERVICE_UNKNOWN {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-s.health.shutdown:
+			return stream.Send(&healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_NOT_SERVING})
+		case <-ticker.C:
+		}
+
+		next := s.status(ctx)
+		if next == current {
+			continue
+		}
+		current = next
+
+		if err := stream.Send(&healthpb.HealthCheckResponse{Status: current}); err != nil {
+			return err
+		}
+	}
+}
+
+// SkipGRPC is a filter for grpctrace.WithFilter, the health calls are not traced
+func SkipGRPC(_ context.Context, fullMethod string) bool {
+	return !strings.HasPrefix(fullMethod, "/"+healthpb.Health_ServiceDesc.ServiceName+"/")
+}
@@ -0,0 +1,244 @@
+// Package health runs the named checks of a service for its liveness and readiness probes,
+// served over HTTP (/healthz, /readyz) and through the standard grpc.health.v1 service.
+// Readiness fails once the graceful shutdown started, so the traffic goes elsewhere while it drains.
+package health
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/alloykh/tracer-demo/log"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// statuses of a Report and of its checks
+const (
+	StatusOK           = "ok"
+	StatusFail         = "fail"
+	StatusShuttingDown = "shutting down"
+)
+
+// ErrShuttingDown fails the readiness during the graceful shutdown
+var ErrShuttingDown = errors.New("shutting down")
+
+var defaultTimeout = time.Second * 2
+
+// Check reports the health of a dependency, nil when it is healthy
+type Check func(ctx context.Context) error
+
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+// Health holds the checks of a service.
+type Health struct {
+	timeout time.Duration
+	logr    *log.Factory
+
+	mu     sync.RWMutex
+	live   []namedCheck
+	ready  []namedCheck
+	status map[string]string
+
+	shuttingDown int32
+	shutdown     chan struct{}
+}
+
+// Option controls the behavior of Health.
+type Option func(*Health)
+
+// WithTimeout sets the time given to each check, 2s by default
+func WithTimeout(timeout time.Duration) Option {
+	return func(h *Health) {
+		if timeout > 0 {
+			h.timeout = timeout
+		}
+	}
+}
+
+// WithLogger logs the status changes of the probes with the failed checks
+func WithLogger(logr *log.Factory) Option {
+	return func(h *Health) {
+		h.logr = logr
+	}
+}
+
+// New returns the health of a service without checks: live and ready
+func New(opts ...Option) *Health {
+
+	h := &Health{
+		timeout:  defaultTimeout,
+		status:   make(map[string]string),
+		shutdown: make(chan struct{}),
+	}
+
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	return h
+}
+
+// Live adds a liveness check, failing it means the process should be restarted
+func (h *Health) Live(name string, check Check) {
+	h.mu.Lock()
+	h.live = append(h.live, namedCheck{name: name, check: check})
+	h.mu.Unlock()
+}
+
+// Ready adds a readiness check, failing it means the service should not get traffic for now
+func (h *Health) Ready(name string, check Check) {
+	h.mu.Lock()
+	h.ready = append(h.ready, namedCheck{name: name, check: check})
+	h.mu.Unlock()
+}
+
+// Shutdown fails the readiness from now on, call it first thing in the graceful shutdown
+func (h *Health) Shutdown() {
+	if atomic.CompareAndSwapInt32(&h.shuttingDown, 0, 1) {
+		close(h.shutdown)
+		if h.logr != nil {
+			h.logr.Default().Info("not ready: shutting down")
+		}
+	}
+}
+
+// ShuttingDown reports whether Shutdown was called
+func (h *Health) ShuttingDown() bool {
+	return atomic.LoadInt32(&h.shuttingDown) == 1
+}
+
+// Result is the outcome of a check
+type Result struct {
+	Name     string  `json:"name"`
+	Status   string  `json:"status"`
+	Error    string  `json:"error,omitempty"`
+	Duration float64 `json:"duration_ms"`
+}
+
+// Report is the outcome of the checks of a probe
+type Report struct {
+	Status string   `json:"status"`
+	Checks []Result `json:"checks,omitempty"`
+}
+
+// OK reports whether the probe succeeded
+func (r Report) OK() bool {
+	return r.Status == StatusOK
+}
+
+// Liveness runs the liveness checks
+func (h *Health) Liveness(ctx context.Context) Report {
+
+	h.mu.RLock()
+	checks := h.live
+	h.mu.RUnlock()
+
+	return h.run(ctx, "liveness", checks)
+}
+
+// Readiness runs the readiness checks, it fails without running them during the shutdown
+func (h *Health) Readiness(ctx context.Context) Report {
+
+	if h.ShuttingDown() {
+		return Report{Status: StatusShuttingDown}
+	}
+
+	h.mu.RLock()
+	checks := h.ready
+	h.mu.RUnlock()
+
+	return h.run(ctx, "readiness", checks)
+}
+
+// run runs the checks concurrently, each with the timeout
+func (h *Health) run(ctx context.Context, probe string, checks []namedCheck) Report {
+
+	report := Report{Status: StatusOK, Checks: make([]Result, len(checks))}
+
+	var wg sync.WaitGroup
+
+	for i, c := range checks {
+		wg.Add(1)
+		go func(i int, c namedCheck) {
+			defer wg.Done()
+			report.Checks[i] = h.check(ctx, c)
+		}(i, c)
+	}
+
+	wg.Wait()
+
+	for _, res := range report.Checks {
+		if res.Status != StatusOK {
+			report.Status = StatusFail
+		}
+	}
+
+	h.logChange(probe, report)
+
+	return report
+}
+
+// logChange logs the report when the status of the probe changed, the first report only if it failed
+func (h *Health) logChange(probe string, report Report) {
+
+	if h.logr == nil {
+		return
+	}
+
+	h.mu.Lock()
+	last, ok := h.status[probe]
+	h.status[probe] = report.Status
+	h.mu.Unlock()
+
+	if last == report.Status || (!ok && report.OK()) {
+		return
+	}
+
+	if report.OK() {
+		h.logr.Default().Info("health probe ok", zap.String("probe", probe))
+		return
+	}
+
+	for _, res := range report.Checks {
+		if res.Status != StatusOK {
+			h.logr.Default().Error("health check failed", zap.String("probe", probe), zap.String("check", res.Name), zap.String("err", res.Error))
+		}
+	}
+}
+
+func (h *Health) check(ctx context.Context, c namedCheck) Result {
+
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	start := time.Now()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- c.check(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = errors.Wrap(ctx.Err(), "check")
+	}
+
+	res := Result{
+		Name:     c.name,
+		Status:   StatusOK,
+		Duration: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		res.Status = StatusFail
+		res.Error = err.Error()
+	}
+
+	return res
+}
@@ -0,0 +1,134 @@
+package health_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alloykh/tracer-demo/health"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestHTTPProbes(t *testing.T) {
+
+	h := health.New(health.WithTimeout(50 * time.Millisecond))
+
+	var stock error
+	h.Ready("stock", func(ctx context.Context) error { return stock })
+	h.Ready("slow", func(ctx context.Context) error {
+		<-ctx.Done()
+		return nil
+	})
+
+	mux := http.NewServeMux()
+	health.Mount(mux, h)
+
+	probe := func(path string) (int, health.Report) {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+
+		var report health.Report
+		if err := json.NewDecoder(rec.Body).Decode(&report); err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		return rec.Code, report
+	}
+
+	// the slow check times out
+	if code, report := probe(health.ReadyPath); code != http.StatusServiceUnavailable || len(report.Checks) != 2 || report.Checks[1].Status != health.StatusFail {
+		t.Errorf("ready with a slow check: %d %+v, want 503 and the slow check failed", code, report)
+	}
+
+	h = health.New()
+	h.Ready("stock", func(ctx context.Context) error { return stock })
+	mux = http.NewServeMux()
+	health.Mount(mux, h)
+
+	if code, report := probe(health.ReadyPath); code != http.StatusOK || report.Status != health.StatusOK {
+		t.Errorf("ready: %d %+v, want 200", code, report)
+	}
+
+	stock = errors.New("connection refused")
+	if code, report := probe(health.ReadyPath); code != http.StatusServiceUnavailable || report.Checks[0].Error != "connection refused" {
+		t.Errorf("ready with a failed check: %d %+v, want 503 and the error", code, report)
+	}
+
+	stock = nil
+	h.Shutdown()
+
+	if code, report := probe(health.ReadyPath); code != http.StatusServiceUnavailable || report.Status != health.StatusShuttingDown {
+		t.Errorf("ready after shutdown: %d %+v, want 503 shutting down", code, report)
+	}
+	if code, _ := probe(health.LivePath); code != http.StatusOK {
+		t.Errorf("live after shutdown: %d, want 200", code)
+	}
+}
+
+func TestGRPCWatchEndsOnShutdown(t *testing.T) {
+
+	h := health.New()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := grpc.NewServer()
+	health.RegisterGRPC(srv, h)
+	go func() {
+		_ = srv.Serve(lis)
+	}()
+	defer srv.Stop()
+
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	client := healthpb.NewHealthClient(conn)
+
+	stream, err := client.Watch(ctx, &healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recv := func() healthpb.HealthCheckResponse_ServingStatus {
+		resp, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("watch: %v", err)
+		}
+		return resp.GetStatus()
+	}
+
+	if status := recv(); status != healthpb.HealthCheckResponse_SERVING {
+		t.Fatalf("watch: %s, want SERVING", status)
+	}
+
+	h.Shutdown()
+
+	if status := recv(); status != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Errorf("watch after shutdown: %s, want NOT_SERVING", status)
+	}
+	if _, err := stream.Recv(); err != io.EOF {
+		t.Errorf("watch after shutdown: %v, want the end of the stream", err)
+	}
+
+	resp, err := client.Check(ctx, &healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.GetStatus() != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Errorf("check after shutdown: %s, want NOT_SERVING", resp.GetStatus())
+	}
+}
@@ -0,0 +1,49 @@
+package health
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// default mount points of the probes
+const (
+	LivePath  = "/healthz"
+	ReadyPath = "/readyz"
+)
+
+// LiveHandler serves the liveness report as JSON, 200 when live, 503 otherwise
+func LiveHandler(h *Health) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeReport(w, h.Liveness(r.Context()))
+	})
+}
+
+// ReadyHandler serves the readiness report as JSON, 200 when ready, 503 otherwise
+func ReadyHandler(h *Health) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeReport(w, h.Readiness(r.Context()))
+	})
+}
+
+func writeReport(w http.ResponseWriter, report Report) {
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+
+	if !report.OK() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+
+	_ = json.NewEncoder(w).Encode(report)
+}
+
+// Mount registers the probes on LivePath and ReadyPath
+func Mount(mux *http.ServeMux, h *Health) {
+	mux.Handle(LivePath, LiveHandler(h))
+	mux.Handle(ReadyPath, ReadyHandler(h))
+}
+
+// SkipProbes is a span filter for tracing.MWSpanFilter, the probes are not traced
+func SkipProbes(r *http.Request) bool {
+	return r.URL.Path != LivePath && r.URL.Path != ReadyPath
+}
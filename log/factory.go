@@ -6,20 +6,41 @@ import (
 	"github.com/uber/jaeger-client-go"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
+	"net/http"
 )
 
 type Factory struct {
 	logger Logger
 	tr     func()
+	level  zap.AtomicLevel
+
+	baggageKeys []string
+}
+
+// FactoryOption controls the behavior of the Factory.
+type FactoryOption func(*Factory)
+
+// WithBaggageFields returns a FactoryOption adding the baggage items of keys, as baggage.<key>,
+// to the fields of the loggers returned by For
+func WithBaggageFields(keys ...string) FactoryOption {
+	return func(f *Factory) {
+		f.baggageKeys = append(f.baggageKeys, keys...)
+	}
 }
 
-func NewFactory(name string, level zapcore.Level) *Factory {
+func NewFactory(name string, level zapcore.Level, opts ...FactoryOption) *Factory {
 	// for now, zap log should be enough
-	logger, tr := NewZapLogger(level)
-	return &Factory{
+	atomicLevel := zap.NewAtomicLevelAt(level)
+	logger, tr := newZapLogger(atomicLevel)
+	f := &Factory{
 		logger: logger,
 		tr:     tr,
+		level:  atomicLevel,
 	}
+	for _, opt := range opts {
+		opt(f)
+	}
+	return f
 }
 
 func (f *Factory) Default() Logger {
@@ -42,6 +63,12 @@ func (f Factory) For(ctx context.Context) Logger {
 			}
 		}
 
+		for _, key := range f.baggageKeys {
+			if v := span.BaggageItem(key); v != "" {
+				logger.spanFields = append(logger.spanFields, zap.String("baggage."+key, v))
+			}
+		}
+
 		return logger
 	}
 	return f.Default()
@@ -49,5 +76,27 @@ func (f Factory) For(ctx context.Context) Logger {
 
 // With creates a child logger, and optionally adds some context fields to that logger.
 func (f Factory) With(fields ...zapcore.Field) Factory {
-	return Factory{logger: f.logger.With(fields...)}
+	return Factory{logger: f.logger.With(fields...), level: f.level, baggageKeys: f.baggageKeys}
+}
+
+// Level returns the minimum level of the entries logged
+func (f *Factory) Level() zapcore.Level {
+	return f.level.Level()
+}
+
+// LevelHandler serves the level as JSON, {"level":"info"}, and changes it on PUT, see zap.AtomicLevel
+func (f *Factory) LevelHandler() http.Handler {
+	return f.level
+}
+
+// SetLevel changes the minimum level of the entries logged, by all the loggers of the factory
+func (f *Factory) SetLevel(level zapcore.Level) {
+	f.level.SetLevel(level)
+}
+
+// Sync flushes the buffered log entries, call it before the process exits
+func (f *Factory) Sync() {
+	if f.tr != nil {
+		f.tr()
+	}
 }
@@ -8,13 +8,18 @@ import (
 )
 
 func NewZapLogger(level zapcore.Level) (*zapLogger, func()) {
+	return newZapLogger(zap.NewAtomicLevelAt(level))
+}
+
+// newZapLogger - the entries below level are dropped, the level can change while the logger is in use
+func newZapLogger(level zap.AtomicLevel) (*zapLogger, func()) {
 
 	// determine log priority
 	highPriority := zap.LevelEnablerFunc(func(lvl zapcore.Level) bool {
-		return lvl >= zapcore.ErrorLevel
+		return lvl >= zapcore.ErrorLevel && level.Enabled(lvl)
 	})
 	lowPriority := zap.LevelEnablerFunc(func(lvl zapcore.Level) bool {
-		return lvl < zapcore.ErrorLevel
+		return lvl < zapcore.ErrorLevel && level.Enabled(lvl)
 	})
 
 	// High-priority output should also go to standard error, and low-priority
@@ -0,0 +1,108 @@
+package remote
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/alloykh/tracer-demo/log"
+	"github.com/sony/gobreaker"
+	"go.uber.org/zap"
+)
+
+// Breaker is the circuit breaker of a client, it can be reset by hand, e.g. from the admin server
+type Breaker struct {
+	name     string
+	settings gobreaker.Settings
+	logr     *log.Factory
+
+	// *gobreaker.CircuitBreaker, replaced on reset
+	cb atomic.Value
+	// unix nano of the last change of state
+	changed int64
+}
+
+// breakers of the clients alive, for Breakers
+var breakers = struct {
+	sync.Mutex
+	all map[*Breaker]struct{}
+}{all: make(map[*Breaker]struct{})}
+
+func newBreaker(logr *log.Factory, name string) *Breaker {
+
+	b := &Breaker{
+		name:     name,
+		settings: breakerSettings(logr, name),
+		logr:     logr,
+		changed:  time.Now().UnixNano(),
+	}
+
+	onStateChange := b.settings.OnStateChange
+	b.settings.OnStateChange = func(name string, from gobreaker.State, to gobreaker.State) {
+		atomic.StoreInt64(&b.changed, time.Now().UnixNano())
+		onStateChange(name, from, to)
+	}
+
+	b.cb.Store(gobreaker.NewCircuitBreaker(b.settings))
+
+	breakers.Lock()
+	breakers.all[b] = struct{}{}
+	breakers.Unlock()
+
+	return b
+}
+
+// Breakers returns the circuit breakers of the clients, sorted by name
+func Breakers() []*Breaker {
+
+	breakers.Lock()
+	out := make([]*Breaker, 0, len(breakers.all))
+	for b := range breakers.all {
+		out = append(out, b)
+	}
+	breakers.Unlock()
+
+	sort.Slice(out, func(i, j int) bool { return out[i].name < out[j].name })
+
+	return out
+}
+
+func (b *Breaker) current() *gobreaker.CircuitBreaker {
+	return b.cb.Load().(*gobreaker.CircuitBreaker)
+}
+
+// Name returns the name of the breaker, HTTP or gRPC <service>
+func (b *Breaker) Name() string {
+	return b.name
+}
+
+// State returns the state of the breaker
+func (b *Breaker) State() gobreaker.State {
+	return b.current().State()
+}
+
+// Changed returns the time of the last change of state, or of the creation of the breaker
+func (b *Breaker) Changed() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&b.changed))
+}
+
+// Execute runs fn unless the breaker is open, see gobreaker.CircuitBreaker
+func (b *Breaker) Execute(fn func() (interface{}, error)) (interface{}, error) {
+	return b.current().Execute(fn)
+}
+
+// Reset closes the breaker and clears its counts, the calls in flight count for the breaker replaced
+func (b *Breaker) Reset() {
+	from := b.State()
+	b.cb.Store(gobreaker.NewCircuitBreaker(b.settings))
+	atomic.StoreInt64(&b.changed, time.Now().UnixNano())
+	b.logr.Default().Info("circuit breaker reset", zap.String("name", b.name), zap.String("from", from.String()))
+}
+
+// close forgets the breaker, its client is closed
+func (b *Breaker) close() {
+	breakers.Lock()
+	delete(breakers.all, b)
+	breakers.Unlock()
+}
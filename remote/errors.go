@@ -1,13 +1,17 @@
 package remote
 
 import (
+	"context"
 	"fmt"
 	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 var (
 	ErrConnectionFailed       = errors.New("could not connect to service")
 	ErrUnexpectedResponseData = errors.New("service returned unexpected data")
+	ErrBudgetExhausted        = errors.New("request budget exhausted")
 )
 
 type Error struct {
@@ -20,3 +24,14 @@ func (e Error) Error() string {
 }
 
 func (e Error) Unwrap() error { return e.Err }
+
+// IsTimeout reports whether err comes from a deadline: a call skipped or given up for lack of budget,
+// a gRPC DeadlineExceeded or an expired context. Handlers answer those with 504.
+func IsTimeout(err error) bool {
+
+	if errors.Is(err, ErrBudgetExhausted) || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	return status.Code(err) == codes.DeadlineExceeded
+}
@@ -0,0 +1,356 @@
+package remote
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/alloykh/tracer-demo/log"
+	"github.com/alloykh/tracer-demo/tracing"
+	"github.com/alloykh/tracer-demo/tracing/grpctrace"
+	grpcRetry "github.com/grpc-ecosystem/go-grpc-middleware/retry"
+	"github.com/pkg/errors"
+	"github.com/sony/gobreaker"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	defaultGRPCCallTimeout = time.Second * 10
+	defaultGRPCMaxAttempts = uint(3)
+
+	// transient codes only: the request did not reach the handler or the server asked to back off.
+	// NotFound, InvalidArgument and the like are answers and retrying them changes nothing.
+	defaultGRPCRetryCodes = []codes.Code{codes.Unavailable, codes.ResourceExhausted}
+
+	// codes counted as failures by the circuit breaker, the server or the network is in trouble
+	breakerCodes = map[codes.Code]bool{
+		codes.Unavailable:       true,
+		codes.DeadlineExceeded:  true,
+		codes.Internal:          true,
+		codes.Unknown:           true,
+		codes.ResourceExhausted: true,
+	}
+
+	defaultKeepalive = keepalive.ClientParameters{
+		Time:                time.Second * 30,
+		Timeout:             time.Second * 10,
+		PermitWithoutStream: true,
+	}
+)
+
+// GRPCConn - traced client connection with retries, circuit breaking and keepalive.
+// Generated clients accept it as is: inventory_service.NewInventoryServiceClient(conn).
+type GRPCConn struct {
+	*grpc.ClientConn
+
+	name string
+	logr *log.Factory
+	cb   *Breaker
+
+	// callTimeout in nanoseconds, read on every call
+	callTimeout int64
+
+	stopWatch context.CancelFunc
+}
+
+type grpcOptions struct {
+	callTimeout time.Duration
+	maxAttempts uint
+	retryCodes  []codes.Code
+	backoff     grpcRetry.BackoffFunc
+	keepalive   keepalive.ClientParameters
+	creds       credentials.TransportCredentials
+	traceOpts   []grpctrace.Option
+	dialOpts    []grpc.DialOption
+}
+
+// GRPCOption controls the behavior of DialGRPC.
+type GRPCOption func(*grpcOptions)
+
+// WithGRPCCallTimeout sets the deadline of unary calls without an earlier one, 10s by default, 0 disables it
+func WithGRPCCallTimeout(timeOut time.Duration) GRPCOption {
+	return func(o *grpcOptions) {
+		o.callTimeout = timeOut
+	}
+}
+
+// WithGRPCRetry sets the attempts of a call, the first one included (3 by default, 1 disables retries)
+// and the retried codes (Unavailable and ResourceExhausted by default).
+// Only retry codes the server returns before doing any work, or idempotent methods.
+func WithGRPCRetry(maxAttempts uint, retryCodes ...codes.Code) GRPCOption {
+	return func(o *grpcOptions) {
+		o.maxAttempts = maxAttempts
+		if len(retryCodes) > 0 {
+			o.retryCodes = retryCodes
+		}
+	}
+}
+
+// WithGRPCBackoff sets the wait between attempts, exponential from 100ms with 20% jitter by default
+func WithGRPCBackoff(backoff grpcRetry.BackoffFunc) GRPCOption {
+	return func(o *grpcOptions) {
+		o.backoff = backoff
+	}
+}
+
+// WithGRPCKeepalive sets the keepalive pings, the servers must permit them (KeepaliveServerOptions)
+func WithGRPCKeepalive(params keepalive.ClientParameters) GRPCOption {
+	return func(o *grpcOptions) {
+		o.keepalive = params
+	}
+}
+
+// WithGRPCCredentials sets the transport credentials, connections are plaintext by default
+func WithGRPCCredentials(creds credentials.TransportCredentials) GRPCOption {
+	return func(o *grpcOptions) {
+		o.creds = creds
+	}
+}
+
+// WithGRPCTracing adds options to the tracing interceptors, which log with the logger and tag peer.service by default
+func WithGRPCTracing(opts ...grpctrace.Option) GRPCOption {
+	return func(o *grpcOptions) {
+		o.traceOpts = append(o.traceOpts, opts...)
+	}
+}
+
+// WithGRPCDialOptions appends raw dial options
+func WithGRPCDialOptions(opts ...grpc.DialOption) GRPCOption {
+	return func(o *grpcOptions) {
+		o.dialOpts = append(o.dialOpts, opts...)
+	}
+}
+
+// DialGRPC - connection to the service name at target, a comma separated list of host:port balanced round robin.
+//
+// Unary calls go through, from the outside in: the call deadline, the circuit breaker of the target,
+// the retries and the tracing interceptor, so every attempt is a span and a call rejected by an open
+// breaker is neither retried nor sent. The dial does not block, connection states are logged.
+func DialGRPC(logr *log.Factory, name, target string, opts ...GRPCOption) (*GRPCConn, error) {
+
+	o := &grpcOptions{
+		callTimeout: defaultGRPCCallTimeout,
+		maxAttempts: defaultGRPCMaxAttempts,
+		retryCodes:  defaultGRPCRetryCodes,
+		backoff:     grpcRetry.BackoffExponentialWithJitter(100*time.Millisecond, 0.2),
+		keepalive:   defaultKeepalive,
+	}
+
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	c := &GRPCConn{
+		name:        name,
+		logr:        logr,
+		cb:          newBreaker(logr, "gRPC "+name),
+		callTimeout: int64(o.callTimeout),
+	}
+
+	traceOpts := append([]grpctrace.Option{grpctrace.WithLogger(logr), grpctrace.WithPeerService(name)}, o.traceOpts...)
+
+	unary := make([]grpc.UnaryClientInterceptor, 0, 4)
+	unary = append(unary, deadlineInterceptor(c.CallTimeout))
+	unary = append(unary, c.breakerInterceptor())
+	if o.maxAttempts > 1 {
+		unary = append(unary, grpcRetry.UnaryClientInterceptor(
+			grpcRetry.WithMax(o.maxAttempts),
+			grpcRetry.WithCodes(o.retryCodes...),
+			grpcRetry.WithBackoff(o.backoff),
+		))
+	}
+	unary = append(unary, grpctrace.UnaryClientInterceptor(traceOpts...))
+
+	dialOpts := []grpc.DialOption{
+		grpc.WithChainUnaryInterceptor(unary...),
+		grpc.WithChainStreamInterceptor(grpctrace.StreamClientInterceptor(traceOpts...)),
+		grpc.WithKeepaliveParams(o.keepalive),
+	}
+
+	if o.creds != nil {
+		dialOpts = append(dialOpts, grpc.WithTransportCredentials(o.creds))
+	} else {
+		dialOpts = append(dialOpts, grpc.WithInsecure())
+	}
+
+	dialTarget := target
+	if addrs := splitAddresses(target); len(addrs) > 1 {
+		// a resolver local to this connection, nothing is registered globally
+		dialTarget = staticScheme + ":///" + name
+		dialOpts = append(dialOpts,
+			grpc.WithResolvers(staticBuilder(addrs)),
+			grpc.WithDefaultServiceConfig(`{"loadBalancingConfig":[{"round_robin":{}}]}`),
+		)
+	}
+
+	conn, err := grpc.Dial(dialTarget, append(dialOpts, o.dialOpts...)...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "grpc dial %s (%s)", name, target)
+	}
+
+	c.ClientConn = conn
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c.stopWatch = cancel
+	go c.watchState(ctx)
+
+	logr.Default().Debug("grpc client created", zap.String("name", name), zap.String("target", target))
+
+	return c, nil
+}
+
+// Name returns the name of the called service
+func (c *GRPCConn) Name() string {
+	return c.name
+}
+
+// CallTimeout returns the deadline of unary calls without an earlier one, 0 when disabled
+func (c *GRPCConn) CallTimeout() time.Duration {
+	return time.Duration(atomic.LoadInt64(&c.callTimeout))
+}
+
+// SetCallTimeout changes the deadline of the calls made from now on, 0 disables it
+func (c *GRPCConn) SetCallTimeout(timeOut time.Duration) {
+	atomic.StoreInt64(&c.callTimeout, int64(timeOut))
+}
+
+// Breaker returns the circuit breaker of the connection
+func (c *GRPCConn) Breaker() *Breaker {
+	return c.cb
+}
+
+// Close stops the state logging and closes the connection
+func (c *GRPCConn) Close() error {
+	c.stopWatch()
+	c.cb.close()
+	return c.ClientConn.Close()
+}
+
+// TearDown closes the connection, it fits the TearDowns of the services
+func (c *GRPCConn) TearDown(logr *log.Factory) {
+	logr.Default().Debug("shutting down grpc client", zap.String("name", c.name))
+	if err := c.Close(); err != nil {
+		logr.Default().Error("grpc client connection close", zap.String("name", c.name), zap.String("err", err.Error()))
+	}
+}
+
+func (c *GRPCConn) watchState(ctx context.Context) {
+
+	for {
+		state := c.GetState()
+
+		fields := []zap.Field{zap.String("name", c.name), zap.String("state", state.String())}
+		if state == connectivity.TransientFailure {
+			c.logr.Default().Error("grpc connection state", fields...)
+		} else {
+			c.logr.Default().Debug("grpc connection state", fields...)
+		}
+
+		if state == connectivity.Shutdown || !c.WaitForStateChange(ctx, state) {
+			return
+		}
+	}
+}
+
+// breakerInterceptor - only server side and network failures count, an open breaker fails the call with Unavailable
+func (c *GRPCConn) breakerInterceptor() grpc.UnaryClientInterceptor {
+
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+
+		var callErr error
+
+		_, err := c.cb.Execute(func() (interface{}, error) {
+			callErr = invoker(ctx, method, req, reply, cc, opts...)
+			if breakerCodes[status.Code(callErr)] {
+				return nil, callErr
+			}
+			return nil, nil
+		})
+
+		if err == gobreaker.ErrOpenState || err == gobreaker.ErrTooManyRequests {
+			c.logr.For(ctx).Error("grpc call rejected", zap.String("name", c.name), zap.String("err", err.Error()))
+			return status.Errorf(codes.Unavailable, "%s: circuit breaker: %v", c.name, err)
+		}
+
+		return callErr
+	}
+}
+
+// deadlineInterceptor sets the call deadline given by callTimeout, if any, unless the context has an earlier one.
+// A call with no budget left is not made, nor counted by the circuit breaker: the caller ran out of time, not the server.
+func deadlineInterceptor(callTimeout func() time.Duration) grpc.UnaryClientInterceptor {
+
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+
+		if tracing.BudgetExhausted(ctx) {
+			return status.Errorf(codes.DeadlineExceeded, "%s: %v", method, ErrBudgetExhausted)
+		}
+
+		timeOut := callTimeout()
+		if deadline, ok := ctx.Deadline(); timeOut > 0 && (!ok || time.Until(deadline) > timeOut) {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeOut)
+			defer cancel()
+		}
+
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// KeepaliveServerOptions lets clients created by DialGRPC ping idle connections,
+// by default servers close connections pinging more than every 5 minutes
+func KeepaliveServerOptions() []grpc.ServerOption {
+	return []grpc.ServerOption{
+		grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+			MinTime:             defaultKeepalive.Time / 2,
+			PermitWithoutStream: true,
+		}),
+	}
+}
+
+func splitAddresses(target string) []string {
+	addrs := make([]string, 0)
+	for _, a := range strings.Split(target, ",") {
+		if a = strings.TrimSpace(a); a != "" {
+			addrs = append(addrs, a)
+		}
+	}
+	return addrs
+}
+
+const staticScheme = "static"
+
+// staticBuilder resolves to a fixed list of addresses
+type staticBuilder []string
+
+func (b staticBuilder) Build(_ resolver.Target, cc resolver.ClientConn, _ resolver.BuildOptions) (resolver.Resolver, error) {
+
+	addrs := make([]resolver.Address, 0, len(b))
+	for _, a := range b {
+		addrs = append(addrs, resolver.Address{Addr: a})
+	}
+
+	if err := cc.UpdateState(resolver.State{Addresses: addrs}); err != nil {
+		return nil, err
+	}
+
+	return staticResolver{}, nil
+}
+
+func (b staticBuilder) Scheme() string {
+	return staticScheme
+}
+
+type staticResolver struct{}
+
+func (staticResolver) ResolveNow(resolver.ResolveNowOptions) {}
+
+func (staticResolver) Close() {}
@@ -5,12 +5,18 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/alloykh/tracer-demo/log"
+	"github.com/alloykh/tracer-demo/tracing"
+	"github.com/alloykh/tracer-demo/tracing/semconv"
 	"github.com/opentracing-contrib/go-stdlib/nethttp"
 	"github.com/opentracing/opentracing-go"
+	"github.com/uber/jaeger-lib/metrics"
 	"go.uber.org/zap"
 	"io"
+	"net"
 	"net/http"
+	"net/http/httptrace"
 	"net/url"
+	"sync/atomic"
 	"time"
 
 	"github.com/sony/gobreaker"
@@ -22,9 +28,20 @@ type HTTPService struct {
 	client *http.Client
 	logr   *log.Factory
 
-	cb *gobreaker.CircuitBreaker
+	cb *Breaker
 
 	timeOut time.Duration
+
+	// the client in use, a copy of client replaced by SetTimeout
+	current atomic.Value
+
+	transport *http.Transport
+	pool      *connPool
+	metrics   *httpMetrics
+	// connection phases on the spans
+	connTracing bool
+	// peer.service of the spans
+	peerService string
 }
 
 type Option func(client *HTTPService)
@@ -32,15 +49,11 @@ type Option func(client *HTTPService)
 func WithProxy(rawUrl string) Option {
 
 	return func(client *HTTPService) {
-		tr, ok := client.client.Transport.(*http.Transport)
-		if ok {
-			proxyUrl, err := url.Parse(rawUrl)
-			if err != nil {
-				return
-			}
-			tr.Proxy = http.ProxyURL(proxyUrl)
+		proxyUrl, err := url.Parse(rawUrl)
+		if err != nil {
+			return
 		}
-
+		client.transport.Proxy = http.ProxyURL(proxyUrl)
 	}
 }
 
@@ -53,14 +66,44 @@ func WithTimeOut(timeOut time.Duration) Option {
 	}
 }
 
+// WithConnTracing - DNS, connect, TLS handshake, time to first byte and connection reuse as events and tags
+// of the request spans, on by default. The metrics and pool stats are kept either way.
+func WithConnTracing(enabled bool) Option {
+	return func(s *HTTPService) {
+		s.connTracing = enabled
+	}
+}
+
+// WithPeerService tags the spans with the name of the service called, so the dependency graph of
+// the process names the callee as its own spans do
+func WithPeerService(name string) Option {
+	return func(s *HTTPService) {
+		s.peerService = name
+	}
+}
+
+// WithMetrics - timers of the connection phases and counters of the connections, new or reused, per host
+func WithMetrics(factory metrics.Factory) Option {
+	return func(s *HTTPService) {
+		if factory == nil {
+			return
+		}
+		s.metrics = newHTTPMetrics(factory)
+	}
+}
+
 // NewClient - new http client
 func NewClient(logr *log.Factory, opts ...Option) (s *HTTPService) {
 
 	// create a new circuit breaker
 	cb := NewCircuitBreaker(logr)
 
+	pool := newConnPool()
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+
 	// create a custom http transport
-	tr := http.Transport{
+	tr := &http.Transport{
+		DialContext:         pool.dialContext(dialer.DialContext),
 		MaxIdleConns:        150,
 		MaxIdleConnsPerHost: 2, // default
 		IdleConnTimeout:     time.Second * 100,
@@ -73,9 +116,12 @@ func NewClient(logr *log.Factory, opts ...Option) (s *HTTPService) {
 		logr: logr,
 		cb:   cb,
 		client: &http.Client{
-			Transport: &nethttp.Transport{RoundTripper: &tr},
+			Transport: &nethttp.Transport{RoundTripper: tr},
 			Timeout:   defaultTimeOut,
 		},
+		transport:   tr,
+		pool:        pool,
+		connTracing: true,
 	}
 
 	// circuit breaker settings
@@ -83,35 +129,131 @@ func NewClient(logr *log.Factory, opts ...Option) (s *HTTPService) {
 		opt(s)
 	}
 
+	s.current.Store(s.client)
+
 	return
 }
 
+// SetTimeout changes the time limit of the requests made from now on, 0 or less is ignored
+func (s *HTTPService) SetTimeout(timeOut time.Duration) {
+	if timeOut <= 0 {
+		return
+	}
+	c := *s.httpClient()
+	c.Timeout = timeOut
+	s.current.Store(&c)
+}
+
+// Timeout returns the time limit of the requests
+func (s *HTTPService) Timeout() time.Duration {
+	return s.httpClient().Timeout
+}
+
+func (s *HTTPService) httpClient() *http.Client {
+	return s.current.Load().(*http.Client)
+}
+
+// PoolStats returns the connections of the client per host
+func (s *HTTPService) PoolStats() []HostStats {
+	return s.pool.Stats()
+}
+
+// Close closes the idle connections and removes the client from HTTPPools and Breakers
+func (s *HTTPService) Close() error {
+	s.pool.unregister()
+	s.cb.close()
+	s.transport.CloseIdleConnections()
+	return nil
+}
+
+// Breaker returns the circuit breaker of the client
+func (s *HTTPService) Breaker() *Breaker {
+	return s.cb
+}
+
 // NewCircuitBreaker - circuit breaker init
-func NewCircuitBreaker(logr *log.Factory) *gobreaker.CircuitBreaker {
-	settings := gobreaker.Settings{
-		Name:        "HTTP",
+func NewCircuitBreaker(logr *log.Factory) *Breaker {
+	// init circuit breaker
+	return newBreaker(logr, "HTTP")
+}
+
+// BreakerThresholds - a circuit breaker trips when at least FailureRatio of MinRequests or more requests failed
+type BreakerThresholds struct {
+	MinRequests  uint32
+	FailureRatio float64
+}
+
+var breakerThresholds atomic.Value
+
+func init() {
+	breakerThresholds.Store(BreakerThresholds{MinRequests: 3, FailureRatio: 0.6})
+}
+
+// SetBreakerThresholds changes the thresholds of all the circuit breakers, the ones already created included
+func SetBreakerThresholds(t BreakerThresholds) {
+	breakerThresholds.Store(t)
+}
+
+// GetBreakerThresholds returns the thresholds of the circuit breakers
+func GetBreakerThresholds() BreakerThresholds {
+	return breakerThresholds.Load().(BreakerThresholds)
+}
+
+// breakerSettings - trips on the BreakerThresholds, 60% of 3 or more requests failed by default, half-opens after 30s
+func breakerSettings(logr *log.Factory, name string) gobreaker.Settings {
+	return gobreaker.Settings{
+		Name:        name,
 		MaxRequests: 2,
 		Interval:    time.Minute * 5,
 		Timeout:     time.Second * 30,
 		ReadyToTrip: func(counts gobreaker.Counts) bool {
+			t := GetBreakerThresholds()
 			failureRatio := float64(counts.TotalFailures) / float64(counts.Requests)
-			return counts.Requests >= 3 && failureRatio >= 0.6
+			return counts.Requests >= t.MinRequests && failureRatio >= t.FailureRatio
 		},
 		OnStateChange: func(name string, from gobreaker.State, to gobreaker.State) {
 			logr.Default().Debug("circuit breaker state change", zap.String("name", name), zap.String("from", from.String()), zap.String("to", to.String()))
 		},
 	}
-
-	// init circuit breaker
-	return gobreaker.NewCircuitBreaker(settings)
 }
 
 // Do - execute http request
+// The time left to the deadline of ctx is sent in tracing.BudgetHeader, a call with no budget left
+// is not made and fails with ErrBudgetExhausted.
 func (h *HTTPService) Do(ctx context.Context, req *http.Request, resp interface{}) (err error) {
 
+	if tracing.BudgetExhausted(ctx) {
+		h.logr.For(ctx).Error("http do call skipped, no budget left", zap.String("url", req.URL.String()))
+		return &Error{
+			Err:  ErrBudgetExhausted,
+			Info: req.URL.String(),
+		}
+	}
+
+	// the connection phases go to the span of each attempt, the metrics and the pool stats
+	ct := newConnTrace(h.pool, h.metrics, h.connTracing)
+	defer ct.done()
+	req = req.WithContext(httptrace.WithClientTrace(withConnTrace(req.Context(), ct), ct.clientTrace()))
+
 	// if we have open tracing and registered as a global tracer, we start op-span - we inject span context into the http request headers
 	if opentracing.IsGlobalTracerRegistered() {
-		traceReq, sp := nethttp.TraceRequest(opentracing.GlobalTracer(), req, nethttp.OperationName(fmt.Sprintf("HTTP %s: %s", req.Method, req.URL.Path)))
+		traceReq, sp := nethttp.TraceRequest(opentracing.GlobalTracer(), req,
+			nethttp.OperationName(fmt.Sprintf("HTTP %s: %s", req.Method, req.URL.Path)),
+			// the hooks of ct log the phases instead
+			nethttp.ClientTrace(false),
+			nethttp.ClientSpanObserver(func(span opentracing.Span, r *http.Request) {
+				ct.attempt(span)
+				semconv.HTTPClientRequest(span, r)
+				if h.peerService != "" {
+					semconv.PeerService(span, h.peerService)
+				}
+				tracing.TagBudget(span, ctx)
+				// the debug flag of the trace goes downstream with the span context, debug traces log the request as well
+				if tracing.IsDebugSpan(span) {
+					tracing.LogRequestHeaders(span, "request", r.Header)
+				}
+			}),
+		)
 		req = traceReq
 		defer sp.Finish()
 	}
@@ -121,9 +263,12 @@ func (h *HTTPService) Do(ctx context.Context, req *http.Request, resp interface{
 
 	for {
 
+		// the budget is refreshed on every attempt
+		tracing.InjectBudget(ctx, req.Header)
+
 		// execute http.do via circuit breaker
 		rawResp, err = h.cb.Execute(func() (interface{}, error) {
-			return h.client.Do(req)
+			return h.httpClient().Do(req)
 		})
 
 		// if no err, break the loop to handle the http response
@@ -138,8 +283,16 @@ func (h *HTTPService) Do(ctx context.Context, req *http.Request, resp interface{
 			break
 		}
 
-		// wait for some time before retrying
-		time.Sleep(5 * (time.Duration(interval) + 1) * time.Second)
+		// wait for some time before retrying, unless the caller gives up first
+		select {
+		case <-time.After(5 * (time.Duration(interval) + 1) * time.Second):
+		case <-ctx.Done():
+			h.logr.For(ctx).Error("http do call given up, no budget left", zap.String("url", req.URL.String()))
+			return &Error{
+				Err:  ErrBudgetExhausted,
+				Info: err.Error(),
+			}
+		}
 
 		interval++
 
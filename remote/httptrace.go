@@ -0,0 +1,315 @@
+package remote
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http/httptrace"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/alloykh/tracer-demo/tracing/semconv"
+	"github.com/opentracing/opentracing-go"
+	otlog "github.com/opentracing/opentracing-go/log"
+	"github.com/uber/jaeger-lib/metrics"
+)
+
+// connTrace records the connection phases of a request: span events and tags on the span of the attempt,
+// metrics per host and the pool stats
+type connTrace struct {
+	pool    *connPool
+	metrics *httpMetrics
+	spans   bool
+
+	mu   sync.Mutex
+	span opentracing.Span
+	host string
+	// counters of the host while the request holds a connection
+	held *hostCounters
+	// a connection was handed to the attempt, the dials still running were started for nothing
+	gotConn bool
+
+	getConn, dnsStart, connectStart, tlsStart time.Time
+}
+
+func newConnTrace(pool *connPool, m *httpMetrics, spans bool) *connTrace {
+	return &connTrace{pool: pool, metrics: m, spans: spans}
+}
+
+type connTraceKey struct{}
+
+// withConnTrace returns ctx holding t, for the dials of the transport
+func withConnTrace(ctx context.Context, t *connTrace) context.Context {
+	return context.WithValue(ctx, connTraceKey{}, t)
+}
+
+func connTraceFrom(ctx context.Context) *connTrace {
+	t, _ := ctx.Value(connTraceKey{}).(*connTrace)
+	return t
+}
+
+// hostPort returns the address the request asked a connection for
+func (t *connTrace) hostPort() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.host
+}
+
+// attempt starts an attempt of the request, span is its span
+func (t *connTrace) attempt(span opentracing.Span) {
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.release()
+
+	if t.spans {
+		t.span = span
+	}
+}
+
+// done ends the request, its response is read
+func (t *connTrace) done() {
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.release()
+	t.span = nil
+}
+
+func (t *connTrace) release() {
+	if t.held != nil {
+		atomic.AddInt64(&t.held.inUse, -1)
+		t.held = nil
+	}
+}
+
+// event logs an event on the span of the attempt, the lock is held
+func (t *connTrace) event(name string, fields ...otlog.Field) {
+	if t.span != nil {
+		t.span.LogFields(append([]otlog.Field{otlog.String("event", name)}, fields...)...)
+	}
+}
+
+// phase records how long a phase of the connection took, the lock is held
+func (t *connTrace) phase(key, name string, d time.Duration) {
+	if t.span != nil {
+		semconv.HTTPPhase(t.span, key, d)
+	}
+	t.metrics.phase(t.host, name, d)
+}
+
+func durationField(d time.Duration) otlog.Field {
+	return otlog.Float64("duration_ms", float64(d.Microseconds())/1000)
+}
+
+func errorFields(err error) []otlog.Field {
+	if err == nil {
+		return nil
+	}
+	return []otlog.Field{otlog.Error(err)}
+}
+
+func (t *connTrace) clientTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		GetConn: func(hostPort string) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+
+			t.host = hostPort
+			t.getConn = time.Now()
+			t.gotConn = false
+			t.dnsStart, t.connectStart, t.tlsStart = time.Time{}, time.Time{}, time.Time{}
+			t.event("get conn", otlog.String("host", hostPort))
+		},
+		DNSStart: func(info httptrace.DNSStartInfo) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+
+			if t.gotConn {
+				return
+			}
+			t.dnsStart = time.Now()
+			t.event("dns start", otlog.String("host", info.Host))
+		},
+		DNSDone: func(info httptrace.DNSDoneInfo) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+
+			if t.gotConn || t.dnsStart.IsZero() {
+				return
+			}
+			d := time.Since(t.dnsStart)
+			t.event("dns done", append(errorFields(info.Err), durationField(d), otlog.Int("addrs", len(info.Addrs)))...)
+			if info.Err == nil {
+				t.phase(semconv.HTTPDNSKey, "dns", d)
+			}
+		},
+		ConnectStart: func(network, addr string) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+
+			if t.gotConn {
+				return
+			}
+			// the dials of both address families race, the first one started times the phase
+			if t.connectStart.IsZero() {
+				t.connectStart = time.Now()
+			}
+			t.event("connect start", otlog.String("addr", addr))
+		},
+		ConnectDone: func(network, addr string, err error) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+
+			if t.gotConn || t.connectStart.IsZero() {
+				return
+			}
+			d := time.Since(t.connectStart)
+			t.event("connect done", append(errorFields(err), durationField(d), otlog.String("addr", addr))...)
+			if err == nil {
+				t.phase(semconv.HTTPConnectKey, "connect", d)
+			}
+		},
+		TLSHandshakeStart: func() {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+
+			if t.gotConn {
+				return
+			}
+			t.tlsStart = time.Now()
+			t.event("tls handshake start")
+		},
+		TLSHandshakeDone: func(state tls.ConnectionState, err error) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+
+			if t.gotConn || t.tlsStart.IsZero() {
+				return
+			}
+			d := time.Since(t.tlsStart)
+			t.event("tls handshake done", append(errorFields(err), durationField(d),
+				otlog.String("tls.version", tlsVersion(state.Version)), otlog.Bool("tls.resumed", state.DidResume))...)
+			if err == nil {
+				t.phase(semconv.HTTPTLSKey, "tls", d)
+			}
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+
+			t.gotConn = true
+			wait := time.Since(t.getConn)
+
+			t.event("got conn", durationField(wait), otlog.Bool("reused", info.Reused),
+				otlog.Bool("was_idle", info.WasIdle), otlog.Int64("idle_time_ms", info.IdleTime.Milliseconds()))
+			if t.span != nil {
+				semconv.NetConn(t.span, info.Reused, info.WasIdle, info.IdleTime)
+			}
+			t.phase(semconv.HTTPConnWaitKey, "conn_wait", wait)
+			t.metrics.conn(t.host, info.Reused)
+
+			t.release()
+			t.held = t.pool.host(t.host)
+			atomic.AddInt64(&t.held.inUse, 1)
+			if info.Reused {
+				atomic.AddInt64(&t.held.reused, 1)
+			} else {
+				atomic.AddInt64(&t.held.new, 1)
+			}
+		},
+		WroteRequest: func(info httptrace.WroteRequestInfo) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+
+			t.event("wrote request", errorFields(info.Err)...)
+		},
+		GotFirstResponseByte: func() {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+
+			ttfb := time.Since(t.getConn)
+			t.event("first response byte", durationField(ttfb))
+			t.phase(semconv.HTTPFirstByteKey, "ttfb", ttfb)
+		},
+	}
+}
+
+func tlsVersion(v uint16) string {
+	switch v {
+	case tls.VersionTLS10:
+		return "1.0"
+	case tls.VersionTLS11:
+		return "1.1"
+	case tls.VersionTLS12:
+		return "1.2"
+	case tls.VersionTLS13:
+		return "1.3"
+	}
+	return strconv.Itoa(int(v))
+}
+
+// httpMetrics - http_client_phase timers by host and phase, http_client_connections counters by host
+// and reuse, nil records nothing
+type httpMetrics struct {
+	factory metrics.Factory
+
+	mu     sync.Mutex
+	phases map[[2]string]metrics.Timer
+	conns  map[[2]string]metrics.Counter
+}
+
+func newHTTPMetrics(factory metrics.Factory) *httpMetrics {
+	return &httpMetrics{
+		factory: factory,
+		phases:  make(map[[2]string]metrics.Timer),
+		conns:   make(map[[2]string]metrics.Counter),
+	}
+}
+
+func (m *httpMetrics) phase(host, phase string, d time.Duration) {
+
+	if m == nil {
+		return
+	}
+
+	m.mu.Lock()
+	key := [2]string{host, phase}
+	timer, ok := m.phases[key]
+	if !ok {
+		timer = m.factory.Timer(metrics.TimerOptions{
+			Name: "http_client_phase",
+			Tags: map[string]string{"host": host, "phase": phase},
+			Help: "Time of the connection phases of the outbound HTTP requests",
+		})
+		m.phases[key] = timer
+	}
+	m.mu.Unlock()
+
+	timer.Record(d)
+}
+
+func (m *httpMetrics) conn(host string, reused bool) {
+
+	if m == nil {
+		return
+	}
+
+	m.mu.Lock()
+	key := [2]string{host, strconv.FormatBool(reused)}
+	counter, ok := m.conns[key]
+	if !ok {
+		counter = m.factory.Counter(metrics.Options{
+			Name: "http_client_connections",
+			Tags: map[string]string{"host": host, "reused": key[1]},
+			Help: "Connections handed to the outbound HTTP requests",
+		})
+		m.conns[key] = counter
+	}
+	m.mu.Unlock()
+
+	counter.Inc(1)
+}
@@ -0,0 +1,191 @@
+package remote
+
+import (
+	"context"
+	"net"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// HostStats - the connections of HTTP clients to a host, host:port as the transport pools them:
+// the address of the proxy for the requests going through one
+type HostStats struct {
+	Host string `json:"host"`
+	// Open - dialed and not closed yet
+	Open int64 `json:"open"`
+	// InUse - held by a request
+	InUse int64 `json:"in_use"`
+	// Idle - open and waiting in the pool
+	Idle       int64 `json:"idle"`
+	Dials      int64 `json:"dials"`
+	DialErrors int64 `json:"dial_errors"`
+	// Reused - requests served on a pooled connection, New on a connection dialed for them
+	Reused int64 `json:"reused"`
+	New    int64 `json:"new"`
+}
+
+type hostCounters struct {
+	open, inUse, dials, dialErrors, reused, new int64
+}
+
+// connPool counts the connections of a transport per host
+type connPool struct {
+	mu    sync.Mutex
+	hosts map[string]*hostCounters
+}
+
+// pools of the HTTP clients, for HTTPPools
+var pools = struct {
+	sync.Mutex
+	all []*connPool
+}{}
+
+func newConnPool() *connPool {
+
+	p := &connPool{hosts: make(map[string]*hostCounters)}
+
+	pools.Lock()
+	pools.all = append(pools.all, p)
+	pools.Unlock()
+
+	return p
+}
+
+// unregister removes the pool from HTTPPools
+func (p *connPool) unregister() {
+
+	pools.Lock()
+	defer pools.Unlock()
+
+	for i, known := range pools.all {
+		if known == p {
+			pools.all = append(pools.all[:i], pools.all[i+1:]...)
+			return
+		}
+	}
+}
+
+func (p *connPool) host(hostPort string) *hostCounters {
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	c, ok := p.hosts[hostPort]
+	if !ok {
+		c = &hostCounters{}
+		p.hosts[hostPort] = c
+	}
+
+	return c
+}
+
+// dialContext counts the connections dialed by dial, and their close. They are counted under the host
+// the request asked a connection for, as GotConn counts them, the dialed address only without a connTrace.
+func (p *connPool) dialContext(dial func(ctx context.Context, network, addr string) (net.Conn, error)) func(ctx context.Context, network, addr string) (net.Conn, error) {
+
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+
+		host := addr
+		if t := connTraceFrom(ctx); t != nil {
+			if h := t.hostPort(); h != "" {
+				host = h
+			}
+		}
+
+		c := p.host(host)
+		atomic.AddInt64(&c.dials, 1)
+
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			atomic.AddInt64(&c.dialErrors, 1)
+			return nil, err
+		}
+
+		atomic.AddInt64(&c.open, 1)
+
+		return &countedConn{Conn: conn, counters: c}, nil
+	}
+}
+
+// Stats returns the stats of the hosts, sorted
+func (p *connPool) Stats() []HostStats {
+
+	p.mu.Lock()
+	out := make([]HostStats, 0, len(p.hosts))
+	for host, c := range p.hosts {
+		out = append(out, c.stats(host))
+	}
+	p.mu.Unlock()
+
+	sort.Slice(out, func(i, j int) bool { return out[i].Host < out[j].Host })
+
+	return out
+}
+
+func (c *hostCounters) stats(host string) HostStats {
+
+	s := HostStats{
+		Host:       host,
+		Open:       atomic.LoadInt64(&c.open),
+		InUse:      atomic.LoadInt64(&c.inUse),
+		Dials:      atomic.LoadInt64(&c.dials),
+		DialErrors: atomic.LoadInt64(&c.dialErrors),
+		Reused:     atomic.LoadInt64(&c.reused),
+		New:        atomic.LoadInt64(&c.new),
+	}
+
+	if s.Idle = s.Open - s.InUse; s.Idle < 0 {
+		s.Idle = 0
+	}
+
+	return s
+}
+
+// HTTPPools returns the connection stats of all the HTTP clients per host
+func HTTPPools() []HostStats {
+
+	pools.Lock()
+	all := append([]*connPool(nil), pools.all...)
+	pools.Unlock()
+
+	byHost := make(map[string]*HostStats)
+	for _, p := range all {
+		for _, s := range p.Stats() {
+			total, ok := byHost[s.Host]
+			if !ok {
+				total = &HostStats{Host: s.Host}
+				byHost[s.Host] = total
+			}
+			total.Open += s.Open
+			total.InUse += s.InUse
+			total.Idle += s.Idle
+			total.Dials += s.Dials
+			total.DialErrors += s.DialErrors
+			total.Reused += s.Reused
+			total.New += s.New
+		}
+	}
+
+	out := make([]HostStats, 0, len(byHost))
+	for _, s := range byHost {
+		out = append(out, *s)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Host < out[j].Host })
+
+	return out
+}
+
+// countedConn decrements the open connections of its host once closed
+type countedConn struct {
+	net.Conn
+	counters *hostCounters
+	closed   int32
+}
+
+func (c *countedConn) Close() error {
+	if atomic.CompareAndSwapInt32(&c.closed, 0, 1) {
+		atomic.AddInt64(&c.counters.open, -1)
+	}
+	return c.Conn.Close()
+}
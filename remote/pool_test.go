@@ -0,0 +1,66 @@
+package remote_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/alloykh/tracer-demo/log"
+	"github.com/alloykh/tracer-demo/remote"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestPoolStatsReusedConnections(t *testing.T) {
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	}))
+	defer srv.Close()
+
+	host := strings.TrimPrefix(srv.URL, "http://")
+
+	client := remote.NewClient(log.NewFactory("test", zapcore.ErrorLevel))
+
+	for i := 0; i < 2; i++ {
+		req, err := http.NewRequest(http.MethodGet, srv.URL+"/stock", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var resp map[string]interface{}
+		if err := client.Do(context.Background(), req, &resp); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := remote.HostStats{Host: host, Open: 1, Idle: 1, Dials: 1, New: 1, Reused: 1}
+
+	stats := client.PoolStats()
+	if len(stats) != 1 || stats[0] != want {
+		t.Errorf("pool stats %+v, want %+v", stats, want)
+	}
+
+	if !hasHost(remote.HTTPPools(), want) {
+		t.Errorf("HTTPPools %+v, want %+v", remote.HTTPPools(), want)
+	}
+
+	if err := client.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, s := range remote.HTTPPools() {
+		if s.Host == host {
+			t.Errorf("HTTPPools still lists %+v after the client closed", s)
+		}
+	}
+}
+
+func hasHost(stats []remote.HostStats, want remote.HostStats) bool {
+	for _, s := range stats {
+		if s == want {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,128 @@
+package agent
+
+import (
+	"context"
+	"net"
+	"sync"
+
+	"github.com/alloykh/tracer-demo/log"
+	"github.com/alloykh/tracer-demo/tracing/model"
+	"github.com/pkg/errors"
+	"github.com/uber/jaeger-client-go/thrift"
+	agentThrift "github.com/uber/jaeger-client-go/thrift-gen/agent"
+	"github.com/uber/jaeger-client-go/thrift-gen/jaeger"
+	"github.com/uber/jaeger-client-go/thrift-gen/zipkincore"
+	"go.uber.org/zap"
+)
+
+// maxPacketSize is the largest UDP packet jaeger clients send (utils.UDPPacketMaxLength)
+const maxPacketSize = 65000
+
+// Agent is a stand-in for jaeger-agent: it receives thrift compact batches over UDP
+// (the default transport of jaeger clients) and hands the decoded spans to its sinks.
+type Agent struct {
+	addr  string
+	logr  *log.Factory
+	sinks []Sink
+
+	conn *net.UDPConn
+	wg   sync.WaitGroup
+}
+
+// New - agent listening on the UDP addr ("localhost:6831" is what jaeger clients use by default)
+func New(addr string, logr *log.Factory, sinks ...Sink) *Agent {
+	return &Agent{
+		addr:  addr,
+		logr:  logr,
+		sinks: sinks,
+	}
+}
+
+// Run binds the UDP socket and starts receiving in the background
+func (a *Agent) Run() error {
+
+	udpAddr, err := net.ResolveUDPAddr("udp", a.addr)
+	if err != nil {
+		return errors.Wrap(err, "agent resolve addr")
+	}
+
+	a.conn, err = net.ListenUDP("udp", udpAddr)
+	if err != nil {
+		return errors.Wrap(err, "agent listen")
+	}
+
+	a.wg.Add(1)
+	go a.serve()
+
+	a.logr.Default().Debug("JAEGER AGENT RUNNING...", zap.String("ADDR", a.Addr()))
+
+	return nil
+}
+
+// Addr returns the bound UDP address, useful when listening on port 0
+func (a *Agent) Addr() string {
+	if a.conn == nil {
+		return a.addr
+	}
+	return a.conn.LocalAddr().String()
+}
+
+// Close stops receiving and waits for the packet in progress
+func (a *Agent) Close() error {
+	if a.conn == nil {
+		return nil
+	}
+	err := a.conn.Close()
+	a.wg.Wait()
+	return err
+}
+
+func (a *Agent) serve() {
+
+	defer a.wg.Done()
+
+	processor := agentThrift.NewAgentProcessor(handler{agent: a})
+	packet := make([]byte, maxPacketSize)
+
+	for {
+		n, err := a.conn.Read(packet)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			a.logr.Default().Error("agent udp read", zap.String("err", err.Error()))
+			continue
+		}
+
+		buf := thrift.NewTMemoryBufferLen(n)
+		_, _ = buf.Write(packet[:n])
+		protocol := thrift.NewTCompactProtocolConf(buf, &thrift.TConfiguration{})
+
+		if _, err := processor.Process(context.Background(), protocol, protocol); err != nil {
+			a.logr.Default().Error("agent batch decode", zap.String("err", err.Error()))
+		}
+	}
+}
+
+func (a *Agent) add(spans []*model.Span) {
+	for _, sink := range a.sinks {
+		if err := sink.Add(spans); err != nil {
+			a.logr.Default().Error("agent sink add", zap.String("err", err.Error()))
+		}
+	}
+}
+
+// handler implements the thrift agent service
+type handler struct {
+	agent *Agent
+}
+
+func (h handler) EmitBatch(_ context.Context, batch *jaeger.Batch) error {
+	h.agent.add(model.FromBatch(batch))
+	return nil
+}
+
+func (h handler) EmitZipkinBatch(_ context.Context, spans []*zipkincore.Span) error {
+	h.agent.logr.Default().Debug("agent ignores zipkin batches", zap.Int("spans", len(spans)))
+	return nil
+}
@@ -0,0 +1,69 @@
+package agent_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alloykh/tracer-demo/log"
+	"github.com/alloykh/tracer-demo/tracing"
+	"github.com/alloykh/tracer-demo/tracing/agent"
+	"github.com/uber/jaeger-client-go"
+	"github.com/uber/jaeger-lib/metrics"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestAgentReceivesInitJaegerSpans(t *testing.T) {
+
+	logr := log.NewFactory("test", zapcore.ErrorLevel)
+
+	store := agent.NewStore(0)
+	a := agent.New("127.0.0.1:0", logr, store)
+	if err := a.Run(); err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+
+	tracer, closer := tracing.InitJaeger("agent-test", metrics.NullFactory, logr,
+		tracing.WithLocalAgent(a.Addr(), 10*time.Millisecond),
+	)
+	defer closer()
+
+	span := tracer.StartSpan("agent-test-op")
+	span.SetTag("test.tag", "value")
+	span.Finish()
+
+	traceID := span.Context().(jaeger.SpanContext).TraceID().String()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		trace, ok := store.Trace(traceID)
+		if !ok {
+			time.Sleep(20 * time.Millisecond)
+			continue
+		}
+
+		if len(trace.Spans) != 1 {
+			t.Fatalf("got %d spans, want 1", len(trace.Spans))
+		}
+		got := trace.Spans[0]
+		if got.OperationName != "agent-test-op" {
+			t.Errorf("operation %q, want agent-test-op", got.OperationName)
+		}
+		if got.Process == nil || got.Process.ServiceName != "agent-test" {
+			t.Errorf("process %+v, want service agent-test", got.Process)
+		}
+
+		var tagged bool
+		for _, kv := range got.Tags {
+			if kv.Key == "test.tag" && kv.Value == "value" {
+				tagged = true
+			}
+		}
+		if !tagged {
+			t.Errorf("tags %+v, want test.tag=value", got.Tags)
+		}
+		return
+	}
+
+	t.Fatalf("trace %s never reached the agent", traceID)
+}
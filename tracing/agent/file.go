@@ -0,0 +1,54 @@
+package agent
+
+import (
+	"bufio"
+	"os"
+	"sync"
+
+	"github.com/alloykh/tracer-demo/tracing/model"
+	"github.com/pkg/errors"
+)
+
+// FileSink appends the received spans to a JSON-lines file (see model.WriteJSONLines)
+type FileSink struct {
+	mu   sync.Mutex
+	file *os.File
+	w    *bufio.Writer
+}
+
+// NewFileSink - opens the file in append mode, creating it if needed
+func NewFileSink(path string) (*FileSink, error) {
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, errors.Wrap(err, "span file open")
+	}
+
+	return &FileSink{file: f, w: bufio.NewWriter(f)}, nil
+}
+
+// Add implements Sink, every batch is flushed to the file
+func (f *FileSink) Add(spans []*model.Span) error {
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := model.WriteJSONLines(f.w, spans); err != nil {
+		return err
+	}
+
+	return errors.Wrap(f.w.Flush(), "span file flush")
+}
+
+// Close flushes and closes the file
+func (f *FileSink) Close() error {
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.w.Flush(); err != nil {
+		return errors.Wrap(err, "span file flush")
+	}
+
+	return f.file.Close()
+}
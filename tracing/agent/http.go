@@ -0,0 +1,181 @@
+package agent
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alloykh/tracer-demo/tracing/model"
+)
+
+// Handler serves a subset of the jaeger query API over the store:
+//
+//	GET /api/services
+//	GET /api/services/{service}/operations
+//	GET /api/traces?service=&operation=&tags={"k":"v"}&minDuration=&maxDuration=&start=&end=&limit=
+//	GET /api/traces/{traceID}
+//	GET /api/spans (every stored span as JSON lines)
+//
+// The responses use the jaeger JSON envelope, so jaeger tooling (and tracectl) can read them.
+func Handler(store *Store) http.Handler {
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/api/services", func(w http.ResponseWriter, r *http.Request) {
+		writeData(w, store.Services())
+	})
+
+	mux.HandleFunc("/api/services/", func(w http.ResponseWriter, r *http.Request) {
+		rest := strings.TrimPrefix(r.URL.Path, "/api/services/")
+		service := strings.TrimSuffix(rest, "/operations")
+		if service == rest || service == "" {
+			http.NotFound(w, r)
+			return
+		}
+		writeData(w, store.Operations(service))
+	})
+
+	mux.HandleFunc("/api/traces", func(w http.ResponseWriter, r *http.Request) {
+		q, err := ParseQuery(r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = model.WriteJaegerJSON(w, store.Traces(q))
+	})
+
+	mux.HandleFunc("/api/traces/", func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimPrefix(r.URL.Path, "/api/traces/")
+		t, ok := store.Trace(id)
+		if !ok {
+			writeError(w, http.StatusNotFound, "trace not found")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = model.WriteJaegerTrace(w, t)
+	})
+
+	mux.HandleFunc("/api/spans", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		_ = model.WriteJSONLines(w, store.Spans())
+	})
+
+	return mux
+}
+
+// ParseQuery reads the search parameters of /api/traces, durations use the time.ParseDuration
+// format and start/end are unix microseconds, as in the jaeger query API.
+func ParseQuery(r *http.Request) (q Query, err error) {
+
+	v := r.URL.Query()
+
+	q.Service = v.Get("service")
+	q.Operation = v.Get("operation")
+
+	if tags := v.Get("tags"); tags != "" {
+		if err = json.Unmarshal([]byte(tags), &q.Tags); err != nil {
+			return q, err
+		}
+	}
+
+	if q.MinDuration, err = parseDuration(v.Get("minDuration")); err != nil {
+		return q, err
+	}
+
+	if q.MaxDuration, err = parseDuration(v.Get("maxDuration")); err != nil {
+		return q, err
+	}
+
+	if q.Start, err = parseMicros(v.Get("start")); err != nil {
+		return q, err
+	}
+
+	if q.End, err = parseMicros(v.Get("end")); err != nil {
+		return q, err
+	}
+
+	if limit := v.Get("limit"); limit != "" {
+		if q.Limit, err = strconv.Atoi(limit); err != nil {
+			return q, err
+		}
+	}
+
+	return q, nil
+}
+
+func parseDuration(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(s)
+}
+
+func parseMicros(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	us, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(0, us*int64(time.Microsecond)), nil
+}
+
+func writeData(w http.ResponseWriter, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+}
+
+func writeError(w http.ResponseWriter, code int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"data":   nil,
+		"errors": []map[string]interface{}{{"code": code, "msg": msg}},
+	})
+}
+
+// Values encodes the query with the parameters of /api/traces, the inverse of ParseQuery
+func (q Query) Values() url.Values {
+
+	v := url.Values{}
+
+	if q.Service != "" {
+		v.Set("service", q.Service)
+	}
+
+	if q.Operation != "" {
+		v.Set("operation", q.Operation)
+	}
+
+	if len(q.Tags) > 0 {
+		tags, _ := json.Marshal(q.Tags)
+		v.Set("tags", string(tags))
+	}
+
+	if q.MinDuration > 0 {
+		v.Set("minDuration", q.MinDuration.String())
+	}
+
+	if q.MaxDuration > 0 {
+		v.Set("maxDuration", q.MaxDuration.String())
+	}
+
+	if !q.Start.IsZero() {
+		v.Set("start", strconv.FormatInt(q.Start.UnixNano()/int64(time.Microsecond), 10))
+	}
+
+	if !q.End.IsZero() {
+		v.Set("end", strconv.FormatInt(q.End.UnixNano()/int64(time.Microsecond), 10))
+	}
+
+	if q.Limit > 0 {
+		v.Set("limit", strconv.Itoa(q.Limit))
+	}
+
+	return v
+}
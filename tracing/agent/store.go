@@ -0,0 +1,271 @@
+package agent
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alloykh/tracer-demo/tracing/model"
+)
+
+var defaultMaxTraces = 10000
+
+// Sink receives the spans decoded by the agent
+type Sink interface {
+	Add(spans []*model.Span) error
+}
+
+// Store keeps the received spans in memory grouped by trace.
+// When full, the trace received first is evicted.
+type Store struct {
+	mu     sync.RWMutex
+	traces map[string]*model.Trace
+	ring   []string // trace ids, ring[next] is the oldest once the ring is full
+	next   int
+}
+
+// Query filters the traces of the store, empty fields match everything
+type Query struct {
+	Service     string
+	Operation   string
+	Tags        map[string]string
+	MinDuration time.Duration
+	MaxDuration time.Duration
+	Start       time.Time
+	End         time.Time
+	Limit       int
+}
+
+// NewStore - in-memory store keeping at most maxTraces traces (10000 when maxTraces <= 0)
+func NewStore(maxTraces int) *Store {
+
+	if maxTraces <= 0 {
+		maxTraces = defaultMaxTraces
+	}
+
+	return &Store{
+		traces: make(map[string]*model.Trace),
+		ring:   make([]string, 0, maxTraces),
+	}
+}
+
+// Add implements Sink
+func (s *Store) Add(spans []*model.Span) error {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	touched := make(map[*model.Trace]bool)
+
+	for _, span := range spans {
+		t, ok := s.traces[span.TraceID]
+		if !ok {
+			t = &model.Trace{TraceID: span.TraceID}
+
+			if len(s.ring) < cap(s.ring) {
+				s.ring = append(s.ring, span.TraceID)
+			} else {
+				delete(s.traces, s.ring[s.next])
+				s.ring[s.next] = span.TraceID
+				s.next = (s.next + 1) % len(s.ring)
+			}
+
+			s.traces[span.TraceID] = t
+		}
+		t.Spans = append(t.Spans, span)
+		touched[t] = true
+	}
+
+	for t := range touched {
+		t.Normalize()
+	}
+
+	return nil
+}
+
+// Trace returns a copy of the trace with the given id
+func (s *Store) Trace(id string) (*model.Trace, bool) {
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	t, ok := s.traces[normalizeTraceID(id)]
+	if !ok {
+		return nil, false
+	}
+
+	return copyTrace(t), true
+}
+
+// Traces returns copies of the traces matching the query, the most recent first
+func (s *Store) Traces(q Query) []*model.Trace {
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	found := make([]*model.Trace, 0)
+
+	for i := len(s.ring) - 1; i >= 0; i-- {
+		t := s.traces[s.id(i)]
+		if !q.Matches(t) {
+			continue
+		}
+		found = append(found, copyTrace(t))
+		if q.Limit > 0 && len(found) >= q.Limit {
+			break
+		}
+	}
+
+	return found
+}
+
+// Spans returns all the stored spans
+func (s *Store) Spans() []*model.Span {
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	spans := make([]*model.Span, 0)
+	for i := range s.ring {
+		spans = append(spans, s.traces[s.id(i)].Spans...)
+	}
+
+	return spans
+}
+
+// Services returns the sorted names of the services seen so far
+func (s *Store) Services() []string {
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	seen := make(map[string]bool)
+	for _, t := range s.traces {
+		for _, span := range t.Spans {
+			seen[span.ServiceName()] = true
+		}
+	}
+
+	return sortedKeys(seen)
+}
+
+// Operations returns the sorted operation names of the service
+func (s *Store) Operations(service string) []string {
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	seen := make(map[string]bool)
+	for _, t := range s.traces {
+		for _, span := range t.Spans {
+			if span.ServiceName() == service {
+				seen[span.OperationName] = true
+			}
+		}
+	}
+
+	return sortedKeys(seen)
+}
+
+// Reset drops every stored trace
+func (s *Store) Reset() {
+	s.mu.Lock()
+	s.traces = make(map[string]*model.Trace)
+	s.ring = s.ring[:0]
+	s.next = 0
+	s.mu.Unlock()
+}
+
+// id returns the id of the i-th trace received, the oldest first
+func (s *Store) id(i int) string {
+	return s.ring[(s.next+i)%len(s.ring)]
+}
+
+// Matches reports whether one span of the trace satisfies every condition of the query
+func (q Query) Matches(t *model.Trace) bool {
+
+	start, _ := t.Bounds()
+	startTime := time.Unix(0, start*int64(time.Microsecond))
+
+	if !q.Start.IsZero() && startTime.Before(q.Start) {
+		return false
+	}
+
+	if !q.End.IsZero() && startTime.After(q.End) {
+		return false
+	}
+
+	for _, span := range t.Spans {
+		if q.matchesSpan(span) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (q Query) matchesSpan(span *model.Span) bool {
+
+	if q.Service != "" && span.ServiceName() != q.Service {
+		return false
+	}
+
+	if q.Operation != "" && span.OperationName != q.Operation {
+		return false
+	}
+
+	if q.MinDuration > 0 && span.Elapsed() < q.MinDuration {
+		return false
+	}
+
+	if q.MaxDuration > 0 && span.Elapsed() > q.MaxDuration {
+		return false
+	}
+
+	for key, value := range q.Tags {
+		kv, ok := span.Tag(key)
+		if !ok || kv.String() != value {
+			return false
+		}
+	}
+
+	return true
+}
+
+func copyTrace(t *model.Trace) *model.Trace {
+
+	c := &model.Trace{
+		TraceID:   t.TraceID,
+		Spans:     make([]*model.Span, len(t.Spans)),
+		Processes: make(map[string]*model.Process, len(t.Processes)),
+		Warnings:  t.Warnings,
+	}
+
+	copy(c.Spans, t.Spans)
+	for id, p := range t.Processes {
+		c.Processes[id] = p
+	}
+
+	return c
+}
+
+// normalizeTraceID pads ids the way jaeger.TraceID.String does, so "abc" finds "0000000000000abc"
+func normalizeTraceID(id string) string {
+	switch {
+	case len(id) < 16:
+		return strings.Repeat("0", 16-len(id)) + id
+	case len(id) > 16 && len(id) < 32:
+		return strings.Repeat("0", 32-len(id)) + id
+	}
+	return id
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
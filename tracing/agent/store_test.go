@@ -0,0 +1,45 @@
+package agent_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/alloykh/tracer-demo/tracing/agent"
+	"github.com/alloykh/tracer-demo/tracing/model"
+)
+
+func TestStoreEvictsTheOldestTraces(t *testing.T) {
+
+	store := agent.NewStore(3)
+
+	for i := 1; i <= 5; i++ {
+		span := &model.Span{TraceID: fmt.Sprintf("%016x", i), SpanID: "1", OperationName: "op", StartTime: int64(i), Duration: 1}
+		if err := store.Add([]*model.Span{span}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var got []string
+	for _, trace := range store.Traces(agent.Query{}) {
+		got = append(got, trace.TraceID)
+	}
+
+	if want := []string{"0000000000000005", "0000000000000004", "0000000000000003"}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("traces %v, want %v", got, want)
+	}
+
+	if _, ok := store.Trace("1"); ok {
+		t.Error("trace 1 not evicted")
+	}
+	if _, ok := store.Trace("3"); !ok {
+		t.Error("trace 3 evicted")
+	}
+
+	store.Reset()
+	if err := store.Add([]*model.Span{{TraceID: "0000000000000006", SpanID: "1", OperationName: "op", StartTime: 6, Duration: 1}}); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(store.Traces(agent.Query{})); n != 1 {
+		t.Errorf("%d traces after reset, want 1", n)
+	}
+}
@@ -0,0 +1,156 @@
+package analysis
+
+import (
+	"sort"
+	"time"
+)
+
+// EndpointReport attributes the latency of every trace of an endpoint to services and operations
+type EndpointReport struct {
+	Endpoint string        `json:"endpoint"`
+	Traces   int           `json:"traces"`
+	Errors   int           `json:"errors"` // traces with at least one failed span
+	P50      time.Duration `json:"p50"`
+	P95      time.Duration `json:"p95"`
+	Max      time.Duration `json:"max"`
+
+	Services   []Attribution `json:"services"`
+	Operations []Attribution `json:"operations"`
+
+	MeanIdle          time.Duration `json:"meanIdle"`
+	MeanRetryOverhead time.Duration `json:"meanRetryOverhead"`
+}
+
+// Attribution is the mean critical path time of a service or an operation per trace,
+// and its share of the mean endpoint latency
+type Attribution struct {
+	Name             string        `json:"name"`
+	MeanCriticalTime time.Duration `json:"meanCriticalTime"`
+	MeanSelfTime     time.Duration `json:"meanSelfTime"`
+	Percent          float64       `json:"percent"`
+}
+
+// Aggregate groups the reports by endpoint, the slowest endpoints (by p95) first
+func Aggregate(reports []*Report) []EndpointReport {
+
+	byEndpoint := make(map[string][]*Report)
+	for _, r := range reports {
+		if r.Endpoint == "" {
+			continue
+		}
+		byEndpoint[r.Endpoint] = append(byEndpoint[r.Endpoint], r)
+	}
+
+	out := make([]EndpointReport, 0, len(byEndpoint))
+	for endpoint, rs := range byEndpoint {
+		out = append(out, aggregate(endpoint, rs))
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].P95 != out[j].P95 {
+			return out[i].P95 > out[j].P95
+		}
+		return out[i].Endpoint < out[j].Endpoint
+	})
+
+	return out
+}
+
+func aggregate(endpoint string, reports []*Report) EndpointReport {
+
+	e := EndpointReport{Endpoint: endpoint, Traces: len(reports)}
+
+	durations := make([]time.Duration, 0, len(reports))
+	var total, idle, retry time.Duration
+
+	services := make(map[string]*Attribution)
+	operations := make(map[string]*Attribution)
+
+	for _, r := range reports {
+		durations = append(durations, r.Duration)
+		total += r.Duration
+		idle += r.IdleTime
+
+		for _, rt := range r.Retries {
+			retry += rt.Overhead
+		}
+
+		failed := false
+		for _, s := range r.Services {
+			addAttribution(services, s)
+			failed = failed || s.Errors > 0
+		}
+		for _, o := range r.Operations {
+			addAttribution(operations, o)
+		}
+		if failed {
+			e.Errors++
+		}
+	}
+
+	n := time.Duration(len(reports))
+	mean := total / n
+
+	e.P50 = Percentile(durations, 50)
+	e.P95 = Percentile(durations, 95)
+	e.Max = Percentile(durations, 100)
+	e.MeanIdle = idle / n
+	e.MeanRetryOverhead = retry / n
+	e.Services = finishAttributions(services, n, mean)
+	e.Operations = finishAttributions(operations, n, mean)
+
+	return e
+}
+
+func addAttribution(m map[string]*Attribution, s Share) {
+	a, ok := m[s.Name]
+	if !ok {
+		a = &Attribution{Name: s.Name}
+		m[s.Name] = a
+	}
+	a.MeanCriticalTime += s.CriticalTime
+	a.MeanSelfTime += s.SelfTime
+}
+
+func finishAttributions(m map[string]*Attribution, n, mean time.Duration) []Attribution {
+
+	out := make([]Attribution, 0, len(m))
+	for _, a := range m {
+		a.MeanCriticalTime /= n
+		a.MeanSelfTime /= n
+		if mean > 0 {
+			a.Percent = float64(a.MeanCriticalTime) / float64(mean) * 100
+		}
+		out = append(out, *a)
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].MeanCriticalTime != out[j].MeanCriticalTime {
+			return out[i].MeanCriticalTime > out[j].MeanCriticalTime
+		}
+		return out[i].Name < out[j].Name
+	})
+
+	return out
+}
+
+// Percentile returns the p-th percentile (nearest rank) of the durations, 0 for an empty slice
+func Percentile(durations []time.Duration, p float64) time.Duration {
+
+	if len(durations) == 0 {
+		return 0
+	}
+
+	sorted := append([]time.Duration(nil), durations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	rank := int(p/100*float64(len(sorted))+0.999999) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	if rank >= len(sorted) {
+		rank = len(sorted) - 1
+	}
+
+	return sorted[rank]
+}
@@ -0,0 +1,271 @@
+package analysis_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alloykh/tracer-demo/tracing/analysis"
+	"github.com/alloykh/tracer-demo/tracing/model"
+)
+
+// span describes a fixture span, times are milliseconds from the start of the trace
+type span struct {
+	id, parent string
+	service    string
+	operation  string
+	start, end int64
+	failed     bool
+}
+
+const ms = int64(time.Millisecond / time.Microsecond)
+
+func newTrace(id string, spans ...span) *model.Trace {
+
+	processes := make(map[string]*model.Process)
+	t := &model.Trace{TraceID: id}
+
+	for _, s := range spans {
+		p, ok := processes[s.service]
+		if !ok {
+			p = &model.Process{ServiceName: s.service}
+			processes[s.service] = p
+		}
+
+		ts := &model.Span{
+			TraceID:       id,
+			SpanID:        s.id,
+			OperationName: s.operation,
+			StartTime:     1000000*ms + s.start*ms,
+			Duration:      (s.end - s.start) * ms,
+			Process:       p,
+		}
+		if s.parent != "" {
+			ts.References = []model.Reference{{RefType: model.ChildOf, TraceID: id, SpanID: s.parent}}
+		}
+		if s.failed {
+			ts.Tags = []model.KeyValue{{Key: "error", Type: model.BoolType, Value: true}}
+		}
+
+		t.Spans = append(t.Spans, ts)
+	}
+
+	t.Normalize()
+
+	return t
+}
+
+// checkout: the frontend authenticates, then order reserves the stock with two parallel calls
+func checkout() *model.Trace {
+	return newTrace("1",
+		span{id: "1", service: "frontend", operation: "HTTP GET /order", start: 0, end: 100},
+		span{id: "2", parent: "1", service: "frontend", operation: "auth", start: 5, end: 15},
+		span{id: "3", parent: "1", service: "order", operation: "Create", start: 20, end: 90},
+		span{id: "4", parent: "3", service: "inventory", operation: "Reserve", start: 25, end: 45},
+		span{id: "5", parent: "3", service: "inventory", operation: "Reserve", start: 30, end: 60},
+	)
+}
+
+// offset returns the milliseconds of a span time from the start of the fixture traces
+func offset(micros int64) int64 {
+	return (micros - 1000000*ms) / ms
+}
+
+func TestCriticalPath(t *testing.T) {
+
+	tests := []struct {
+		name  string
+		trace *model.Trace
+		want  string
+	}{
+		{
+			name:  "single span",
+			trace: newTrace("1", span{id: "1", service: "frontend", operation: "GET", start: 0, end: 10}),
+			want:  "1[0-10]",
+		},
+		{
+			name: "sequential children",
+			trace: newTrace("1",
+				span{id: "1", service: "frontend", operation: "GET", start: 0, end: 50},
+				span{id: "2", parent: "1", service: "order", operation: "A", start: 10, end: 20},
+				span{id: "3", parent: "1", service: "order", operation: "B", start: 20, end: 45},
+			),
+			want: "1[0-10] 2[10-20] 3[20-45] 1[45-50]",
+		},
+		{
+			name:  "parallel children",
+			trace: checkout(),
+			want:  "1[0-5] 2[5-15] 1[15-20] 3[20-25] 4[25-30] 5[30-60] 3[60-90] 1[90-100]",
+		},
+		{
+			name: "child outliving its parent",
+			trace: newTrace("1",
+				span{id: "1", service: "frontend", operation: "GET", start: 0, end: 50},
+				span{id: "2", parent: "1", service: "mailer", operation: "send", start: 10, end: 80},
+			),
+			want: "1[0-10] 2[10-50]",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			segments := analysis.CriticalPath(tt.trace)
+
+			got := make([]string, 0, len(segments))
+			total := time.Duration(0)
+			for _, s := range segments {
+				got = append(got, fmt.Sprintf("%s[%d-%d]", s.SpanID, offset(s.Start), offset(s.End)))
+				total += s.Duration()
+			}
+
+			if strings.Join(got, " ") != tt.want {
+				t.Errorf("segments %s, want %s", strings.Join(got, " "), tt.want)
+			}
+			if root := tt.trace.Root().Elapsed(); total != root {
+				t.Errorf("segments add up to %s, want the root duration %s", total, root)
+			}
+		})
+	}
+}
+
+func TestSelfTimes(t *testing.T) {
+
+	tests := []struct {
+		name  string
+		trace *model.Trace
+		want  map[string]int64
+	}{
+		{
+			name:  "parallel children",
+			trace: checkout(),
+			want:  map[string]int64{"1": 20, "2": 10, "3": 35, "4": 20, "5": 30},
+		},
+		{
+			name: "child outliving its parent",
+			trace: newTrace("1",
+				span{id: "1", service: "frontend", operation: "GET", start: 0, end: 50},
+				span{id: "2", parent: "1", service: "mailer", operation: "send", start: 10, end: 80},
+			),
+			want: map[string]int64{"1": 10, "2": 70},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			self := analysis.SelfTimes(tt.trace)
+			for id, want := range tt.want {
+				if got := self[id]; got != time.Duration(want)*time.Millisecond {
+					t.Errorf("span %s: self time %s, want %dms", id, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestAnalyzeGaps(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		minGap   time.Duration
+		wantGaps string
+	}{
+		{name: "every gap", wantGaps: "3+60:30 1+90:10 1+0:5 1+15:5 3+20:5"},
+		{name: "min gap", minGap: 10 * time.Millisecond, wantGaps: "3+60:30 1+90:10"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			r := analysis.Analyze(checkout(), analysis.Options{MinGap: tt.minGap})
+
+			got := make([]string, 0, len(r.Gaps))
+			for _, g := range r.Gaps {
+				got = append(got, fmt.Sprintf("%s+%d:%d", g.SpanID, g.Offset.Milliseconds(), g.Duration.Milliseconds()))
+			}
+
+			if strings.Join(got, " ") != tt.wantGaps {
+				t.Errorf("gaps %s, want %s", strings.Join(got, " "), tt.wantGaps)
+			}
+			// the idle time counts the gaps below the minimum as well
+			if r.IdleTime != 55*time.Millisecond {
+				t.Errorf("idle time %s, want 55ms", r.IdleTime)
+			}
+		})
+	}
+}
+
+func TestAdjustClockSkew(t *testing.T) {
+
+	tests := []struct {
+		name  string
+		trace *model.Trace
+		want  map[string]int64 // span id to start
+		moved []string
+	}{
+		{
+			name: "remote child ahead of its caller",
+			trace: newTrace("1",
+				span{id: "1", service: "frontend", operation: "GET", start: 0, end: 100},
+				span{id: "2", parent: "1", service: "order", operation: "Create", start: 500, end: 560},
+				span{id: "3", parent: "2", service: "order", operation: "insert", start: 510, end: 520},
+			),
+			want:  map[string]int64{"1": 0, "2": 20, "3": 30},
+			moved: []string{"2:order<frontend:-480ms"},
+		},
+		{
+			name: "remote child inside its caller",
+			trace: newTrace("1",
+				span{id: "1", service: "frontend", operation: "GET", start: 0, end: 100},
+				span{id: "2", parent: "1", service: "order", operation: "Create", start: 10, end: 30},
+			),
+			want: map[string]int64{"1": 0, "2": 10},
+		},
+		{
+			name: "same service shares the clock",
+			trace: newTrace("1",
+				span{id: "1", service: "frontend", operation: "GET", start: 0, end: 100},
+				span{id: "2", parent: "1", service: "frontend", operation: "flush", start: 150, end: 160},
+			),
+			want: map[string]int64{"1": 0, "2": 150},
+		},
+		{
+			name: "remote child longer than its caller",
+			trace: newTrace("1",
+				span{id: "1", service: "frontend", operation: "GET", start: 0, end: 100},
+				span{id: "2", parent: "1", service: "order", operation: "Create", start: 300, end: 500},
+			),
+			want: map[string]int64{"1": 0, "2": 300},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			before := make(map[string]int64)
+			for _, s := range tt.trace.Spans {
+				before[s.SpanID] = s.StartTime
+			}
+
+			adjusted, adjustments := analysis.AdjustClockSkew(tt.trace)
+
+			for id, want := range tt.want {
+				if got := offset(adjusted.Span(id).StartTime); got != want {
+					t.Errorf("span %s starts at %dms, want %dms", id, got, want)
+				}
+				if tt.trace.Span(id).StartTime != before[id] {
+					t.Errorf("span %s of the original trace moved", id)
+				}
+			}
+
+			moved := make([]string, 0, len(adjustments))
+			for _, a := range adjustments {
+				moved = append(moved, fmt.Sprintf("%s:%s<%s:%s", a.SpanID, a.Service, a.Parent, a.Delta))
+			}
+			if fmt.Sprint(moved) != fmt.Sprint(append([]string{}, tt.moved...)) {
+				t.Errorf("adjustments %v, want %v", moved, tt.moved)
+			}
+		})
+	}
+}
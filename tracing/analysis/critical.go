@@ -0,0 +1,144 @@
+package analysis
+
+import (
+	"sort"
+	"time"
+
+	"github.com/alloykh/tracer-demo/tracing/model"
+)
+
+// Segment is a slice of the critical path spent in a span (not in its children).
+// Start and End are microseconds since epoch, as in model.Span.
+type Segment struct {
+	SpanID    string `json:"spanID"`
+	Service   string `json:"service"`
+	Operation string `json:"operation"`
+	Start     int64  `json:"start"`
+	End       int64  `json:"end"`
+}
+
+// Duration returns the length of the segment
+func (s Segment) Duration() time.Duration {
+	return time.Duration(s.End-s.Start) * time.Microsecond
+}
+
+// CriticalPath returns the segments of the path which determines the duration of the root span,
+// ordered by time. Walking back from the end of a span, the child finishing last before the cursor
+// is on the path, the time between the child end and the cursor belongs to the span itself.
+// The segments cover the root span exactly once, so their durations add up to its duration.
+func CriticalPath(t *model.Trace) []Segment {
+
+	root := t.Root()
+	if root == nil {
+		return nil
+	}
+
+	children := t.Children()
+	segments := make([]Segment, 0)
+	visited := make(map[string]bool)
+
+	var walk func(s *model.Span, start, end int64)
+	walk = func(s *model.Span, start, end int64) {
+
+		visited[s.SpanID] = true
+
+		kids := append([]*model.Span(nil), children[s.SpanID]...)
+		sort.SliceStable(kids, func(i, j int) bool {
+			return spanEnd(kids[i]) > spanEnd(kids[j])
+		})
+
+		cursor := end
+		for _, c := range kids {
+			if visited[c.SpanID] || c.StartTime >= cursor || spanEnd(c) <= start {
+				continue
+			}
+
+			childEnd := min64(spanEnd(c), cursor)
+			childStart := max64(c.StartTime, start)
+
+			segments = appendSegment(segments, s, childEnd, cursor)
+			walk(c, childStart, childEnd)
+
+			cursor = childStart
+			if cursor <= start {
+				break
+			}
+		}
+
+		segments = appendSegment(segments, s, start, cursor)
+	}
+
+	walk(root, root.StartTime, spanEnd(root))
+
+	sort.SliceStable(segments, func(i, j int) bool {
+		return segments[i].Start < segments[j].Start
+	})
+
+	return segments
+}
+
+// OnCriticalPath returns the ids of the spans contributing to the critical path
+func OnCriticalPath(segments []Segment) map[string]bool {
+	ids := make(map[string]bool, len(segments))
+	for _, s := range segments {
+		ids[s.SpanID] = true
+	}
+	return ids
+}
+
+// SelfTimes returns, for each span, its duration minus the time covered by its children
+func SelfTimes(t *model.Trace) map[string]time.Duration {
+
+	children := t.Children()
+	self := make(map[string]time.Duration, len(t.Spans))
+
+	for _, s := range t.Spans {
+		covered := int64(0)
+		cursor := s.StartTime
+		end := spanEnd(s)
+
+		// children are ordered by start time, merge their intervals clipped to the parent
+		for _, c := range children[s.SpanID] {
+			cs, ce := max64(c.StartTime, cursor), min64(spanEnd(c), end)
+			if ce > cs {
+				covered += ce - cs
+				cursor = ce
+			}
+		}
+
+		self[s.SpanID] = time.Duration(s.Duration-covered) * time.Microsecond
+	}
+
+	return self
+}
+
+func appendSegment(segments []Segment, s *model.Span, start, end int64) []Segment {
+	if end <= start {
+		return segments
+	}
+	return append(segments, Segment{
+		SpanID:    s.SpanID,
+		Service:   s.ServiceName(),
+		Operation: s.OperationName,
+		Start:     start,
+		End:       end,
+	})
+}
+
+func spanEnd(s *model.Span) int64 {
+	return s.StartTime + s.Duration
+}
+
+func min64(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max64(a, b int64) int64 {
+	if a > b {
+		return a
+	}
+	return b
+}
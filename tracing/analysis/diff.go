@@ -0,0 +1,255 @@
+package analysis
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/alloykh/tracer-demo/tracing/model"
+)
+
+// NodeStats summarizes a node of a canonical tree on one side of a diff
+type NodeStats struct {
+	Traces            int           `json:"traces"`
+	CallsPerTrace     float64       `json:"callsPerTrace"`
+	MaxCallsPerParent int           `json:"maxCallsPerParent"`
+	P50               time.Duration `json:"p50"`
+	P95               time.Duration `json:"p95"`
+}
+
+// NodeDiff compares a call between the base and the head traces of an endpoint,
+// Base or Head is nil when the call only exists on the other side
+type NodeDiff struct {
+	Path      string     `json:"path"`
+	Service   string     `json:"service"`
+	Operation string     `json:"operation"`
+	Depth     int        `json:"depth"`
+	Base      *NodeStats `json:"base,omitempty"`
+	Head      *NodeStats `json:"head,omitempty"`
+
+	Added    bool `json:"added,omitempty"`
+	Removed  bool `json:"removed,omitempty"`
+	NPlusOne bool `json:"nPlusOne,omitempty"` // the head calls it in a loop, the base did not
+
+	P50Delta time.Duration `json:"p50Delta"`
+	P95Delta time.Duration `json:"p95Delta"`
+}
+
+// CallsChanged tells if the call is made a different number of times per trace
+func (d NodeDiff) CallsChanged() bool {
+	return d.Base != nil && d.Head != nil && d.Base.CallsPerTrace != d.Head.CallsPerTrace
+}
+
+// EndpointDiff compares the traces of an endpoint, BaseTraces or HeadTraces is 0 when the endpoint
+// was only seen on one side
+type EndpointDiff struct {
+	Endpoint   string     `json:"endpoint"`
+	BaseTraces int        `json:"baseTraces"`
+	HeadTraces int        `json:"headTraces"`
+	Nodes      []NodeDiff `json:"nodes"`
+}
+
+// Diff is the structural and latency comparison of two sets of traces, typically captured while
+// running the same flow against two builds
+type Diff struct {
+	Endpoints []EndpointDiff `json:"endpoints"`
+}
+
+// DiffOptions tune Compare
+type DiffOptions struct {
+	// NPlusOne is the number of calls of the same operation by one parent span from which
+	// the calls are reported as an N+1 pattern, 3 when 0
+	NPlusOne int
+}
+
+var defaultNPlusOne = 3
+
+// Compare builds the canonical trees of the base and head traces and compares them per endpoint
+func Compare(base, head []*model.Trace, opts DiffOptions) *Diff {
+
+	if opts.NPlusOne <= 0 {
+		opts.NPlusOne = defaultNPlusOne
+	}
+
+	baseTrees := BuildTrees(base)
+	headTrees := BuildTrees(head)
+
+	endpoints := make(map[string]bool)
+	for e := range baseTrees {
+		endpoints[e] = true
+	}
+	for e := range headTrees {
+		endpoints[e] = true
+	}
+
+	d := &Diff{Endpoints: make([]EndpointDiff, 0, len(endpoints))}
+
+	for e := range endpoints {
+		d.Endpoints = append(d.Endpoints, compareTrees(e, baseTrees[e], headTrees[e], opts))
+	}
+
+	sort.Slice(d.Endpoints, func(i, j int) bool {
+		return d.Endpoints[i].Endpoint < d.Endpoints[j].Endpoint
+	})
+
+	return d
+}
+
+func compareTrees(endpoint string, base, head *Tree, opts DiffOptions) EndpointDiff {
+
+	ed := EndpointDiff{Endpoint: endpoint, Nodes: make([]NodeDiff, 0)}
+
+	paths := make(map[string]*Node)
+	if base != nil {
+		ed.BaseTraces = base.Traces
+		for _, n := range base.Nodes() {
+			paths[n.Path] = n
+		}
+	}
+	if head != nil {
+		ed.HeadTraces = head.Traces
+		for _, n := range head.Nodes() {
+			paths[n.Path] = n
+		}
+	}
+
+	for path, n := range paths {
+
+		nd := NodeDiff{Path: path, Service: n.Service, Operation: n.Operation, Depth: n.Depth}
+
+		if base != nil {
+			nd.Base = stats(base.Node(path), base.Traces)
+		}
+		if head != nil {
+			nd.Head = stats(head.Node(path), head.Traces)
+		}
+
+		switch {
+		case nd.Base == nil:
+			nd.Added = true
+		case nd.Head == nil:
+			nd.Removed = true
+		default:
+			nd.P50Delta = nd.Head.P50 - nd.Base.P50
+			nd.P95Delta = nd.Head.P95 - nd.Base.P95
+		}
+
+		if nd.Head != nil && nd.Head.MaxCallsPerParent >= opts.NPlusOne &&
+			(nd.Base == nil || nd.Base.MaxCallsPerParent < opts.NPlusOne) {
+			nd.NPlusOne = true
+		}
+
+		ed.Nodes = append(ed.Nodes, nd)
+	}
+
+	sort.Slice(ed.Nodes, func(i, j int) bool {
+		return ed.Nodes[i].Path < ed.Nodes[j].Path
+	})
+
+	return ed
+}
+
+func stats(n *Node, traces int) *NodeStats {
+
+	if n == nil {
+		return nil
+	}
+
+	return &NodeStats{
+		Traces:            n.Traces,
+		CallsPerTrace:     float64(n.Calls) / float64(traces),
+		MaxCallsPerParent: n.MaxCallsPerParent,
+		P50:               n.P50(),
+		P95:               n.P95(),
+	}
+}
+
+// Thresholds decide which differences are regressions
+type Thresholds struct {
+	// MaxP95Increase is the tolerated relative p95 increase of a call, 0.2 is +20%, 0 disables the check
+	MaxP95Increase float64
+	// MinLatencyDelta ignores latency changes smaller than this, noise of short calls
+	MinLatencyDelta time.Duration
+	// MaxCallsIncrease is the tolerated relative increase of calls per trace, 0 disables the check
+	MaxCallsIncrease float64
+	// MinTraces skips the latency checks of calls seen in fewer traces on either side
+	MinTraces int
+
+	FailOnAdded    bool
+	FailOnRemoved  bool
+	FailOnNPlusOne bool
+}
+
+// Violation is a difference exceeding the thresholds
+type Violation struct {
+	Endpoint string `json:"endpoint"`
+	Path     string `json:"path,omitempty"`
+	Reason   string `json:"reason"`
+}
+
+func (v Violation) String() string {
+	if v.Path == "" {
+		return v.Endpoint + ": " + v.Reason
+	}
+	return v.Path + ": " + v.Reason
+}
+
+// Check returns the differences exceeding the thresholds, the build fails when there are any
+func (d *Diff) Check(th Thresholds) []Violation {
+
+	violations := make([]Violation, 0)
+
+	for _, e := range d.Endpoints {
+
+		if e.BaseTraces == 0 {
+			if th.FailOnAdded {
+				violations = append(violations, Violation{Endpoint: e.Endpoint, Reason: "new endpoint"})
+			}
+			continue
+		}
+		if e.HeadTraces == 0 {
+			if th.FailOnRemoved {
+				violations = append(violations, Violation{Endpoint: e.Endpoint, Reason: "endpoint not exercised anymore"})
+			}
+			continue
+		}
+
+		for _, n := range e.Nodes {
+			v := Violation{Endpoint: e.Endpoint, Path: n.Path}
+
+			if n.Added && th.FailOnAdded {
+				v.Reason = "new call"
+				violations = append(violations, v)
+			}
+			if n.Removed && th.FailOnRemoved {
+				v.Reason = "call removed"
+				violations = append(violations, v)
+			}
+			if n.NPlusOne && th.FailOnNPlusOne {
+				v.Reason = fmt.Sprintf("new N+1: %d calls from one parent", n.Head.MaxCallsPerParent)
+				violations = append(violations, v)
+			}
+
+			if n.Base == nil || n.Head == nil {
+				continue
+			}
+
+			if th.MaxCallsIncrease > 0 && n.Head.CallsPerTrace > n.Base.CallsPerTrace*(1+th.MaxCallsIncrease) {
+				v.Reason = fmt.Sprintf("calls per trace %.2f -> %.2f", n.Base.CallsPerTrace, n.Head.CallsPerTrace)
+				violations = append(violations, v)
+			}
+
+			if n.Base.Traces < th.MinTraces || n.Head.Traces < th.MinTraces {
+				continue
+			}
+
+			if th.MaxP95Increase > 0 && n.P95Delta >= th.MinLatencyDelta &&
+				float64(n.Head.P95) > float64(n.Base.P95)*(1+th.MaxP95Increase) {
+				v.Reason = fmt.Sprintf("p95 %s -> %s", n.Base.P95, n.Head.P95)
+				violations = append(violations, v)
+			}
+		}
+	}
+
+	return violations
+}
@@ -0,0 +1,107 @@
+package analysis_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alloykh/tracer-demo/tracing/analysis"
+	"github.com/alloykh/tracer-demo/tracing/model"
+)
+
+// orders returns n traces of the order endpoint, each reserving the stock calls times in reserve ms
+func orders(n, calls int, reserve int64, extra ...span) []*model.Trace {
+
+	traces := make([]*model.Trace, 0, n)
+
+	for i := 0; i < n; i++ {
+		spans := []span{{id: "1", service: "frontend", operation: "HTTP GET /order", start: 0, end: 200}}
+		for c := 0; c < calls; c++ {
+			start := 10 + int64(c)*(reserve+1)
+			spans = append(spans, span{id: fmt.Sprint(c + 2), parent: "1", service: "inventory", operation: "Reserve", start: start, end: start + reserve})
+		}
+		traces = append(traces, newTrace(fmt.Sprint(i+1), append(spans, extra...)...))
+	}
+
+	return traces
+}
+
+func TestDiffThresholds(t *testing.T) {
+
+	audit := span{id: "a", parent: "1", service: "audit", operation: "record", start: 150, end: 160}
+
+	tests := []struct {
+		name       string
+		base, head []*model.Trace
+		thresholds analysis.Thresholds
+		want       []string // reasons of the violations
+	}{
+		{
+			name:       "unchanged",
+			base:       orders(5, 1, 10),
+			head:       orders(5, 1, 10),
+			thresholds: analysis.Thresholds{MaxP95Increase: 0.2, MaxCallsIncrease: 0.5, FailOnAdded: true, FailOnRemoved: true, FailOnNPlusOne: true},
+		},
+		{
+			name:       "p95 increase",
+			base:       orders(5, 1, 10),
+			head:       orders(5, 1, 20),
+			thresholds: analysis.Thresholds{MaxP95Increase: 0.2},
+			want:       []string{"p95 10ms -> 20ms"},
+		},
+		{
+			name:       "p95 increase below the latency noise",
+			base:       orders(5, 1, 10),
+			head:       orders(5, 1, 20),
+			thresholds: analysis.Thresholds{MaxP95Increase: 0.2, MinLatencyDelta: 50 * time.Millisecond},
+		},
+		{
+			name:       "p95 increase seen in too few traces",
+			base:       orders(2, 1, 10),
+			head:       orders(2, 1, 20),
+			thresholds: analysis.Thresholds{MaxP95Increase: 0.2, MinTraces: 3},
+		},
+		{
+			name:       "n+1",
+			base:       orders(5, 1, 10),
+			head:       orders(5, 3, 10),
+			thresholds: analysis.Thresholds{FailOnNPlusOne: true, MaxCallsIncrease: 1},
+			want:       []string{"new N+1: 3 calls from one parent", "calls per trace 1.00 -> 3.00"},
+		},
+		{
+			name:       "new call",
+			base:       orders(5, 1, 10),
+			head:       orders(5, 1, 10, audit),
+			thresholds: analysis.Thresholds{FailOnAdded: true},
+			want:       []string{"new call"},
+		},
+		{
+			name:       "removed call not failing",
+			base:       orders(5, 1, 10, audit),
+			head:       orders(5, 1, 10),
+			thresholds: analysis.Thresholds{FailOnAdded: true},
+		},
+		{
+			name:       "endpoint not exercised",
+			base:       orders(5, 1, 10),
+			thresholds: analysis.Thresholds{FailOnRemoved: true},
+			want:       []string{"endpoint not exercised anymore"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			violations := analysis.Compare(tt.base, tt.head, analysis.DiffOptions{}).Check(tt.thresholds)
+
+			got := make([]string, 0, len(violations))
+			for _, v := range violations {
+				got = append(got, v.Reason)
+			}
+
+			if fmt.Sprint(got) != fmt.Sprint(append([]string{}, tt.want...)) {
+				t.Errorf("violations %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
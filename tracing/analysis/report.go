@@ -0,0 +1,283 @@
+package analysis
+
+import (
+	"sort"
+	"time"
+
+	"github.com/alloykh/tracer-demo/tracing/model"
+)
+
+// Report is the latency breakdown of a single trace
+type Report struct {
+	TraceID  string        `json:"traceID"`
+	Endpoint string        `json:"endpoint"`
+	Duration time.Duration `json:"duration"`
+
+	CriticalPath []Segment    `json:"criticalPath"`
+	Services     []Share      `json:"services"`
+	Operations   []Share      `json:"operations"`
+	Gaps         []Gap        `json:"gaps"`
+	Retries      []Retry      `json:"retries"`
+	Skew         []Adjustment `json:"skew"`
+
+	// MaxConcurrency is the largest number of spans running at the same time
+	MaxConcurrency int `json:"maxConcurrency"`
+	// IdleTime is the time inside spans with children during which none of the children ran
+	IdleTime time.Duration `json:"idleTime"`
+}
+
+// Share is the time a service or an operation accounts for in a trace
+type Share struct {
+	Name         string        `json:"name"`
+	Spans        int           `json:"spans"`
+	Errors       int           `json:"errors"`
+	SelfTime     time.Duration `json:"selfTime"`
+	CriticalTime time.Duration `json:"criticalTime"`
+}
+
+// Gap is an interval inside a span before, between or after its children where no child runs,
+// typically local work, lock waits or a missing instrumentation
+type Gap struct {
+	SpanID    string        `json:"spanID"`
+	Service   string        `json:"service"`
+	Operation string        `json:"operation"`
+	Offset    time.Duration `json:"offset"` // from the start of the trace
+	Duration  time.Duration `json:"duration"`
+}
+
+// Retry groups sibling calls to the same operation where an earlier attempt failed
+type Retry struct {
+	Parent    string        `json:"parentSpanID"`
+	Service   string        `json:"service"`
+	Operation string        `json:"operation"`
+	Attempts  int           `json:"attempts"`
+	Failed    int           `json:"failed"`
+	Overhead  time.Duration `json:"overhead"` // from the first attempt start to the last attempt start
+}
+
+// Options tune Analyze
+type Options struct {
+	// AdjustSkew shifts the spans of remote services into their callers before the analysis
+	AdjustSkew bool
+	// MinGap ignores smaller gaps
+	MinGap time.Duration
+}
+
+// Analyze computes the critical path, the per-service and per-operation breakdown, idle gaps,
+// concurrency and retry overhead of a trace
+func Analyze(t *model.Trace, opts Options) *Report {
+
+	r := &Report{TraceID: t.TraceID, Skew: []Adjustment{}}
+
+	if opts.AdjustSkew {
+		t, r.Skew = AdjustClockSkew(t)
+	}
+
+	root := t.Root()
+	if root == nil {
+		return r
+	}
+
+	r.Endpoint = Endpoint(root)
+	r.Duration = root.Elapsed()
+	r.CriticalPath = CriticalPath(t)
+
+	self := SelfTimes(t)
+
+	services := make(map[string]*Share)
+	operations := make(map[string]*Share)
+
+	for _, s := range t.Spans {
+		for _, share := range []*Share{
+			getShare(services, s.ServiceName()),
+			getShare(operations, s.ServiceName()+": "+s.OperationName),
+		} {
+			share.Spans++
+			share.SelfTime += self[s.SpanID]
+			if s.HasError() {
+				share.Errors++
+			}
+		}
+	}
+
+	for _, seg := range r.CriticalPath {
+		getShare(services, seg.Service).CriticalTime += seg.Duration()
+		getShare(operations, seg.Service+": "+seg.Operation).CriticalTime += seg.Duration()
+	}
+
+	r.Services = sortShares(services)
+	r.Operations = sortShares(operations)
+
+	start, _ := t.Bounds()
+	r.Gaps, r.IdleTime = gaps(t, start, opts.MinGap)
+	r.Retries = retries(t)
+	r.MaxConcurrency = maxConcurrency(t)
+
+	return r
+}
+
+// Endpoint names the request type of a trace: the service and operation of its root span
+func Endpoint(root *model.Span) string {
+	return root.ServiceName() + ": " + root.OperationName
+}
+
+func getShare(m map[string]*Share, name string) *Share {
+	s, ok := m[name]
+	if !ok {
+		s = &Share{Name: name}
+		m[name] = s
+	}
+	return s
+}
+
+// sortShares orders by critical time, then by self time
+func sortShares(m map[string]*Share) []Share {
+
+	shares := make([]Share, 0, len(m))
+	for _, s := range m {
+		shares = append(shares, *s)
+	}
+
+	sort.Slice(shares, func(i, j int) bool {
+		if shares[i].CriticalTime != shares[j].CriticalTime {
+			return shares[i].CriticalTime > shares[j].CriticalTime
+		}
+		if shares[i].SelfTime != shares[j].SelfTime {
+			return shares[i].SelfTime > shares[j].SelfTime
+		}
+		return shares[i].Name < shares[j].Name
+	})
+
+	return shares
+}
+
+func gaps(t *model.Trace, traceStart int64, minGap time.Duration) ([]Gap, time.Duration) {
+
+	found := make([]Gap, 0)
+	idle := time.Duration(0)
+
+	children := t.Children()
+
+	for _, s := range t.Spans {
+		kids := children[s.SpanID]
+		if len(kids) == 0 {
+			continue
+		}
+
+		gap := func(from, to int64) {
+			if to <= from {
+				return
+			}
+			d := time.Duration(to-from) * time.Microsecond
+			idle += d
+			if d >= minGap {
+				found = append(found, Gap{
+					SpanID:    s.SpanID,
+					Service:   s.ServiceName(),
+					Operation: s.OperationName,
+					Offset:    time.Duration(from-traceStart) * time.Microsecond,
+					Duration:  d,
+				})
+			}
+		}
+
+		// idle time before, between and after the children, kids are ordered by start time
+		cursor := s.StartTime
+		for _, c := range kids {
+			gap(cursor, c.StartTime)
+			cursor = max64(cursor, spanEnd(c))
+		}
+		gap(cursor, spanEnd(s))
+	}
+
+	sort.SliceStable(found, func(i, j int) bool {
+		return found[i].Duration > found[j].Duration
+	})
+
+	return found, idle
+}
+
+func retries(t *model.Trace) []Retry {
+
+	found := make([]Retry, 0)
+
+	for parent, kids := range t.Children() {
+
+		groups := make(map[string][]*model.Span)
+		order := make([]string, 0)
+		for _, c := range kids {
+			key := c.ServiceName() + "\x00" + c.OperationName
+			if _, ok := groups[key]; !ok {
+				order = append(order, key)
+			}
+			groups[key] = append(groups[key], c)
+		}
+
+		for _, key := range order {
+			attempts := groups[key]
+			if len(attempts) < 2 {
+				continue
+			}
+
+			// only failed attempts followed by another attempt count as retries,
+			// repeated successful calls are an N+1 pattern, not a retry
+			failed := 0
+			for _, a := range attempts[:len(attempts)-1] {
+				if a.HasError() {
+					failed++
+				}
+			}
+			if failed == 0 {
+				continue
+			}
+
+			first, last := attempts[0], attempts[len(attempts)-1]
+			found = append(found, Retry{
+				Parent:    parent,
+				Service:   first.ServiceName(),
+				Operation: first.OperationName,
+				Attempts:  len(attempts),
+				Failed:    failed,
+				Overhead:  time.Duration(last.StartTime-first.StartTime) * time.Microsecond,
+			})
+		}
+	}
+
+	sort.SliceStable(found, func(i, j int) bool {
+		return found[i].Overhead > found[j].Overhead
+	})
+
+	return found
+}
+
+// maxConcurrency sweeps span starts and ends
+func maxConcurrency(t *model.Trace) int {
+
+	type event struct {
+		at    int64
+		delta int
+	}
+
+	events := make([]event, 0, 2*len(t.Spans))
+	for _, s := range t.Spans {
+		events = append(events, event{s.StartTime, 1}, event{spanEnd(s), -1})
+	}
+
+	// ends before starts at the same instant, back to back spans don't overlap
+	sort.Slice(events, func(i, j int) bool {
+		if events[i].at == events[j].at {
+			return events[i].delta < events[j].delta
+		}
+		return events[i].at < events[j].at
+	})
+
+	current, max := 0, 0
+	for _, e := range events {
+		current += e.delta
+		if current > max {
+			max = current
+		}
+	}
+
+	return max
+}
@@ -0,0 +1,92 @@
+package analysis
+
+import (
+	"time"
+
+	"github.com/alloykh/tracer-demo/tracing/model"
+)
+
+// Adjustment records a shift applied to a span subtree to compensate clock skew between services
+type Adjustment struct {
+	SpanID  string        `json:"spanID"`
+	Service string        `json:"service"`
+	Parent  string        `json:"parentService"`
+	Delta   time.Duration `json:"delta"`
+}
+
+// AdjustClockSkew returns a copy of the trace where the spans of a service called by another one
+// are shifted so they fit inside the calling span, the way the jaeger UI adjusts skew:
+// a child shorter than its parent is centered in it, splitting the network latency evenly.
+// Spans of the same service share a clock and are never moved relative to their parent.
+func AdjustClockSkew(t *model.Trace) (*model.Trace, []Adjustment) {
+
+	adjusted := copyTrace(t)
+	adjustments := make([]Adjustment, 0)
+
+	root := adjusted.Root()
+	if root == nil {
+		return adjusted, adjustments
+	}
+
+	children := adjusted.Children()
+
+	var shift func(s *model.Span, delta int64)
+	shift = func(s *model.Span, delta int64) {
+		s.StartTime += delta
+		for i := range s.Logs {
+			s.Logs[i].Timestamp += delta
+		}
+		for _, c := range children[s.SpanID] {
+			shift(c, delta)
+		}
+	}
+
+	var walk func(parent *model.Span)
+	walk = func(parent *model.Span) {
+
+		for _, c := range children[parent.SpanID] {
+
+			if c.ServiceName() != parent.ServiceName() && !fits(parent, c) && c.Duration <= parent.Duration {
+				delta := parent.StartTime + (parent.Duration-c.Duration)/2 - c.StartTime
+				shift(c, delta)
+				adjustments = append(adjustments, Adjustment{
+					SpanID:  c.SpanID,
+					Service: c.ServiceName(),
+					Parent:  parent.ServiceName(),
+					Delta:   time.Duration(delta) * time.Microsecond,
+				})
+			}
+
+			walk(c)
+		}
+	}
+
+	walk(root)
+
+	adjusted.Normalize()
+
+	return adjusted, adjustments
+}
+
+func fits(parent, child *model.Span) bool {
+	return child.StartTime >= parent.StartTime && spanEnd(child) <= spanEnd(parent)
+}
+
+// copyTrace copies the spans and their logs, tags and processes are shared
+func copyTrace(t *model.Trace) *model.Trace {
+
+	c := &model.Trace{
+		TraceID:   t.TraceID,
+		Spans:     make([]*model.Span, 0, len(t.Spans)),
+		Processes: t.Processes,
+		Warnings:  t.Warnings,
+	}
+
+	for _, s := range t.Spans {
+		sc := *s
+		sc.Logs = append([]model.Log(nil), s.Logs...)
+		c.Spans = append(c.Spans, &sc)
+	}
+
+	return c
+}
@@ -0,0 +1,186 @@
+package analysis
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/alloykh/tracer-demo/tracing/model"
+)
+
+// PathSeparator joins the names of the calls from the root to a node of a canonical tree
+const PathSeparator = " > "
+
+// Node is a call of the canonical tree of an endpoint: all the spans of its traces reached by
+// the same path of service and operation names from the root. Span ids play no role and
+// repeated siblings (a loop calling the same operation) are merged into one node.
+type Node struct {
+	Path      string `json:"path"`
+	Service   string `json:"service"`
+	Operation string `json:"operation"`
+	Depth     int    `json:"depth"`
+
+	// Traces is the number of traces with at least one call
+	Traces int `json:"traces"`
+	// Calls is the number of calls in all the traces
+	Calls int `json:"calls"`
+	// MaxCallsPerParent is the most calls made by a single parent span, above 1 it is a loop
+	MaxCallsPerParent int `json:"maxCallsPerParent"`
+
+	Durations []time.Duration `json:"-"`
+}
+
+// P50 returns the median duration of the calls
+func (n *Node) P50() time.Duration {
+	return Percentile(n.Durations, 50)
+}
+
+// P95 returns the 95th percentile duration of the calls
+func (n *Node) P95() time.Duration {
+	return Percentile(n.Durations, 95)
+}
+
+// Tree is the canonical tree of the traces of one endpoint
+type Tree struct {
+	Endpoint string
+	Traces   int
+
+	nodes map[string]*Node
+}
+
+// Node returns the node at path, nil when no trace made this call
+func (t *Tree) Node(path string) *Node {
+	return t.nodes[path]
+}
+
+// Nodes returns the nodes parents first, siblings by name
+func (t *Tree) Nodes() []*Node {
+
+	nodes := make([]*Node, 0, len(t.nodes))
+	for _, n := range t.nodes {
+		nodes = append(nodes, n)
+	}
+
+	// the separator starts with a space, so sorting the paths puts every subtree right after its root
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].Path < nodes[j].Path
+	})
+
+	return nodes
+}
+
+// BuildTrees groups the traces by endpoint and merges each group into a canonical tree
+func BuildTrees(traces []*model.Trace) map[string]*Tree {
+
+	trees := make(map[string]*Tree)
+
+	for _, t := range traces {
+		root := t.Root()
+		if root == nil {
+			continue
+		}
+
+		endpoint := CanonicalName(root)
+		tree, ok := trees[endpoint]
+		if !ok {
+			tree = &Tree{Endpoint: endpoint, nodes: make(map[string]*Node)}
+			trees[endpoint] = tree
+		}
+
+		tree.add(t, root)
+	}
+
+	return trees
+}
+
+func (t *Tree) add(trace *model.Trace, root *model.Span) {
+
+	t.Traces++
+
+	children := trace.Children()
+	seen := make(map[string]bool)
+	visited := make(map[string]bool)
+
+	var walk func(spans []*model.Span, parentPath string, depth int)
+	walk = func(spans []*model.Span, parentPath string, depth int) {
+
+		// calls per name from this parent
+		perParent := make(map[string]int)
+
+		for _, s := range spans {
+			if visited[s.SpanID] {
+				continue
+			}
+			visited[s.SpanID] = true
+
+			name := CanonicalName(s)
+			path := name
+			if parentPath != "" {
+				path = parentPath + PathSeparator + name
+			}
+
+			n, ok := t.nodes[path]
+			if !ok {
+				n = &Node{
+					Path:      path,
+					Service:   s.ServiceName(),
+					Operation: CanonicalOperation(s.OperationName),
+					Depth:     depth,
+				}
+				t.nodes[path] = n
+			}
+
+			if !seen[path] {
+				seen[path] = true
+				n.Traces++
+			}
+
+			n.Calls++
+			n.Durations = append(n.Durations, s.Elapsed())
+
+			perParent[path]++
+			if perParent[path] > n.MaxCallsPerParent {
+				n.MaxCallsPerParent = perParent[path]
+			}
+
+			walk(children[s.SpanID], path, depth+1)
+		}
+	}
+
+	walk([]*model.Span{root}, "", 0)
+}
+
+// CanonicalName is the service and the canonical operation of a span
+func CanonicalName(s *model.Span) string {
+	return s.ServiceName() + ": " + CanonicalOperation(s.OperationName)
+}
+
+var (
+	uuidSegment = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+	hexSegment  = regexp.MustCompile(`^[0-9a-fA-F]{16,}$`)
+	numSegment  = regexp.MustCompile(`^[0-9]+$`)
+)
+
+// CanonicalOperation drops the query string of an operation name and replaces the path segments
+// looking like ids (numbers, uuids, long hex strings) by {id},
+// so "HTTP GET /order/42?x=1" and "HTTP GET /order/43" are the same call
+func CanonicalOperation(op string) string {
+
+	if i := strings.IndexByte(op, '?'); i >= 0 {
+		op = op[:i]
+	}
+
+	if !strings.Contains(op, "/") {
+		return op
+	}
+
+	segments := strings.Split(op, "/")
+	for i, seg := range segments {
+		if numSegment.MatchString(seg) || uuidSegment.MatchString(seg) || hexSegment.MatchString(seg) {
+			segments[i] = "{id}"
+		}
+	}
+
+	return strings.Join(segments, "/")
+}
@@ -0,0 +1,231 @@
+package async_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alloykh/tracer-demo/tracing/async"
+	"github.com/alloykh/tracer-demo/tracing/semconv"
+	"github.com/opentracing/opentracing-go"
+	"github.com/uber/jaeger-client-go"
+)
+
+func newTracer(t *testing.T) (opentracing.Tracer, *jaeger.InMemoryReporter) {
+	t.Helper()
+
+	reporter := jaeger.NewInMemoryReporter()
+	tracer, closer := jaeger.NewTracer("async-test", jaeger.NewConstSampler(true), reporter)
+	t.Cleanup(func() { _ = closer.Close() })
+
+	return tracer, reporter
+}
+
+// spansNamed returns the reported spans with the operation name
+func spansNamed(reporter *jaeger.InMemoryReporter, name string) []*jaeger.Span {
+	var spans []*jaeger.Span
+	for _, s := range reporter.GetSpans() {
+		if span := s.(*jaeger.Span); span.OperationName() == name {
+			spans = append(spans, span)
+		}
+	}
+	return spans
+}
+
+func failed(span *jaeger.Span) bool {
+	v, _ := span.Tags()[semconv.ErrorKey].(bool)
+	return v
+}
+
+func TestGoOutlivesItsParent(t *testing.T) {
+
+	tracer, reporter := newTracer(t)
+
+	parent := tracer.StartSpan("request")
+	ctx, cancel := context.WithCancel(opentracing.ContextWithSpan(context.Background(), parent))
+
+	release := make(chan struct{})
+	done := make(chan error, 1)
+
+	async.Go(ctx, "send receipt", func(ctx context.Context) error {
+		<-release
+		done <- ctx.Err()
+		return nil
+	}, async.WithTracer(tracer))
+
+	// the request is answered before the background work ends
+	cancel()
+	parent.Finish()
+	close(release)
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("background context cancelled with its parent: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("background task did not run")
+	}
+
+	var spans []*jaeger.Span
+	for deadline := time.Now().Add(2 * time.Second); len(spans) == 0 && time.Now().Before(deadline); {
+		spans = spansNamed(reporter, "send receipt")
+		time.Sleep(time.Millisecond)
+	}
+	if len(spans) != 1 {
+		t.Fatalf("%d background spans, want 1", len(spans))
+	}
+
+	refs := spans[0].References()
+	if len(refs) != 1 || refs[0].Type != opentracing.FollowsFromRef {
+		t.Fatalf("references %v, want one follows from", refs)
+	}
+	if ref := refs[0].ReferencedContext.(jaeger.SpanContext); ref.SpanID() != parent.Context().(jaeger.SpanContext).SpanID() {
+		t.Error("background span does not follow from the request span")
+	}
+}
+
+func TestGroupReturnsTheFirstError(t *testing.T) {
+
+	tracer, reporter := newTracer(t)
+
+	parent := tracer.StartSpan("request")
+	defer parent.Finish()
+
+	errStock := errors.New("stock unavailable")
+
+	g, _ := async.WithContext(opentracing.ContextWithSpan(context.Background(), parent), async.WithTracer(tracer))
+	g.Go("get user", func(ctx context.Context) error { return nil })
+	g.Go("get stock", func(ctx context.Context) error { return errStock })
+	g.Go("get price", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	if err := g.Wait(); err != errStock {
+		t.Errorf("wait: %v, want %v", err, errStock)
+	}
+
+	want := map[string]bool{"get user": false, "get stock": true, "get price": true}
+	for name, wantFailed := range want {
+		spans := spansNamed(reporter, name)
+		if len(spans) != 1 {
+			t.Fatalf("%s: %d spans, want 1", name, len(spans))
+		}
+		if failed(spans[0]) != wantFailed {
+			t.Errorf("%s: error tag %v, want %v", name, failed(spans[0]), wantFailed)
+		}
+		if spans[0].SpanContext().ParentID() != parent.Context().(jaeger.SpanContext).SpanID() {
+			t.Errorf("%s: not a child of the group span", name)
+		}
+	}
+}
+
+func TestPoolQueueWait(t *testing.T) {
+
+	tracer, reporter := newTracer(t)
+
+	pool := async.NewPool("mail", 1, 2, async.WithTracer(tracer))
+
+	release := make(chan struct{})
+	if err := pool.Submit(context.Background(), "busy", func(ctx context.Context) error {
+		<-release
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := pool.Submit(context.Background(), "queued", func(ctx context.Context) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	close(release)
+	pool.Close()
+
+	spans := spansNamed(reporter, "queued")
+	if len(spans) != 1 {
+		t.Fatalf("%d queued spans, want 1", len(spans))
+	}
+
+	tags := spans[0].Tags()
+	if wait, _ := tags[semconv.QueueWaitKey].(int64); wait < 30 {
+		t.Errorf("%s %v, want at least 30", semconv.QueueWaitKey, tags[semconv.QueueWaitKey])
+	}
+	if tags[semconv.PoolNameKey] != "mail" || tags[semconv.PoolWorkerKey] != 0 {
+		t.Errorf("tags %v, want the pool name and its worker", tags)
+	}
+
+	if err := pool.Submit(context.Background(), "late", func(ctx context.Context) error { return nil }); err != async.ErrPoolClosed {
+		t.Errorf("submit after close: %v, want %v", err, async.ErrPoolClosed)
+	}
+}
+
+func TestSchedulerRuns(t *testing.T) {
+
+	tracer, reporter := newTracer(t)
+
+	scheduling := tracer.StartSpan("startup")
+	ctx := opentracing.ContextWithSpan(context.Background(), scheduling)
+
+	var runs int32
+	second := make(chan struct{})
+
+	s := async.NewScheduler(async.WithTracer(tracer))
+	s.Every(ctx, "stock report", 5*time.Millisecond, func(ctx context.Context) error {
+		if atomic.AddInt32(&runs, 1) == 2 {
+			close(second)
+			// the run in progress is cancelled by Stop
+			<-ctx.Done()
+		}
+		return nil
+	})
+
+	select {
+	case <-second:
+	case <-time.After(2 * time.Second):
+		t.Fatal("the job did not run twice")
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		s.Stop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-time.After(2 * time.Second):
+		t.Fatal("stop did not cancel the running job")
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	if n := atomic.LoadInt32(&runs); n != 2 {
+		t.Errorf("%d runs, want 2 and none after stop", n)
+	}
+
+	sc := scheduling.Context().(jaeger.SpanContext)
+
+	spans := spansNamed(reporter, "stock report")
+	if len(spans) != 2 {
+		t.Fatalf("%d run spans, want 2", len(spans))
+	}
+
+	for i, span := range spans {
+		tags := span.Tags()
+		if span.SpanContext().ParentID() != 0 || len(span.References()) != 0 {
+			t.Errorf("run %d is not a root span", i+1)
+		}
+		if span.SpanContext().TraceID() == sc.TraceID() {
+			t.Errorf("run %d is in the trace of the scheduling span", i+1)
+		}
+		if tags[semconv.LinkTraceIDKey] != sc.TraceID().String() || tags[semconv.LinkSpanIDKey] != sc.SpanID().String() ||
+			tags[semconv.LinkTypeKey] != "scheduled_by" {
+			t.Errorf("run %d: link tags %v, want the scheduling span", i+1, tags)
+		}
+		if tags[semconv.ScheduleRunKey] != i+1 {
+			t.Errorf("run %d: %s %v", i+1, semconv.ScheduleRunKey, tags[semconv.ScheduleRunKey])
+		}
+	}
+}
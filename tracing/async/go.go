@@ -0,0 +1,28 @@
+package async
+
+import (
+	"context"
+
+	"github.com/opentracing/opentracing-go"
+)
+
+// Go runs fn in a goroutine with a span following from the span of ctx: part of the same trace,
+// without the parent waiting for it. fn gets a context with the values of ctx but not its cancellation,
+// it keeps running once the request that started it has been answered.
+//
+//	async.Go(ctx, "send receipt", func(ctx context.Context) error { return mailer.Send(ctx, receipt) })
+func Go(ctx context.Context, name string, fn func(ctx context.Context) error, opts ...Option) {
+
+	o := newOptions(opts)
+
+	var refs []opentracing.StartSpanOption
+	if parent := opentracing.SpanFromContext(ctx); parent != nil {
+		refs = append(refs, opentracing.FollowsFrom(parent.Context()))
+	}
+
+	span := o.startSpan(name, refs...)
+
+	go func() {
+		_ = o.run(Detach(ctx), span, fn)
+	}()
+}
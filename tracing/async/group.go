@@ -0,0 +1,80 @@
+package async
+
+import (
+	"context"
+	"sync"
+
+	"github.com/opentracing/opentracing-go"
+)
+
+// Group runs tasks concurrently and waits for them, each task is a child span of the span of the group context.
+// Like errgroup, the first failure cancels the context of the other tasks and is returned by Wait.
+//
+//	g, ctx := async.WithContext(ctx)
+//	g.Go("get user", func(ctx context.Context) error { ... })
+//	g.Go("get stock", func(ctx context.Context) error { ... })
+//	err := g.Wait()
+type Group struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	opts   *options
+
+	wg      sync.WaitGroup
+	errOnce sync.Once
+	err     error
+
+	sem chan struct{}
+}
+
+// WithContext returns a group and the context cancelled by its first failure or by Wait
+func WithContext(ctx context.Context, opts ...Option) (*Group, context.Context) {
+
+	ctx, cancel := context.WithCancel(ctx)
+
+	return &Group{ctx: ctx, cancel: cancel, opts: newOptions(opts)}, ctx
+}
+
+// SetLimit bounds the tasks running at once, Go blocks until one finishes. Call it before Go.
+func (g *Group) SetLimit(n int) {
+	if n > 0 {
+		g.sem = make(chan struct{}, n)
+	}
+}
+
+// Go runs fn in a goroutine with a child span named name, a failed task is tagged with error=true
+func (g *Group) Go(name string, fn func(ctx context.Context) error) {
+
+	if g.sem != nil {
+		g.sem <- struct{}{}
+	}
+
+	var refs []opentracing.StartSpanOption
+	if parent := opentracing.SpanFromContext(g.ctx); parent != nil {
+		refs = append(refs, opentracing.ChildOf(parent.Context()))
+	}
+
+	span := g.opts.startSpan(name, refs...)
+
+	g.wg.Add(1)
+
+	go func() {
+		defer g.wg.Done()
+		if g.sem != nil {
+			defer func() { <-g.sem }()
+		}
+
+		if err := g.opts.run(g.ctx, span, fn); err != nil {
+			g.errOnce.Do(func() {
+				g.err = err
+				g.cancel()
+			})
+		}
+	}()
+}
+
+// Wait waits for every task and returns the first failure
+func (g *Group) Wait() error {
+	g.wg.Wait()
+	g.cancel()
+	return g.err
+}
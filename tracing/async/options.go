@@ -0,0 +1,123 @@
+package async
+
+import (
+	"context"
+	"runtime/debug"
+	"time"
+
+	"github.com/alloykh/tracer-demo/log"
+	"github.com/alloykh/tracer-demo/tracing/semconv"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"go.uber.org/zap"
+)
+
+const componentName = "async"
+
+type options struct {
+	tracer opentracing.Tracer
+	logr   *log.Factory
+	tags   opentracing.Tags
+}
+
+// Option controls the spans and logs of the background work.
+type Option func(*options)
+
+// WithTracer sets the tracer of the spans, the global tracer by default
+func WithTracer(tracer opentracing.Tracer) Option {
+	return func(o *options) {
+		o.tracer = tracer
+	}
+}
+
+// WithLogger logs failures and panics with the trace of the task, nothing is logged by default
+func WithLogger(logr *log.Factory) Option {
+	return func(o *options) {
+		o.logr = logr
+	}
+}
+
+// WithTag adds a tag to every span
+func WithTag(key string, value interface{}) Option {
+	return func(o *options) {
+		o.tags[key] = value
+	}
+}
+
+func newOptions(opts []Option) *options {
+
+	o := &options{
+		tags: opentracing.Tags{},
+	}
+
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return o
+}
+
+func (o *options) getTracer() opentracing.Tracer {
+	if o.tracer != nil {
+		return o.tracer
+	}
+	return opentracing.GlobalTracer()
+}
+
+func (o *options) startSpan(name string, refs ...opentracing.StartSpanOption) opentracing.Span {
+
+	span := o.getTracer().StartSpan(name, append(refs, o.tags)...)
+	ext.Component.Set(span, componentName)
+
+	return span
+}
+
+// run calls fn with the span in ctx, records its error or panic and finishes the span.
+// A panic is re-raised once recorded, background work must not fail silently.
+func (o *options) run(ctx context.Context, span opentracing.Span, fn func(ctx context.Context) error) (err error) {
+
+	ctx = opentracing.ContextWithSpan(ctx, span)
+	start := time.Now()
+
+	defer func() {
+
+		if p := recover(); p != nil {
+			semconv.Panic(span, p, debug.Stack())
+			if o.logr != nil {
+				o.logr.For(ctx).Error("background task panic", zap.Any("panic", p))
+			}
+			span.Finish()
+			panic(p)
+		}
+
+		if err != nil {
+			if o.logr != nil {
+				ext.Error.Set(span, true)
+				o.logr.For(ctx).Error("background task failed", zap.Duration("duration", time.Since(start)), zap.String("err", err.Error()))
+			} else {
+				semconv.Exception(span, err)
+			}
+		}
+
+		span.Finish()
+	}()
+
+	return fn(ctx)
+}
+
+// detached keeps the values of its parent, the span and the baggage, but not its cancellation:
+// background work outlives the request that started it.
+type detached struct {
+	context.Context
+}
+
+func (detached) Deadline() (time.Time, bool) { return time.Time{}, false }
+
+func (detached) Done() <-chan struct{} { return nil }
+
+func (detached) Err() error { return nil }
+
+// Detach returns a context with the values of ctx which is never cancelled
+func Detach(ctx context.Context) context.Context {
+	return detached{ctx}
+}
@@ -0,0 +1,127 @@
+package async
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/alloykh/tracer-demo/tracing/semconv"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	otlog "github.com/opentracing/opentracing-go/log"
+	"github.com/pkg/errors"
+)
+
+// ErrPoolClosed - the pool does not accept tasks once closed
+var ErrPoolClosed = errors.New("worker pool closed")
+
+// Pool runs the submitted tasks on a fixed number of workers.
+// Each task is a span following from the submitter's span, started when the task is queued:
+// the queue.wait_ms tag and the dequeued event tell the time spent waiting for a worker.
+type Pool struct {
+	name string
+	opts *options
+
+	tasks chan *task
+	wg    sync.WaitGroup
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+type task struct {
+	ctx    context.Context
+	span   opentracing.Span
+	queued time.Time
+	fn     func(ctx context.Context) error
+}
+
+// NewPool starts workers goroutines taking tasks from a queue of queueSize
+func NewPool(name string, workers, queueSize int, opts ...Option) *Pool {
+
+	if workers <= 0 {
+		workers = 1
+	}
+
+	p := &Pool{
+		name:  name,
+		opts:  newOptions(opts),
+		tasks: make(chan *task, queueSize),
+	}
+
+	p.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go p.work(i)
+	}
+
+	return p
+}
+
+// Submit queues fn, waiting for room in the queue until ctx is done. fn gets the values of ctx
+// but not its cancellation, it runs even if the submitter is gone by then.
+func (p *Pool) Submit(ctx context.Context, name string, fn func(ctx context.Context) error) error {
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.closed {
+		return ErrPoolClosed
+	}
+
+	queued := time.Now()
+
+	refs := []opentracing.StartSpanOption{opentracing.StartTime(queued), semconv.PoolTask(p.name)}
+	if parent := opentracing.SpanFromContext(ctx); parent != nil {
+		refs = append(refs, opentracing.FollowsFrom(parent.Context()))
+	}
+
+	t := &task{
+		ctx:    Detach(ctx),
+		span:   p.opts.startSpan(name, refs...),
+		queued: queued,
+		fn:     fn,
+	}
+
+	select {
+	case p.tasks <- t:
+		return nil
+	case <-ctx.Done():
+		ext.Error.Set(t.span, true)
+		t.span.LogFields(otlog.String("event", "not queued"), otlog.String("message", ctx.Err().Error()))
+		t.span.Finish()
+		return ctx.Err()
+	}
+}
+
+// Queued returns the number of tasks waiting for a worker
+func (p *Pool) Queued() int {
+	return len(p.tasks)
+}
+
+// Close stops accepting tasks and waits for the queued ones to run
+func (p *Pool) Close() {
+
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.tasks)
+	}
+	p.mu.Unlock()
+
+	p.wg.Wait()
+}
+
+func (p *Pool) work(id int) {
+
+	defer p.wg.Done()
+
+	for t := range p.tasks {
+
+		wait := time.Since(t.queued)
+
+		semconv.PoolDequeued(t.span, id, wait)
+		t.span.LogFields(otlog.String("event", "dequeued"), otlog.Int("worker", id))
+
+		_ = p.opts.run(t.ctx, t.span, t.fn)
+	}
+}
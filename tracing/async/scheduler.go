@@ -0,0 +1,103 @@
+package async
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/alloykh/tracer-demo/tracing"
+	"github.com/alloykh/tracer-demo/tracing/semconv"
+	"github.com/opentracing/opentracing-go"
+)
+
+// Scheduler runs periodic jobs. Each run is the root span of its own trace, a scheduled job is not part of
+// the request that scheduled it: the span of the scheduling context is recorded as a link instead.
+//
+//	s := async.NewScheduler(async.WithLogger(logr))
+//	s.Every(ctx, "stock report", time.Minute, report)
+//	defer s.Stop()
+type Scheduler struct {
+	opts *options
+
+	mu      sync.Mutex
+	stopped bool
+	stop    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// NewScheduler returns a scheduler without jobs
+func NewScheduler(opts ...Option) *Scheduler {
+	return &Scheduler{
+		opts: newOptions(opts),
+		stop: make(chan struct{}),
+	}
+}
+
+// Every runs fn every interval until ctx is done or the scheduler is stopped. Runs do not overlap,
+// a run taking longer than interval delays the next one. fn gets the values of ctx, the span of the run
+// replacing the one of ctx, and is cancelled by Stop.
+func (s *Scheduler) Every(ctx context.Context, name string, interval time.Duration, fn func(ctx context.Context) error) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stopped {
+		return
+	}
+
+	var scheduling opentracing.SpanContext
+	if parent := opentracing.SpanFromContext(ctx); parent != nil {
+		scheduling = parent.Context()
+	}
+
+	s.wg.Add(1)
+	go s.loop(ctx, name, interval, scheduling, fn)
+}
+
+// Stop cancels the running jobs and waits for them
+func (s *Scheduler) Stop() {
+
+	s.mu.Lock()
+	if !s.stopped {
+		s.stopped = true
+		close(s.stop)
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+}
+
+func (s *Scheduler) loop(ctx context.Context, name string, interval time.Duration, scheduling opentracing.SpanContext, fn func(ctx context.Context) error) {
+
+	defer s.wg.Done()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	go func() {
+		select {
+		case <-s.stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for run := 1; ; run++ {
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		span := s.opts.startSpan(name, semconv.ScheduleRun(run, interval))
+		if scheduling != nil {
+			tracing.TagLink(span, scheduling, "scheduled_by")
+		}
+
+		_ = s.opts.run(ctx, span, fn)
+	}
+}
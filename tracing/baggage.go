@@ -0,0 +1,89 @@
+package tracing
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+	"github.com/opentracing/opentracing-go"
+	"github.com/uber/jaeger-client-go"
+)
+
+// BaggageTagPrefix - prefix of the span tags and log fields carrying baggage items
+const BaggageTagPrefix = "baggage."
+
+// SetBaggage sets a baggage item on the span of ctx, it travels with the trace to every downstream service.
+// It reports false when ctx has no span. The tracer may still drop the item or truncate its value,
+// see WithBaggageRestrictions.
+func SetBaggage(ctx context.Context, key, value string) bool {
+
+	span := opentracing.SpanFromContext(ctx)
+	if span == nil {
+		return false
+	}
+
+	span.SetBaggageItem(key, value)
+
+	return true
+}
+
+// Baggage returns the baggage item of the span of ctx, "" when it is missing
+func Baggage(ctx context.Context, key string) string {
+
+	if span := opentracing.SpanFromContext(ctx); span != nil {
+		return span.BaggageItem(key)
+	}
+
+	return ""
+}
+
+// AllBaggage returns every baggage item of the span of ctx
+func AllBaggage(ctx context.Context) map[string]string {
+
+	items := make(map[string]string)
+
+	if span := opentracing.SpanFromContext(ctx); span != nil {
+		span.Context().ForeachBaggageItem(func(k, v string) bool {
+			items[k] = v
+			return true
+		})
+	}
+
+	return items
+}
+
+// SetGinBaggage sets a baggage item on the request span, see SetBaggage
+func SetGinBaggage(c *gin.Context, key, value string) bool {
+	return SetBaggage(c.Request.Context(), key, value)
+}
+
+// GinBaggage returns the baggage item of the request span, see Baggage
+func GinBaggage(c *gin.Context, key string) string {
+	return Baggage(c.Request.Context(), key)
+}
+
+// baggageTagger tags finished spans with the baggage items of its keys
+type baggageTagger []string
+
+// OnStartSpan implements jaeger.ContribObserver. The span is tagged when it finishes,
+// by then it has the items set on it as well as the inherited ones.
+func (keys baggageTagger) OnStartSpan(sp opentracing.Span, _ string, _ opentracing.StartSpanOptions) (jaeger.ContribSpanObserver, bool) {
+	return &baggageSpanTagger{keys: keys, span: sp}, true
+}
+
+type baggageSpanTagger struct {
+	keys baggageTagger
+	span opentracing.Span
+}
+
+func (t *baggageSpanTagger) OnSetOperationName(string) {}
+
+func (t *baggageSpanTagger) OnSetTag(string, interface{}) {}
+
+// OnFinish runs before the span is reported, its tags can still change
+func (t *baggageSpanTagger) OnFinish(opentracing.FinishOptions) {
+	for _, key := range t.keys {
+		if v := t.span.BaggageItem(key); v != "" {
+			t.span.SetTag(BaggageTagPrefix+key, v)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package baggage
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/alloykh/tracer-demo/log"
+	"go.uber.org/zap"
+)
+
+// Path is where jaeger clients poll their baggage restrictions, on the host:port of tracing.WithBaggageRestrictions
+const Path = "/baggageRestrictions"
+
+// Handler serves the jaeger-agent baggage restrictions API: GET /baggageRestrictions?service=<name>.
+// Mount it next to the sampling strategies, e.g. sampling.NewServer(addr, store, logr, sampling.WithHandler(baggage.Path, baggage.Handler(rs, logr))).
+func Handler(store *Store, logr *log.Factory) http.Handler {
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		service := r.URL.Query().Get("service")
+		if service == "" {
+			http.Error(w, "'service' parameter must be provided", http.StatusBadRequest)
+			return
+		}
+
+		data, err := json.Marshal(store.Get(service))
+		if err != nil {
+			logr.Default().Error("baggage restrictions marshal", zap.String("service", service), zap.String("err", err.Error()))
+			http.Error(w, "cannot marshal restrictions", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(data)
+	})
+}
@@ -0,0 +1,156 @@
+package baggage
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/uber/jaeger-client-go/thrift-gen/baggage"
+	"gopkg.in/yaml.v2"
+)
+
+// defaultMaxValueLength is used for keys without max_value_length
+const defaultMaxValueLength = 64
+
+// Restrictions is the content of a baggage restrictions file, the baggage keys each service may set.
+// The keys of default_keys are allowed everywhere, service_keys add to them or override their length:
+//
+//	{
+//	  "default_keys": [{"key": "tenant", "max_value_length": 32}],
+//	  "service_keys": [
+//	    {"service": "frontend", "keys": [{"key": "user", "max_value_length": 64}]}
+//	  ]
+//	}
+//
+// Jaeger clients drop the baggage items of keys not listed and truncate longer values.
+type Restrictions struct {
+	DefaultKeys []Key        `json:"default_keys" yaml:"default_keys"`
+	ServiceKeys []ServiceKey `json:"service_keys" yaml:"service_keys"`
+}
+
+// Key - an allowed baggage key and the maximum length of its values
+type Key struct {
+	Key            string `json:"key" yaml:"key"`
+	MaxValueLength int    `json:"max_value_length" yaml:"max_value_length"`
+}
+
+// ServiceKey lists the keys allowed to a single service on top of the default ones.
+type ServiceKey struct {
+	Service string `json:"service" yaml:"service"`
+	Keys    []Key  `json:"keys" yaml:"keys"`
+}
+
+// ParseRestrictions decodes a restrictions file, the format is picked by the file extension (.yaml, .yml or .json)
+func ParseRestrictions(name string, data []byte) (r *Restrictions, err error) {
+
+	r = &Restrictions{}
+
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".yaml", ".yml":
+		err = yaml.UnmarshalStrict(data, r)
+	default:
+		err = json.Unmarshal(data, r)
+	}
+
+	if err != nil {
+		return nil, errors.Wrap(err, "baggage restrictions decode")
+	}
+
+	if err = r.Validate(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// Validate checks the keys and lengths of the file.
+func (r *Restrictions) Validate() error {
+
+	if err := validateKeys(r.DefaultKeys); err != nil {
+		return errors.Wrap(err, "default_keys")
+	}
+
+	seen := make(map[string]bool, len(r.ServiceKeys))
+
+	for i, sk := range r.ServiceKeys {
+		if sk.Service == "" {
+			return fmt.Errorf("service_keys[%d]: service name is empty", i)
+		}
+		if seen[sk.Service] {
+			return fmt.Errorf("service_keys[%d]: duplicate service %q", i, sk.Service)
+		}
+		seen[sk.Service] = true
+
+		if err := validateKeys(sk.Keys); err != nil {
+			return errors.Wrapf(err, "service_keys[%d] (%s)", i, sk.Service)
+		}
+	}
+
+	return nil
+}
+
+func validateKeys(keys []Key) error {
+
+	seen := make(map[string]bool, len(keys))
+
+	for i, k := range keys {
+		if k.Key == "" {
+			return fmt.Errorf("keys[%d]: key is empty", i)
+		}
+		if seen[k.Key] {
+			return fmt.Errorf("keys[%d]: duplicate key %q", i, k.Key)
+		}
+		seen[k.Key] = true
+
+		if k.MaxValueLength < 0 {
+			return fmt.Errorf("keys[%d] (%s): max_value_length must not be negative, got %d", i, k.Key, k.MaxValueLength)
+		}
+	}
+
+	return nil
+}
+
+// Allowed returns the keys of the service with their maximum lengths, default keys included
+func (r *Restrictions) Allowed(service string) map[string]int {
+
+	allowed := make(map[string]int, len(r.DefaultKeys))
+
+	add := func(keys []Key) {
+		for _, k := range keys {
+			length := k.MaxValueLength
+			if length == 0 {
+				length = defaultMaxValueLength
+			}
+			allowed[k.Key] = length
+		}
+	}
+
+	add(r.DefaultKeys)
+
+	for _, sk := range r.ServiceKeys {
+		if sk.Service == service {
+			add(sk.Keys)
+			break
+		}
+	}
+
+	return allowed
+}
+
+// Response builds the answer of the /baggageRestrictions endpoint for the given service, sorted by key.
+func (r *Restrictions) Response(service string) []*baggage.BaggageRestriction {
+
+	allowed := r.Allowed(service)
+
+	resp := make([]*baggage.BaggageRestriction, 0, len(allowed))
+	for key, length := range allowed {
+		resp = append(resp, &baggage.BaggageRestriction{BaggageKey: key, MaxValueLength: int32(length)})
+	}
+
+	sort.Slice(resp, func(i, j int) bool { return resp[i].BaggageKey < resp[j].BaggageKey })
+
+	return resp
+}
@@ -0,0 +1,77 @@
+package baggage
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/alloykh/tracer-demo/config"
+	"github.com/alloykh/tracer-demo/log"
+	"github.com/uber/jaeger-client-go/thrift-gen/baggage"
+)
+
+var defaultReloadInterval = config.DefaultPollInterval
+
+// Store keeps the restrictions of a file in memory and reloads them when the file changes.
+// A broken file never replaces a valid one, the previous restrictions stay in use.
+type Store struct {
+	file *config.PolledFile
+	logr *log.Factory
+
+	mu           sync.RWMutex
+	restrictions *Restrictions
+}
+
+// NewStore - loads the restrictions file, fails if the file is missing or invalid
+func NewStore(path string, logr *log.Factory) (*Store, error) {
+
+	s := &Store{
+		file: config.NewPolledFile("baggage restrictions", path),
+		logr: logr,
+	}
+
+	if err := s.file.Load(s.load); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Reload reads the file if it was modified since the last load. It reports whether the restrictions changed.
+func (s *Store) Reload() (changed bool, err error) {
+	return s.file.Reload(s.load)
+}
+
+// Watch polls the file every interval until ctx is done, use it in a separate goroutine
+func (s *Store) Watch(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultReloadInterval
+	}
+	s.file.Watch(ctx, interval, s.logr, s.load)
+}
+
+func (s *Store) load(data []byte) error {
+
+	restrictions, err := ParseRestrictions(s.file.Path(), data)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.restrictions = restrictions
+	s.mu.Unlock()
+
+	return nil
+}
+
+// Restrictions returns the restrictions currently in use
+func (s *Store) Restrictions() *Restrictions {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.restrictions
+}
+
+// Get returns the baggage restrictions of the service
+func (s *Store) Get(service string) []*baggage.BaggageRestriction {
+	return s.Restrictions().Response(service)
+}
@@ -0,0 +1,179 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/alloykh/tracer-demo/tracing/semconv"
+	"github.com/gin-gonic/gin"
+	"github.com/opentracing/opentracing-go"
+)
+
+// BudgetHeader carries the time left to the caller's deadline, in milliseconds.
+// A relative budget rather than a deadline, clocks of the hosts do not have to agree.
+const BudgetHeader = "X-Request-Budget-Ms"
+
+// BudgetTag - span tag with the milliseconds left to the deadline when the span started
+const BudgetTag = semconv.DeadlineBudgetKey
+
+// Budget returns the time left to the deadline of ctx, false when ctx has no deadline
+func Budget(ctx context.Context) (time.Duration, bool) {
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return 0, false
+	}
+
+	return time.Until(deadline), true
+}
+
+// BudgetExhausted reports whether ctx is done or its deadline has passed, calls made with it are wasted
+func BudgetExhausted(ctx context.Context) bool {
+
+	if ctx.Err() != nil {
+		return true
+	}
+
+	left, ok := Budget(ctx)
+
+	return ok && left <= 0
+}
+
+// InjectBudget sets BudgetHeader to the time left to the deadline of ctx, nothing when ctx has no deadline
+func InjectBudget(ctx context.Context, h http.Header) {
+	if left, ok := Budget(ctx); ok {
+		h.Set(BudgetHeader, strconv.FormatInt(left.Milliseconds(), 10))
+	}
+}
+
+// ExtractBudget reads BudgetHeader, false when it is missing or malformed
+func ExtractBudget(h http.Header) (time.Duration, bool) {
+
+	v := h.Get(BudgetHeader)
+	if v == "" {
+		return 0, false
+	}
+
+	ms, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+
+	return time.Duration(ms) * time.Millisecond, true
+}
+
+// TagBudget records the time left to the deadline of ctx on the span, nothing when ctx has no deadline
+func TagBudget(span opentracing.Span, ctx context.Context) {
+	if left, ok := Budget(ctx); ok {
+		semconv.DeadlineBudget(span, left)
+	}
+}
+
+type budgetOptions struct {
+	defaultBudget time.Duration
+	maxBudget     time.Duration
+	routes        map[string]time.Duration
+}
+
+// BudgetOption controls the behavior of the Deadline middleware.
+type BudgetOption func(*budgetOptions)
+
+// BudgetDefault sets the budget of requests without BudgetHeader, none by default
+func BudgetDefault(budget time.Duration) BudgetOption {
+	return func(o *budgetOptions) {
+		o.defaultBudget = budget
+	}
+}
+
+// BudgetRoute sets the budget of a route, "GET /order" with the path as registered in gin.
+// It caps the budget of the caller as well, the route never gets more.
+func BudgetRoute(method, path string, budget time.Duration) BudgetOption {
+	return func(o *budgetOptions) {
+		o.routes[method+" "+path] = budget
+	}
+}
+
+// BudgetMax caps the budget callers may ask for, 0 (the default) trusts them
+func BudgetMax(budget time.Duration) BudgetOption {
+	return func(o *budgetOptions) {
+		o.maxBudget = budget
+	}
+}
+
+// Deadline - applies the caller's BudgetHeader, or the default budget of the route, to the request context,
+// so the handler and its downstream calls give up together with the caller.
+// Use it after Tracer: the server span records the budget, and whether it was exceeded.
+// A request arriving with no budget left is answered 504 without running the handler.
+func Deadline(options ...BudgetOption) gin.HandlerFunc {
+
+	opts := &budgetOptions{
+		routes: make(map[string]time.Duration),
+	}
+
+	for _, opt := range options {
+		opt(opts)
+	}
+
+	return func(c *gin.Context) {
+
+		budget, source := opts.budget(c)
+		if source == "" {
+			c.Next()
+			return
+		}
+
+		span := opentracing.SpanFromContext(c.Request.Context())
+		if span != nil {
+			semconv.DeadlineSource(span, source)
+		}
+
+		if budget <= 0 {
+			if span != nil {
+				semconv.DeadlineBudget(span, budget)
+				semconv.DeadlineExceeded(span)
+			}
+			c.AbortWithStatusJSON(http.StatusGatewayTimeout, gin.H{"error": "request budget exhausted"})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), budget)
+		defer cancel()
+
+		c.Request = c.Request.WithContext(ctx)
+
+		if span != nil {
+			TagBudget(span, ctx)
+		}
+
+		c.Next()
+
+		if span != nil && ctx.Err() == context.DeadlineExceeded {
+			semconv.DeadlineExceeded(span)
+		}
+	}
+}
+
+// budget returns the budget of the request and where it comes from: header, route or default
+func (o *budgetOptions) budget(c *gin.Context) (time.Duration, string) {
+
+	budget, source := time.Duration(0), ""
+
+	if b, ok := ExtractBudget(c.Request.Header); ok {
+		budget, source = b, "header"
+		if o.maxBudget > 0 && budget > o.maxBudget {
+			budget = o.maxBudget
+		}
+	}
+
+	if b, ok := o.routes[c.Request.Method+" "+c.FullPath()]; ok {
+		if source == "" || b < budget {
+			budget, source = b, "route"
+		}
+	} else if source == "" && o.defaultBudget > 0 {
+		budget, source = o.defaultBudget, "default"
+	}
+
+	return budget, source
+}
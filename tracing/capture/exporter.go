@@ -0,0 +1,172 @@
+package capture
+
+import (
+	"bufio"
+	"os"
+	"sync"
+
+	"github.com/alloykh/tracer-demo/log"
+	"github.com/alloykh/tracer-demo/tracing/model"
+	"github.com/pkg/errors"
+	"github.com/uber/jaeger-client-go"
+	"go.uber.org/zap"
+)
+
+var defaultMaxSpans = 100000
+
+// Exporter is a jaeger.Reporter keeping every finished span in memory, unlike the traceview
+// ring buffer nothing is evicted: it is meant for tests and CI runs capturing a whole flow.
+// With WithFile the spans are written as JSON lines when the tracer is closed.
+type Exporter struct {
+	mu    sync.Mutex
+	spans []*model.Span
+
+	maxSpans int
+	dropped  int
+	path     string
+	logr     *log.Factory
+}
+
+// Option controls the behavior of the Exporter.
+type Option func(*Exporter)
+
+// WithMaxSpans bounds the spans kept in memory, spans above the limit are counted and dropped
+func WithMaxSpans(n int) Option {
+	return func(e *Exporter) {
+		if n > 0 {
+			e.maxSpans = n
+		}
+	}
+}
+
+// WithFile writes the captured spans to path (JSON lines, tracectl reads them) on Close
+func WithFile(path string) Option {
+	return func(e *Exporter) {
+		e.path = path
+	}
+}
+
+// WithLogger logs the write of the file on Close, a failed capture is reported when it happens
+func WithLogger(logr *log.Factory) Option {
+	return func(e *Exporter) {
+		e.logr = logr
+	}
+}
+
+// NewExporter - plug it with tracing.InitJaeger(..., tracing.WithSpanReporter(exporter))
+func NewExporter(opts ...Option) *Exporter {
+
+	e := &Exporter{maxSpans: defaultMaxSpans}
+
+	for _, opt := range opts {
+		opt(e)
+	}
+
+	return e
+}
+
+// Report implements jaeger.Reporter
+func (e *Exporter) Report(span *jaeger.Span) {
+	e.Add(model.FromJaegerSpan(span))
+}
+
+// Close implements jaeger.Reporter, it is called when the tracer is closed.
+// The reporter interface has no error, the write of the file is logged with WithLogger.
+func (e *Exporter) Close() {
+
+	if e.path == "" {
+		return
+	}
+
+	err := e.WriteFile(e.path)
+
+	if e.logr == nil {
+		return
+	}
+
+	if err != nil {
+		e.logr.Default().Error("span capture", zap.String("file", e.path), zap.String("err", err.Error()))
+		return
+	}
+
+	e.mu.Lock()
+	spans, dropped := len(e.spans), e.dropped
+	e.mu.Unlock()
+
+	e.logr.Default().Info("spans captured", zap.String("file", e.path), zap.Int("spans", spans), zap.Int("dropped", dropped))
+}
+
+// Add stores a finished span
+func (e *Exporter) Add(span *model.Span) {
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if len(e.spans) >= e.maxSpans {
+		e.dropped++
+		return
+	}
+
+	e.spans = append(e.spans, span)
+}
+
+// Spans returns a copy of the captured spans
+func (e *Exporter) Spans() []*model.Span {
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	spans := make([]*model.Span, 0, len(e.spans))
+	for _, s := range e.spans {
+		sc := *s
+		spans = append(spans, &sc)
+	}
+
+	return spans
+}
+
+// Traces groups the captured spans by trace
+func (e *Exporter) Traces() []*model.Trace {
+	return model.GroupTraces(e.Spans())
+}
+
+// Dropped returns the number of spans over the WithMaxSpans limit
+func (e *Exporter) Dropped() int {
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.dropped
+}
+
+// Reset forgets the captured spans
+func (e *Exporter) Reset() {
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.spans = nil
+	e.dropped = 0
+}
+
+// WriteFile writes the captured spans to path as JSON lines
+func (e *Exporter) WriteFile(path string) (err error) {
+
+	f, err := os.Create(path)
+	if err != nil {
+		return errors.Wrap(err, "create capture file")
+	}
+	defer func() {
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}()
+
+	w := bufio.NewWriter(f)
+
+	if err = model.WriteJSONLines(w, e.Spans()); err != nil {
+		return errors.Wrap(err, "write capture file")
+	}
+
+	return w.Flush()
+}
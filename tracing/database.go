@@ -2,9 +2,8 @@ package tracing
 
 import (
 	"context"
-	"fmt"
+	"github.com/alloykh/tracer-demo/tracing/semconv"
 	"github.com/opentracing/opentracing-go"
-	"github.com/opentracing/opentracing-go/ext"
 )
 
 // NewDBSpanFromContext - returns new span for the database operations
@@ -16,10 +15,9 @@ func NewDBSpanFromContext(ctx context.Context, params ...interface{}) (opentraci
 
 		span := opentracing.StartSpan("Product Get", opentracing.ChildOf(span.Context()))
 
-		for i, p := range params {
-			s := fmt.Sprintf("param.#%d", i)
-			span.SetTag(s, p)
-		}
+		semconv.DBParams(span, params...)
+
+		TagBudget(span, ctx)
 
 		ctx = opentracing.ContextWithSpan(ctx, span)
 		return span, ctx
@@ -29,7 +27,5 @@ func NewDBSpanFromContext(ctx context.Context, params ...interface{}) (opentraci
 }
 
 func WrapWithTags(span opentracing.Span, dbType, query string) {
-	ext.SpanKindRPCClient.Set(span)
-	ext.PeerService.Set(span, dbType) // can be any database call
-	span.SetTag("query", query)
+	semconv.DB(span, dbType, query) // can be any database call
 }
@@ -0,0 +1,122 @@
+package tracing
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/alloykh/tracer-demo/tracing/semconv"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	otlog "github.com/opentracing/opentracing-go/log"
+	"github.com/uber/jaeger-client-go"
+)
+
+// DebugHeader - Jaeger's header forcing a new trace to be sampled as a debug trace, its value is tagged on the root span
+// so the trace can be searched by it: curl -H "jaeger-debug-id: bug-1234" ...
+const DebugHeader = jaeger.JaegerDebugHeader
+
+// DebugPayloadLimit - bytes of request and response payloads logged on the spans of debug traces
+const DebugPayloadLimit = 4 << 10
+
+// headers never logged, whatever the trace
+var redactedHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+	"Set-Cookie":          true,
+}
+
+// IsDebug reports whether the span of ctx belongs to a debug trace: sampled whatever the sampler
+// and logged verbosely by every service it goes through
+func IsDebug(ctx context.Context) bool {
+	return IsDebugSpan(opentracing.SpanFromContext(ctx))
+}
+
+// IsDebugSpan reports whether the span belongs to a debug trace
+func IsDebugSpan(span opentracing.Span) bool {
+
+	if span == nil {
+		return false
+	}
+
+	sc, ok := span.Context().(jaeger.SpanContext)
+
+	return ok && sc.IsDebug()
+}
+
+// ForceSampling turns the trace of span into a debug trace. The debug flag travels in the trace context,
+// so remote.HTTPService and the gRPC clients propagate it and downstream services sample the trace as well.
+func ForceSampling(span opentracing.Span, reason string) {
+	ext.SamplingPriority.Set(span, 1)
+	semconv.SamplingForced(span, reason)
+}
+
+// MWForceSampling returns a MWOption forcing the sampling of requests carrying header with the shared secret
+// as value, e.g. MWForceSampling("X-Force-Trace", os.Getenv("FORCE_TRACE_SECRET")). An empty secret disables it.
+// The header is never logged.
+func MWForceSampling(header, secret string) MWOption {
+	return func(options *mwOptions) {
+		if header == "" || secret == "" {
+			return
+		}
+		options.forceHeader = http.CanonicalHeaderKey(header)
+		options.forceSecret = secret
+	}
+}
+
+// forceSampling forces the sampling of the request span when asked by jaeger-debug-id or the secret header.
+// Jaeger already samples new traces started with jaeger-debug-id, requests continuing a trace are forced here.
+// Untrusted callers (MWTrustPolicy) need the secret header, their jaeger-debug-id is ignored.
+func (o *mwOptions) forceSampling(span opentracing.Span, r *http.Request, trusted bool) {
+
+	forced := false
+
+	if id := r.Header.Get(DebugHeader); trusted && id != "" && !IsDebugSpan(span) {
+		ForceSampling(span, DebugHeader)
+		span.SetTag(DebugHeader, id)
+		forced = true
+	}
+
+	if v := r.Header.Get(o.forceHeader); o.forceHeader != "" && v != "" {
+		if subtle.ConstantTimeCompare([]byte(v), []byte(o.forceSecret)) == 1 {
+			ForceSampling(span, o.forceHeader)
+			forced = true
+		} else {
+			semconv.SamplingForceRejected(span)
+		}
+	}
+
+	// the start tags of a span not sampled when it started were dropped
+	if forced {
+		ext.SpanKindRPCServer.Set(span)
+	}
+}
+
+// LogRequestHeaders logs the headers of the request on the span as a single event, credentials left out
+func LogRequestHeaders(span opentracing.Span, event string, h http.Header, hidden ...string) {
+
+	skip := make(map[string]bool, len(hidden))
+	for _, name := range hidden {
+		skip[http.CanonicalHeaderKey(name)] = true
+	}
+
+	names := make([]string, 0, len(h))
+	for name := range h {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fields := []otlog.Field{otlog.String("event", event)}
+
+	for _, name := range names {
+		if skip[name] || redactedHeaders[name] {
+			continue
+		}
+		fields = append(fields, otlog.String("header."+strings.ToLower(name), strings.Join(h[name], ",")))
+	}
+
+	span.LogFields(fields...)
+}
@@ -0,0 +1,164 @@
+// Package decorate is the runtime of the decorators generated by cmd/tracegen.
+package decorate
+
+import (
+	"context"
+	"time"
+
+	"github.com/alloykh/tracer-demo/log"
+	"github.com/alloykh/tracer-demo/tracing"
+	"github.com/alloykh/tracer-demo/tracing/semconv"
+	"github.com/opentracing/opentracing-go"
+	"github.com/uber/jaeger-lib/metrics"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/status"
+)
+
+type options struct {
+	tracer         opentracing.Tracer
+	logr           *log.Factory
+	metricsFactory metrics.Factory
+	rootSpans      bool
+}
+
+// Option controls the spans, logs and metrics of a decorator.
+type Option func(*options)
+
+// WithTracer sets the tracer of the spans, the global tracer by default
+func WithTracer(tracer opentracing.Tracer) Option {
+	return func(o *options) {
+		o.tracer = tracer
+	}
+}
+
+// WithLogger logs every call with the trace of the call: failures at error level, the others at debug level
+func WithLogger(logr *log.Factory) Option {
+	return func(o *options) {
+		o.logr = logr
+	}
+}
+
+// WithMetrics records the latency of every method as the <type>_latency timer, tagged with the method and the result
+func WithMetrics(factory metrics.Factory) Option {
+	return func(o *options) {
+		o.metricsFactory = factory
+	}
+}
+
+// WithRootSpans traces the calls made without a span in their context, as root spans.
+// By default such calls are not traced: a repository call is seldom worth a trace of its own.
+func WithRootSpans() Option {
+	return func(o *options) {
+		o.rootSpans = true
+	}
+}
+
+// Decorator holds what the methods of a generated decorator share
+type Decorator struct {
+	typ  string
+	opts *options
+
+	latency map[string]map[bool]metrics.Timer
+}
+
+// New returns the decorator of the interface typ with methods
+func New(typ string, methods []string, opts ...Option) *Decorator {
+
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	d := &Decorator{typ: typ, opts: o}
+
+	if o.metricsFactory != nil {
+		d.latency = make(map[string]map[bool]metrics.Timer, len(methods))
+		for _, method := range methods {
+			d.latency[method] = map[bool]metrics.Timer{
+				false: o.metricsFactory.Timer(metrics.TimerOptions{Name: typ + "_latency", Tags: map[string]string{"method": method, "result": "ok"}}),
+				true:  o.metricsFactory.Timer(metrics.TimerOptions{Name: typ + "_latency", Tags: map[string]string{"method": method, "result": "error"}}),
+			}
+		}
+	}
+
+	return d
+}
+
+func (d *Decorator) getTracer() opentracing.Tracer {
+	if d.opts.tracer != nil {
+		return d.opts.tracer
+	}
+	return opentracing.GlobalTracer()
+}
+
+// Start starts the span of method, Type.Method, and returns the context of the decorated call
+func (d *Decorator) Start(ctx context.Context, method string) (*Call, context.Context) {
+
+	c := &Call{d: d, method: method, start: time.Now(), ctx: ctx}
+
+	parent := opentracing.SpanFromContext(ctx)
+
+	switch {
+	case parent != nil:
+		c.span = d.getTracer().StartSpan(d.typ+"."+method, opentracing.ChildOf(parent.Context()))
+	case d.opts.rootSpans:
+		c.span = d.getTracer().StartSpan(d.typ + "." + method)
+	default:
+		// not traced, the call is still logged with the original context
+		c.span = opentracing.NoopTracer{}.StartSpan(d.typ + "." + method)
+		return c, ctx
+	}
+
+	tracing.TagBudget(c.span, ctx)
+
+	c.ctx = opentracing.ContextWithSpan(ctx, c.span)
+
+	return c, c.ctx
+}
+
+// Call is a decorated method call
+type Call struct {
+	d      *Decorator
+	method string
+	start  time.Time
+
+	ctx  context.Context
+	span opentracing.Span
+}
+
+// Tag tags the span of the call
+func (c *Call) Tag(key string, value interface{}) {
+	c.span.SetTag(key, value)
+}
+
+// Finish records the outcome of the call, err is the error returned by the method, if any
+func (c *Call) Finish(err error) {
+
+	duration := time.Since(c.start)
+
+	if err != nil {
+		semconv.Exception(c.span, err)
+		if s, ok := status.FromError(err); ok {
+			semconv.GRPCStatus(c.span, s.Code())
+		}
+	}
+
+	if c.d.opts.logr != nil {
+		name := c.d.typ + "." + c.method
+		if err != nil {
+			fields := []zap.Field{zap.Duration("duration", duration), zap.String("err", err.Error())}
+			if s, ok := status.FromError(err); ok {
+				fields = append(fields, zap.String("code", s.Code().String()))
+			}
+			c.d.opts.logr.For(c.ctx).Error(name, fields...)
+		} else {
+			c.d.opts.logr.For(c.ctx).Debug(name, zap.Duration("duration", duration))
+		}
+	}
+
+	if c.d.latency != nil {
+		c.d.latency[c.method][err != nil].Record(duration)
+	}
+
+	c.span.Finish()
+}
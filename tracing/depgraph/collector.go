@@ -0,0 +1,173 @@
+package depgraph
+
+import (
+	"sync"
+	"time"
+
+	"github.com/alloykh/tracer-demo/tracing/model"
+	"github.com/uber/jaeger-client-go"
+)
+
+var (
+	defaultSettle        = time.Second * 5
+	defaultMaxOpenTraces = 10000
+)
+
+// Collector feeds a Graph with finished spans. Spans are buffered per trace and the trace is added
+// to the graph once no span of it arrived for the settle period, so the parent of a span is known
+// when the call is attributed.
+//
+// It is a jaeger.Reporter (tracing.WithSpanReporter) for the graph of a process
+// and an agent.Sink for the graph of every service reporting to the agent.
+type Collector struct {
+	graph *Graph
+
+	mu      sync.Mutex
+	open    map[string]*openTrace
+	settle  time.Duration
+	maxOpen int
+}
+
+type openTrace struct {
+	spans []*model.Span
+	last  time.Time
+}
+
+// CollectorOption controls the behavior of the Collector.
+type CollectorOption func(*Collector)
+
+// WithSettle sets how long a trace waits for more spans before it is added to the graph
+func WithSettle(d time.Duration) CollectorOption {
+	return func(c *Collector) {
+		if d > 0 {
+			c.settle = d
+		}
+	}
+}
+
+// WithMaxOpenTraces bounds the buffered traces, the oldest are added early when the limit is reached
+func WithMaxOpenTraces(n int) CollectorOption {
+	return func(c *Collector) {
+		if n > 0 {
+			c.maxOpen = n
+		}
+	}
+}
+
+// NewCollector - collector feeding a new graph
+func NewCollector(opts ...CollectorOption) *Collector {
+
+	c := &Collector{
+		graph:   New(),
+		open:    make(map[string]*openTrace),
+		settle:  defaultSettle,
+		maxOpen: defaultMaxOpenTraces,
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+// Report implements jaeger.Reporter
+func (c *Collector) Report(span *jaeger.Span) {
+	c.AddSpan(model.FromJaegerSpan(span))
+}
+
+// Close implements jaeger.Reporter
+func (c *Collector) Close() {
+	c.Flush()
+}
+
+// Add implements agent.Sink
+func (c *Collector) Add(spans []*model.Span) error {
+	for _, s := range spans {
+		c.AddSpan(s)
+	}
+	return nil
+}
+
+// AddSpan buffers a finished span
+func (c *Collector) AddSpan(span *model.Span) {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+
+	t, ok := c.open[span.TraceID]
+	if !ok {
+		if len(c.open) >= c.maxOpen {
+			c.settleLocked(now, true)
+		}
+		t = &openTrace{}
+		c.open[span.TraceID] = t
+	}
+
+	t.spans = append(t.spans, span)
+	t.last = now
+}
+
+// Flush adds every buffered trace to the graph
+func (c *Collector) Flush() {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for id, t := range c.open {
+		c.addLocked(id, t)
+	}
+}
+
+// Snapshot adds the settled traces to the graph and returns it
+func (c *Collector) Snapshot() Snapshot {
+
+	c.mu.Lock()
+	c.settleLocked(time.Now(), false)
+	c.mu.Unlock()
+
+	return c.graph.Snapshot()
+}
+
+// Reset forgets the graph and the buffered traces
+func (c *Collector) Reset() {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.open = make(map[string]*openTrace)
+	c.graph.Reset()
+}
+
+// settleLocked adds the traces quiet for the settle period, with force the oldest one at least
+func (c *Collector) settleLocked(now time.Time, force bool) {
+
+	var oldestID string
+	var oldest *openTrace
+
+	for id, t := range c.open {
+		if now.Sub(t.last) >= c.settle {
+			c.addLocked(id, t)
+			force = false
+			continue
+		}
+		if oldest == nil || t.last.Before(oldest.last) {
+			oldestID, oldest = id, t
+		}
+	}
+
+	if force && oldest != nil {
+		c.addLocked(oldestID, oldest)
+	}
+}
+
+func (c *Collector) addLocked(id string, t *openTrace) {
+
+	delete(c.open, id)
+
+	for _, trace := range model.GroupTraces(t.spans) {
+		c.graph.AddTrace(trace)
+	}
+}
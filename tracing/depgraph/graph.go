@@ -0,0 +1,355 @@
+package depgraph
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alloykh/tracer-demo/tracing/analysis"
+	"github.com/alloykh/tracer-demo/tracing/model"
+)
+
+// durations kept per edge for the percentiles, the oldest are overwritten
+var maxEdgeSamples = 1024
+
+// Edge is an observed dependency: Caller made Calls requests to Callee
+type Edge struct {
+	Caller    string        `json:"caller"`
+	Callee    string        `json:"callee"`
+	Protocol  string        `json:"protocol,omitempty"`
+	Calls     int           `json:"calls"`
+	Errors    int           `json:"errors"`
+	ErrorRate float64       `json:"errorRate"`
+	Mean      time.Duration `json:"mean"`
+	P50       time.Duration `json:"p50"`
+	P95       time.Duration `json:"p95"`
+}
+
+// Snapshot is the state of a graph at a point in time
+type Snapshot struct {
+	Services []string `json:"services"`
+	Edges    []Edge   `json:"edges"`
+}
+
+type edgeKey struct {
+	caller, callee, protocol string
+}
+
+type edgeStats struct {
+	calls, errors int
+	total         time.Duration
+	samples       []time.Duration
+	next          int
+}
+
+// merge adds the calls of o, its samples are kept while there is room
+func (e *edgeStats) merge(o *edgeStats) {
+
+	e.calls += o.calls
+	e.errors += o.errors
+	e.total += o.total
+
+	for _, d := range o.samples {
+		if len(e.samples) >= maxEdgeSamples {
+			break
+		}
+		e.samples = append(e.samples, d)
+	}
+}
+
+func (e *edgeStats) observe(d time.Duration, failed bool) {
+
+	e.calls++
+	e.total += d
+	if failed {
+		e.errors++
+	}
+
+	if len(e.samples) < maxEdgeSamples {
+		e.samples = append(e.samples, d)
+		return
+	}
+	e.samples[e.next] = d
+	e.next = (e.next + 1) % maxEdgeSamples
+}
+
+// Graph accumulates the calls between services found in traces. It is safe for concurrent use.
+//
+// A call is a span whose parent belongs to another service: the latency is the one seen by the
+// caller (the parent when it is a client span) and the call failed when either side is tagged
+// with error=true. Client spans without a child in another service (the callee is not traced or
+// its spans are reported elsewhere) are attributed to the service of a server span further down the
+// trace, otherwise to the peer: the peer.service tag, the host of http.url or the service of a gRPC method.
+// A peer named by a host or a gRPC service is shown as the service whose server spans were seen
+// answering on that host or service, in any trace.
+type Graph struct {
+	mu       sync.RWMutex
+	edges    map[edgeKey]*edgeStats
+	services map[string]bool
+	// peer names of the client spans to the services seen serving them
+	aliases map[string]string
+}
+
+// New - empty graph
+func New() *Graph {
+	return &Graph{
+		edges:    make(map[edgeKey]*edgeStats),
+		services: make(map[string]bool),
+		aliases:  make(map[string]string),
+	}
+}
+
+// Build - graph of the given traces, for exported files
+func Build(traces []*model.Trace) *Graph {
+
+	g := New()
+	for _, t := range traces {
+		g.AddTrace(t)
+	}
+
+	return g
+}
+
+// AddTrace adds the calls of a trace to the graph
+func (g *Graph) AddTrace(t *model.Trace) {
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	byID := make(map[string]*model.Span, len(t.Spans))
+	for _, s := range t.Spans {
+		byID[s.SpanID] = s
+	}
+
+	// client spans with a traced callee
+	resolved := make(map[string]bool)
+
+	for _, s := range t.Spans {
+		g.services[s.ServiceName()] = true
+
+		if isServer(s) {
+			for _, name := range serverNames(s) {
+				g.aliases[name] = s.ServiceName()
+			}
+		}
+
+		parent := byID[s.ParentSpanID()]
+		if parent == nil || parent.ServiceName() == s.ServiceName() {
+			continue
+		}
+
+		latency := s.Elapsed()
+		if isClient(parent) {
+			latency = parent.Elapsed()
+			resolved[parent.SpanID] = true
+			if peer := peerName(parent); peer != "" && peer != s.ServiceName() {
+				g.aliases[peer] = s.ServiceName()
+			}
+		}
+
+		g.observe(edgeKey{parent.ServiceName(), s.ServiceName(), protocol(parent, s)}, latency, s.HasError() || parent.HasError())
+	}
+
+	children := t.Children()
+
+	for _, s := range t.Spans {
+		if !isClient(s) || resolved[s.SpanID] {
+			continue
+		}
+
+		// a span of the callee further down, under other spans of the caller
+		if callee := calleeSpan(s, children); callee != nil {
+			g.observe(edgeKey{s.ServiceName(), callee.ServiceName(), protocol(s, callee)}, s.Elapsed(), s.HasError() || callee.HasError())
+			continue
+		}
+
+		if peer := peerName(s); peer != "" {
+			g.services[peer] = true
+			g.observe(edgeKey{s.ServiceName(), peer, protocol(s, nil)}, s.Elapsed(), s.HasError())
+		}
+	}
+}
+
+// calleeSpan returns the first span of another service under the client span s, nil if the trace has none.
+// The client spans under s account for their own calls.
+func calleeSpan(s *model.Span, children map[string][]*model.Span) *model.Span {
+
+	queue := children[s.SpanID]
+	for len(queue) > 0 {
+		c := queue[0]
+		queue = queue[1:]
+		if c.ServiceName() != s.ServiceName() {
+			return c
+		}
+		if !isClient(c) {
+			queue = append(queue, children[c.SpanID]...)
+		}
+	}
+
+	return nil
+}
+
+func (g *Graph) observe(key edgeKey, d time.Duration, failed bool) {
+
+	e, ok := g.edges[key]
+	if !ok {
+		e = &edgeStats{}
+		g.edges[key] = e
+	}
+
+	e.observe(d, failed)
+}
+
+// Snapshot returns the services and the edges ordered by caller and callee
+func (g *Graph) Snapshot() Snapshot {
+
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	snap := Snapshot{
+		Services: make([]string, 0, len(g.services)),
+		Edges:    make([]Edge, 0, len(g.edges)),
+	}
+
+	// the peers named by a host or a gRPC service are merged into the service seen serving them
+	services := make(map[string]bool, len(g.services))
+	for s := range g.services {
+		services[g.resolve(s)] = true
+	}
+	for s := range services {
+		snap.Services = append(snap.Services, s)
+	}
+	sort.Strings(snap.Services)
+
+	merged := make(map[edgeKey]*edgeStats, len(g.edges))
+	for k, e := range g.edges {
+		k.callee = g.resolve(k.callee)
+		m, ok := merged[k]
+		if !ok {
+			m = &edgeStats{}
+			merged[k] = m
+		}
+		m.merge(e)
+	}
+
+	for k, e := range merged {
+		snap.Edges = append(snap.Edges, Edge{
+			Caller:    k.caller,
+			Callee:    k.callee,
+			Protocol:  k.protocol,
+			Calls:     e.calls,
+			Errors:    e.errors,
+			ErrorRate: float64(e.errors) / float64(e.calls),
+			Mean:      e.total / time.Duration(e.calls),
+			P50:       analysis.Percentile(e.samples, 50),
+			P95:       analysis.Percentile(e.samples, 95),
+		})
+	}
+
+	sort.Slice(snap.Edges, func(i, j int) bool {
+		a, b := snap.Edges[i], snap.Edges[j]
+		if a.Caller != b.Caller {
+			return a.Caller < b.Caller
+		}
+		if a.Callee != b.Callee {
+			return a.Callee < b.Callee
+		}
+		return a.Protocol < b.Protocol
+	})
+
+	return snap
+}
+
+// Reset forgets every observed call
+func (g *Graph) Reset() {
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.edges = make(map[edgeKey]*edgeStats)
+	g.services = make(map[string]bool)
+	g.aliases = make(map[string]string)
+}
+
+// resolve returns the service seen serving the peer name, the name itself when none was
+func (g *Graph) resolve(name string) string {
+	if service, ok := g.aliases[name]; ok {
+		return service
+	}
+	return name
+}
+
+func isClient(s *model.Span) bool {
+	kv, ok := s.Tag("span.kind")
+	return ok && (kv.String() == "client" || kv.String() == "producer")
+}
+
+func isServer(s *model.Span) bool {
+	kv, ok := s.Tag("span.kind")
+	return ok && (kv.String() == "server" || kv.String() == "consumer")
+}
+
+// serverNames are the names client spans give the service of a server span: the host it was called on
+// and the service of a gRPC method
+func serverNames(s *model.Span) []string {
+
+	var names []string
+
+	if kv, ok := s.Tag("http.host"); ok && kv.String() != "" {
+		names = append(names, kv.String())
+	}
+
+	if service := grpcService(s.OperationName); service != "" {
+		names = append(names, service)
+	}
+
+	return names
+}
+
+// grpcService returns the service of a gRPC method named /package.Service/Method
+func grpcService(op string) string {
+	if strings.HasPrefix(op, "/") && strings.Count(op, "/") == 2 {
+		return op[1:strings.LastIndex(op, "/")]
+	}
+	return ""
+}
+
+// protocol is the component of the client span, of the server span otherwise
+func protocol(client, server *model.Span) string {
+
+	for _, s := range []*model.Span{client, server} {
+		if s == nil {
+			continue
+		}
+		if kv, ok := s.Tag("component"); ok {
+			switch c := strings.ToLower(kv.String()); c {
+			case "grpc":
+				return "grpc"
+			case "net/http", "gin":
+				return "http"
+			default:
+				return c
+			}
+		}
+	}
+
+	return ""
+}
+
+// peerName names the callee of a client span from its tags, when the trace has no span of the callee
+func peerName(s *model.Span) string {
+
+	if kv, ok := s.Tag("peer.service"); ok && kv.String() != "" {
+		return kv.String()
+	}
+
+	if kv, ok := s.Tag("http.url"); ok {
+		if u, err := url.Parse(kv.String()); err == nil && u.Host != "" {
+			return u.Host
+		}
+	}
+
+	return grpcService(s.OperationName)
+}
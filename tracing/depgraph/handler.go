@@ -0,0 +1,45 @@
+package depgraph
+
+import (
+	"bytes"
+	"net/http"
+)
+
+// Path is the default mount point of the graph
+const Path = "/debug/dependencies"
+
+// Source provides the graph to serve, Graph and Collector implement it
+type Source interface {
+	Snapshot() Snapshot
+}
+
+// Handler serves the graph, ?format=json (default), dot or mermaid
+func Handler(source Source) http.Handler {
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		format := r.URL.Query().Get("format")
+		if format == "" {
+			format = FormatJSON
+		}
+
+		var buf bytes.Buffer
+		if err := Write(&buf, format, source.Snapshot()); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if format == FormatJSON {
+			w.Header().Set("Content-Type", "application/json")
+		} else {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		}
+
+		_, _ = w.Write(buf.Bytes())
+	})
+}
+
+// Mount registers the handler on Path
+func Mount(mux *http.ServeMux, source Source) {
+	mux.Handle(Path, Handler(source))
+}
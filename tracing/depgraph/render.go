@@ -0,0 +1,124 @@
+package depgraph
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// output formats of the graph
+const (
+	FormatJSON    = "json"
+	FormatDOT     = "dot"     // graphviz: dot -Tsvg deps.dot > deps.svg
+	FormatMermaid = "mermaid" // paste into a markdown ```mermaid block
+)
+
+// Write encodes the snapshot in one of the formats
+func Write(w io.Writer, format string, snap Snapshot) error {
+	switch format {
+	case FormatJSON, "":
+		return WriteJSON(w, snap)
+	case FormatDOT:
+		return WriteDOT(w, snap)
+	case FormatMermaid:
+		return WriteMermaid(w, snap)
+	}
+	return fmt.Errorf("unknown graph format %q", format)
+}
+
+// WriteJSON encodes the snapshot as JSON
+func WriteJSON(w io.Writer, snap Snapshot) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(snap)
+}
+
+// WriteDOT encodes the snapshot as a graphviz digraph, edges with errors are red
+func WriteDOT(w io.Writer, snap Snapshot) error {
+
+	var b strings.Builder
+
+	b.WriteString("digraph dependencies {\n")
+	b.WriteString("  rankdir=LR;\n")
+	b.WriteString("  node [shape=box, style=rounded];\n")
+
+	for _, s := range snap.Services {
+		fmt.Fprintf(&b, "  %q;\n", s)
+	}
+
+	for _, e := range snap.Edges {
+		color := "black"
+		if e.Errors > 0 {
+			color = "red"
+		}
+		fmt.Fprintf(&b, "  %q -> %q [label=%q, color=%s];\n", e.Caller, e.Callee, edgeLabel(e, "\n"), color)
+	}
+
+	b.WriteString("}\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteMermaid encodes the snapshot as a mermaid flowchart
+func WriteMermaid(w io.Writer, snap Snapshot) error {
+
+	var b strings.Builder
+
+	b.WriteString("flowchart LR\n")
+
+	// mermaid ids are restricted, the names go into the labels
+	ids := make(map[string]string, len(snap.Services))
+	id := func(name string) string {
+		if v, ok := ids[name]; ok {
+			return v
+		}
+		v := fmt.Sprintf("s%d", len(ids))
+		ids[name] = v
+		fmt.Fprintf(&b, "  %s[\"%s\"]\n", v, mermaidEscape(name))
+		return v
+	}
+
+	for _, s := range snap.Services {
+		id(s)
+	}
+
+	for i, e := range snap.Edges {
+		fmt.Fprintf(&b, "  %s -->|\"%s\"| %s\n", id(e.Caller), mermaidEscape(edgeLabel(e, "<br/>")), id(e.Callee))
+		if e.Errors > 0 {
+			fmt.Fprintf(&b, "  linkStyle %d stroke:red\n", i)
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func edgeLabel(e Edge, newline string) string {
+
+	label := fmt.Sprintf("%d calls", e.Calls)
+	if e.Protocol != "" {
+		label = e.Protocol + " " + label
+	}
+	if e.Errors > 0 {
+		label += fmt.Sprintf(", %.1f%% errors", e.ErrorRate*100)
+	}
+
+	return label + newline + fmt.Sprintf("p50 %s p95 %s", round(e.P50), round(e.P95))
+}
+
+func round(d time.Duration) time.Duration {
+	switch {
+	case d >= time.Second:
+		return d.Round(time.Millisecond)
+	case d >= time.Millisecond:
+		return d.Round(time.Microsecond * 10)
+	}
+	return d.Round(time.Microsecond)
+}
+
+func mermaidEscape(s string) string {
+	return strings.ReplaceAll(s, `"`, "#quot;")
+}
@@ -1,10 +1,14 @@
 package tracing
 
 import (
+	"context"
 	"fmt"
+	"github.com/alloykh/tracer-demo/tracing/profiling"
+	"github.com/alloykh/tracer-demo/tracing/semconv"
 	"github.com/gin-gonic/gin"
 	"github.com/opentracing/opentracing-go"
 	"github.com/opentracing/opentracing-go/ext"
+	otlog "github.com/opentracing/opentracing-go/log"
 	"net/http"
 	"net/url"
 )
@@ -12,11 +16,19 @@ import (
 const defaultComponentName = "net/http"
 
 type mwOptions struct {
-	opNameFunc    func(r *http.Request) string
-	spanFilter    func(r *http.Request) bool
-	spanObserver  func(span opentracing.Span, r *http.Request)
-	urlTagFunc    func(u *url.URL) string
-	componentName string
+	opNameFunc     func(r *http.Request) string
+	spanFilter     func(r *http.Request) bool
+	spanObserver   func(span opentracing.Span, r *http.Request)
+	urlTagFunc     func(u *url.URL) string
+	componentName  string
+	baggageHeaders map[string]string
+	userKey        string
+	tenantKey      string
+	forceHeader    string
+	forceSecret    string
+	trust          *TrustPolicy
+	profileLabels  *profiling.Labels
+	slowProfiler   *profiling.SlowProfiler
 }
 
 // MWOption controls the behavior of the Middleware.
@@ -64,6 +76,46 @@ func MWURLTagFunc(f func(u *url.URL) string) MWOption {
 	}
 }
 
+// MWIdentityBaggage returns a MWOption tagging the server span with the user.id and tenant.id
+// found in the baggage items userKey and tenantKey, an empty key is not looked up
+func MWIdentityBaggage(userKey, tenantKey string) MWOption {
+	return func(options *mwOptions) {
+		options.userKey = userKey
+		options.tenantKey = tenantKey
+	}
+}
+
+// MWProfileLabels returns a MWOption running the handlers with the pprof labels operation, route
+// and service, plus trace_id for sampled traces when traceIDs is set,
+// see profiling.Handler to capture the CPU profile of a label
+func MWProfileLabels(service string, traceIDs bool) MWOption {
+	return func(options *mwOptions) {
+		options.profileLabels = &profiling.Labels{Service: service, TraceIDs: traceIDs}
+	}
+}
+
+// MWSlowProfile returns a MWOption attaching a short CPU profile to the requests slower than
+// the threshold of p, it turns the trace_id pprof label on
+func MWSlowProfile(p *profiling.SlowProfiler) MWOption {
+	return func(options *mwOptions) {
+		options.slowProfiler = p
+	}
+}
+
+// MWBaggageHeaders returns a MWOption that turns request headers into baggage items,
+// header name to baggage key, e.g. {"X-Tenant-ID": "tenant"}. Use it at the edge:
+// downstream services get the items with the trace, whatever the protocol.
+func MWBaggageHeaders(headers map[string]string) MWOption {
+	return func(options *mwOptions) {
+		if options.baggageHeaders == nil {
+			options.baggageHeaders = make(map[string]string, len(headers))
+		}
+		for header, key := range headers {
+			options.baggageHeaders[header] = key
+		}
+	}
+}
+
 // Tracer - use this function as a middleware in your gin router (router.Use(tracer))
 func Tracer(tr opentracing.Tracer, options ...MWOption) gin.HandlerFunc {
 
@@ -89,6 +141,13 @@ func Tracer(tr opentracing.Tracer, options ...MWOption) gin.HandlerFunc {
 		componentName = defaultComponentName
 	}
 
+	if opts.slowProfiler != nil {
+		if opts.profileLabels == nil {
+			opts.profileLabels = &profiling.Labels{}
+		}
+		opts.profileLabels.TraceIDs = true
+	}
+
 	handler := func(c *gin.Context) {
 
 		if !opts.spanFilter(c.Request) {
@@ -103,16 +162,55 @@ func Tracer(tr opentracing.Tracer, options ...MWOption) gin.HandlerFunc {
 		// operation name
 		opName := opts.opNameFunc(c.Request)
 
-		// starting a new span for this request
-		span := tr.StartSpan(opName, ext.RPCServerOption(ctx))
+		// starting a new span for this request, the trace of untrusted callers is not continued
+		trusted := opts.trust == nil || opts.trust.Trusted(c.Request)
+
+		var span opentracing.Span
+		if trusted {
+			span = tr.StartSpan(opName, ext.RPCServerOption(ctx))
+		} else {
+			span = opts.trust.startUntrusted(tr, opName, ctx)
+		}
 		defer span.Finish()
 
+		// jaeger-debug-id and the secret header turn the trace into a debug trace, logged verbosely.
+		// Before any tag, those of a span not sampled yet would be dropped
+		opts.forceSampling(span, c.Request, trusted)
+		debug := IsDebugSpan(span)
+		if debug {
+			hidden := []string{opts.forceHeader}
+			if opts.trust != nil {
+				hidden = append(hidden, opts.trust.header)
+			}
+			LogRequestHeaders(span, "request", c.Request.Header, hidden...)
+		}
+
 		// set span tag info
-		ext.HTTPMethod.Set(span, c.Request.Method)
-		ext.HTTPUrl.Set(span, opts.urlTagFunc(c.Request.URL))
-		ext.Component.Set(span, componentName)
+		semconv.HTTPServerRequest(span, c.Request, opts.urlTagFunc(c.Request.URL))
+		semconv.Component(span, componentName)
+		semconv.HTTPRoute(span, c.FullPath())
+
+		// the headers of untrusted callers go through the same allow-list as their baggage
+		for header, key := range opts.baggageHeaders {
+			v := c.Request.Header.Get(header)
+			if v == "" {
+				continue
+			}
+			if !trusted && !opts.trust.keepsBaggage(key) {
+				semconv.EdgeBaggageDropped(span)
+				continue
+			}
+			span.SetBaggageItem(key, v)
+		}
+
+		if opts.userKey != "" {
+			semconv.User(span, span.BaggageItem(opts.userKey))
+		}
+		if opts.tenantKey != "" {
+			semconv.Tenant(span, span.BaggageItem(opts.tenantKey))
+		}
 
-		// span observer I dont have a fucking clue what is it for
+		// MWSpanObserver hook, it can add its own tags once the request ones are set
 		opts.spanObserver(span, c.Request)
 
 		// update request info with a new span info - to pass down span info
@@ -120,12 +218,29 @@ func Tracer(tr opentracing.Tracer, options ...MWOption) gin.HandlerFunc {
 			opentracing.ContextWithSpan(c.Request.Context(), span),
 		)
 
-		// proceed
-		c.Next()
+		// proceed, with the pprof labels of the request if any
+		stopProfile := opts.slowProfiler.Watch(span)
 
-		code := uint16(c.Writer.Status())
+		opts.profileLabels.Do(c.Request.Context(), span, opName, c.FullPath(), func(ctx context.Context) {
+			if ctx != c.Request.Context() {
+				c.Request = c.Request.WithContext(ctx)
+			}
+			c.Next()
+		})
+
+		stopProfile()
 
-		ext.HTTPStatusCode.Set(span, code)
+		semconv.HTTPStatus(span, c.Writer.Status())
+		semconv.HTTPResponseSize(span, c.Writer.Size())
+
+		if debug {
+			span.LogFields(
+				otlog.String("event", "response"),
+				otlog.Int("status", c.Writer.Status()),
+				otlog.Int("size", c.Writer.Size()),
+				otlog.String("errors", c.Errors.String()),
+			)
+		}
 	}
 
 	return handler
@@ -0,0 +1,65 @@
+package grpctrace
+
+import (
+	"context"
+
+	grpcMiddleware "github.com/grpc-ecosystem/go-grpc-middleware"
+	grpcRecovery "github.com/grpc-ecosystem/go-grpc-middleware/recovery"
+	grpcRetry "github.com/grpc-ecosystem/go-grpc-middleware/retry"
+	grpcCtxTags "github.com/grpc-ecosystem/go-grpc-middleware/tags"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ServerOptions returns the interceptor chains of a server: ctxtags, tracing and panic recovery.
+// Recovery runs inside the tracing interceptor, so a panic ends the span as an Internal error.
+//
+//	s := grpc.NewServer(grpctrace.ServerOptions(grpctrace.WithLogger(logr))...)
+func ServerOptions(opts ...Option) []grpc.ServerOption {
+
+	o := newOptions(opts)
+
+	recoveryOpts := []grpcRecovery.Option{
+		grpcRecovery.WithRecoveryHandlerContext(func(ctx context.Context, p interface{}) error {
+			if o.logr != nil {
+				o.logr.For(ctx).Error("grpc handler panic", zap.Any("panic", p))
+			}
+			return status.Errorf(codes.Internal, "panic: %v", p)
+		}),
+	}
+
+	return []grpc.ServerOption{
+		grpcMiddleware.WithUnaryServerChain(
+			grpcCtxTags.UnaryServerInterceptor(),
+			UnaryServerInterceptor(opts...),
+			grpcRecovery.UnaryServerInterceptor(recoveryOpts...),
+		),
+		grpcMiddleware.WithStreamServerChain(
+			grpcCtxTags.StreamServerInterceptor(),
+			StreamServerInterceptor(opts...),
+			grpcRecovery.StreamServerInterceptor(recoveryOpts...),
+		),
+	}
+}
+
+// DialOptions returns the interceptor chains of a client connection: retries (WithRetry) and tracing.
+// Retries wrap the tracing interceptor, so every attempt is a span of its own.
+//
+//	conn, err := grpc.Dial(target, append(grpctrace.DialOptions(grpctrace.WithLogger(logr)), grpc.WithInsecure())...)
+func DialOptions(opts ...Option) []grpc.DialOption {
+
+	o := newOptions(opts)
+
+	unary := make([]grpc.UnaryClientInterceptor, 0, 2)
+	if len(o.retry) > 0 {
+		unary = append(unary, grpcRetry.UnaryClientInterceptor(o.retry...))
+	}
+	unary = append(unary, UnaryClientInterceptor(opts...))
+
+	return []grpc.DialOption{
+		grpc.WithChainUnaryInterceptor(unary...),
+		grpc.WithChainStreamInterceptor(StreamClientInterceptor(opts...)),
+	}
+}
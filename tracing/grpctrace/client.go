@@ -0,0 +1,199 @@
+package grpctrace
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/alloykh/tracer-demo/tracing"
+	"github.com/alloykh/tracer-demo/tracing/semconv"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// UnaryClientInterceptor starts a client span per call and injects its context into the outgoing metadata
+func UnaryClientInterceptor(opts ...Option) grpc.UnaryClientInterceptor {
+
+	o := newOptions(opts)
+
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, callOpts ...grpc.CallOption) error {
+
+		if o.filter != nil && !o.filter(ctx, method) {
+			return invoker(ctx, method, req, reply, cc, callOpts...)
+		}
+
+		span, ctx := o.startClientSpan(ctx, method, cc)
+		start := time.Now()
+
+		o.logMessage(span, messageSent, 1, req)
+
+		err := invoker(ctx, method, req, reply, cc, callOpts...)
+
+		if err == nil {
+			o.logMessage(span, messageReceived, 1, reply)
+		}
+
+		o.finish(ctx, span, "client", method, start, err)
+
+		return err
+	}
+}
+
+// StreamClientInterceptor starts a client span per stream, finished when the stream ends:
+// the server closes it, an error is received or the context is done.
+// Each message is an event of the span, the end of the stream one more with the message counts.
+func StreamClientInterceptor(opts ...Option) grpc.StreamClientInterceptor {
+
+	o := newOptions(opts)
+
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, callOpts ...grpc.CallOption) (grpc.ClientStream, error) {
+
+		if o.filter != nil && !o.filter(ctx, method) {
+			return streamer(ctx, desc, cc, method, callOpts...)
+		}
+
+		span, ctx := o.startClientSpan(ctx, method, cc)
+		start := time.Now()
+
+		cs, err := streamer(ctx, desc, cc, method, callOpts...)
+		if err != nil {
+			o.finish(ctx, span, "client", method, start, err)
+			return cs, err
+		}
+
+		s := &clientStream{
+			ClientStream: cs,
+			opts:         o,
+			desc:         desc,
+			ctx:          ctx,
+			span:         span,
+			method:       method,
+			start:        start,
+		}
+
+		go func() {
+			// the stream context is done once the stream ends, when the caller gave up on it
+			// before reading it to the end the span is finished with the cancellation
+			<-cs.Context().Done()
+			if err := ctx.Err(); err != nil {
+				s.finishOnce(err)
+			}
+		}()
+
+		return s, nil
+	}
+}
+
+func (o *options) startClientSpan(ctx context.Context, method string, cc *grpc.ClientConn) (opentracing.Span, context.Context) {
+
+	tracer := o.getTracer()
+
+	var parent opentracing.SpanContext
+	if p := opentracing.SpanFromContext(ctx); p != nil {
+		parent = p.Context()
+	}
+
+	span := tracer.StartSpan(o.opNameFunc(method), opentracing.ChildOf(parent), ext.SpanKindRPCClient)
+
+	setMethodTags(span, method)
+	semconv.PeerAddress(span, cc.Target())
+	tracing.TagBudget(span, ctx)
+	if o.peerService != "" {
+		ext.PeerService.Set(span, o.peerService)
+	}
+
+	md, ok := metadata.FromOutgoingContext(ctx)
+	if ok {
+		md = md.Copy()
+	} else {
+		md = metadata.MD{}
+	}
+
+	o.setMetadataTags(span, md)
+
+	// injection failures leave the call untraced downstream, never failed
+	_ = tracer.Inject(span.Context(), opentracing.HTTPHeaders, metadataCarrier(md))
+
+	ctx = metadata.NewOutgoingContext(ctx, md)
+
+	return span, opentracing.ContextWithSpan(ctx, span)
+}
+
+type clientStream struct {
+	grpc.ClientStream
+
+	opts   *options
+	desc   *grpc.StreamDesc
+	ctx    context.Context
+	span   opentracing.Span
+	method string
+	start  time.Time
+
+	mu       sync.Mutex
+	sent     int
+	received int
+	once     sync.Once
+}
+
+func (s *clientStream) SendMsg(m interface{}) error {
+
+	err := s.ClientStream.SendMsg(m)
+	if err == io.EOF {
+		// the stream is over, its status comes with RecvMsg
+		return err
+	}
+	if err != nil {
+		s.finishOnce(err)
+		return err
+	}
+
+	s.mu.Lock()
+	s.sent++
+	id := s.sent
+	s.mu.Unlock()
+
+	s.opts.logMessage(s.span, messageSent, id, m)
+
+	return nil
+}
+
+func (s *clientStream) RecvMsg(m interface{}) error {
+
+	err := s.ClientStream.RecvMsg(m)
+
+	if err == io.EOF {
+		s.finishOnce(nil)
+		return err
+	}
+	if err != nil {
+		s.finishOnce(err)
+		return err
+	}
+
+	s.mu.Lock()
+	s.received++
+	id := s.received
+	s.mu.Unlock()
+
+	s.opts.logMessage(s.span, messageReceived, id, m)
+
+	// client streams and unary calls get a single response
+	if !s.desc.ServerStreams {
+		s.finishOnce(nil)
+	}
+
+	return nil
+}
+
+func (s *clientStream) finishOnce(err error) {
+	s.once.Do(func() {
+		s.mu.Lock()
+		sent, received := s.sent, s.received
+		s.mu.Unlock()
+
+		s.opts.endStream(s.ctx, s.span, "client", s.method, s.start, sent, received, err)
+	})
+}
@@ -0,0 +1,156 @@
+package grpctrace
+
+import (
+	"context"
+
+	"github.com/alloykh/tracer-demo/log"
+	"github.com/alloykh/tracer-demo/tracing/profiling"
+	grpcRetry "github.com/grpc-ecosystem/go-grpc-middleware/retry"
+	"github.com/opentracing/opentracing-go"
+)
+
+const componentName = "gRPC"
+
+type options struct {
+	tracer       opentracing.Tracer
+	logr         *log.Factory
+	opNameFunc   func(fullMethod string) string
+	filter       func(ctx context.Context, fullMethod string) bool
+	metadataKeys []string
+	payloadSizes bool
+	payloadLimit int
+	maxEvents    int
+	peerService  string
+	retry        []grpcRetry.CallOption
+
+	// server only
+	profileLabels *profiling.Labels
+	slowProfiler  *profiling.SlowProfiler
+}
+
+// Option controls the behavior of the interceptors.
+type Option func(*options)
+
+func newOptions(opts []Option) *options {
+
+	o := &options{
+		opNameFunc:   func(fullMethod string) string { return fullMethod },
+		payloadSizes: true,
+		maxEvents:    defaultMaxMessageEvents,
+	}
+
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	// the profiles of the slow calls keep the samples of their trace
+	if o.slowProfiler != nil {
+		if o.profileLabels == nil {
+			o.profileLabels = &profiling.Labels{}
+		}
+		o.profileLabels.TraceIDs = true
+	}
+
+	return o
+}
+
+// message events logged per direction of a stream, the span keeps its logs in memory until it is finished
+const defaultMaxMessageEvents = 100
+
+// getTracer resolves the global tracer on every call, clients are often dialed before it is set
+func (o *options) getTracer() opentracing.Tracer {
+	if o.tracer != nil {
+		return o.tracer
+	}
+	return opentracing.GlobalTracer()
+}
+
+// WithTracer sets the tracer, the global tracer is used by default
+func WithTracer(tracer opentracing.Tracer) Option {
+	return func(o *options) {
+		o.tracer = tracer
+	}
+}
+
+// WithLogger logs every call through logr.For, so the lines carry the trace id and are echoed
+// into the span: failed calls at the error level, the others at the debug level
+func WithLogger(logr *log.Factory) Option {
+	return func(o *options) {
+		o.logr = logr
+	}
+}
+
+// WithOpName sets the operation name of the spans, the full method (/package.Service/Method) by default
+func WithOpName(f func(fullMethod string) string) Option {
+	return func(o *options) {
+		o.opNameFunc = f
+	}
+}
+
+// WithFilter skips tracing the calls for which f returns false, e.g. health checks
+func WithFilter(f func(ctx context.Context, fullMethod string) bool) Option {
+	return func(o *options) {
+		o.filter = f
+	}
+}
+
+// WithMetadataTags tags the spans with the values of the given metadata keys as grpc.metadata.<key>,
+// incoming metadata on the server side and outgoing metadata on the client side
+func WithMetadataTags(keys ...string) Option {
+	return func(o *options) {
+		o.metadataKeys = append(o.metadataKeys, keys...)
+	}
+}
+
+// WithPayloadSizes logs a span event with the size of every message sent and received, on by default
+func WithPayloadSizes(enabled bool) Option {
+	return func(o *options) {
+		o.payloadSizes = enabled
+	}
+}
+
+// WithPayloadCapture adds the messages as JSON, truncated to maxBytes, to the message events.
+// Payloads may hold personal data, keep it for development and debugging.
+func WithPayloadCapture(maxBytes int) Option {
+	return func(o *options) {
+		o.payloadLimit = maxBytes
+	}
+}
+
+// WithMaxMessageEvents caps the message events logged on the span of a stream per direction, 100 by default,
+// 0 or less logs them all. The messages are still counted.
+func WithMaxMessageEvents(n int) Option {
+	return func(o *options) {
+		o.maxEvents = n
+	}
+}
+
+// WithPeerService tags client spans with peer.service, the name of the called service
+func WithPeerService(name string) Option {
+	return func(o *options) {
+		o.peerService = name
+	}
+}
+
+// WithRetry makes DialOptions retry unary calls, every attempt gets its own span
+func WithRetry(callOpts ...grpcRetry.CallOption) Option {
+	return func(o *options) {
+		o.retry = append(o.retry, callOpts...)
+	}
+}
+
+// WithProfileLabels runs the server handlers with the pprof labels operation, route (the full method)
+// and service, plus trace_id for sampled traces when traceIDs is set
+func WithProfileLabels(service string, traceIDs bool) Option {
+	return func(o *options) {
+		o.profileLabels = &profiling.Labels{Service: service, TraceIDs: traceIDs}
+	}
+}
+
+// WithSlowProfile attaches a short CPU profile to the server calls slower than the threshold of p,
+// it turns the trace_id pprof label on
+func WithSlowProfile(p *profiling.SlowProfiler) Option {
+	return func(o *options) {
+		o.slowProfiler = p
+	}
+}
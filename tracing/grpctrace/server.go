@@ -0,0 +1,171 @@
+package grpctrace
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/alloykh/tracer-demo/tracing"
+	"github.com/alloykh/tracer-demo/tracing/semconv"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// UnaryServerInterceptor continues the trace of the incoming metadata with a server span per call
+func UnaryServerInterceptor(opts ...Option) grpc.UnaryServerInterceptor {
+
+	o := newOptions(opts)
+
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+
+		if o.filter != nil && !o.filter(ctx, info.FullMethod) {
+			return handler(ctx, req)
+		}
+
+		span, ctx := o.startServerSpan(ctx, info.FullMethod)
+		start := time.Now()
+
+		o.logMessage(span, messageReceived, 1, req)
+
+		stopProfile := o.slowProfiler.Watch(span)
+
+		var resp interface{}
+		var err error
+		o.profileLabels.Do(ctx, span, o.opNameFunc(info.FullMethod), info.FullMethod, func(ctx context.Context) {
+			resp, err = handler(ctx, req)
+		})
+
+		stopProfile()
+
+		if err == nil {
+			o.logMessage(span, messageSent, 1, resp)
+		}
+
+		o.finish(ctx, span, "server", info.FullMethod, start, err)
+
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor continues the trace of the incoming metadata with a server span per stream,
+// the stream context carries the span so handlers can log with log.Factory.For(stream.Context())
+func StreamServerInterceptor(opts ...Option) grpc.StreamServerInterceptor {
+
+	o := newOptions(opts)
+
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+
+		if o.filter != nil && !o.filter(ss.Context(), info.FullMethod) {
+			return handler(srv, ss)
+		}
+
+		span, ctx := o.startServerSpan(ss.Context(), info.FullMethod)
+		start := time.Now()
+
+		stream := &serverStream{ServerStream: ss, opts: o, ctx: ctx, span: span}
+
+		stopProfile := o.slowProfiler.Watch(span)
+
+		var err error
+		o.profileLabels.Do(ctx, span, o.opNameFunc(info.FullMethod), info.FullMethod, func(ctx context.Context) {
+			stream.ctx = ctx
+			err = handler(srv, stream)
+		})
+
+		stopProfile()
+
+		// a handler returning nil once the client went away still ended with the cancellation
+		spanErr := err
+		if spanErr == nil {
+			spanErr = ss.Context().Err()
+		}
+
+		stream.mu.Lock()
+		sent, received := stream.sent, stream.received
+		stream.mu.Unlock()
+
+		o.endStream(ctx, span, "server", info.FullMethod, start, sent, received, spanErr)
+
+		return err
+	}
+}
+
+func (o *options) startServerSpan(ctx context.Context, method string) (opentracing.Span, context.Context) {
+
+	tracer := o.getTracer()
+
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		md = metadata.MD{}
+	}
+
+	// a missing or corrupted context starts a new trace
+	parent, _ := tracer.Extract(opentracing.HTTPHeaders, metadataCarrier(md))
+
+	span := tracer.StartSpan(o.opNameFunc(method), ext.RPCServerOption(parent))
+
+	setMethodTags(span, method)
+	o.setMetadataTags(span, md)
+	tracing.TagBudget(span, ctx)
+
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		semconv.PeerAddress(span, p.Addr.String())
+		semconv.NetPeer(span, p.Addr.String())
+	}
+
+	return span, opentracing.ContextWithSpan(ctx, span)
+}
+
+type serverStream struct {
+	grpc.ServerStream
+
+	opts *options
+	ctx  context.Context
+	span opentracing.Span
+
+	mu       sync.Mutex
+	sent     int
+	received int
+}
+
+// Context returns the stream context with the server span
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *serverStream) SendMsg(m interface{}) error {
+
+	err := s.ServerStream.SendMsg(m)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.sent++
+	id := s.sent
+	s.mu.Unlock()
+
+	s.opts.logMessage(s.span, messageSent, id, m)
+
+	return nil
+}
+
+func (s *serverStream) RecvMsg(m interface{}) error {
+
+	err := s.ServerStream.RecvMsg(m)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.received++
+	id := s.received
+	s.mu.Unlock()
+
+	s.opts.logMessage(s.span, messageReceived, id, m)
+
+	return nil
+}
@@ -0,0 +1,189 @@
+package grpctrace
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/alloykh/tracer-demo/tracing"
+	"github.com/alloykh/tracer-demo/tracing/semconv"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	otlog "github.com/opentracing/opentracing-go/log"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// message event types
+const (
+	messageSent     = "SENT"
+	messageReceived = "RECEIVED"
+)
+
+// metadataCarrier injects and extracts span contexts into gRPC metadata
+type metadataCarrier metadata.MD
+
+// Set implements opentracing.TextMapWriter, metadata keys are lowercase
+func (c metadataCarrier) Set(key, val string) {
+	key = strings.ToLower(key)
+	c[key] = append(c[key], val)
+}
+
+// ForeachKey implements opentracing.TextMapReader
+func (c metadataCarrier) ForeachKey(handler func(key, val string) error) error {
+	for k, values := range c {
+		for _, v := range values {
+			if err := handler(k, v); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// setMethodTags tags the rpc service and method of /package.Service/Method
+func setMethodTags(span opentracing.Span, fullMethod string) {
+
+	semconv.Component(span, componentName)
+	semconv.RPC(span, "grpc", fullMethod)
+}
+
+func (o *options) setMetadataTags(span opentracing.Span, md metadata.MD) {
+	for _, key := range o.metadataKeys {
+		if values := md.Get(key); len(values) > 0 {
+			span.SetTag("grpc.metadata."+strings.ToLower(key), strings.Join(values, ","))
+		}
+	}
+}
+
+// logMessage records a message event with its size and, when captured, its payload
+func (o *options) logMessage(span opentracing.Span, direction string, id int, msg interface{}) {
+
+	// debug traces capture the payloads whatever the options
+	limit := o.payloadLimit
+	if limit <= 0 && tracing.IsDebugSpan(span) {
+		limit = tracing.DebugPayloadLimit
+	}
+
+	if !o.payloadSizes && limit <= 0 {
+		return
+	}
+
+	if o.maxEvents > 0 && id > o.maxEvents {
+		return
+	}
+
+	fields := []otlog.Field{
+		otlog.String("event", "message"),
+		otlog.String("message.type", direction),
+		otlog.Int("message.id", id),
+	}
+
+	m, ok := msg.(proto.Message)
+
+	if ok && o.payloadSizes {
+		fields = append(fields, otlog.Int("message.uncompressed_size", proto.Size(m)))
+	}
+
+	if ok && limit > 0 {
+		if data, err := protojson.Marshal(m); err == nil {
+			if len(data) > limit {
+				data = append(data[:limit], "..."...)
+			}
+			fields = append(fields, otlog.String("message.payload", string(data)))
+		}
+	}
+
+	span.LogFields(fields...)
+}
+
+// endStream records how a stream ended and the messages it carried, then finishes the span
+func (o *options) endStream(ctx context.Context, span opentracing.Span, side, fullMethod string, start time.Time, sent, received int, err error) {
+
+	err = codeError(err)
+
+	event := "stream end"
+	if code := status.Code(err); code == codes.Canceled || code == codes.DeadlineExceeded {
+		event = "stream cancelled"
+	}
+
+	span.SetTag("grpc.messages.sent", sent)
+	span.SetTag("grpc.messages.received", received)
+
+	if o.maxEvents > 0 && (sent > o.maxEvents || received > o.maxEvents) {
+		span.SetTag("grpc.messages.events_dropped", dropped(sent, o.maxEvents)+dropped(received, o.maxEvents))
+	}
+
+	span.LogFields(
+		otlog.String("event", event),
+		otlog.Int("message.sent", sent),
+		otlog.Int("message.received", received),
+	)
+
+	o.finish(ctx, span, side, fullMethod, start, err, zap.Int("grpc.sent", sent), zap.Int("grpc.received", received))
+}
+
+// dropped returns the message events over max not logged
+func dropped(n, max int) int {
+	if n > max {
+		return n - max
+	}
+	return 0
+}
+
+// finish tags the status code, records the error and logs the call. A call cancelled by its own context,
+// e.g. a watch stream stopped at shutdown, ended as asked: it is not recorded as a failure.
+func (o *options) finish(ctx context.Context, span opentracing.Span, side, fullMethod string, start time.Time, err error, extra ...zap.Field) {
+
+	err = codeError(err)
+
+	code := status.Code(err)
+	semconv.GRPCStatus(span, code)
+
+	elapsed := time.Since(start)
+
+	cancelled := code == codes.Canceled && ctx.Err() == context.Canceled
+	failed := err != nil && code != codes.OK && !cancelled
+
+	if failed {
+		if o.logr == nil {
+			semconv.Exception(span, err)
+		} else {
+			ext.Error.Set(span, true)
+		}
+	}
+
+	if o.logr != nil {
+		fields := []zap.Field{
+			zap.String("grpc.method", fullMethod),
+			zap.String("grpc.code", code.String()),
+			zap.Duration("grpc.duration", elapsed),
+		}
+		fields = append(fields, extra...)
+		switch {
+		case failed:
+			o.logr.For(ctx).Error("grpc "+side+" call failed", append(fields, zap.String("err", err.Error()))...)
+		case cancelled:
+			o.logr.For(ctx).Debug("grpc "+side+" call cancelled", fields...)
+		default:
+			o.logr.For(ctx).Debug("grpc "+side+" call", fields...)
+		}
+	}
+
+	span.Finish()
+}
+
+// codeError gives the bare context errors, handlers and callers give up with, their status code
+func codeError(err error) error {
+	switch err {
+	case context.DeadlineExceeded:
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	case context.Canceled:
+		return status.Error(codes.Canceled, err.Error())
+	}
+	return err
+}
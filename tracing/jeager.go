@@ -4,33 +4,174 @@ import (
 	"fmt"
 	"github.com/alloykh/tracer-demo/log"
 	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/uber/jaeger-client-go"
 	config "github.com/uber/jaeger-client-go/config"
 	"github.com/uber/jaeger-client-go/rpcmetrics"
 	"github.com/uber/jaeger-lib/metrics"
 	"go.uber.org/zap"
+	"sync/atomic"
+	"time"
 )
 
+type jaegerOptions struct {
+	sampler     *config.SamplerConfig
+	reporter    *config.ReporterConfig
+	reporters   []jaeger.Reporter
+	baggage     *config.BaggageRestrictionsConfig
+	baggageTags []string
+	stats       *ReporterStats
+
+	dynamicSampler *Sampler
+}
+
+// JaegerOption controls the behavior of the tracer created by InitJaeger.
+type JaegerOption func(*jaegerOptions)
+
+// WithSampler returns a JaegerOption that replaces the default const sampler, and a dynamic sampler given before.
+func WithSampler(sampler *config.SamplerConfig) JaegerOption {
+	return func(options *jaegerOptions) {
+		if sampler != nil {
+			options.sampler = sampler
+			options.dynamicSampler = nil
+		}
+	}
+}
+
+// WithRemoteSampler returns a JaegerOption that polls the sampling strategy of the service
+// from serverURL (e.g. "http://localhost:5778/sampling") every refresh interval.
+// initialRate is the probability used until the first strategy is received.
+func WithRemoteSampler(serverURL string, refresh time.Duration, initialRate float64) JaegerOption {
+	return WithSampler(&config.SamplerConfig{
+		Type:                    "remote",
+		Param:                   initialRate,
+		SamplingServerURL:       serverURL,
+		SamplingRefreshInterval: refresh,
+	})
+}
+
+// WithReporter returns a JaegerOption that configures where and how spans are sent.
+func WithReporter(reporter *config.ReporterConfig) JaegerOption {
+	return func(options *jaegerOptions) {
+		if reporter != nil {
+			options.reporter = reporter
+		}
+	}
+}
+
+// WithLocalAgent returns a JaegerOption that sends spans to the agent at hostPort
+// (e.g. the stand-in of tracing/agent) and flushes them every flush interval.
+func WithLocalAgent(hostPort string, flush time.Duration) JaegerOption {
+	return WithReporter(&config.ReporterConfig{
+		LocalAgentHostPort:  hostPort,
+		BufferFlushInterval: flush,
+	})
+}
+
+// WithSpanReporter returns a JaegerOption that hands every finished sampled span
+// to r in addition to the agent, e.g. an in-process trace recorder.
+func WithSpanReporter(r jaeger.Reporter) JaegerOption {
+	return func(options *jaegerOptions) {
+		if r != nil {
+			options.reporters = append(options.reporters, r)
+		}
+	}
+}
+
+// WithBaggageRestrictions returns a JaegerOption that polls the baggage keys the service may set, and their maximum
+// lengths, from the agent at hostPort (e.g. the sampling server given a restrictions file) every refresh interval.
+// Keys not allowed are dropped, longer values truncated. With denyOnFailure no baggage is set
+// until the restrictions are received, otherwise any key is allowed until then.
+func WithBaggageRestrictions(hostPort string, refresh time.Duration, denyOnFailure bool) JaegerOption {
+	return func(options *jaegerOptions) {
+		options.baggage = &config.BaggageRestrictionsConfig{
+			HostPort:                           hostPort,
+			RefreshInterval:                    refresh,
+			DenyBaggageOnInitializationFailure: denyOnFailure,
+		}
+	}
+}
+
+// WithBaggageTags returns a JaegerOption that tags every span with the baggage items of keys, as baggage.<key>,
+// so traces can be searched by them.
+func WithBaggageTags(keys ...string) JaegerOption {
+	return func(options *jaegerOptions) {
+		options.baggageTags = append(options.baggageTags, keys...)
+	}
+}
+
 // InitJaeger -
-func InitJaeger(serviceName string, metricsFactory metrics.Factory, logger *log.Factory) (opentracing.Tracer, func()) {
+func InitJaeger(serviceName string, metricsFactory metrics.Factory, logger *log.Factory, options ...JaegerOption) (opentracing.Tracer, func()) {
 
-	// Jaeger configuration
-	cfg := config.Configuration{
-		ServiceName: serviceName, // app name
-		Sampler: &config.SamplerConfig{
+	// default options
+	opts := &jaegerOptions{
+		sampler: &config.SamplerConfig{
 			Type:  "const",
 			Param: 1,
 		},
 	}
 
+	for _, opt := range options {
+		opt(opts)
+	}
+
+	// Jaeger configuration
+	cfg := config.Configuration{
+		ServiceName: serviceName, // app name
+		Sampler:     opts.sampler,
+		Reporter:    opts.reporter,
+
+		BaggageRestrictions: opts.baggage,
+	}
+
 	// logger for jaeger
 	jaegerLogger := jaegerLoggerAdapter{logger: logger.Default()}
 
-	// init jaeger tracer
-	tracer, closer, err := cfg.NewTracer(
+	// the metrics of the reporter are followed by the stats
+	if opts.stats != nil {
+		queueSize := defaultReporterQueueSize
+		if cfg.Reporter != nil && cfg.Reporter.QueueSize > 0 {
+			queueSize = cfg.Reporter.QueueSize
+		}
+		atomic.StoreInt64(&opts.stats.queueSize, int64(queueSize))
+
+		if metricsFactory == nil {
+			metricsFactory = metrics.NullFactory
+		}
+		metricsFactory = statsFactory{Factory: metricsFactory, stats: opts.stats}
+	}
+
+	tracerOptions := []config.Option{
 		config.Logger(jaegerLogger),
 		config.Metrics(metricsFactory),
 		config.Observer(rpcmetrics.NewObserver(metricsFactory, rpcmetrics.DefaultNameNormalizer)),
-	)
+	}
+
+	if opts.dynamicSampler != nil {
+		tracerOptions = append(tracerOptions, config.Sampler(opts.dynamicSampler))
+	}
+
+	if len(opts.baggageTags) > 0 {
+		tracerOptions = append(tracerOptions, config.ContribObserver(baggageTagger(opts.baggageTags)))
+	}
+
+	// extra reporters run next to the one sending spans to the agent
+	if len(opts.reporters) > 0 {
+		reporterCfg := cfg.Reporter
+		if reporterCfg == nil {
+			reporterCfg = &config.ReporterConfig{}
+		}
+
+		remoteReporter, err := reporterCfg.NewReporter(serviceName, jaeger.NewMetrics(metricsFactory, nil), jaegerLogger)
+		if err != nil {
+			logger.Default().Fatal("cannot initialize Jaeger reporter", zap.Error(err))
+		}
+
+		reporter := jaeger.NewCompositeReporter(append([]jaeger.Reporter{remoteReporter}, opts.reporters...)...)
+		tracerOptions = append(tracerOptions, config.Reporter(reporter))
+	}
+
+	// init jaeger tracer
+	tracer, closer, err := cfg.NewTracer(tracerOptions...)
 
 	if err != nil {
 		logger.Default().Fatal("cannot initialize Jaeger Tracer", zap.Error(err))
@@ -0,0 +1,19 @@
+package tracing
+
+import (
+	"github.com/alloykh/tracer-demo/tracing/semconv"
+	"github.com/opentracing/opentracing-go"
+	"github.com/uber/jaeger-client-go"
+)
+
+// TagLink records a span context the span relates to without being part of its trace, a link,
+// as the link.trace_id, link.span_id and link.type tags. Nothing is tagged for an invalid context.
+func TagLink(span opentracing.Span, linked opentracing.SpanContext, linkType string) {
+
+	sc, ok := linked.(jaeger.SpanContext)
+	if !ok || !sc.IsValid() {
+		return
+	}
+
+	semconv.Link(span, sc.TraceID().String(), sc.SpanID().String(), linkType)
+}
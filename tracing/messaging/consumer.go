@@ -0,0 +1,124 @@
+package messaging
+
+import (
+	"context"
+
+	"github.com/alloykh/tracer-demo/tracing/semconv"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	otlog "github.com/opentracing/opentracing-go/log"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// Handler processes a message, the message is acked when it returns nil and redelivered otherwise
+type Handler func(ctx context.Context, msg *Message) error
+
+// Consumer receives the messages of a subscription and hands each one to its handler with a consumer span.
+// The span follows from the producer span found in the message headers: the producer does not wait for it.
+type Consumer struct {
+	sub     Subscription
+	group   string
+	handler Handler
+	opts    *options
+}
+
+// NewConsumer wraps sub, the subscription of group
+func NewConsumer(sub Subscription, group string, handler Handler, opts ...Option) *Consumer {
+	return &Consumer{sub: sub, group: group, handler: handler, opts: newOptions(opts)}
+}
+
+// Run handles messages until ctx is done, it returns nil then, or the subscription fails
+func (c *Consumer) Run(ctx context.Context) error {
+
+	for {
+
+		d, err := c.sub.Receive(ctx)
+
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		c.handle(ctx, d)
+	}
+}
+
+func (c *Consumer) handle(ctx context.Context, d Delivery) {
+
+	msg := d.Message()
+	tracer := c.opts.getTracer()
+
+	var refs []opentracing.StartSpanOption
+	producer, err := tracer.Extract(opentracing.TextMap, msg.Headers)
+	if err == nil {
+		refs = append(refs, opentracing.FollowsFrom(producer))
+	}
+
+	span := tracer.StartSpan("receive "+msg.Topic, append(refs, ext.SpanKindConsumer)...)
+	defer span.Finish()
+
+	semconv.Component(span, componentName)
+	semconv.Messaging(span, ext.SpanKindConsumerEnum, c.opts.system, msg.Topic)
+	semconv.MessageID(span, msg.ID)
+	semconv.MessagePayloadSize(span, len(msg.Payload))
+	semconv.MessageConsumerGroup(span, c.group)
+	semconv.MessageRedeliveries(span, msg.Redeliveries())
+
+	ctx = opentracing.ContextWithSpan(ctx, span)
+
+	if err != nil && err != opentracing.ErrSpanContextNotFound && c.opts.logr != nil {
+		c.opts.logr.For(ctx).Error("extract trace context", zap.String("id", msg.ID), zap.String("err", err.Error()))
+	}
+
+	if c.opts.maxRedeliveries > 0 && msg.Redeliveries() > c.opts.maxRedeliveries {
+		ext.Error.Set(span, true)
+		if c.opts.logr != nil {
+			c.opts.logr.For(ctx).Error("message dropped", zap.String("id", msg.ID), zap.Int("redeliveries", msg.Redeliveries()))
+		} else {
+			span.LogFields(otlog.String("event", "dropped"), otlog.Int("redeliveries", msg.Redeliveries()))
+		}
+		c.settle(ctx, span, d.Ack)
+		return
+	}
+
+	if c.opts.logr != nil {
+		c.opts.logr.For(ctx).Debug("message received", zap.String("topic", msg.Topic), zap.String("id", msg.ID), zap.Int("redeliveries", msg.Redeliveries()))
+	}
+
+	if err := c.call(ctx, msg); err != nil {
+		if c.opts.logr != nil {
+			ext.Error.Set(span, true)
+			c.opts.logr.For(ctx).Error("message handling failed", zap.String("id", msg.ID), zap.String("err", err.Error()))
+		} else {
+			semconv.Exception(span, err)
+		}
+		c.settle(ctx, span, d.Nack)
+		return
+	}
+
+	c.settle(ctx, span, d.Ack)
+}
+
+// call turns a panic of the handler into an error, the message is redelivered
+func (c *Consumer) call(ctx context.Context, msg *Message) (err error) {
+
+	defer func() {
+		if p := recover(); p != nil {
+			err = errors.Errorf("handler panic: %v", p)
+		}
+	}()
+
+	return c.handler(ctx, msg)
+}
+
+func (c *Consumer) settle(ctx context.Context, span opentracing.Span, outcome func() error) {
+	if err := outcome(); err != nil {
+		span.LogFields(otlog.String("event", "settle failed"), otlog.String("message", err.Error()))
+		if c.opts.logr != nil {
+			c.opts.logr.For(ctx).Error("message settle", zap.String("err", err.Error()))
+		}
+	}
+}
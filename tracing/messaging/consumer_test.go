@@ -0,0 +1,129 @@
+package messaging_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alloykh/tracer-demo/tracing/messaging"
+	"github.com/opentracing/opentracing-go"
+	"github.com/uber/jaeger-client-go"
+)
+
+// consume runs a consumer of group until done returns true
+func consume(t *testing.T, m *messaging.Memory, group string, handler messaging.Handler, done func() bool, opts ...messaging.Option) {
+	t.Helper()
+
+	sub := subscribe(t, m, "orders", group)
+	defer sub.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- messaging.NewConsumer(sub, group, handler, opts...).Run(ctx)
+	}()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !done() {
+		if time.Now().After(deadline) {
+			t.Fatal("consumer timed out")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	cancel()
+	if err := <-stopped; err != nil {
+		t.Errorf("run: %v", err)
+	}
+}
+
+func TestConsumerSpanFollowsFromProducer(t *testing.T) {
+
+	reporter := jaeger.NewInMemoryReporter()
+	tracer, closer := jaeger.NewTracer("messaging-test", jaeger.NewConstSampler(true), reporter)
+	defer closer.Close()
+
+	m := messaging.NewMemory()
+	defer m.Close()
+
+	sub := subscribe(t, m, "orders", "billing")
+	_ = sub.Close()
+
+	parent := tracer.StartSpan("checkout")
+	ctx := opentracing.ContextWithSpan(context.Background(), parent)
+
+	if _, err := messaging.NewProducer(m, messaging.WithTracer(tracer)).Publish(ctx, "orders", []byte("a"), nil); err != nil {
+		t.Fatal(err)
+	}
+	parent.Finish()
+
+	var handled int32
+	consume(t, m, "billing", func(ctx context.Context, msg *messaging.Message) error {
+		atomic.AddInt32(&handled, 1)
+		return nil
+	}, func() bool { return atomic.LoadInt32(&handled) == 1 && m.Pending("orders", "billing") == 0 }, messaging.WithTracer(tracer))
+
+	spans := make(map[string]*jaeger.Span)
+	for _, s := range reporter.GetSpans() {
+		span := s.(*jaeger.Span)
+		spans[span.OperationName()] = span
+	}
+
+	producer, consumer := spans["send orders"], spans["receive orders"]
+	if producer == nil || consumer == nil {
+		t.Fatalf("spans %v, want send orders and receive orders", spans)
+	}
+
+	if producer.SpanContext().ParentID() != parent.Context().(jaeger.SpanContext).SpanID() {
+		t.Error("producer span is not a child of the publishing span")
+	}
+
+	refs := consumer.References()
+	if len(refs) != 1 || refs[0].Type != opentracing.FollowsFromRef {
+		t.Fatalf("consumer references %v, want one follows from", refs)
+	}
+	if ref := refs[0].ReferencedContext.(jaeger.SpanContext); ref.SpanID() != producer.SpanContext().SpanID() {
+		t.Errorf("consumer follows from %s, want the producer span %s", ref.SpanID(), producer.SpanContext().SpanID())
+	}
+	if consumer.SpanContext().TraceID() != producer.SpanContext().TraceID() {
+		t.Error("consumer span not in the trace of the producer")
+	}
+}
+
+func TestConsumerDropsAfterMaxRedeliveries(t *testing.T) {
+
+	reporter := jaeger.NewInMemoryReporter()
+	tracer, closer := jaeger.NewTracer("messaging-test", jaeger.NewConstSampler(true), reporter)
+	defer closer.Close()
+
+	m := messaging.NewMemory()
+	defer m.Close()
+
+	_ = subscribe(t, m, "orders", "billing").Close()
+	publish(t, m, "orders", "a")
+
+	var handled int32
+	consume(t, m, "billing", func(ctx context.Context, msg *messaging.Message) error {
+		atomic.AddInt32(&handled, 1)
+		return errors.New("billing down")
+	}, func() bool { return m.Pending("orders", "billing") == 0 }, messaging.WithTracer(tracer), messaging.WithMaxRedeliveries(2))
+
+	// the first delivery and two redeliveries are handled, the third redelivery is dropped
+	if n := atomic.LoadInt32(&handled); n != 3 {
+		t.Errorf("handled %d times, want 3", n)
+	}
+
+	spans := reporter.GetSpans()
+	if len(spans) != 4 {
+		t.Fatalf("%d spans, want 4", len(spans))
+	}
+	for _, s := range spans {
+		if failed, _ := s.(*jaeger.Span).Tags()["error"].(bool); !failed {
+			t.Errorf("span %s not marked as an error", s.(*jaeger.Span).OperationName())
+		}
+	}
+}
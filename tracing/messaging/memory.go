@@ -0,0 +1,431 @@
+package messaging
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultAckTimeout - time a consumer has to ack a message before it is redelivered
+const DefaultAckTimeout = 30 * time.Second
+
+// DefaultMaxIdleMessages - messages kept for a consumer group while it has no subscription
+const DefaultMaxIdleMessages = 10000
+
+// Memory is an in-process broker, for the demos and for testing locally.
+// Topics and consumer groups are created on first use. A group only gets the messages published
+// once it exists, unacked messages are redelivered to the group after the ack timeout and nacked ones at once.
+// A group whose subscriptions are all closed keeps the last messages for the next one, up to the
+// max idle messages, the older ones are dropped.
+type Memory struct {
+	ackTimeout time.Duration
+	maxIdle    int
+
+	mu     sync.Mutex
+	topics map[string]map[string]*memoryGroup
+	seq    uint64
+	closed bool
+}
+
+// MemoryOption controls the behavior of the Memory broker.
+type MemoryOption func(*Memory)
+
+// WithAckTimeout sets the time a consumer has to ack a message, DefaultAckTimeout by default
+func WithAckTimeout(d time.Duration) MemoryOption {
+	return func(m *Memory) {
+		if d > 0 {
+			m.ackTimeout = d
+		}
+	}
+}
+
+// WithMaxIdleMessages sets the messages kept for a group without subscription, DefaultMaxIdleMessages by default
+func WithMaxIdleMessages(n int) MemoryOption {
+	return func(m *Memory) {
+		if n > 0 {
+			m.maxIdle = n
+		}
+	}
+}
+
+// NewMemory returns an empty broker
+func NewMemory(opts ...MemoryOption) *Memory {
+
+	m := &Memory{
+		ackTimeout: DefaultAckTimeout,
+		maxIdle:    DefaultMaxIdleMessages,
+		topics:     make(map[string]map[string]*memoryGroup),
+	}
+
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	return m
+}
+
+// Publish implements Publisher, every group of the topic gets its own copy of msg
+func (m *Memory) Publish(ctx context.Context, msg *Message) error {
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return ErrClosed
+	}
+
+	m.seq++
+	if msg.ID == "" {
+		msg.ID = fmt.Sprintf("%s-%d", msg.Topic, m.seq)
+	}
+
+	for _, g := range m.topics[msg.Topic] {
+		g.push(m.seq, &Message{
+			ID:      msg.ID,
+			Topic:   msg.Topic,
+			Headers: msg.Headers.clone(),
+			Payload: msg.Payload,
+		})
+	}
+
+	return nil
+}
+
+// Subscribe implements Subscriber
+func (m *Memory) Subscribe(topic, group string) (Subscription, error) {
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return nil, ErrClosed
+	}
+
+	groups, ok := m.topics[topic]
+	if !ok {
+		groups = make(map[string]*memoryGroup)
+		m.topics[topic] = groups
+	}
+
+	g, ok := groups[group]
+	if !ok {
+		g = newMemoryGroup(m.ackTimeout, m.maxIdle)
+		groups[group] = g
+	}
+
+	g.subscribe()
+
+	return &memorySubscription{group: g, done: make(chan struct{})}, nil
+}
+
+// Pending returns the messages of the group waiting for a consumer or for an ack
+func (m *Memory) Pending(topic, group string) int {
+
+	m.mu.Lock()
+	g := m.topics[topic][group]
+	m.mu.Unlock()
+
+	if g == nil {
+		return 0
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return g.queue.len() + len(g.inflight)
+}
+
+// Dropped returns the messages of the group dropped while it had no subscription
+func (m *Memory) Dropped(topic, group string) int {
+
+	m.mu.Lock()
+	g := m.topics[topic][group]
+	m.mu.Unlock()
+
+	if g == nil {
+		return 0
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return g.dropped
+}
+
+// Close stops the broker, the pending messages are lost
+func (m *Memory) Close() error {
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return nil
+	}
+	m.closed = true
+
+	for _, groups := range m.topics {
+		for _, g := range groups {
+			g.close()
+		}
+	}
+
+	return nil
+}
+
+// queued is a message of a group, seq is the sequence number the broker gave it, the ids of the
+// publishers may not be unique
+type queued struct {
+	seq uint64
+	msg *Message
+}
+
+// ring is the queue of a group, a ring buffer growing when full so that popping does not leak
+// the backing array as slicing would
+type ring struct {
+	buf  []queued
+	head int
+	n    int
+}
+
+func (r *ring) len() int {
+	return r.n
+}
+
+func (r *ring) push(q queued) {
+
+	if r.n == len(r.buf) {
+		buf := make([]queued, 2*len(r.buf)+1)
+		for i := 0; i < r.n; i++ {
+			buf[i] = r.buf[(r.head+i)%len(r.buf)]
+		}
+		r.buf, r.head = buf, 0
+	}
+
+	r.buf[(r.head+r.n)%len(r.buf)] = q
+	r.n++
+}
+
+// pop must not be called on an empty ring
+func (r *ring) pop() queued {
+
+	q := r.buf[r.head]
+	r.buf[r.head] = queued{}
+	r.head = (r.head + 1) % len(r.buf)
+	r.n--
+
+	return q
+}
+
+type inflight struct {
+	queued
+	deadline time.Time
+}
+
+type memoryGroup struct {
+	ackTimeout time.Duration
+	maxIdle    int
+
+	mu          sync.Mutex
+	queue       ring
+	inflight    map[uint64]*inflight
+	subscribers int
+	dropped     int
+	closed      bool
+
+	// closed and replaced on every change, wakes up the waiting subscriptions
+	changed chan struct{}
+}
+
+func newMemoryGroup(ackTimeout time.Duration, maxIdle int) *memoryGroup {
+	return &memoryGroup{
+		ackTimeout: ackTimeout,
+		maxIdle:    maxIdle,
+		inflight:   make(map[uint64]*inflight),
+		changed:    make(chan struct{}),
+	}
+}
+
+// notify must be called with the lock held
+func (g *memoryGroup) notify() {
+	close(g.changed)
+	g.changed = make(chan struct{})
+}
+
+func (g *memoryGroup) push(seq uint64, msg *Message) {
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.queue.push(queued{seq: seq, msg: msg})
+
+	// nobody consumes the group, the oldest messages go
+	for g.subscribers == 0 && g.queue.len() > g.maxIdle {
+		g.queue.pop()
+		g.dropped++
+	}
+
+	g.notify()
+}
+
+func (g *memoryGroup) subscribe() {
+	g.mu.Lock()
+	g.subscribers++
+	g.mu.Unlock()
+}
+
+func (g *memoryGroup) unsubscribe() {
+	g.mu.Lock()
+	g.subscribers--
+	g.mu.Unlock()
+}
+
+func (g *memoryGroup) close() {
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.closed = true
+	g.queue = ring{}
+	g.inflight = make(map[uint64]*inflight)
+	g.notify()
+}
+
+// next returns the next message and its sequence number, or the channel to wait on and the time of the next ack timeout
+func (g *memoryGroup) next(now time.Time) (*Message, uint64, <-chan struct{}, time.Time, error) {
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.closed {
+		return nil, 0, nil, time.Time{}, ErrClosed
+	}
+
+	var wake time.Time
+	for seq, f := range g.inflight {
+		if !f.deadline.After(now) {
+			delete(g.inflight, seq)
+			g.queue.push(f.queued)
+		} else if wake.IsZero() || f.deadline.Before(wake) {
+			wake = f.deadline
+		}
+	}
+
+	if g.queue.len() == 0 {
+		return nil, 0, g.changed, wake, nil
+	}
+
+	q := g.queue.pop()
+
+	q.msg.Deliveries++
+	g.inflight[q.seq] = &inflight{queued: q, deadline: now.Add(g.ackTimeout)}
+
+	// the consumer gets a copy, the group keeps counting the deliveries of its own
+	delivered := *q.msg
+	return &delivered, q.seq, nil, time.Time{}, nil
+}
+
+// settle acks or nacks a delivered message, false when this delivery is not in flight anymore
+func (g *memoryGroup) settle(seq uint64, msg *Message, ack bool) bool {
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	f, ok := g.inflight[seq]
+	if !ok || f.msg.Deliveries != msg.Deliveries {
+		return false
+	}
+
+	delete(g.inflight, seq)
+
+	if !ack {
+		g.queue.push(f.queued)
+		g.notify()
+	}
+
+	return true
+}
+
+type memorySubscription struct {
+	group *memoryGroup
+
+	once sync.Once
+	done chan struct{}
+}
+
+// Receive implements Subscription
+func (s *memorySubscription) Receive(ctx context.Context) (Delivery, error) {
+
+	for {
+
+		msg, seq, changed, wake, err := s.group.next(time.Now())
+		if err != nil {
+			return nil, err
+		}
+		if msg != nil {
+			return &memoryDelivery{group: s.group, seq: seq, msg: msg}, nil
+		}
+
+		var timer *time.Timer
+		var timeout <-chan time.Time
+		if !wake.IsZero() {
+			timer = time.NewTimer(time.Until(wake))
+			timeout = timer.C
+		}
+
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+		case <-s.done:
+			err = ErrClosed
+		case <-changed:
+		case <-timeout:
+		}
+
+		if timer != nil {
+			timer.Stop()
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Close implements Subscription, the messages in flight are redelivered after their ack timeout
+func (s *memorySubscription) Close() error {
+	s.once.Do(func() {
+		close(s.done)
+		s.group.unsubscribe()
+	})
+	return nil
+}
+
+type memoryDelivery struct {
+	group *memoryGroup
+	seq   uint64
+	msg   *Message
+}
+
+func (d *memoryDelivery) Message() *Message {
+	return d.msg
+}
+
+func (d *memoryDelivery) Ack() error {
+	if !d.group.settle(d.seq, d.msg, true) {
+		return errors.Errorf("message %s: ack timeout expired", d.msg.ID)
+	}
+	return nil
+}
+
+func (d *memoryDelivery) Nack() error {
+	if !d.group.settle(d.seq, d.msg, false) {
+		return errors.Errorf("message %s: ack timeout expired", d.msg.ID)
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package messaging_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alloykh/tracer-demo/tracing/messaging"
+)
+
+func receive(t *testing.T, sub messaging.Subscription) messaging.Delivery {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	d, err := sub.Receive(ctx)
+	if err != nil {
+		t.Fatalf("receive: %v", err)
+	}
+
+	return d
+}
+
+func subscribe(t *testing.T, m *messaging.Memory, topic, group string) messaging.Subscription {
+	t.Helper()
+
+	sub, err := m.Subscribe(topic, group)
+	if err != nil {
+		t.Fatalf("subscribe %s: %v", group, err)
+	}
+
+	return sub
+}
+
+func publish(t *testing.T, m *messaging.Memory, topic string, payloads ...string) {
+	t.Helper()
+
+	for _, p := range payloads {
+		if err := m.Publish(context.Background(), &messaging.Message{Topic: topic, Payload: []byte(p)}); err != nil {
+			t.Fatalf("publish %s: %v", p, err)
+		}
+	}
+}
+
+func TestMemoryDeliversToEveryGroup(t *testing.T) {
+
+	m := messaging.NewMemory()
+	defer m.Close()
+
+	billing := subscribe(t, m, "orders", "billing")
+	shipping := subscribe(t, m, "orders", "shipping")
+
+	publish(t, m, "orders", "a", "b")
+
+	for _, sub := range []messaging.Subscription{billing, shipping} {
+		for _, want := range []string{"a", "b"} {
+			d := receive(t, sub)
+			if got := string(d.Message().Payload); got != want {
+				t.Errorf("payload %q, want %q", got, want)
+			}
+			if err := d.Ack(); err != nil {
+				t.Errorf("ack: %v", err)
+			}
+		}
+	}
+
+	for _, group := range []string{"billing", "shipping"} {
+		if n := m.Pending("orders", group); n != 0 {
+			t.Errorf("%s: %d pending messages, want 0", group, n)
+		}
+	}
+
+	// a group created after the publication does not get the message
+	late := subscribe(t, m, "orders", "audit")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	if d, err := late.Receive(ctx); err == nil {
+		t.Errorf("late group received %q", d.Message().Payload)
+	}
+}
+
+func TestMemoryRedelivery(t *testing.T) {
+
+	m := messaging.NewMemory(messaging.WithAckTimeout(20 * time.Millisecond))
+	defer m.Close()
+
+	sub := subscribe(t, m, "orders", "billing")
+	publish(t, m, "orders", "a")
+
+	// nacked, redelivered at once
+	first := receive(t, sub)
+	if err := first.Nack(); err != nil {
+		t.Fatalf("nack: %v", err)
+	}
+
+	second := receive(t, sub)
+	if second.Message().ID != first.Message().ID {
+		t.Fatalf("redelivered %s, want %s", second.Message().ID, first.Message().ID)
+	}
+	if n := second.Message().Redeliveries(); n != 1 {
+		t.Errorf("%d redeliveries, want 1", n)
+	}
+
+	// not acked, redelivered after the ack timeout
+	start := time.Now()
+	third := receive(t, sub)
+	if waited := time.Since(start); waited < 15*time.Millisecond {
+		t.Errorf("redelivered after %s, before the ack timeout", waited)
+	}
+	if n := third.Message().Redeliveries(); n != 2 {
+		t.Errorf("%d redeliveries, want 2", n)
+	}
+
+	// the stale delivery cannot settle the message anymore
+	if err := second.Ack(); err == nil {
+		t.Error("ack of a redelivered message succeeded")
+	}
+	if err := third.Ack(); err != nil {
+		t.Errorf("ack: %v", err)
+	}
+	if n := m.Pending("orders", "billing"); n != 0 {
+		t.Errorf("%d pending messages, want 0", n)
+	}
+}
+
+func TestMemoryDropsIdleMessages(t *testing.T) {
+
+	m := messaging.NewMemory(messaging.WithMaxIdleMessages(2))
+	defer m.Close()
+
+	if err := subscribe(t, m, "orders", "billing").Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	publish(t, m, "orders", "a", "b", "c", "d", "e")
+
+	if n := m.Dropped("orders", "billing"); n != 3 {
+		t.Errorf("%d dropped messages, want 3", n)
+	}
+	if n := m.Pending("orders", "billing"); n != 2 {
+		t.Errorf("%d pending messages, want 2", n)
+	}
+
+	sub := subscribe(t, m, "orders", "billing")
+
+	var got []string
+	for i := 0; i < 2; i++ {
+		d := receive(t, sub)
+		got = append(got, string(d.Message().Payload))
+		_ = d.Ack()
+	}
+
+	if fmt.Sprint(got) != "[d e]" {
+		t.Errorf("received %v, want [d e]", got)
+	}
+}
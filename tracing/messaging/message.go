@@ -0,0 +1,88 @@
+package messaging
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ErrClosed - the broker or the subscription was closed
+var ErrClosed = errors.New("messaging: closed")
+
+// Message is what goes through a broker. The trace context travels in Headers.
+type Message struct {
+	ID      string
+	Topic   string
+	Headers Headers
+	Payload []byte
+
+	// Deliveries is the number of times the message was handed to the consumer group, 1 on the first delivery
+	Deliveries int
+}
+
+// Redeliveries returns the number of deliveries before this one
+func (m *Message) Redeliveries() int {
+	if m.Deliveries <= 1 {
+		return 0
+	}
+	return m.Deliveries - 1
+}
+
+// Headers carries the trace context of a message, it is both an opentracing.TextMapWriter and an opentracing.TextMapReader.
+// Keys are lower-cased: brokers don't agree on the case of header names.
+type Headers map[string]string
+
+// Set implements opentracing.TextMapWriter
+func (h Headers) Set(key, val string) {
+	h[strings.ToLower(key)] = val
+}
+
+// ForeachKey implements opentracing.TextMapReader
+func (h Headers) ForeachKey(handler func(key, val string) error) error {
+	for k, v := range h {
+		if err := handler(k, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Get returns the value of key, whatever its case
+func (h Headers) Get(key string) string {
+	return h[strings.ToLower(key)]
+}
+
+func (h Headers) clone() Headers {
+	c := make(Headers, len(h))
+	for k, v := range h {
+		c[k] = v
+	}
+	return c
+}
+
+// Publisher sends messages to a topic. Publish sets the ID of the message when it is empty.
+type Publisher interface {
+	Publish(ctx context.Context, msg *Message) error
+}
+
+// Subscriber joins the consumer group of a topic: every group gets every message of the topic,
+// a message goes to a single subscription of the group.
+type Subscriber interface {
+	Subscribe(topic, group string) (Subscription, error)
+}
+
+// Subscription hands out the messages of a consumer group
+type Subscription interface {
+	// Receive waits for the next message until ctx is done
+	Receive(ctx context.Context) (Delivery, error)
+	Close() error
+}
+
+// Delivery is a received message waiting for its outcome: Ack removes it from the group,
+// Nack, or no Ack in time, gives it back to the group for redelivery.
+type Delivery interface {
+	Message() *Message
+	Ack() error
+	Nack() error
+}
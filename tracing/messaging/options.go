@@ -0,0 +1,67 @@
+package messaging
+
+import (
+	"github.com/alloykh/tracer-demo/log"
+	"github.com/opentracing/opentracing-go"
+)
+
+const componentName = "messaging"
+
+type options struct {
+	tracer opentracing.Tracer
+	logr   *log.Factory
+	system string
+
+	// consumer only
+	maxRedeliveries int
+}
+
+// Option controls the spans and logs of a Producer or a Consumer.
+type Option func(*options)
+
+// WithTracer sets the tracer of the spans, the global tracer by default
+func WithTracer(tracer opentracing.Tracer) Option {
+	return func(o *options) {
+		o.tracer = tracer
+	}
+}
+
+// WithLogger logs sent and received messages and failures with the trace of the message
+func WithLogger(logr *log.Factory) Option {
+	return func(o *options) {
+		o.logr = logr
+	}
+}
+
+// WithSystem sets the messaging.system tag of the spans, the kind of broker, e.g. kafka
+func WithSystem(system string) Option {
+	return func(o *options) {
+		o.system = system
+	}
+}
+
+// WithMaxRedeliveries drops the messages redelivered more than n times instead of handling them again,
+// 0, the default, never drops a message
+func WithMaxRedeliveries(n int) Option {
+	return func(o *options) {
+		o.maxRedeliveries = n
+	}
+}
+
+func newOptions(opts []Option) *options {
+
+	o := &options{}
+
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return o
+}
+
+func (o *options) getTracer() opentracing.Tracer {
+	if o.tracer != nil {
+		return o.tracer
+	}
+	return opentracing.GlobalTracer()
+}
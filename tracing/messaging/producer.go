@@ -0,0 +1,71 @@
+package messaging
+
+import (
+	"context"
+
+	"github.com/alloykh/tracer-demo/tracing/semconv"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"go.uber.org/zap"
+)
+
+// Producer publishes messages with a producer span, child of the span of the publishing context,
+// whose context is injected in the message headers.
+type Producer struct {
+	pub  Publisher
+	opts *options
+}
+
+// NewProducer wraps pub
+func NewProducer(pub Publisher, opts ...Option) *Producer {
+	return &Producer{pub: pub, opts: newOptions(opts)}
+}
+
+// Publish sends payload to topic, headers are optional
+func (p *Producer) Publish(ctx context.Context, topic string, payload []byte, headers Headers) (*Message, error) {
+
+	msg := &Message{
+		Topic:   topic,
+		Headers: Headers{},
+		Payload: payload,
+	}
+	for k, v := range headers {
+		msg.Headers.Set(k, v)
+	}
+
+	var refs []opentracing.StartSpanOption
+	if parent := opentracing.SpanFromContext(ctx); parent != nil {
+		refs = append(refs, opentracing.ChildOf(parent.Context()))
+	}
+
+	tracer := p.opts.getTracer()
+
+	span := tracer.StartSpan("send "+topic, append(refs, ext.SpanKindProducer)...)
+	defer span.Finish()
+
+	semconv.Component(span, componentName)
+	semconv.Messaging(span, ext.SpanKindProducerEnum, p.opts.system, topic)
+	semconv.MessagePayloadSize(span, len(payload))
+
+	ctx = opentracing.ContextWithSpan(ctx, span)
+
+	if err := tracer.Inject(span.Context(), opentracing.TextMap, msg.Headers); err != nil && p.opts.logr != nil {
+		p.opts.logr.For(ctx).Error("inject trace context", zap.String("topic", topic), zap.String("err", err.Error()))
+	}
+
+	if err := p.pub.Publish(ctx, msg); err != nil {
+		semconv.Exception(span, err)
+		if p.opts.logr != nil {
+			p.opts.logr.For(ctx).Error("message not published", zap.String("topic", topic), zap.String("err", err.Error()))
+		}
+		return nil, err
+	}
+
+	semconv.MessageID(span, msg.ID)
+
+	if p.opts.logr != nil {
+		p.opts.logr.For(ctx).Debug("message published", zap.String("topic", topic), zap.String("id", msg.ID), zap.Int("size", len(payload)))
+	}
+
+	return msg, nil
+}
@@ -0,0 +1,255 @@
+package model
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// chromeEvent is an entry of the Chrome trace-event format, loadable by Perfetto and chrome://tracing.
+// Spans are complete events ("X"), processes and lanes are named with metadata events ("M").
+type chromeEvent struct {
+	Name string                 `json:"name"`
+	Cat  string                 `json:"cat,omitempty"`
+	Ph   string                 `json:"ph"`
+	Ts   int64                  `json:"ts"`
+	Dur  int64                  `json:"dur,omitempty"`
+	Pid  int                    `json:"pid"`
+	Tid  int                    `json:"tid"`
+	Args map[string]interface{} `json:"args,omitempty"`
+}
+
+type chromeTrace struct {
+	TraceEvents     []chromeEvent `json:"traceEvents"`
+	DisplayTimeUnit string        `json:"displayTimeUnit"`
+}
+
+// args keys carrying the span identity, they make the conversion reversible
+const (
+	chromeTraceID   = "traceID"
+	chromeSpanID    = "spanID"
+	chromeParentID  = "parentSpanID"
+	chromeService   = "service"
+	chromeLogPrefix = "log."
+)
+
+// WriteChrome encodes traces in the Chrome trace-event format.
+// Each service is a process, spans are spread over lanes (threads) so that
+// spans sharing a lane are properly nested, as the format requires.
+func WriteChrome(w io.Writer, traces []*Trace) error {
+
+	out := chromeTrace{DisplayTimeUnit: "ms", TraceEvents: make([]chromeEvent, 0)}
+
+	pids := make(map[string]int)
+	lanes := make(map[int][][]int64) // pid -> lanes -> stack of open span ends
+
+	spans := Flatten(traces)
+	sort.SliceStable(spans, func(i, j int) bool {
+		if spans[i].StartTime == spans[j].StartTime {
+			return spans[i].Duration > spans[j].Duration
+		}
+		return spans[i].StartTime < spans[j].StartTime
+	})
+
+	for _, s := range spans {
+
+		service := s.ServiceName()
+		pid, ok := pids[service]
+		if !ok {
+			pid = len(pids) + 1
+			pids[service] = pid
+			out.TraceEvents = append(out.TraceEvents, chromeEvent{
+				Name: "process_name", Ph: "M", Pid: pid,
+				Args: map[string]interface{}{"name": service},
+			})
+		}
+
+		tid := assignLane(lanes, pid, s)
+
+		args := map[string]interface{}{
+			chromeTraceID: s.TraceID,
+			chromeSpanID:  s.SpanID,
+			chromeService: service,
+		}
+		if parent := s.ParentSpanID(); parent != "" {
+			args[chromeParentID] = parent
+		}
+		for _, kv := range s.Tags {
+			args[kv.Key] = kv.Value
+		}
+
+		out.TraceEvents = append(out.TraceEvents, chromeEvent{
+			Name: s.OperationName,
+			Cat:  service,
+			Ph:   "X",
+			Ts:   s.StartTime,
+			Dur:  s.Duration,
+			Pid:  pid,
+			Tid:  tid,
+			Args: args,
+		})
+
+		// logs become instant events on the same lane
+		for _, l := range s.Logs {
+			fields := make(map[string]interface{}, len(l.Fields))
+			for _, kv := range l.Fields {
+				fields[kv.Key] = kv.Value
+			}
+			out.TraceEvents = append(out.TraceEvents, chromeEvent{
+				Name: logName(l),
+				Cat:  chromeLogPrefix + s.SpanID,
+				Ph:   "i",
+				Ts:   l.Timestamp,
+				Pid:  pid,
+				Tid:  tid,
+				Args: fields,
+			})
+		}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", " ")
+
+	return errors.Wrap(enc.Encode(out), "chrome trace encode")
+}
+
+func assignLane(lanes map[int][][]int64, pid int, s *Span) int {
+
+	end := s.StartTime + s.Duration
+
+	for i, stack := range lanes[pid] {
+		// close the spans finished before this one starts
+		for len(stack) > 0 && stack[len(stack)-1] <= s.StartTime {
+			stack = stack[:len(stack)-1]
+		}
+		if len(stack) == 0 || end <= stack[len(stack)-1] {
+			lanes[pid][i] = append(stack, end)
+			return i + 1
+		}
+		lanes[pid][i] = stack
+	}
+
+	lanes[pid] = append(lanes[pid], []int64{end})
+
+	return len(lanes[pid])
+}
+
+func logName(l Log) string {
+	for _, kv := range l.Fields {
+		if kv.Key == "event" || kv.Key == "message" {
+			return kv.String()
+		}
+	}
+	return "log"
+}
+
+// ReadChrome decodes spans from a Chrome trace-event file written by WriteChrome.
+// Events without span identity get generated ids and are grouped in a single trace.
+func ReadChrome(r io.Reader) ([]*Span, error) {
+
+	var in chromeTrace
+	if err := json.NewDecoder(r).Decode(&in); err != nil {
+		return nil, errors.Wrap(err, "chrome trace decode")
+	}
+
+	processes := make(map[int]*Process)
+	for _, e := range in.TraceEvents {
+		if e.Ph == "M" && e.Name == "process_name" {
+			name, _ := e.Args["name"].(string)
+			processes[e.Pid] = &Process{ServiceName: name}
+		}
+	}
+
+	spans := make([]*Span, 0)
+	byID := make(map[string]*Span)
+
+	for i, e := range in.TraceEvents {
+		if e.Ph != "X" {
+			continue
+		}
+
+		process := processes[e.Pid]
+		if process == nil {
+			process = &Process{ServiceName: e.Cat}
+			processes[e.Pid] = process
+		}
+
+		s := &Span{
+			TraceID:       stringArg(e.Args, chromeTraceID, "0000000000000001"),
+			SpanID:        stringArg(e.Args, chromeSpanID, spanIDString(int64(i+1))),
+			OperationName: e.Name,
+			StartTime:     e.Ts,
+			Duration:      e.Dur,
+			Process:       process,
+			References:    []Reference{},
+			Logs:          []Log{},
+			Tags:          []KeyValue{},
+		}
+
+		if parent := stringArg(e.Args, chromeParentID, ""); parent != "" {
+			s.References = append(s.References, Reference{RefType: ChildOf, TraceID: s.TraceID, SpanID: parent})
+		}
+
+		for _, k := range sortedArgs(e.Args) {
+			switch k {
+			case chromeTraceID, chromeSpanID, chromeParentID, chromeService:
+				continue
+			}
+			s.Tags = append(s.Tags, newKeyValue(k, e.Args[k]))
+		}
+
+		spans = append(spans, s)
+		byID[s.SpanID] = s
+	}
+
+	for _, e := range in.TraceEvents {
+		if e.Ph != "i" || len(e.Cat) <= len(chromeLogPrefix) {
+			continue
+		}
+		s, ok := byID[e.Cat[len(chromeLogPrefix):]]
+		if !ok {
+			continue
+		}
+		l := Log{Timestamp: e.Ts}
+		for _, k := range sortedArgs(e.Args) {
+			l.Fields = append(l.Fields, newKeyValue(k, e.Args[k]))
+		}
+		s.Logs = append(s.Logs, l)
+	}
+
+	return spans, nil
+}
+
+func stringArg(args map[string]interface{}, key, def string) string {
+	if v, ok := args[key].(string); ok && v != "" {
+		return v
+	}
+	return def
+}
+
+func sortedArgs(args map[string]interface{}) []string {
+	keys := make([]string, 0, len(args))
+	for k := range args {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func newKeyValue(key string, value interface{}) KeyValue {
+	kv := KeyValue{Key: key, Value: value}
+	switch v := value.(type) {
+	case bool:
+		kv.Type = BoolType
+	case float64:
+		kv.Type = Float64Type
+		if v == float64(int64(v)) {
+			kv.Type = Int64Type
+		}
+	default:
+		kv.Type = StringType
+	}
+	return kv
+}
@@ -0,0 +1,163 @@
+package model
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// maxLineSize bounds a single JSON-lines record
+const maxLineSize = 8 << 20
+
+// response is the envelope of the jaeger query API (/api/traces, /api/traces/{id})
+type response struct {
+	Data   interface{} `json:"data"`
+	Total  int         `json:"total"`
+	Limit  int         `json:"limit"`
+	Offset int         `json:"offset"`
+	Errors []struct {
+		Code int    `json:"code"`
+		Msg  string `json:"msg"`
+	} `json:"errors"`
+}
+
+// ReadJaegerJSON decodes traces from the jaeger query API envelope ({"data": [...]}),
+// a bare array of traces or a single trace object.
+func ReadJaegerJSON(r io.Reader) ([]*Trace, error) {
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "jaeger json read")
+	}
+
+	data = bytes.TrimSpace(data)
+
+	var traces []*Trace
+
+	switch {
+	case bytes.HasPrefix(data, []byte("[")):
+		err = json.Unmarshal(data, &traces)
+	default:
+		var probe struct {
+			Data   json.RawMessage `json:"data"`
+			Errors []struct {
+				Msg string `json:"msg"`
+			} `json:"errors"`
+		}
+		if err = json.Unmarshal(data, &probe); err != nil {
+			break
+		}
+		if len(probe.Errors) > 0 {
+			return nil, errors.New(probe.Errors[0].Msg)
+		}
+		if probe.Data == nil {
+			t := &Trace{}
+			err = json.Unmarshal(data, t)
+			traces = []*Trace{t}
+			break
+		}
+		if bytes.HasPrefix(bytes.TrimSpace(probe.Data), []byte("{")) {
+			t := &Trace{}
+			err = json.Unmarshal(probe.Data, t)
+			traces = []*Trace{t}
+			break
+		}
+		err = json.Unmarshal(probe.Data, &traces)
+	}
+
+	if err != nil {
+		return nil, errors.Wrap(err, "jaeger json decode")
+	}
+
+	for _, t := range traces {
+		t.Normalize()
+	}
+
+	return traces, nil
+}
+
+// WriteJaegerJSON encodes traces in the jaeger query API envelope, the file can be loaded by the jaeger UI
+func WriteJaegerJSON(w io.Writer, traces []*Trace) error {
+
+	out := make([]*Trace, 0, len(traces))
+	for _, t := range traces {
+		out = append(out, forJaegerJSON(t))
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(response{Data: out, Total: len(out)})
+}
+
+// WriteJaegerTrace encodes a single trace in the envelope of /api/traces/{id}
+func WriteJaegerTrace(w io.Writer, trace *Trace) error {
+	return json.NewEncoder(w).Encode(response{Data: []*Trace{forJaegerJSON(trace)}, Total: 1})
+}
+
+// forJaegerJSON copies the trace without processes embedded in the spans
+func forJaegerJSON(t *Trace) *Trace {
+
+	t.Normalize()
+
+	out := &Trace{
+		TraceID:   t.TraceID,
+		Spans:     make([]*Span, 0, len(t.Spans)),
+		Processes: t.Processes,
+		Warnings:  t.Warnings,
+	}
+
+	for _, s := range t.Spans {
+		c := *s
+		c.Process = nil
+		out.Spans = append(out.Spans, &c)
+	}
+
+	return out
+}
+
+// ReadJSONLines decodes spans written one per line with their process embedded
+func ReadJSONLines(r io.Reader) ([]*Span, error) {
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64<<10), maxLineSize)
+
+	spans := make([]*Span, 0)
+
+	for line := 1; scanner.Scan(); line++ {
+		data := bytes.TrimSpace(scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+		span := &Span{}
+		if err := json.Unmarshal(data, span); err != nil {
+			return nil, errors.Wrapf(err, "json lines decode, line %d", line)
+		}
+		spans = append(spans, span)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrap(err, "json lines read")
+	}
+
+	return spans, nil
+}
+
+// WriteJSONLines encodes spans one per line with their process embedded
+func WriteJSONLines(w io.Writer, spans []*Span) error {
+
+	enc := json.NewEncoder(w)
+
+	for _, s := range spans {
+		c := *s
+		c.ProcessID = ""
+		if err := enc.Encode(&c); err != nil {
+			return errors.Wrap(err, "json lines encode")
+		}
+	}
+
+	return nil
+}
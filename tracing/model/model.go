@@ -0,0 +1,358 @@
+package model
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// reference types, same values as in the jaeger JSON format
+const (
+	ChildOf     = "CHILD_OF"
+	FollowsFrom = "FOLLOWS_FROM"
+)
+
+// tag value types, same values as in the jaeger JSON format
+const (
+	StringType  = "string"
+	BoolType    = "bool"
+	Int64Type   = "int64"
+	Float64Type = "float64"
+	BinaryType  = "binary"
+)
+
+// Trace is a set of spans sharing the trace id.
+// The JSON layout is the one returned by the jaeger query API and the "Download JSON" button of the UI.
+type Trace struct {
+	TraceID   string              `json:"traceID"`
+	Spans     []*Span             `json:"spans"`
+	Processes map[string]*Process `json:"processes"`
+	Warnings  []string            `json:"warnings"`
+}
+
+// Span is a finished span, times are in microseconds as in the jaeger JSON format.
+type Span struct {
+	TraceID       string      `json:"traceID"`
+	SpanID        string      `json:"spanID"`
+	Flags         int32       `json:"flags,omitempty"`
+	OperationName string      `json:"operationName"`
+	References    []Reference `json:"references"`
+	StartTime     int64       `json:"startTime"`
+	Duration      int64       `json:"duration"`
+	Tags          []KeyValue  `json:"tags"`
+	Logs          []Log       `json:"logs"`
+	ProcessID     string      `json:"processID,omitempty"`
+	Warnings      []string    `json:"warnings"`
+
+	// Process is embedded in the JSON-lines format only, in traces it is resolved from Trace.Processes
+	Process *Process `json:"process,omitempty"`
+}
+
+// Reference links a span to another span.
+type Reference struct {
+	RefType string `json:"refType"`
+	TraceID string `json:"traceID"`
+	SpanID  string `json:"spanID"`
+}
+
+// KeyValue is a typed tag or log field.
+type KeyValue struct {
+	Key   string      `json:"key"`
+	Type  string      `json:"type"`
+	Value interface{} `json:"value"`
+}
+
+// Log is a timestamped set of fields recorded on a span.
+type Log struct {
+	Timestamp int64      `json:"timestamp"`
+	Fields    []KeyValue `json:"fields"`
+}
+
+// Process describes the service which emitted a span.
+type Process struct {
+	ServiceName string     `json:"serviceName"`
+	Tags        []KeyValue `json:"tags"`
+}
+
+// String returns the value formatted for humans
+func (kv KeyValue) String() string {
+	switch v := kv.Value.(type) {
+	case string:
+		return v
+	case float64:
+		// JSON decoding turns every number into float64, print integers without a fraction
+		if kv.Type == Int64Type || v == float64(int64(v)) {
+			return fmt.Sprintf("%d", int64(v))
+		}
+		return fmt.Sprintf("%g", v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// Bool reports whether the value is a true boolean (or the string "true")
+func (kv KeyValue) Bool() bool {
+	switch v := kv.Value.(type) {
+	case bool:
+		return v
+	case string:
+		return v == "true"
+	}
+	return false
+}
+
+// ServiceName returns the name of the service which emitted the span
+func (s *Span) ServiceName() string {
+	if s.Process == nil {
+		return ""
+	}
+	return s.Process.ServiceName
+}
+
+// ParentSpanID returns the id of the parent span, empty for root spans.
+// CHILD_OF references win over FOLLOWS_FROM ones.
+func (s *Span) ParentSpanID() string {
+	for _, ref := range s.References {
+		if ref.RefType == ChildOf && ref.TraceID == s.TraceID {
+			return ref.SpanID
+		}
+	}
+	for _, ref := range s.References {
+		if ref.TraceID == s.TraceID {
+			return ref.SpanID
+		}
+	}
+	return ""
+}
+
+// Start returns the start time of the span
+func (s *Span) Start() time.Time {
+	return time.Unix(0, s.StartTime*int64(time.Microsecond))
+}
+
+// End returns the finish time of the span
+func (s *Span) End() time.Time {
+	return time.Unix(0, (s.StartTime+s.Duration)*int64(time.Microsecond))
+}
+
+// Elapsed returns the duration of the span
+func (s *Span) Elapsed() time.Duration {
+	return time.Duration(s.Duration) * time.Microsecond
+}
+
+// Tag returns the first tag with the given key
+func (s *Span) Tag(key string) (KeyValue, bool) {
+	for _, kv := range s.Tags {
+		if kv.Key == key {
+			return kv, true
+		}
+	}
+	return KeyValue{}, false
+}
+
+// HasError reports whether the span is tagged with error=true
+func (s *Span) HasError() bool {
+	kv, ok := s.Tag("error")
+	return ok && kv.Bool()
+}
+
+// Normalize links every span to its process and fills Processes for spans that carry their own process.
+// It is safe to call it several times.
+func (t *Trace) Normalize() {
+
+	if t.Processes == nil {
+		t.Processes = make(map[string]*Process)
+	}
+
+	for _, span := range t.Spans {
+		if t.TraceID == "" {
+			t.TraceID = span.TraceID
+		}
+
+		if span.Process == nil {
+			span.Process = t.Processes[span.ProcessID]
+			continue
+		}
+
+		if known, ok := t.Processes[span.ProcessID]; ok && known == span.Process {
+			continue
+		}
+
+		span.ProcessID = t.processID(span.Process)
+	}
+
+	sort.SliceStable(t.Spans, func(i, j int) bool {
+		return t.Spans[i].StartTime < t.Spans[j].StartTime
+	})
+}
+
+// processID finds the id of an equal process or registers a new one
+func (t *Trace) processID(p *Process) string {
+
+	for id, known := range t.Processes {
+		if known == p || (known.ServiceName == p.ServiceName && sameTags(known.Tags, p.Tags)) {
+			return id
+		}
+	}
+
+	id := fmt.Sprintf("p%d", len(t.Processes)+1)
+	for t.Processes[id] != nil {
+		id += "'"
+	}
+	t.Processes[id] = p
+
+	return id
+}
+
+func sameTags(a, b []KeyValue) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Key != b[i].Key || a[i].String() != b[i].String() {
+			return false
+		}
+	}
+	return true
+}
+
+// Span returns the span with the given id
+func (t *Trace) Span(id string) *Span {
+	for _, s := range t.Spans {
+		if s.SpanID == id {
+			return s
+		}
+	}
+	return nil
+}
+
+// Root returns the earliest span without a parent in the trace.
+// When the real root is missing the earliest span is returned.
+func (t *Trace) Root() *Span {
+
+	if len(t.Spans) == 0 {
+		return nil
+	}
+
+	ids := make(map[string]bool, len(t.Spans))
+	for _, s := range t.Spans {
+		ids[s.SpanID] = true
+	}
+
+	var root *Span
+	for _, s := range t.Spans {
+		if parent := s.ParentSpanID(); parent != "" && ids[parent] {
+			continue
+		}
+		if root == nil || s.StartTime < root.StartTime {
+			root = s
+		}
+	}
+
+	return root
+}
+
+// Children returns the spans of the trace grouped by their parent span id, ordered by start time
+func (t *Trace) Children() map[string][]*Span {
+
+	children := make(map[string][]*Span, len(t.Spans))
+	for _, s := range t.Spans {
+		parent := s.ParentSpanID()
+		children[parent] = append(children[parent], s)
+	}
+
+	for _, list := range children {
+		sort.SliceStable(list, func(i, j int) bool {
+			return list[i].StartTime < list[j].StartTime
+		})
+	}
+
+	return children
+}
+
+// Bounds returns the earliest start and the latest end of the spans in microseconds
+func (t *Trace) Bounds() (start, end int64) {
+	for i, s := range t.Spans {
+		if i == 0 || s.StartTime < start {
+			start = s.StartTime
+		}
+		if i == 0 || s.StartTime+s.Duration > end {
+			end = s.StartTime + s.Duration
+		}
+	}
+	return
+}
+
+// Elapsed returns the time between the first span start and the last span end
+func (t *Trace) Elapsed() time.Duration {
+	start, end := t.Bounds()
+	return time.Duration(end-start) * time.Microsecond
+}
+
+// Services returns the sorted names of the services taking part in the trace
+func (t *Trace) Services() []string {
+
+	seen := make(map[string]bool)
+	for _, s := range t.Spans {
+		seen[s.ServiceName()] = true
+	}
+
+	services := make([]string, 0, len(seen))
+	for name := range seen {
+		services = append(services, name)
+	}
+	sort.Strings(services)
+
+	return services
+}
+
+// HasError reports whether any span of the trace is tagged with error=true
+func (t *Trace) HasError() bool {
+	for _, s := range t.Spans {
+		if s.HasError() {
+			return true
+		}
+	}
+	return false
+}
+
+// GroupTraces builds normalized traces out of spans, ordered by start time
+func GroupTraces(spans []*Span) []*Trace {
+
+	byID := make(map[string]*Trace)
+	traces := make([]*Trace, 0)
+
+	for _, span := range spans {
+		t, ok := byID[span.TraceID]
+		if !ok {
+			t = &Trace{TraceID: span.TraceID}
+			byID[span.TraceID] = t
+			traces = append(traces, t)
+		}
+		t.Spans = append(t.Spans, span)
+	}
+
+	for _, t := range traces {
+		t.Normalize()
+	}
+
+	sort.SliceStable(traces, func(i, j int) bool {
+		si, _ := traces[i].Bounds()
+		sj, _ := traces[j].Bounds()
+		return si < sj
+	})
+
+	return traces
+}
+
+// Flatten returns the spans of the traces with their process embedded
+func Flatten(traces []*Trace) []*Span {
+
+	spans := make([]*Span, 0)
+	for _, t := range traces {
+		t.Normalize()
+		spans = append(spans, t.Spans...)
+	}
+
+	return spans
+}
@@ -0,0 +1,112 @@
+package model
+
+import (
+	"fmt"
+
+	"github.com/uber/jaeger-client-go"
+	j "github.com/uber/jaeger-client-go/thrift-gen/jaeger"
+)
+
+// FromBatch converts a thrift batch, as emitted by jaeger clients to the agent, into spans
+func FromBatch(batch *j.Batch) []*Span {
+
+	process := fromThriftProcess(batch.GetProcess())
+
+	spans := make([]*Span, 0, len(batch.GetSpans()))
+	for _, s := range batch.GetSpans() {
+		spans = append(spans, FromThrift(s, process))
+	}
+
+	return spans
+}
+
+// FromJaegerSpan converts a span finished by the in-process jaeger tracer.
+// Call it from jaeger.Reporter.Report, the span may be reused once Report returns.
+func FromJaegerSpan(span *jaeger.Span) *Span {
+	return FromThrift(jaeger.BuildJaegerThrift(span), fromThriftProcess(jaeger.BuildJaegerProcessThrift(span)))
+}
+
+// FromThrift converts a thrift span emitted by the given process
+func FromThrift(s *j.Span, process *Process) *Span {
+
+	traceID := traceIDString(s.TraceIdHigh, s.TraceIdLow)
+
+	span := &Span{
+		TraceID:       traceID,
+		SpanID:        spanIDString(s.SpanId),
+		Flags:         s.Flags,
+		OperationName: s.OperationName,
+		StartTime:     s.StartTime,
+		Duration:      s.Duration,
+		Tags:          fromThriftTags(s.Tags),
+		Logs:          make([]Log, 0, len(s.Logs)),
+		References:    make([]Reference, 0, len(s.References)+1),
+		Process:       process,
+	}
+
+	for _, l := range s.Logs {
+		span.Logs = append(span.Logs, Log{Timestamp: l.Timestamp, Fields: fromThriftTags(l.Fields)})
+	}
+
+	parentFound := s.ParentSpanId == 0
+	for _, ref := range s.References {
+		r := Reference{
+			RefType: ChildOf,
+			TraceID: traceIDString(ref.TraceIdHigh, ref.TraceIdLow),
+			SpanID:  spanIDString(ref.SpanId),
+		}
+		if ref.RefType == j.SpanRefType_FOLLOWS_FROM {
+			r.RefType = FollowsFrom
+		}
+		if ref.SpanId == s.ParentSpanId {
+			parentFound = true
+		}
+		span.References = append(span.References, r)
+	}
+
+	// older clients only fill parentSpanId
+	if !parentFound {
+		span.References = append([]Reference{{RefType: ChildOf, TraceID: traceID, SpanID: spanIDString(s.ParentSpanId)}}, span.References...)
+	}
+
+	return span
+}
+
+func fromThriftProcess(p *j.Process) *Process {
+	if p == nil {
+		return &Process{}
+	}
+	return &Process{ServiceName: p.ServiceName, Tags: fromThriftTags(p.Tags)}
+}
+
+func fromThriftTags(tags []*j.Tag) []KeyValue {
+
+	kvs := make([]KeyValue, 0, len(tags))
+
+	for _, t := range tags {
+		kv := KeyValue{Key: t.Key}
+		switch t.VType {
+		case j.TagType_STRING:
+			kv.Type, kv.Value = StringType, t.GetVStr()
+		case j.TagType_BOOL:
+			kv.Type, kv.Value = BoolType, t.GetVBool()
+		case j.TagType_LONG:
+			kv.Type, kv.Value = Int64Type, t.GetVLong()
+		case j.TagType_DOUBLE:
+			kv.Type, kv.Value = Float64Type, t.GetVDouble()
+		case j.TagType_BINARY:
+			kv.Type, kv.Value = BinaryType, t.GetVBinary()
+		}
+		kvs = append(kvs, kv)
+	}
+
+	return kvs
+}
+
+func traceIDString(high, low int64) string {
+	return jaeger.TraceID{High: uint64(high), Low: uint64(low)}.String()
+}
+
+func spanIDString(id int64) string {
+	return fmt.Sprintf("%016x", uint64(id))
+}
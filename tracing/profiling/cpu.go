@@ -0,0 +1,43 @@
+package profiling
+
+import (
+	"bytes"
+	"runtime/pprof"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+)
+
+// ErrBusy is returned when a CPU profile is already being captured, the process has a single CPU profiler
+var ErrBusy = errors.New("a CPU profile is already being captured")
+
+// busy is set while a capture of this package runs
+var busy int32
+
+type capture struct {
+	buf bytes.Buffer
+}
+
+// startCapture starts the CPU profiler, ErrBusy if a capture, of this package or another one
+// (e.g. net/http/pprof), is running
+func startCapture() (*capture, error) {
+
+	if !atomic.CompareAndSwapInt32(&busy, 0, 1) {
+		return nil, ErrBusy
+	}
+
+	c := &capture{}
+	if err := pprof.StartCPUProfile(&c.buf); err != nil {
+		atomic.StoreInt32(&busy, 0)
+		return nil, ErrBusy
+	}
+
+	return c, nil
+}
+
+// stop stops the CPU profiler and returns the gzipped profile
+func (c *capture) stop() []byte {
+	pprof.StopCPUProfile()
+	atomic.StoreInt32(&busy, 0)
+	return c.buf.Bytes()
+}
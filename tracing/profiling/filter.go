@@ -0,0 +1,193 @@
+package profiling
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// field numbers of profile.proto, github.com/google/pprof/proto/profile.proto
+const (
+	profileSample      = 2
+	profileStringTable = 6
+	sampleLabel        = 3
+	labelKey           = 1
+	labelStr           = 2
+)
+
+// Filter keeps the samples of a gzipped CPU profile, as written by runtime/pprof, labelled key=value.
+// The rest of the profile is kept as is: go tool pprof shows the locations without samples as unused.
+func Filter(profile []byte, key, value string) ([]byte, error) {
+
+	zr, err := gzip.NewReader(bytes.NewReader(profile))
+	if err != nil {
+		return nil, errors.Wrap(err, "profile")
+	}
+
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, errors.Wrap(err, "profile")
+	}
+
+	strings, err := stringTable(data)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, 0, len(data))
+
+	for len(data) > 0 {
+
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return nil, errors.Wrap(protowire.ParseError(n), "profile")
+		}
+
+		m := protowire.ConsumeFieldValue(num, typ, data[n:])
+		if m < 0 {
+			return nil, errors.Wrap(protowire.ParseError(m), "profile")
+		}
+
+		field := data[:n+m]
+		data = data[n+m:]
+
+		if num == profileSample && typ == protowire.BytesType {
+			sample, _ := protowire.ConsumeBytes(field[n:])
+			match, err := hasLabel(sample, strings, key, value)
+			if err != nil {
+				return nil, err
+			}
+			if !match {
+				continue
+			}
+		}
+
+		out = append(out, field...)
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(out); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// stringTable returns the strings of the profile, labels refer to them by index
+func stringTable(data []byte) ([]string, error) {
+
+	var strings []string
+
+	for len(data) > 0 {
+
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return nil, errors.Wrap(protowire.ParseError(n), "profile")
+		}
+		data = data[n:]
+
+		if num == profileStringTable && typ == protowire.BytesType {
+			s, m := protowire.ConsumeString(data)
+			if m < 0 {
+				return nil, errors.Wrap(protowire.ParseError(m), "profile")
+			}
+			strings = append(strings, s)
+			data = data[m:]
+			continue
+		}
+
+		m := protowire.ConsumeFieldValue(num, typ, data)
+		if m < 0 {
+			return nil, errors.Wrap(protowire.ParseError(m), "profile")
+		}
+		data = data[m:]
+	}
+
+	return strings, nil
+}
+
+// hasLabel reports whether the sample has the label key=value
+func hasLabel(sample []byte, strings []string, key, value string) (bool, error) {
+
+	for len(sample) > 0 {
+
+		num, typ, n := protowire.ConsumeTag(sample)
+		if n < 0 {
+			return false, errors.Wrap(protowire.ParseError(n), "profile sample")
+		}
+		sample = sample[n:]
+
+		if num == sampleLabel && typ == protowire.BytesType {
+			label, m := protowire.ConsumeBytes(sample)
+			if m < 0 {
+				return false, errors.Wrap(protowire.ParseError(m), "profile sample")
+			}
+			sample = sample[m:]
+
+			k, v := labelStrings(label, strings)
+			if k == key && v == value {
+				return true, nil
+			}
+			continue
+		}
+
+		m := protowire.ConsumeFieldValue(num, typ, sample)
+		if m < 0 {
+			return false, errors.Wrap(protowire.ParseError(m), "profile sample")
+		}
+		sample = sample[m:]
+	}
+
+	return false, nil
+}
+
+// labelStrings returns the key and the string value of a label, empty for a numeric label
+func labelStrings(label []byte, strings []string) (key, value string) {
+
+	str := func(i uint64) string {
+		if i < uint64(len(strings)) {
+			return strings[i]
+		}
+		return ""
+	}
+
+	for len(label) > 0 {
+
+		num, typ, n := protowire.ConsumeTag(label)
+		if n < 0 {
+			return "", ""
+		}
+		label = label[n:]
+
+		if typ != protowire.VarintType {
+			m := protowire.ConsumeFieldValue(num, typ, label)
+			if m < 0 {
+				return "", ""
+			}
+			label = label[m:]
+			continue
+		}
+
+		v, m := protowire.ConsumeVarint(label)
+		if m < 0 {
+			return "", ""
+		}
+		label = label[m:]
+
+		switch num {
+		case labelKey:
+			key = str(v)
+		case labelStr:
+			value = str(v)
+		}
+	}
+
+	return key, value
+}
@@ -0,0 +1,102 @@
+package profiling
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Path is the default mount point of the labelled CPU profile
+const Path = "/debug/pprof/labeled"
+
+// SpanPath is the default mount point of the profiles of the slow spans, followed by the profile id
+const SpanPath = "/debug/pprof/spans/"
+
+const (
+	defaultSeconds = 10
+	maxSeconds     = 60
+)
+
+// Handler captures a CPU profile keeping the samples of a label,
+// ?label=key=value (required) and &seconds=n (10 by default, 60 at most), e.g.
+//
+//	go tool pprof 'http://host/debug/pprof/labeled?label=route=/api/v1/order&seconds=30'
+//
+// A capture already running answers 409 Conflict.
+func Handler() http.Handler {
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		label := strings.SplitN(r.URL.Query().Get("label"), "=", 2)
+		if len(label) != 2 || label[0] == "" {
+			http.Error(w, "label=key=value is required", http.StatusBadRequest)
+			return
+		}
+
+		seconds := defaultSeconds
+		if s := r.URL.Query().Get("seconds"); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil || n <= 0 || n > maxSeconds {
+				http.Error(w, fmt.Sprintf("seconds must be between 1 and %d", maxSeconds), http.StatusBadRequest)
+				return
+			}
+			seconds = n
+		}
+
+		c, err := startCapture()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+
+		timer := time.NewTimer(time.Duration(seconds) * time.Second)
+		select {
+		case <-timer.C:
+		case <-r.Context().Done():
+			timer.Stop()
+			c.stop()
+			return
+		}
+
+		profile, err := Filter(c.stop(), label[0], label[1])
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		writeProfile(w, "profile", profile)
+	})
+}
+
+// SpanHandler serves the profiles of the slow spans kept by store, by id: {prefix}{id}
+func SpanHandler(store *Store, prefix string) http.Handler {
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		id := strings.Trim(strings.TrimPrefix(r.URL.Path, prefix), "/")
+
+		profile, ok := store.Get(id)
+		if !ok {
+			http.Error(w, "profile not found, it may have been evicted", http.StatusNotFound)
+			return
+		}
+
+		writeProfile(w, id, profile)
+	})
+}
+
+func writeProfile(w http.ResponseWriter, name string, profile []byte) {
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.pb.gz"`, name))
+	_, _ = w.Write(profile)
+}
+
+// Mount registers the handlers on Path and SpanPath, store may be nil without slow span profiling
+func Mount(mux *http.ServeMux, store *Store) {
+	mux.Handle(Path, Handler())
+	if store != nil {
+		mux.Handle(SpanPath, SpanHandler(store, SpanPath))
+	}
+}
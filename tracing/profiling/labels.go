@@ -0,0 +1,58 @@
+// Package profiling ties CPU profiles to traces: handlers run with pprof labels naming the operation,
+// the route, the service and, for sampled traces, the trace, the profiles can be filtered by label
+// and a short profile can be attached to the slow spans.
+package profiling
+
+import (
+	"context"
+	"runtime/pprof"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/uber/jaeger-client-go"
+)
+
+// pprof label keys
+const (
+	LabelOperation = "operation"
+	LabelRoute     = "route"
+	LabelService   = "service"
+	LabelTraceID   = "trace_id"
+)
+
+// Labels are the pprof labels set around the handling of a request.
+type Labels struct {
+	Service string
+	// TraceIDs labels the samples of sampled traces with their trace id, the profiles get bigger
+	TraceIDs bool
+}
+
+// Do runs fn with the labels of the span on the goroutine, and on the goroutines it starts,
+// ctx is given to fn with the labels
+func (l *Labels) Do(ctx context.Context, span opentracing.Span, operation, route string, fn func(ctx context.Context)) {
+
+	if l == nil {
+		fn(ctx)
+		return
+	}
+
+	labels := []string{LabelOperation, operation}
+	if route != "" {
+		labels = append(labels, LabelRoute, route)
+	}
+	if l.Service != "" {
+		labels = append(labels, LabelService, l.Service)
+	}
+	if id := TraceID(span); id != "" && l.TraceIDs {
+		labels = append(labels, LabelTraceID, id)
+	}
+
+	pprof.Do(ctx, pprof.Labels(labels...), fn)
+}
+
+// TraceID returns the trace id of a sampled span, empty otherwise
+func TraceID(span opentracing.Span) string {
+	if sc, ok := span.Context().(jaeger.SpanContext); ok && sc.IsSampled() {
+		return sc.TraceID().String()
+	}
+	return ""
+}
@@ -0,0 +1,181 @@
+package profiling
+
+import (
+	"sync"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	otlog "github.com/opentracing/opentracing-go/log"
+	"github.com/uber/jaeger-client-go"
+)
+
+// Store keeps the last profiles of the slow spans, by id
+type Store struct {
+	mu       sync.Mutex
+	size     int
+	ids      []string
+	profiles map[string][]byte
+}
+
+// NewStore keeps up to size profiles, the oldest are evicted first
+func NewStore(size int) *Store {
+	if size <= 0 {
+		size = 1
+	}
+	return &Store{size: size, profiles: make(map[string][]byte, size)}
+}
+
+// Add keeps profile under id
+func (s *Store) Add(id string, profile []byte) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.profiles[id]; !ok {
+		if len(s.ids) == s.size {
+			delete(s.profiles, s.ids[0])
+			s.ids = s.ids[1:]
+		}
+		s.ids = append(s.ids, id)
+	}
+
+	s.profiles[id] = profile
+}
+
+// Get returns the profile kept under id
+func (s *Store) Get(id string) ([]byte, bool) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	profile, ok := s.profiles[id]
+
+	return profile, ok
+}
+
+// SlowProfiler captures a short CPU profile of the sampled spans still running after a threshold.
+// The profile keeps the samples labelled with the trace id, see Labels.TraceIDs, it is kept in a Store
+// and the span gets a "profile" event whose profile.ref is the path of the profile under SpanPath.
+// A single profile is captured at a time, the spans slow during a capture get a "profile skipped" event.
+type SlowProfiler struct {
+	threshold time.Duration
+	duration  time.Duration
+	store     *Store
+}
+
+// NewSlowProfiler profiles the spans running longer than threshold for up to duration
+func NewSlowProfiler(threshold, duration time.Duration, store *Store) *SlowProfiler {
+	return &SlowProfiler{threshold: threshold, duration: duration, store: store}
+}
+
+// Store returns the store of the profiles, nil for a nil profiler
+func (p *SlowProfiler) Store() *Store {
+	if p == nil {
+		return nil
+	}
+	return p.store
+}
+
+// Watch starts watching span, call the returned function before finishing it
+func (p *SlowProfiler) Watch(span opentracing.Span) func() {
+
+	if p == nil {
+		return func() {}
+	}
+
+	sc, ok := span.Context().(jaeger.SpanContext)
+	if !ok || !sc.IsSampled() {
+		return func() {}
+	}
+
+	w := &watch{profiler: p}
+	w.timer = time.AfterFunc(p.threshold, w.start)
+
+	return func() {
+		w.stop(span, sc)
+	}
+}
+
+type watch struct {
+	profiler *SlowProfiler
+	timer    *time.Timer
+
+	mu      sync.Mutex
+	stopped bool
+	skipped bool
+	capture *capture
+	started time.Time
+	ended   time.Time
+	profile []byte
+}
+
+// start runs once the threshold is reached
+func (w *watch) start() {
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.stopped {
+		return
+	}
+
+	c, err := startCapture()
+	if err != nil {
+		w.skipped = true
+		return
+	}
+
+	w.capture = c
+	w.started = time.Now()
+
+	time.AfterFunc(w.profiler.duration, w.end)
+}
+
+// end stops the capture when it lasted for the profiler duration
+func (w *watch) end() {
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.capture != nil && w.profile == nil {
+		w.profile = w.capture.stop()
+		w.ended = time.Now()
+	}
+}
+
+func (w *watch) stop(span opentracing.Span, sc jaeger.SpanContext) {
+
+	w.timer.Stop()
+
+	w.mu.Lock()
+	w.stopped = true
+	if w.capture != nil && w.profile == nil {
+		w.profile = w.capture.stop()
+		w.ended = time.Now()
+	}
+	skipped, profile, elapsed := w.skipped, w.profile, w.ended.Sub(w.started)
+	w.mu.Unlock()
+
+	if skipped {
+		span.LogFields(otlog.String("event", "profile skipped"), otlog.String("reason", ErrBusy.Error()))
+		return
+	}
+	if profile == nil {
+		return
+	}
+
+	filtered, err := Filter(profile, LabelTraceID, sc.TraceID().String())
+	if err != nil {
+		span.LogFields(otlog.String("event", "profile skipped"), otlog.String("reason", err.Error()))
+		return
+	}
+
+	id := sc.TraceID().String() + "-" + sc.SpanID().String()
+	w.profiler.store.Add(id, filtered)
+
+	span.LogFields(
+		otlog.String("event", "profile"),
+		otlog.String("profile.ref", SpanPath+id),
+		otlog.Int64("profile.duration_ms", elapsed.Milliseconds()),
+	)
+}
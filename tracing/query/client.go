@@ -0,0 +1,75 @@
+package query
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/alloykh/tracer-demo/tracing/agent"
+	"github.com/alloykh/tracer-demo/tracing/model"
+	"github.com/pkg/errors"
+)
+
+var defaultTimeOut = time.Second * 10
+
+// Client reads traces from a jaeger query API (jaeger-query, all-in-one or the agent stand-in)
+type Client struct {
+	baseURL string
+	client  *http.Client
+}
+
+// NewClient - baseURL is the root of the query service, e.g. http://localhost:16686
+func NewClient(baseURL string) *Client {
+	return &Client{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		client:  &http.Client{Timeout: defaultTimeOut},
+	}
+}
+
+// Trace fetches a trace by id
+func (c *Client) Trace(ctx context.Context, id string) (*model.Trace, error) {
+
+	traces, err := c.get(ctx, "/api/traces/"+url.PathEscape(id))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(traces) == 0 {
+		return nil, fmt.Errorf("trace %s not found", id)
+	}
+
+	return traces[0], nil
+}
+
+// Search fetches the traces matching the query, jaeger requires the service to be set
+func (c *Client) Search(ctx context.Context, q agent.Query) ([]*model.Trace, error) {
+	return c.get(ctx, "/api/traces?"+q.Values().Encode())
+}
+
+func (c *Client) get(ctx context.Context, path string) ([]*model.Trace, error) {
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "query request create")
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "query request")
+	}
+
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode >= 400 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<10))
+		return nil, fmt.Errorf("query %s: %s: %s", path, resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	return model.ReadJaegerJSON(resp.Body)
+}
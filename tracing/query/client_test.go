@@ -0,0 +1,105 @@
+package query_test
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alloykh/tracer-demo/tracing/agent"
+	"github.com/alloykh/tracer-demo/tracing/model"
+	"github.com/alloykh/tracer-demo/tracing/query"
+)
+
+// fixture stores two traces of frontend: a slow one failing in order and a fast one
+func fixture(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	start := time.Now().Add(-time.Minute).UnixNano() / int64(time.Microsecond)
+	frontend := &model.Process{ServiceName: "frontend"}
+	order := &model.Process{ServiceName: "order"}
+
+	spans := []*model.Span{
+		{TraceID: "00000000000000a1", SpanID: "1", OperationName: "HTTP GET /order", StartTime: start, Duration: 300000, Process: frontend},
+		{TraceID: "00000000000000a1", SpanID: "2", OperationName: "Create", StartTime: start + 1000, Duration: 250000, Process: order,
+			References: []model.Reference{{RefType: "CHILD_OF", TraceID: "00000000000000a1", SpanID: "1"}},
+			Tags:       []model.KeyValue{{Key: "error", Type: model.BoolType, Value: true}}},
+		{TraceID: "00000000000000b2", SpanID: "3", OperationName: "HTTP GET /health", StartTime: start, Duration: 2000, Process: frontend},
+	}
+
+	store := agent.NewStore(0)
+	if err := store.Add(spans); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(agent.Handler(store))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func TestClientSearch(t *testing.T) {
+
+	client := query.NewClient(fixture(t).URL + "/")
+
+	tests := []struct {
+		name  string
+		query agent.Query
+		want  []string
+	}{
+		{name: "service", query: agent.Query{Service: "frontend"}, want: []string{"00000000000000b2", "00000000000000a1"}},
+		{name: "operation", query: agent.Query{Service: "frontend", Operation: "HTTP GET /health"}, want: []string{"00000000000000b2"}},
+		{name: "tags", query: agent.Query{Service: "order", Tags: map[string]string{"error": "true"}}, want: []string{"00000000000000a1"}},
+		{name: "min duration", query: agent.Query{Service: "frontend", MinDuration: 100 * time.Millisecond}, want: []string{"00000000000000a1"}},
+		{name: "window", query: agent.Query{Service: "frontend", Start: time.Now()}, want: nil},
+		{name: "limit", query: agent.Query{Service: "frontend", Limit: 1}, want: []string{"00000000000000b2"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			traces, err := client.Search(context.Background(), tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []string
+			for _, trace := range traces {
+				got = append(got, trace.TraceID)
+			}
+
+			if len(got) != len(tt.want) {
+				t.Fatalf("traces %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("traces %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestClientTrace(t *testing.T) {
+
+	client := query.NewClient(fixture(t).URL)
+
+	trace, err := client.Trace(context.Background(), "a1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(trace.Spans) != 2 {
+		t.Fatalf("%d spans, want 2", len(trace.Spans))
+	}
+	if root := trace.Root(); root == nil || root.ServiceName() != "frontend" {
+		t.Errorf("root %v, want the frontend span", root)
+	}
+	if child := trace.Span("2"); child == nil || child.ServiceName() != "order" || !child.HasError() {
+		t.Errorf("span 2 %+v, want the failed order span", child)
+	}
+
+	if _, err := client.Trace(context.Background(), "ff"); err == nil {
+		t.Error("unknown trace found")
+	}
+}
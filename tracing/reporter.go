@@ -0,0 +1,87 @@
+package tracing
+
+import (
+	"sync/atomic"
+
+	"github.com/uber/jaeger-lib/metrics"
+)
+
+// queue size of the jaeger reporter when the ReporterConfig does not set one
+const defaultReporterQueueSize = 100
+
+// ReporterStats follows the queue of the span reporter of a tracer created with WithReporterStats.
+// The queue length is the one of the last flush of the reporter.
+type ReporterStats struct {
+	queueSize   int64
+	queueLength int64
+	dropped     int64
+}
+
+// QueueSize returns the capacity of the queue, spans are dropped once it is full
+func (s *ReporterStats) QueueSize() int {
+	return int(atomic.LoadInt64(&s.queueSize))
+}
+
+// QueueLength returns the spans waiting in the queue
+func (s *ReporterStats) QueueLength() int {
+	return int(atomic.LoadInt64(&s.queueLength))
+}
+
+// Dropped returns the spans dropped because the queue was full
+func (s *ReporterStats) Dropped() int64 {
+	return atomic.LoadInt64(&s.dropped)
+}
+
+// WithReporterStats returns a JaegerOption that keeps the queue length and the dropped spans
+// of the reporter in stats, e.g. for a health check.
+func WithReporterStats(stats *ReporterStats) JaegerOption {
+	return func(options *jaegerOptions) {
+		options.stats = stats
+	}
+}
+
+// statsFactory passes the metrics of the jaeger reporter to stats on their way to the metrics factory
+type statsFactory struct {
+	metrics.Factory
+	stats *ReporterStats
+}
+
+func (f statsFactory) Namespace(scope metrics.NSOptions) metrics.Factory {
+	return statsFactory{Factory: f.Factory.Namespace(scope), stats: f.stats}
+}
+
+func (f statsFactory) Gauge(options metrics.Options) metrics.Gauge {
+	g := f.Factory.Gauge(options)
+	if options.Name == "reporter_queue_length" {
+		return statsGauge{Gauge: g, value: &f.stats.queueLength}
+	}
+	return g
+}
+
+func (f statsFactory) Counter(options metrics.Options) metrics.Counter {
+	c := f.Factory.Counter(options)
+	if options.Name == "reporter_spans" && options.Tags["result"] == "dropped" {
+		return statsCounter{Counter: c, value: &f.stats.dropped}
+	}
+	return c
+}
+
+type statsGauge struct {
+	metrics.Gauge
+	value *int64
+}
+
+func (g statsGauge) Update(value int64) {
+	atomic.StoreInt64(g.value, value)
+	g.Gauge.Update(value)
+}
+
+type statsCounter struct {
+	metrics.Counter
+	value *int64
+}
+
+func (c statsCounter) Inc(delta int64) {
+	atomic.AddInt64(c.value, delta)
+	c.Counter.Inc(delta)
+}
@@ -0,0 +1,172 @@
+package tracing
+
+import (
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/uber/jaeger-client-go"
+)
+
+// Sampler is a sampler whose strategy can change while the tracer runs: the configured one follows the config
+// reloads, an override set by hand, e.g. from the admin server, takes precedence until cleared
+type Sampler struct {
+	mu         sync.RWMutex
+	configured SamplingStrategy
+	override   *SamplingStrategy
+	sampler    jaeger.Sampler
+}
+
+// SamplingStrategy - const (param 1 samples every trace, 0 none), probabilistic (param is the probability)
+// or ratelimiting (param is the traces per second)
+type SamplingStrategy struct {
+	Type  string  `json:"type"`
+	Param float64 `json:"param"`
+}
+
+// NewSampler - the configured strategy is typ with param
+func NewSampler(typ string, param float64) (*Sampler, error) {
+	s := &Sampler{}
+	if err := s.Update(typ, param); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// ValidateSampler checks a strategy without creating the sampler
+func ValidateSampler(typ string, param float64) error {
+	switch typ {
+	case jaeger.SamplerTypeConst:
+		if param != 0 && param != 1 {
+			return errors.Errorf("const sampler param must be 0 or 1, got %v", param)
+		}
+	case jaeger.SamplerTypeProbabilistic:
+		if param < 0 || param > 1 {
+			return errors.Errorf("probabilistic sampler param must be in [0, 1], got %v", param)
+		}
+	case jaeger.SamplerTypeRateLimiting:
+		if param < 0 {
+			return errors.Errorf("ratelimiting sampler param must be positive, got %v", param)
+		}
+	default:
+		return errors.Errorf("unknown sampler type %q, want const, probabilistic or ratelimiting", typ)
+	}
+	return nil
+}
+
+// Update replaces the configured strategy, used unless overridden. The traces already started keep their decision.
+func (s *Sampler) Update(typ string, param float64) error {
+
+	sampler, err := newJaegerSampler(typ, param)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.configured = SamplingStrategy{Type: typ, Param: param}
+	if s.override != nil {
+		s.mu.Unlock()
+		sampler.Close()
+		return nil
+	}
+	old := s.sampler
+	s.sampler = sampler
+	s.mu.Unlock()
+
+	if old != nil {
+		old.Close()
+	}
+
+	return nil
+}
+
+// Override samples with typ and param in place of the configured strategy
+func (s *Sampler) Override(typ string, param float64) error {
+
+	sampler, err := newJaegerSampler(typ, param)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.override = &SamplingStrategy{Type: typ, Param: param}
+	old := s.sampler
+	s.sampler = sampler
+	s.mu.Unlock()
+
+	old.Close()
+
+	return nil
+}
+
+// ClearOverride goes back to the configured strategy
+func (s *Sampler) ClearOverride() {
+
+	s.mu.Lock()
+	// the configured strategy was valid
+	sampler, _ := newJaegerSampler(s.configured.Type, s.configured.Param)
+	s.override = nil
+	old := s.sampler
+	s.sampler = sampler
+	s.mu.Unlock()
+
+	old.Close()
+}
+
+// Strategy returns the strategy in use
+func (s *Sampler) Strategy() SamplingStrategy {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.override != nil {
+		return *s.override
+	}
+	return s.configured
+}
+
+// Configured returns the configured strategy and whether it is overridden
+func (s *Sampler) Configured() (strategy SamplingStrategy, overridden bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.configured, s.override != nil
+}
+
+func newJaegerSampler(typ string, param float64) (jaeger.Sampler, error) {
+
+	if err := ValidateSampler(typ, param); err != nil {
+		return nil, err
+	}
+
+	switch typ {
+	case jaeger.SamplerTypeProbabilistic:
+		return jaeger.NewProbabilisticSampler(param)
+	case jaeger.SamplerTypeRateLimiting:
+		return jaeger.NewRateLimitingSampler(param), nil
+	default:
+		return jaeger.NewConstSampler(param == 1), nil
+	}
+}
+
+// IsSampled implements jaeger.Sampler
+func (s *Sampler) IsSampled(id jaeger.TraceID, operation string) (bool, []jaeger.Tag) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.sampler.IsSampled(id, operation)
+}
+
+// Close implements jaeger.Sampler
+func (s *Sampler) Close() {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	s.sampler.Close()
+}
+
+// Equal implements jaeger.Sampler
+func (s *Sampler) Equal(other jaeger.Sampler) bool {
+	return s == other
+}
+
+// WithDynamicSampler returns a JaegerOption that samples with s in place of the sampler config, and of a WithSampler given before
+func WithDynamicSampler(s *Sampler) JaegerOption {
+	return func(options *jaegerOptions) {
+		options.dynamicSampler = s
+	}
+}
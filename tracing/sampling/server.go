@@ -0,0 +1,152 @@
+package sampling
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/alloykh/tracer-demo/log"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// Path is where jaeger clients expect the strategies (config.SamplerConfig.SamplingServerURL)
+const Path = "/sampling"
+
+// Handler serves the jaeger-agent sampling API: GET /sampling?service=<name>.
+// It can be mounted in any mux, e.g. router.GET(sampling.Path, gin.WrapH(sampling.Handler(store, logr))).
+func Handler(store *Store, logr *log.Factory) http.Handler {
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		service := r.URL.Query().Get("service")
+		if service == "" {
+			http.Error(w, "'service' parameter must be provided", http.StatusBadRequest)
+			return
+		}
+
+		data, err := json.Marshal(store.Get(service))
+		if err != nil {
+			logr.Default().Error("sampling strategy marshal", zap.String("service", service), zap.String("err", err.Error()))
+			http.Error(w, "cannot marshal strategy", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(data)
+	})
+}
+
+// Server is a standalone sampling strategy server
+type Server struct {
+	store *Store
+	logr  *log.Factory
+	serv  *http.Server
+
+	listener net.Listener
+
+	reloadInterval time.Duration
+	cancel         context.CancelFunc
+
+	mux *http.ServeMux
+}
+
+// ServerOption controls the behavior of the Server.
+type ServerOption func(*Server)
+
+// WithReloadInterval sets how often the strategies file is checked for changes
+func WithReloadInterval(interval time.Duration) ServerOption {
+	return func(s *Server) {
+		if interval <= 0 {
+			return
+		}
+		s.reloadInterval = interval
+	}
+}
+
+// WithHandler serves another jaeger-agent endpoint next to the strategies, e.g. the baggage restrictions
+func WithHandler(path string, handler http.Handler) ServerOption {
+	return func(s *Server) {
+		s.mux.Handle(path, handler)
+	}
+}
+
+// NewServer - new sampling server listening on addr, the strategies are read from the store
+func NewServer(addr string, store *Store, logr *log.Factory, opts ...ServerOption) *Server {
+
+	mux := http.NewServeMux()
+	mux.Handle(Path, Handler(store, logr))
+	// jaeger-agent also answers on the root path, older clients rely on it
+	mux.Handle("/", Handler(store, logr))
+
+	s := &Server{
+		store: store,
+		logr:  logr,
+		serv: &http.Server{
+			Addr:         addr,
+			Handler:      mux,
+			ReadTimeout:  time.Second * 5,
+			WriteTimeout: time.Second * 5,
+		},
+		reloadInterval: defaultReloadInterval,
+		mux:            mux,
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+// Run binds the listener and serves in the background. The strategies file is watched until Shutdown.
+func (s *Server) Run() (err error) {
+
+	s.listener, err = net.Listen("tcp", s.serv.Addr)
+	if err != nil {
+		return errors.Wrap(err, "sampling server listen")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+
+	go s.store.Watch(ctx, s.reloadInterval)
+
+	go func() {
+		if err := s.serv.Serve(s.listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.logr.Default().Error("sampling server serve", zap.String("err", err.Error()))
+		}
+	}()
+
+	s.logr.Default().Debug("SAMPLING SERVER RUNNING...", zap.String("ADDR", s.Addr()))
+
+	return nil
+}
+
+// Addr returns the bound address, useful when listening on port 0
+func (s *Server) Addr() string {
+	if s.listener == nil {
+		return s.serv.Addr
+	}
+	return s.listener.Addr().String()
+}
+
+// URL returns the value for config.SamplerConfig.SamplingServerURL
+func (s *Server) URL() string {
+	return "http://" + s.Addr() + Path
+}
+
+// Shutdown stops the server and the file watcher
+func (s *Server) Shutdown(ctx context.Context) error {
+	if s.cancel != nil {
+		s.cancel()
+	}
+	return s.serv.Shutdown(ctx)
+}
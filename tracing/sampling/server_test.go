@@ -0,0 +1,82 @@
+package sampling_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alloykh/tracer-demo/log"
+	"github.com/alloykh/tracer-demo/tracing"
+	"github.com/alloykh/tracer-demo/tracing/sampling"
+	"github.com/opentracing/opentracing-go"
+	"github.com/uber/jaeger-client-go"
+	"github.com/uber/jaeger-lib/metrics"
+	"go.uber.org/zap/zapcore"
+)
+
+func writeStrategies(t *testing.T, path string, rate string, modTime time.Time) {
+
+	data := `{"service_strategies": [{"service": "sampled", "type": "probabilistic", "param": ` + rate + `}]}`
+
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	// the store reloads on a new modification time, the rewrites of a test happen within a second
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// waitSampled starts spans until the sampling decision of the tracer is sampled
+func waitSampled(t *testing.T, tracer opentracing.Tracer, sampled bool) {
+
+	deadline := time.Now().Add(5 * time.Second)
+
+	for time.Now().Before(deadline) {
+		span := tracer.StartSpan("op")
+		got := span.Context().(jaeger.SpanContext).IsSampled()
+		span.Finish()
+
+		if got == sampled {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	t.Fatalf("the tracer never got sampled=%v from the sampling server", sampled)
+}
+
+func TestServerStrategiesReachTheTracer(t *testing.T) {
+
+	logr := log.NewFactory("test", zapcore.ErrorLevel)
+
+	path := filepath.Join(t.TempDir(), "strategies.json")
+	now := time.Now()
+	writeStrategies(t, path, "1", now)
+
+	store, err := sampling.NewStore(path, logr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := sampling.NewServer("127.0.0.1:0", store, logr, sampling.WithReloadInterval(20*time.Millisecond))
+	if err := srv.Run(); err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Shutdown(context.Background())
+
+	tracer, closer := tracing.InitJaeger("sampled", metrics.NullFactory, logr,
+		tracing.WithRemoteSampler(srv.URL(), 20*time.Millisecond, 0),
+	)
+	defer closer()
+
+	waitSampled(t, tracer, true)
+
+	writeStrategies(t, path, "0", now.Add(time.Minute))
+	waitSampled(t, tracer, false)
+
+	writeStrategies(t, path, "1", now.Add(2*time.Minute))
+	waitSampled(t, tracer, true)
+}
@@ -0,0 +1,77 @@
+package sampling
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/alloykh/tracer-demo/config"
+	"github.com/alloykh/tracer-demo/log"
+	"github.com/uber/jaeger-client-go/thrift-gen/sampling"
+)
+
+var defaultReloadInterval = config.DefaultPollInterval
+
+// Store keeps the strategies of a file in memory and reloads them when the file changes.
+// A broken file never replaces a valid one, the previous strategies stay in use.
+type Store struct {
+	file *config.PolledFile
+	logr *log.Factory
+
+	mu         sync.RWMutex
+	strategies *Strategies
+}
+
+// NewStore - loads the strategies file, fails if the file is missing or invalid
+func NewStore(path string, logr *log.Factory) (*Store, error) {
+
+	s := &Store{
+		file: config.NewPolledFile("sampling strategies", path),
+		logr: logr,
+	}
+
+	if err := s.file.Load(s.load); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Reload reads the file if it was modified since the last load. It reports whether the strategies changed.
+func (s *Store) Reload() (changed bool, err error) {
+	return s.file.Reload(s.load)
+}
+
+// Watch polls the file every interval until ctx is done, use it in a separate goroutine
+func (s *Store) Watch(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultReloadInterval
+	}
+	s.file.Watch(ctx, interval, s.logr, s.load)
+}
+
+func (s *Store) load(data []byte) error {
+
+	strategies, err := ParseStrategies(s.file.Path(), data)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.strategies = strategies
+	s.mu.Unlock()
+
+	return nil
+}
+
+// Strategies returns the strategies currently in use
+func (s *Store) Strategies() *Strategies {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.strategies
+}
+
+// Get returns the sampling strategy of the service
+func (s *Store) Get(service string) *sampling.SamplingStrategyResponse {
+	return s.Strategies().Response(service)
+}
@@ -0,0 +1,241 @@
+package sampling
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/uber/jaeger-client-go/thrift-gen/sampling"
+	"gopkg.in/yaml.v2"
+)
+
+// strategy types accepted in the strategies file, the same names jaeger-agent uses
+const (
+	TypeProbabilistic = "probabilistic"
+	TypeRateLimiting  = "ratelimiting"
+)
+
+// defaultSamplingRate is used when the file has no default_strategy
+const defaultSamplingRate = 0.001
+
+// Strategies is the content of a strategies file.
+// The layout follows the file format of the jaeger collector (--sampling.strategies-file):
+//
+//	{
+//	  "service_strategies": [
+//	    {"service": "frontend", "type": "probabilistic", "param": 0.5,
+//	     "operation_strategies": [{"operation": "HTTP GET /order", "type": "probabilistic", "param": 1}]}
+//	  ],
+//	  "default_strategy": {"type": "probabilistic", "param": 0.1}
+//	}
+type Strategies struct {
+	ServiceStrategies []ServiceStrategy `json:"service_strategies" yaml:"service_strategies"`
+	DefaultStrategy   *Strategy         `json:"default_strategy" yaml:"default_strategy"`
+}
+
+// Strategy describes how a single service (or the default) is sampled.
+type Strategy struct {
+	Type                string              `json:"type" yaml:"type"`
+	Param               float64             `json:"param" yaml:"param"`
+	OperationStrategies []OperationStrategy `json:"operation_strategies" yaml:"operation_strategies"`
+
+	// LowerBound is the minimum number of traces per second sampled for each operation,
+	// only used together with operation strategies
+	LowerBound float64 `json:"lower_bound" yaml:"lower_bound"`
+}
+
+// ServiceStrategy is a Strategy bound to a service name.
+type ServiceStrategy struct {
+	Service  string `json:"service" yaml:"service"`
+	Strategy `yaml:",inline"`
+}
+
+// OperationStrategy overrides the sampling of a single operation.
+// Jaeger clients only support probabilistic per-operation sampling.
+type OperationStrategy struct {
+	Operation string  `json:"operation" yaml:"operation"`
+	Type      string  `json:"type" yaml:"type"`
+	Param     float64 `json:"param" yaml:"param"`
+}
+
+// ParseStrategies decodes a strategies file, the format is picked by the file extension (.yaml, .yml or .json)
+func ParseStrategies(name string, data []byte) (s *Strategies, err error) {
+
+	s = &Strategies{}
+
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".yaml", ".yml":
+		err = yaml.UnmarshalStrict(data, s)
+	default:
+		err = json.Unmarshal(data, s)
+	}
+
+	if err != nil {
+		return nil, errors.Wrap(err, "strategies decode")
+	}
+
+	if err = s.Validate(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Validate checks types and params of every strategy in the file.
+func (s *Strategies) Validate() error {
+
+	if s.DefaultStrategy != nil {
+		if err := s.DefaultStrategy.validate(); err != nil {
+			return errors.Wrap(err, "default_strategy")
+		}
+	}
+
+	seen := make(map[string]bool, len(s.ServiceStrategies))
+
+	for i, ss := range s.ServiceStrategies {
+		if ss.Service == "" {
+			return fmt.Errorf("service_strategies[%d]: service name is empty", i)
+		}
+		if seen[ss.Service] {
+			return fmt.Errorf("service_strategies[%d]: duplicate service %q", i, ss.Service)
+		}
+		seen[ss.Service] = true
+
+		if err := ss.validate(); err != nil {
+			return errors.Wrapf(err, "service_strategies[%d] (%s)", i, ss.Service)
+		}
+	}
+
+	return nil
+}
+
+func (s Strategy) validate() error {
+
+	if err := validateParam(s.Type, s.Param); err != nil {
+		return err
+	}
+
+	if s.LowerBound < 0 {
+		return fmt.Errorf("lower_bound must not be negative, got %v", s.LowerBound)
+	}
+
+	for i, op := range s.OperationStrategies {
+		if op.Operation == "" {
+			return fmt.Errorf("operation_strategies[%d]: operation name is empty", i)
+		}
+		if op.Type != TypeProbabilistic {
+			return fmt.Errorf("operation_strategies[%d] (%s): only %q is supported per operation, got %q", i, op.Operation, TypeProbabilistic, op.Type)
+		}
+		if err := validateParam(op.Type, op.Param); err != nil {
+			return errors.Wrapf(err, "operation_strategies[%d] (%s)", i, op.Operation)
+		}
+	}
+
+	return nil
+}
+
+func validateParam(typ string, param float64) error {
+	switch typ {
+	case TypeProbabilistic:
+		if param < 0 || param > 1 {
+			return fmt.Errorf("probabilistic param must be between 0 and 1, got %v", param)
+		}
+	case TypeRateLimiting:
+		if param < 0 || param > float64(1<<15-1) {
+			return fmt.Errorf("ratelimiting param must be between 0 and %d, got %v", 1<<15-1, param)
+		}
+	default:
+		return fmt.Errorf("unknown strategy type %q", typ)
+	}
+	return nil
+}
+
+// Response builds the answer of the /sampling endpoint for the given service.
+// Unknown services get the default strategy, operation strategies of the default
+// strategy are merged into the service ones (the service wins on conflicts).
+func (s *Strategies) Response(service string) *sampling.SamplingStrategyResponse {
+
+	def := Strategy{Type: TypeProbabilistic, Param: defaultSamplingRate}
+	if s.DefaultStrategy != nil {
+		def = *s.DefaultStrategy
+	}
+
+	strategy := def
+	for _, ss := range s.ServiceStrategies {
+		if ss.Service == service {
+			strategy = ss.Strategy
+			strategy.OperationStrategies = mergeOperations(ss.OperationStrategies, def.OperationStrategies)
+			break
+		}
+	}
+
+	resp := strategy.response()
+
+	if len(strategy.OperationStrategies) == 0 {
+		return resp
+	}
+
+	// per-operation sampling is always probabilistic, for rate limited services
+	// the default probability falls back to the default strategy
+	defaultProbability := defaultSamplingRate
+	switch {
+	case strategy.Type == TypeProbabilistic:
+		defaultProbability = strategy.Param
+	case def.Type == TypeProbabilistic:
+		defaultProbability = def.Param
+	}
+
+	ops := make([]*sampling.OperationSamplingStrategy, 0, len(strategy.OperationStrategies))
+	for _, op := range strategy.OperationStrategies {
+		ops = append(ops, &sampling.OperationSamplingStrategy{
+			Operation:             op.Operation,
+			ProbabilisticSampling: &sampling.ProbabilisticSamplingStrategy{SamplingRate: op.Param},
+		})
+	}
+
+	resp.OperationSampling = &sampling.PerOperationSamplingStrategies{
+		DefaultSamplingProbability:       defaultProbability,
+		DefaultLowerBoundTracesPerSecond: strategy.LowerBound,
+		PerOperationStrategies:           ops,
+	}
+
+	return resp
+}
+
+func (s Strategy) response() *sampling.SamplingStrategyResponse {
+	if s.Type == TypeRateLimiting {
+		return &sampling.SamplingStrategyResponse{
+			StrategyType:         sampling.SamplingStrategyType_RATE_LIMITING,
+			RateLimitingSampling: &sampling.RateLimitingSamplingStrategy{MaxTracesPerSecond: int16(s.Param)},
+		}
+	}
+	return &sampling.SamplingStrategyResponse{
+		StrategyType:          sampling.SamplingStrategyType_PROBABILISTIC,
+		ProbabilisticSampling: &sampling.ProbabilisticSamplingStrategy{SamplingRate: s.Param},
+	}
+}
+
+func mergeOperations(service, def []OperationStrategy) []OperationStrategy {
+
+	if len(def) == 0 {
+		return service
+	}
+
+	merged := make([]OperationStrategy, 0, len(service)+len(def))
+	merged = append(merged, service...)
+
+	known := make(map[string]bool, len(service))
+	for _, op := range service {
+		known[op.Operation] = true
+	}
+
+	for _, op := range def {
+		if !known[op.Operation] {
+			merged = append(merged, op)
+		}
+	}
+
+	return merged
+}
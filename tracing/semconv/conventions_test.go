@@ -0,0 +1,168 @@
+package semconv_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alloykh/tracer-demo/log"
+	"github.com/alloykh/tracer-demo/remote"
+	"github.com/alloykh/tracer-demo/tracing"
+	"github.com/alloykh/tracer-demo/tracing/async"
+	"github.com/alloykh/tracer-demo/tracing/model"
+	"github.com/alloykh/tracer-demo/tracing/semconv"
+	"github.com/gin-gonic/gin"
+	"github.com/opentracing/opentracing-go"
+	"github.com/uber/jaeger-client-go"
+	"go.uber.org/zap/zapcore"
+)
+
+// TestInstrumentationFollowsConventions records the spans of the instrumentation of the repo and checks their tags
+func TestInstrumentationFollowsConventions(t *testing.T) {
+
+	reporter := jaeger.NewInMemoryReporter()
+	tracer, closer := jaeger.NewTracer("semconv-test", jaeger.NewConstSampler(true), reporter)
+	defer closer.Close()
+
+	global := opentracing.GlobalTracer()
+	opentracing.SetGlobalTracer(tracer)
+	defer opentracing.SetGlobalTracer(global)
+
+	logr := log.NewFactory("test", zapcore.ErrorLevel)
+
+	// server spans of the gin middleware
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(tracing.Tracer(tracer))
+	router.GET("/users/:id", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{})
+	})
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/42?verbose=1", nil))
+
+	// edge spans of an untrusted caller, with a budget
+	trust, err := tracing.NewTrustPolicy()
+	if err != nil {
+		t.Fatal(err)
+	}
+	edge := gin.New()
+	edge.Use(tracing.Tracer(tracer, tracing.MWTrustPolicy(trust)), tracing.Deadline(tracing.BudgetDefault(time.Second)))
+	edge.GET("/order", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{})
+	})
+	caller, _ := tracing.StartSpan(context.Background(), "caller")
+	caller.SetBaggageItem("session", "s-1")
+	edgeReq := httptest.NewRequest(http.MethodGet, "/order", nil)
+	if err := tracer.Inject(caller.Context(), opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(edgeReq.Header)); err != nil {
+		t.Fatal(err)
+	}
+	edge.ServeHTTP(httptest.NewRecorder(), edgeReq)
+	caller.Finish()
+
+	// task spans of a pool
+	pool := async.NewPool("semconv", 1, 1, async.WithTracer(tracer), async.WithLogger(logr))
+	if err := pool.Submit(context.Background(), "task", func(ctx context.Context) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	pool.Close()
+
+	// client spans of the remote client
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	req, err := http.NewRequest(http.MethodGet, srv.URL+"/order", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var resp map[string]interface{}
+	if err := remote.NewClient(logr).Do(context.Background(), req, &resp); err != nil {
+		t.Fatal(err)
+	}
+
+	// database spans
+	span, _ := tracing.StartSpan(context.Background(), "Product Get")
+	tracing.WrapWithTags(span, "postgres", "SELECT * FROM products WHERE uid = $1")
+	semconv.DBParams(span, "42")
+	span.Finish()
+
+	spans := reporter.GetSpans()
+	if len(spans) < 6 {
+		t.Fatalf("recorded %d spans, want at least 6", len(spans))
+	}
+
+	for _, s := range spans {
+		semconv.Check(t, model.FromJaegerSpan(s.(*jaeger.Span)))
+	}
+}
+
+func TestValidateViolations(t *testing.T) {
+
+	tests := []struct {
+		name string
+		tags []model.KeyValue
+		want []string
+	}{
+		{
+			name: "budget not an integer",
+			tags: []model.KeyValue{model.KeyValue{Key: semconv.DeadlineBudgetKey, Type: model.StringType, Value: "400ms"}},
+			want: []string{semconv.DeadlineBudgetKey},
+		},
+		{
+			name: "deadline source without a budget",
+			tags: []model.KeyValue{model.KeyValue{Key: semconv.DeadlineSourceKey, Type: model.StringType, Value: "header"}},
+			want: []string{semconv.DeadlineBudgetKey},
+		},
+		{
+			name: "edge trust not a bool",
+			tags: []model.KeyValue{model.KeyValue{Key: semconv.EdgeTrustedKey, Type: model.StringType, Value: "false"}},
+			want: []string{semconv.EdgeTrustedKey},
+		},
+		{
+			name: "link without its span",
+			tags: []model.KeyValue{model.KeyValue{Key: semconv.LinkTraceIDKey, Type: model.StringType, Value: "1"}, model.KeyValue{Key: semconv.LinkTypeKey, Type: model.StringType, Value: "follows_from"}},
+			want: []string{semconv.LinkSpanIDKey},
+		},
+		{
+			name: "empty forced sampling reason",
+			tags: []model.KeyValue{model.KeyValue{Key: semconv.SamplingForcedKey, Type: model.StringType, Value: ""}},
+			want: []string{semconv.SamplingForcedKey},
+		},
+		{
+			name: "queue wait outside of a pool",
+			tags: []model.KeyValue{model.KeyValue{Key: semconv.QueueWaitKey, Type: model.Int64Type, Value: int64(3)}},
+			want: []string{semconv.PoolNameKey},
+		},
+		{
+			name: "conventional tags",
+			tags: []model.KeyValue{
+				model.KeyValue{Key: semconv.DeadlineBudgetKey, Type: model.Int64Type, Value: int64(400)},
+				model.KeyValue{Key: semconv.DeadlineSourceKey, Type: model.StringType, Value: "header"},
+				model.KeyValue{Key: semconv.EdgeTrustedKey, Type: model.BoolType, Value: false},
+				model.KeyValue{Key: semconv.PoolNameKey, Type: model.StringType, Value: "mail"},
+				model.KeyValue{Key: semconv.QueueWaitKey, Type: model.Int64Type, Value: int64(3)},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			var got []string
+			for _, v := range semconv.Validate(&model.Span{SpanID: "1", OperationName: "op", Tags: tt.tags}) {
+				got = append(got, v.Key)
+			}
+
+			if len(got) != len(tt.want) {
+				t.Fatalf("violations %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("violations %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,396 @@
+// Package semconv names the span tags of the instrumentation and sets them with typed setters,
+// so that a tag means the same thing whichever span it is found on.
+// The names are those of opentracing.ext where it has one.
+package semconv
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	otlog "github.com/opentracing/opentracing-go/log"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// tag names
+const (
+	SpanKindKey  = "span.kind"
+	ComponentKey = "component"
+	ErrorKey     = "error"
+
+	HTTPMethodKey       = "http.method"
+	HTTPURLKey          = "http.url"
+	HTTPStatusCodeKey   = "http.status_code"
+	HTTPRouteKey        = "http.route"
+	HTTPHostKey         = "http.host"
+	HTTPUserAgentKey    = "http.user_agent"
+	HTTPResponseSizeKey = "http.response_size"
+
+	// durations of the phases of an outbound request, in milliseconds
+	HTTPConnWaitKey    = "http.conn_wait_ms"
+	HTTPDNSKey         = "http.dns_ms"
+	HTTPConnectKey     = "http.connect_ms"
+	HTTPTLSKey         = "http.tls_ms"
+	HTTPFirstByteKey   = "http.ttfb_ms"
+	NetConnReusedKey   = "net.conn.reused"
+	NetConnWasIdleKey  = "net.conn.was_idle"
+	NetConnIdleTimeKey = "net.conn.idle_time_ms"
+
+	RPCSystemKey  = "rpc.system"
+	RPCServiceKey = "rpc.service"
+	RPCMethodKey  = "rpc.method"
+	GRPCCodeKey   = "grpc.code"
+
+	DBTypeKey      = "db.type"
+	DBInstanceKey  = "db.instance"
+	DBUserKey      = "db.user"
+	DBStatementKey = "db.statement"
+	DBParamPrefix  = "db.param."
+
+	MessagingSystemKey      = "messaging.system"
+	MessageDestinationKey   = "message_bus.destination"
+	MessageIDKey            = "message.id"
+	MessagePayloadSizeKey   = "message.payload_size"
+	MessageRedeliveryKey    = "message.redelivery_count"
+	MessageConsumerGroupKey = "message.consumer_group"
+
+	UserIDKey   = "user.id"
+	TenantIDKey = "tenant.id"
+
+	PeerServiceKey  = "peer.service"
+	PeerHostnameKey = "peer.hostname"
+	PeerIPv4Key     = "peer.ipv4"
+	PeerIPv6Key     = "peer.ipv6"
+	PeerPortKey     = "peer.port"
+	PeerAddressKey  = "peer.address"
+
+	// the budget of a request, see tracing.MWBudget
+	DeadlineBudgetKey   = "deadline.budget_ms"
+	DeadlineSourceKey   = "deadline.source"
+	DeadlineExceededKey = "deadline.exceeded"
+
+	// the trust of the caller at the edge, see tracing.MWTrustPolicy
+	EdgeTrustedKey        = "edge.trusted"
+	EdgeBaggageDroppedKey = "edge.baggage_dropped"
+
+	// a span context the span relates to outside of its trace
+	LinkTraceIDKey = "link.trace_id"
+	LinkSpanIDKey  = "link.span_id"
+	LinkTypeKey    = "link.type"
+
+	SamplingForcedKey        = "sampling.forced"
+	SamplingForceRejectedKey = "sampling.force_rejected"
+
+	// the tasks of tracing/async
+	PoolNameKey         = "pool.name"
+	PoolWorkerKey       = "pool.worker"
+	QueueWaitKey        = "queue.wait_ms"
+	ScheduleRunKey      = "schedule.run"
+	ScheduleIntervalKey = "schedule.interval_ms"
+)
+
+// span log fields of an error
+const (
+	EventKey        = "event"
+	ErrorKindKey    = "error.kind"
+	ErrorMessageKey = "message"
+	ErrorStackKey   = "stack"
+)
+
+// Component sets the library or the framework which created the span
+func Component(span opentracing.Span, name string) {
+	ext.Component.Set(span, name)
+}
+
+// HTTPServerRequest tags a server span with the request, url is the value of http.url,
+// which may hide parts of the request URL
+func HTTPServerRequest(span opentracing.Span, r *http.Request, url string) {
+	ext.SpanKindRPCServer.Set(span)
+	ext.HTTPMethod.Set(span, r.Method)
+	ext.HTTPUrl.Set(span, url)
+	if r.Host != "" {
+		span.SetTag(HTTPHostKey, r.Host)
+	}
+	if ua := r.UserAgent(); ua != "" {
+		span.SetTag(HTTPUserAgentKey, ua)
+	}
+	NetPeer(span, r.RemoteAddr)
+}
+
+// HTTPClientRequest tags a client span with the request, the peer is the host of the URL
+func HTTPClientRequest(span opentracing.Span, r *http.Request) {
+	ext.SpanKindRPCClient.Set(span)
+	ext.HTTPMethod.Set(span, r.Method)
+	ext.HTTPUrl.Set(span, r.URL.String())
+	span.SetTag(HTTPHostKey, r.URL.Host)
+	NetPeer(span, r.URL.Host)
+}
+
+// HTTPPhase sets the duration of a phase of an outbound request, key is one of HTTPConnWaitKey, HTTPDNSKey,
+// HTTPConnectKey, HTTPTLSKey or HTTPFirstByteKey
+func HTTPPhase(span opentracing.Span, key string, d time.Duration) {
+	span.SetTag(key, float64(d.Microseconds())/1000)
+}
+
+// NetConn tags the connection of an outbound request: reused from the pool, idle and for how long before
+func NetConn(span opentracing.Span, reused, wasIdle bool, idle time.Duration) {
+	span.SetTag(NetConnReusedKey, reused)
+	span.SetTag(NetConnWasIdleKey, wasIdle)
+	if wasIdle {
+		span.SetTag(NetConnIdleTimeKey, idle.Milliseconds())
+	}
+}
+
+// HTTPRoute sets the route template the request matched, e.g. /user/:id
+func HTTPRoute(span opentracing.Span, route string) {
+	if route != "" {
+		span.SetTag(HTTPRouteKey, route)
+	}
+}
+
+// HTTPStatus sets the status code of the response, a server error marks the span as failed
+func HTTPStatus(span opentracing.Span, code int) {
+	ext.HTTPStatusCode.Set(span, uint16(code))
+	if code >= http.StatusInternalServerError {
+		ext.Error.Set(span, true)
+	}
+}
+
+// HTTPResponseSize sets the size of the response body in bytes
+func HTTPResponseSize(span opentracing.Span, size int) {
+	if size >= 0 {
+		span.SetTag(HTTPResponseSizeKey, size)
+	}
+}
+
+// RPC sets the system, e.g. grpc, and the service and method of fullMethod, /package.Service/Method
+func RPC(span opentracing.Span, system, fullMethod string) {
+
+	span.SetTag(RPCSystemKey, system)
+
+	parts := strings.Split(strings.TrimPrefix(fullMethod, "/"), "/")
+	if len(parts) == 2 {
+		span.SetTag(RPCServiceKey, parts[0])
+		span.SetTag(RPCMethodKey, parts[1])
+	}
+}
+
+// GRPCStatus sets the gRPC code of the call
+func GRPCStatus(span opentracing.Span, code codes.Code) {
+	span.SetTag(GRPCCodeKey, code.String())
+}
+
+// DB tags a client span calling a database of type system, e.g. postgresql, with statement.
+// The database is not a service of the traces, peer.service is left to PeerService.
+func DB(span opentracing.Span, system, statement string) {
+	ext.SpanKindRPCClient.Set(span)
+	ext.DBType.Set(span, system)
+	if statement != "" {
+		ext.DBStatement.Set(span, statement)
+	}
+}
+
+// DBInstance sets the name of the database
+func DBInstance(span opentracing.Span, name string) {
+	ext.DBInstance.Set(span, name)
+}
+
+// DBUser sets the user the database is accessed with
+func DBUser(span opentracing.Span, user string) {
+	ext.DBUser.Set(span, user)
+}
+
+// DBParams sets the parameters of the statement as db.param.0, db.param.1...
+func DBParams(span opentracing.Span, params ...interface{}) {
+	for i, p := range params {
+		span.SetTag(DBParamPrefix+strconv.Itoa(i), p)
+	}
+}
+
+// Messaging tags a producer or consumer span of a message going through destination, system is the kind of broker if known
+func Messaging(span opentracing.Span, kind ext.SpanKindEnum, system, destination string) {
+	ext.SpanKind.Set(span, kind)
+	if system != "" {
+		span.SetTag(MessagingSystemKey, system)
+	}
+	ext.MessageBusDestination.Set(span, destination)
+}
+
+// MessageID sets the id of the message given by the broker
+func MessageID(span opentracing.Span, id string) {
+	if id != "" {
+		span.SetTag(MessageIDKey, id)
+	}
+}
+
+// MessagePayloadSize sets the size of the message payload in bytes
+func MessagePayloadSize(span opentracing.Span, size int) {
+	span.SetTag(MessagePayloadSizeKey, size)
+}
+
+// MessageRedeliveries sets the number of deliveries of the message before this one
+func MessageRedeliveries(span opentracing.Span, n int) {
+	span.SetTag(MessageRedeliveryKey, n)
+}
+
+// MessageConsumerGroup sets the consumer group receiving the message
+func MessageConsumerGroup(span opentracing.Span, group string) {
+	span.SetTag(MessageConsumerGroupKey, group)
+}
+
+// Exception marks the span as failed and logs the kind and the message of err
+func Exception(span opentracing.Span, err error) {
+	ext.Error.Set(span, true)
+	span.LogFields(
+		otlog.String(EventKey, "error"),
+		otlog.String(ErrorKindKey, ErrorKind(err)),
+		otlog.String(ErrorMessageKey, err.Error()),
+	)
+}
+
+// Panic marks the span as failed and logs the recovered value and the stack
+func Panic(span opentracing.Span, recovered interface{}, stack []byte) {
+	ext.Error.Set(span, true)
+	span.LogFields(
+		otlog.String(EventKey, "error"),
+		otlog.String(ErrorKindKey, "panic"),
+		otlog.String(ErrorMessageKey, fmt.Sprint(recovered)),
+		otlog.String(ErrorStackKey, string(stack)),
+	)
+}
+
+// ErrorKind - the gRPC code of a status error, the type of the cause of the others
+func ErrorKind(err error) string {
+
+	switch {
+	case errors.Is(err, context.Canceled):
+		return codes.Canceled.String()
+	case errors.Is(err, context.DeadlineExceeded):
+		return codes.DeadlineExceeded.String()
+	}
+
+	if s, ok := status.FromError(err); ok {
+		return s.Code().String()
+	}
+
+	return fmt.Sprintf("%T", errors.Cause(err))
+}
+
+// User sets the id of the user the span works for
+func User(span opentracing.Span, id string) {
+	if id != "" {
+		span.SetTag(UserIDKey, id)
+	}
+}
+
+// Tenant sets the id of the tenant the span works for
+func Tenant(span opentracing.Span, id string) {
+	if id != "" {
+		span.SetTag(TenantIDKey, id)
+	}
+}
+
+// PeerService sets the name of the service on the other side of the call
+func PeerService(span opentracing.Span, name string) {
+	ext.PeerService.Set(span, name)
+}
+
+// PeerAddress sets the address of the other side of the call as given, e.g. a gRPC target
+func PeerAddress(span opentracing.Span, address string) {
+	span.SetTag(PeerAddressKey, address)
+}
+
+// NetPeer sets the host and the port of hostPort, the host as peer.ipv4, peer.ipv6 or peer.hostname
+func NetPeer(span opentracing.Span, hostPort string) {
+
+	host, port, err := net.SplitHostPort(hostPort)
+	if err != nil {
+		host = hostPort
+	}
+
+	if host == "" {
+		return
+	}
+
+	if ip := net.ParseIP(host); ip == nil {
+		ext.PeerHostname.Set(span, host)
+	} else if ip.To4() != nil {
+		span.SetTag(PeerIPv4Key, ip.String())
+	} else {
+		span.SetTag(PeerIPv6Key, ip.String())
+	}
+
+	if p, err := strconv.ParseUint(port, 10, 16); err == nil {
+		ext.PeerPort.Set(span, uint16(p))
+	}
+}
+
+// DeadlineBudget sets the time left to the deadline when the span started, negative once it is past
+func DeadlineBudget(span opentracing.Span, budget time.Duration) {
+	span.SetTag(DeadlineBudgetKey, budget.Milliseconds())
+}
+
+// DeadlineSource sets where the deadline of the span comes from, e.g. header, route or default
+func DeadlineSource(span opentracing.Span, source string) {
+	span.SetTag(DeadlineSourceKey, source)
+}
+
+// DeadlineExceeded marks the span as having run out of its budget
+func DeadlineExceeded(span opentracing.Span) {
+	span.SetTag(DeadlineExceededKey, true)
+}
+
+// EdgeUntrusted marks a span started at the edge for an untrusted caller, whose trace was not continued
+func EdgeUntrusted(span opentracing.Span) {
+	span.SetTag(EdgeTrustedKey, false)
+}
+
+// EdgeBaggageDropped marks a span whose caller sent baggage which was dropped
+func EdgeBaggageDropped(span opentracing.Span) {
+	span.SetTag(EdgeBaggageDroppedKey, true)
+}
+
+// Link sets the trace and the span ids of a span context the span relates to, linkType says how,
+// e.g. follows_from or scheduled_by
+func Link(span opentracing.Span, traceID, spanID, linkType string) {
+	span.SetTag(LinkTraceIDKey, traceID)
+	span.SetTag(LinkSpanIDKey, spanID)
+	span.SetTag(LinkTypeKey, linkType)
+}
+
+// SamplingForced sets why the trace of the span was forced into sampling
+func SamplingForced(span opentracing.Span, reason string) {
+	span.SetTag(SamplingForcedKey, reason)
+}
+
+// SamplingForceRejected marks a span whose request asked for sampling with a wrong secret
+func SamplingForceRejected(span opentracing.Span) {
+	span.SetTag(SamplingForceRejectedKey, true)
+}
+
+// PoolTask is the start option of the span of a task submitted to the pool name
+func PoolTask(name string) opentracing.StartSpanOption {
+	return opentracing.Tag{Key: PoolNameKey, Value: name}
+}
+
+// PoolDequeued sets the worker running the task of the span and how long the task waited for it
+func PoolDequeued(span opentracing.Span, worker int, wait time.Duration) {
+	span.SetTag(QueueWaitKey, wait.Milliseconds())
+	span.SetTag(PoolWorkerKey, worker)
+}
+
+// ScheduleRun is the start option of the span of the run-th run of a task scheduled every interval
+func ScheduleRun(run int, interval time.Duration) opentracing.StartSpanOption {
+	return opentracing.Tags{
+		ScheduleRunKey:      run,
+		ScheduleIntervalKey: interval.Milliseconds(),
+	}
+}
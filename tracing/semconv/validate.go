@@ -0,0 +1,183 @@
+package semconv
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/alloykh/tracer-demo/tracing/model"
+)
+
+// Violation is a span tag breaking the conventions
+type Violation struct {
+	SpanID    string
+	Operation string
+	Key       string
+	Message   string
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("span %s %q: %s: %s", v.SpanID, v.Operation, v.Key, v.Message)
+}
+
+// replaced names the ad hoc tags which have a conventional name
+var replaced = map[string]string{
+	"query":       DBStatementKey,
+	"sql":         DBStatementKey,
+	"user":        UserIDKey,
+	"user_id":     UserIDKey,
+	"tenant":      TenantIDKey,
+	"tenant_id":   TenantIDKey,
+	"status_code": HTTPStatusCodeKey,
+	"method":      HTTPMethodKey,
+	"url":         HTTPURLKey,
+}
+
+var spanKinds = map[string]bool{"client": true, "server": true, "producer": true, "consumer": true}
+
+// Validate checks the tags of a recorded span against the conventions
+func Validate(span *model.Span) []Violation {
+
+	var violations []Violation
+	violate := func(key, format string, args ...interface{}) {
+		violations = append(violations, Violation{
+			SpanID:    span.SpanID,
+			Operation: span.OperationName,
+			Key:       key,
+			Message:   fmt.Sprintf(format, args...),
+		})
+	}
+
+	tags := make(map[string]model.KeyValue, len(span.Tags))
+	for _, kv := range span.Tags {
+		tags[kv.Key] = kv
+
+		if name, ok := replaced[kv.Key]; ok {
+			violate(kv.Key, "ad hoc tag, use %s", name)
+		}
+		if strings.HasPrefix(kv.Key, "param.#") {
+			violate(kv.Key, "ad hoc tag, use %s<n>", DBParamPrefix)
+		}
+	}
+
+	has := func(key string) bool {
+		_, ok := tags[key]
+		return ok
+	}
+
+	kind := ""
+	if kv, ok := tags[SpanKindKey]; ok {
+		kind = kv.String()
+		if !spanKinds[kind] {
+			violate(SpanKindKey, "unknown kind %q", kind)
+		}
+	}
+
+	if kv, ok := tags[ErrorKey]; ok && kv.Type != model.BoolType {
+		violate(ErrorKey, "must be a bool, not a %s", kv.Type)
+	}
+
+	if has(HTTPMethodKey) {
+		if method := tags[HTTPMethodKey].String(); method != strings.ToUpper(method) {
+			violate(HTTPMethodKey, "%q must be upper case", method)
+		}
+		if !has(HTTPURLKey) {
+			violate(HTTPURLKey, "missing on an HTTP span")
+		}
+		if kind != "client" && kind != "server" {
+			violate(SpanKindKey, "an HTTP span must be a client or a server span")
+		}
+	}
+
+	if kv, ok := tags[HTTPStatusCodeKey]; ok {
+		if kv.Type != model.Int64Type {
+			violate(HTTPStatusCodeKey, "must be an integer, not a %s", kv.Type)
+		} else if code, _ := strconv.ParseInt(kv.String(), 10, 64); code < 100 || code > 599 {
+			violate(HTTPStatusCodeKey, "%d is not a status code", code)
+		}
+	}
+
+	if has(RPCServiceKey) || has(RPCMethodKey) {
+		for _, key := range []string{RPCSystemKey, RPCServiceKey, RPCMethodKey} {
+			if !has(key) {
+				violate(key, "missing on an RPC span")
+			}
+		}
+	}
+
+	if has(DBStatementKey) || has(DBTypeKey) {
+		if !has(DBTypeKey) {
+			violate(DBTypeKey, "missing on a database span")
+		}
+		if kind != "client" {
+			violate(SpanKindKey, "a database span must be a client span")
+		}
+		if kv, ok := tags[PeerServiceKey]; ok && kv.String() == tags[DBTypeKey].String() {
+			violate(PeerServiceKey, "%q is the type of the database, not a service", kv.String())
+		}
+	}
+
+	if has(MessageDestinationKey) && kind != "producer" && kind != "consumer" {
+		violate(SpanKindKey, "a messaging span must be a producer or a consumer span")
+	}
+
+	if kv, ok := tags[PeerPortKey]; ok && kv.Type != model.Int64Type {
+		violate(PeerPortKey, "must be an integer, not a %s", kv.Type)
+	}
+
+	for _, key := range []string{UserIDKey, TenantIDKey, DeadlineSourceKey, SamplingForcedKey, PoolNameKey} {
+		if kv, ok := tags[key]; ok && (kv.Type != model.StringType || kv.String() == "") {
+			violate(key, "must be a non empty string")
+		}
+	}
+
+	for _, key := range []string{DeadlineBudgetKey, QueueWaitKey, PoolWorkerKey, ScheduleRunKey, ScheduleIntervalKey} {
+		if kv, ok := tags[key]; ok && kv.Type != model.Int64Type {
+			violate(key, "must be an integer, not a %s", kv.Type)
+		}
+	}
+
+	for _, key := range []string{DeadlineExceededKey, EdgeTrustedKey, EdgeBaggageDroppedKey, SamplingForceRejectedKey} {
+		if kv, ok := tags[key]; ok && kv.Type != model.BoolType {
+			violate(key, "must be a bool, not a %s", kv.Type)
+		}
+	}
+
+	if has(DeadlineSourceKey) && !has(DeadlineBudgetKey) {
+		violate(DeadlineBudgetKey, "missing on a span with a deadline source")
+	}
+
+	if has(LinkTraceIDKey) || has(LinkSpanIDKey) || has(LinkTypeKey) {
+		for _, key := range []string{LinkTraceIDKey, LinkSpanIDKey, LinkTypeKey} {
+			if !has(key) {
+				violate(key, "missing on a linked span")
+			}
+		}
+	}
+
+	if has(QueueWaitKey) && !has(PoolNameKey) {
+		violate(PoolNameKey, "missing on a span which waited in a queue")
+	}
+
+	return violations
+}
+
+// TestingT is the part of testing.TB used by Check
+type TestingT interface {
+	Helper()
+	Errorf(format string, args ...interface{})
+}
+
+// Check fails the test for every violation of the spans, e.g. the spans of a jaeger.InMemoryReporter:
+//
+//	for _, s := range reporter.GetSpans() {
+//		semconv.Check(t, model.FromJaegerSpan(s.(*jaeger.Span)))
+//	}
+func Check(t TestingT, spans ...*model.Span) {
+	t.Helper()
+	for _, span := range spans {
+		for _, v := range Validate(span) {
+			t.Errorf("semconv: %s", v)
+		}
+	}
+}
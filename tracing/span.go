@@ -0,0 +1,105 @@
+package tracing
+
+import (
+	"context"
+	"runtime/debug"
+
+	"github.com/alloykh/tracer-demo/tracing/semconv"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+)
+
+type spanOptions struct {
+	tracer opentracing.Tracer
+	tags   opentracing.Tags
+	refs   []opentracing.StartSpanOption
+}
+
+// SpanOption controls the span started by StartSpan and Trace.
+type SpanOption func(*spanOptions)
+
+// SpanTag returns a SpanOption tagging the span
+func SpanTag(key string, value interface{}) SpanOption {
+	return func(options *spanOptions) {
+		options.tags[key] = value
+	}
+}
+
+// SpanKind returns a SpanOption setting the kind of the span, e.g. ext.SpanKindRPCClientEnum
+func SpanKind(kind ext.SpanKindEnum) SpanOption {
+	return SpanTag(string(ext.SpanKind), kind)
+}
+
+// SpanTracer returns a SpanOption starting the span with tracer instead of the global tracer
+func SpanTracer(tracer opentracing.Tracer) SpanOption {
+	return func(options *spanOptions) {
+		options.tracer = tracer
+	}
+}
+
+// SpanFollowsFrom returns a SpanOption referencing span as the cause of the span started, not its parent,
+// e.g. the spans of the items of a long-lived stream
+func SpanFollowsFrom(span opentracing.Span) SpanOption {
+	return func(options *spanOptions) {
+		options.refs = append(options.refs, opentracing.FollowsFrom(span.Context()))
+	}
+}
+
+// StartSpan starts a span, child of the span of ctx if any, and returns it with the context holding it.
+// Defer FinishSpan with the address of the named error result of the function:
+//
+//	func (s *Service) Do(ctx context.Context) (err error) {
+//		span, ctx := tracing.StartSpan(ctx, "Service.Do")
+//		defer tracing.FinishSpan(span, &err)
+func StartSpan(ctx context.Context, name string, opts ...SpanOption) (opentracing.Span, context.Context) {
+
+	options := &spanOptions{
+		tracer: opentracing.GlobalTracer(),
+		tags:   opentracing.Tags{},
+	}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	refs := append([]opentracing.StartSpanOption{options.tags}, options.refs...)
+	if parent := opentracing.SpanFromContext(ctx); parent != nil {
+		refs = append(refs, opentracing.ChildOf(parent.Context()))
+	}
+
+	span := options.tracer.StartSpan(name, refs...)
+
+	TagBudget(span, ctx)
+
+	return span, opentracing.ContextWithSpan(ctx, span)
+}
+
+// FinishSpan finishes the span, recording the error errp points to, if any.
+// It must be deferred directly: a panic is recorded with its stack and raised again.
+func FinishSpan(span opentracing.Span, errp *error) {
+
+	if p := recover(); p != nil {
+		semconv.Panic(span, p, debug.Stack())
+		span.Finish()
+		panic(p)
+	}
+
+	if errp != nil && *errp != nil {
+		RecordError(span, *errp)
+	}
+
+	span.Finish()
+}
+
+// Trace runs fn in a span named name and returns its error, see StartSpan and FinishSpan
+func Trace(ctx context.Context, name string, fn func(ctx context.Context) error, opts ...SpanOption) (err error) {
+
+	span, ctx := StartSpan(ctx, name, opts...)
+	defer FinishSpan(span, &err)
+
+	return fn(ctx)
+}
+
+// RecordError sets error=true on the span and logs the kind and the message of err
+func RecordError(span opentracing.Span, err error) {
+	semconv.Exception(span, err)
+}
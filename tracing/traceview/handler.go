@@ -0,0 +1,246 @@
+package traceview
+
+import (
+	"html/template"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/alloykh/tracer-demo/tracing/model"
+)
+
+// Path is the default mount point of the viewer
+const Path = "/debug/traces"
+
+// Handler serves the recorded traces as HTML:
+//
+//	{prefix}        recent root operations with duration and error status
+//	{prefix}/{id}   waterfall of a single trace with tags and logs
+//
+// Mount it on both the prefix and the prefix followed by a slash, e.g.
+//
+//	mux.Handle(traceview.Path, h)
+//	mux.Handle(traceview.Path+"/", h)
+//
+// or with gin: router.GET(traceview.Path+"/*id", gin.WrapH(h)).
+func Handler(rec *Recorder, prefix string) http.Handler {
+
+	prefix = strings.TrimSuffix(prefix, "/")
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		id := strings.Trim(strings.TrimPrefix(r.URL.Path, prefix), "/")
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+
+		if id == "" {
+			_ = listTemplate.Execute(w, listPage(rec.Traces(), prefix))
+			return
+		}
+
+		t, ok := rec.Trace(id)
+		if !ok {
+			http.Error(w, "trace not found, it may have been evicted", http.StatusNotFound)
+			return
+		}
+
+		_ = traceTemplate.Execute(w, tracePage(t, prefix))
+	})
+}
+
+type listRow struct {
+	TraceID   string
+	Service   string
+	Operation string
+	Start     string
+	Duration  time.Duration
+	Spans     int
+	Error     bool
+}
+
+type listView struct {
+	Prefix string
+	Rows   []listRow
+}
+
+func listPage(traces []*model.Trace, prefix string) listView {
+
+	v := listView{Prefix: prefix, Rows: make([]listRow, 0, len(traces))}
+
+	for _, t := range traces {
+		root := t.Root()
+		if root == nil {
+			continue
+		}
+		v.Rows = append(v.Rows, listRow{
+			TraceID:   t.TraceID,
+			Service:   root.ServiceName(),
+			Operation: root.OperationName,
+			Start:     root.Start().Format("15:04:05.000"),
+			Duration:  t.Elapsed(),
+			Spans:     len(t.Spans),
+			Error:     t.HasError(),
+		})
+	}
+
+	return v
+}
+
+type spanRow struct {
+	SpanID    string
+	Indent    int
+	Service   string
+	Operation string
+	Offset    time.Duration
+	Duration  time.Duration
+	Left      float64 // percent of the trace
+	Width     float64 // percent of the trace
+	Error     bool
+	Tags      []model.KeyValue
+	Logs      []logRow
+}
+
+type logRow struct {
+	Offset time.Duration
+	Fields []model.KeyValue
+}
+
+type traceView struct {
+	Prefix   string
+	TraceID  string
+	Duration time.Duration
+	Services []string
+	Warnings []string
+	Rows     []spanRow
+}
+
+func tracePage(t *model.Trace, prefix string) traceView {
+
+	start, end := t.Bounds()
+	total := float64(end - start)
+	if total <= 0 {
+		total = 1
+	}
+
+	v := traceView{
+		Prefix:   prefix,
+		TraceID:  t.TraceID,
+		Duration: t.Elapsed(),
+		Services: t.Services(),
+		Warnings: t.Warnings,
+		Rows:     make([]spanRow, 0, len(t.Spans)),
+	}
+
+	children := t.Children()
+	visited := make(map[string]bool, len(t.Spans))
+
+	var walk func(s *model.Span, depth int)
+	walk = func(s *model.Span, depth int) {
+
+		if visited[s.SpanID] {
+			return
+		}
+		visited[s.SpanID] = true
+
+		row := spanRow{
+			SpanID:    s.SpanID,
+			Indent:    depth * 16,
+			Service:   s.ServiceName(),
+			Operation: s.OperationName,
+			Offset:    time.Duration(s.StartTime-start) * time.Microsecond,
+			Duration:  s.Elapsed(),
+			Left:      float64(s.StartTime-start) / total * 100,
+			Width:     float64(s.Duration) / total * 100,
+			Error:     s.HasError(),
+			Tags:      s.Tags,
+		}
+
+		for _, l := range s.Logs {
+			row.Logs = append(row.Logs, logRow{Offset: time.Duration(l.Timestamp-start) * time.Microsecond, Fields: l.Fields})
+		}
+
+		v.Rows = append(v.Rows, row)
+
+		for _, child := range children[s.SpanID] {
+			walk(child, depth+1)
+		}
+	}
+
+	// the root first, then spans whose parent was not recorded (e.g. remote parents)
+	if root := t.Root(); root != nil {
+		walk(root, 0)
+	}
+	for _, s := range t.Spans {
+		walk(s, 0)
+	}
+
+	return v
+}
+
+var funcs = template.FuncMap{
+	"ms": func(d time.Duration) string {
+		return d.Round(time.Microsecond).String()
+	},
+}
+
+const style = `<style>
+body { font-family: sans-serif; font-size: 13px; margin: 20px; }
+table { border-collapse: collapse; width: 100%; }
+td, th { padding: 3px 6px; border-bottom: 1px solid #eee; text-align: left; vertical-align: top; }
+.err { color: #c00; font-weight: bold; }
+.bar { position: relative; height: 14px; background: #f4f4f4; min-width: 300px; }
+.bar div { position: absolute; height: 14px; background: #4a90d9; min-width: 1px; }
+.bar div.err { background: #d9534f; }
+details { font-size: 12px; color: #444; }
+code { background: #f4f4f4; padding: 0 3px; }
+</style>`
+
+var listTemplate = template.Must(template.New("list").Funcs(funcs).Parse(`<!DOCTYPE html>
+<html><head><title>recent traces</title>` + style + `</head><body>
+<h3>Recent traces ({{len .Rows}})</h3>
+<table>
+<tr><th>start</th><th>service</th><th>operation</th><th>duration</th><th>spans</th><th>status</th><th>trace id</th></tr>
+{{range .Rows}}<tr>
+<td>{{.Start}}</td><td>{{.Service}}</td>
+<td><a href="{{$.Prefix}}/{{.TraceID}}">{{.Operation}}</a></td>
+<td>{{ms .Duration}}</td><td>{{.Spans}}</td>
+<td>{{if .Error}}<span class="err">error</span>{{else}}ok{{end}}</td>
+<td><code>{{.TraceID}}</code></td>
+</tr>{{end}}
+</table>
+</body></html>`))
+
+var traceTemplate = template.Must(template.New("trace").Funcs(funcs).Parse(`<!DOCTYPE html>
+<html><head><title>trace {{.TraceID}}</title>` + style + `</head><body>
+<p><a href="{{.Prefix}}">&larr; recent traces</a></p>
+<h3>Trace <code>{{.TraceID}}</code> &mdash; {{ms .Duration}}, {{len .Rows}} spans</h3>
+<p>services: {{range $i, $s := .Services}}{{if $i}}, {{end}}{{$s}}{{end}}</p>
+{{range .Warnings}}<p class="err">{{.}}</p>{{end}}
+<table>
+<tr><th>operation</th><th>offset</th><th>duration</th><th style="width:45%">timeline</th></tr>
+{{range .Rows}}<tr>
+<td><div style="padding-left: {{.Indent}}px">
+<span {{if .Error}}class="err"{{end}}>{{.Service}}: {{.Operation}}</span>
+{{if or .Tags .Logs}}<details><summary>tags &amp; logs</summary>
+{{range .Tags}}<div><code>{{.Key}}</code> = {{.String}}</div>{{end}}
+{{range .Logs}}<div>+{{ms .Offset}}: {{range .Fields}}<code>{{.Key}}</code>={{.String}} {{end}}</div>{{end}}
+</details>{{end}}
+</div></td>
+<td>{{ms .Offset}}</td><td>{{ms .Duration}}</td>
+<td><div class="bar"><div {{if .Error}}class="err"{{end}} style="left: {{printf "%.3f" .Left}}%; width: {{printf "%.3f" .Width}}%"></div></div></td>
+</tr>{{end}}
+</table>
+</body></html>`))
+
+// Mount registers the viewer on mux under Path, for services without an HTTP router (admin listeners)
+func Mount(mux *http.ServeMux, rec *Recorder) {
+	h := Handler(rec, Path)
+	mux.Handle(Path, h)
+	mux.Handle(Path+"/", h)
+}
+
+// SkipDebug is a span filter for tracing.MWSpanFilter, requests to the viewer and the other
+// /debug/ pages are not traced
+func SkipDebug(r *http.Request) bool {
+	return !strings.HasPrefix(r.URL.Path, "/debug/")
+}
@@ -0,0 +1,151 @@
+package traceview
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/alloykh/tracer-demo/tracing/model"
+	"github.com/uber/jaeger-client-go"
+)
+
+var (
+	defaultMaxTraces     = 100
+	defaultMaxTraceSpans = 500
+)
+
+// Recorder is a jaeger.Reporter keeping the last traces produced by the process in a ring buffer.
+// Only sampled spans reach reporters, so the cost is one conversion per sampled span.
+// Plug it with tracing.InitJaeger(..., tracing.WithSpanReporter(recorder)).
+type Recorder struct {
+	mu sync.RWMutex
+
+	traces map[string]*model.Trace
+	ring   []string // trace ids, ring[next] is the oldest once the ring is full
+	next   int
+
+	maxSpans int
+}
+
+// RecorderOption controls the behavior of the Recorder.
+type RecorderOption func(*Recorder)
+
+// WithMaxTraceSpans bounds the spans kept for a single trace, spans above the limit are dropped
+func WithMaxTraceSpans(n int) RecorderOption {
+	return func(r *Recorder) {
+		if n > 0 {
+			r.maxSpans = n
+		}
+	}
+}
+
+// NewRecorder - keeps the last maxTraces traces (100 when maxTraces <= 0)
+func NewRecorder(maxTraces int, opts ...RecorderOption) *Recorder {
+
+	if maxTraces <= 0 {
+		maxTraces = defaultMaxTraces
+	}
+
+	r := &Recorder{
+		traces:   make(map[string]*model.Trace, maxTraces),
+		ring:     make([]string, 0, maxTraces),
+		maxSpans: defaultMaxTraceSpans,
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	return r
+}
+
+// Report implements jaeger.Reporter
+func (r *Recorder) Report(span *jaeger.Span) {
+	r.Add(model.FromJaegerSpan(span))
+}
+
+// Close implements jaeger.Reporter
+func (r *Recorder) Close() {}
+
+// Add stores a finished span, evicting the oldest trace when a new trace does not fit
+func (r *Recorder) Add(span *model.Span) {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	t, ok := r.traces[span.TraceID]
+
+	if !ok {
+		t = &model.Trace{TraceID: span.TraceID}
+
+		if len(r.ring) < cap(r.ring) {
+			r.ring = append(r.ring, span.TraceID)
+		} else {
+			delete(r.traces, r.ring[r.next])
+			r.ring[r.next] = span.TraceID
+			r.next = (r.next + 1) % len(r.ring)
+		}
+
+		r.traces[span.TraceID] = t
+	}
+
+	if len(t.Spans) >= r.maxSpans {
+		if len(t.Warnings) == 0 {
+			t.Warnings = append(t.Warnings, "span limit reached, later spans were dropped")
+		}
+		return
+	}
+
+	t.Spans = append(t.Spans, span)
+}
+
+// Trace returns a normalized copy of the trace with the given id
+func (r *Recorder) Trace(id string) (*model.Trace, bool) {
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	t, ok := r.traces[id]
+	if !ok {
+		return nil, false
+	}
+
+	return snapshot(t), true
+}
+
+// Traces returns normalized copies of the recorded traces, the most recent first
+func (r *Recorder) Traces() []*model.Trace {
+
+	r.mu.RLock()
+	traces := make([]*model.Trace, 0, len(r.traces))
+	for _, t := range r.traces {
+		traces = append(traces, snapshot(t))
+	}
+	r.mu.RUnlock()
+
+	sort.Slice(traces, func(i, j int) bool {
+		si, _ := traces[i].Bounds()
+		sj, _ := traces[j].Bounds()
+		return si > sj
+	})
+
+	return traces
+}
+
+func snapshot(t *model.Trace) *model.Trace {
+
+	c := &model.Trace{
+		TraceID:  t.TraceID,
+		Spans:    make([]*model.Span, 0, len(t.Spans)),
+		Warnings: t.Warnings,
+	}
+
+	// Normalize assigns process ids, work on copies so readers don't race
+	for _, s := range t.Spans {
+		sc := *s
+		c.Spans = append(c.Spans, &sc)
+	}
+
+	c.Normalize()
+
+	return c
+}
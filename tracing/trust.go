@@ -0,0 +1,148 @@
+package tracing
+
+import (
+	"crypto/subtle"
+	"net"
+	"net/http"
+
+	"github.com/alloykh/tracer-demo/tracing/semconv"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"github.com/pkg/errors"
+)
+
+// TrustPolicy decides whether the trace context sent by a caller is continued.
+// Callers from a trusted network, or sending the trust header with the shared secret, continue their trace.
+// Any other caller gets a new trace: its context is only recorded as link tags, its sampling decision and
+// jaeger-debug-id are ignored and its baggage is dropped, but for the keys kept by TrustBaggage.
+type TrustPolicy struct {
+	networks []*net.IPNet
+	header   string
+	secret   string
+	baggage  map[string]bool
+}
+
+// TrustOption controls the behavior of the TrustPolicy.
+type TrustOption func(*TrustPolicy) error
+
+// TrustNetworks trusts callers whose address is in one of the CIDRs, e.g. "10.0.0.0/8".
+// The address is the one of the connection, X-Forwarded-For is not looked at as anyone can send it.
+func TrustNetworks(cidrs ...string) TrustOption {
+	return func(p *TrustPolicy) error {
+		for _, cidr := range cidrs {
+			_, network, err := net.ParseCIDR(cidr)
+			if err != nil {
+				return errors.Wrapf(err, "trusted network %q", cidr)
+			}
+			p.networks = append(p.networks, network)
+		}
+		return nil
+	}
+}
+
+// TrustHeader trusts callers sending header with the shared secret as value, an empty secret disables it
+func TrustHeader(header, secret string) TrustOption {
+	return func(p *TrustPolicy) error {
+		if header == "" || secret == "" {
+			return nil
+		}
+		p.header = http.CanonicalHeaderKey(header)
+		p.secret = secret
+		return nil
+	}
+}
+
+// TrustBaggage keeps the baggage items of keys sent by untrusted callers, the others are dropped
+func TrustBaggage(keys ...string) TrustOption {
+	return func(p *TrustPolicy) error {
+		for _, key := range keys {
+			p.baggage[key] = true
+		}
+		return nil
+	}
+}
+
+// NewTrustPolicy - a policy trusting nobody but the callers allowed by opts
+func NewTrustPolicy(opts ...TrustOption) (*TrustPolicy, error) {
+
+	p := &TrustPolicy{
+		baggage: make(map[string]bool),
+	}
+
+	for _, opt := range opts {
+		if err := opt(p); err != nil {
+			return nil, err
+		}
+	}
+
+	return p, nil
+}
+
+// Trusted reports whether the trace context of the request can be continued
+func (p *TrustPolicy) Trusted(r *http.Request) bool {
+
+	if p.header != "" {
+		if v := r.Header.Get(p.header); v != "" && subtle.ConstantTimeCompare([]byte(v), []byte(p.secret)) == 1 {
+			return true
+		}
+	}
+
+	if len(p.networks) == 0 {
+		return false
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+
+	for _, network := range p.networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// MWTrustPolicy returns a MWOption applying the policy to every request, without it every caller is trusted
+func MWTrustPolicy(policy *TrustPolicy) MWOption {
+	return func(options *mwOptions) {
+		options.trust = policy
+	}
+}
+
+// keepsBaggage reports whether the baggage item key of an untrusted caller is kept
+func (p *TrustPolicy) keepsBaggage(key string) bool {
+	return p.baggage[key]
+}
+
+// startUntrusted starts a new root span for an untrusted caller, tagged with the caller's context
+func (p *TrustPolicy) startUntrusted(tr opentracing.Tracer, opName string, caller opentracing.SpanContext) opentracing.Span {
+
+	span := tr.StartSpan(opName, ext.SpanKindRPCServer)
+
+	semconv.EdgeUntrusted(span)
+
+	if caller == nil {
+		return span
+	}
+
+	TagLink(span, caller, "follows_from")
+
+	caller.ForeachBaggageItem(func(k, v string) bool {
+		if p.keepsBaggage(k) {
+			span.SetBaggageItem(k, v)
+		} else {
+			semconv.EdgeBaggageDropped(span)
+		}
+		return true
+	})
+
+	return span
+}
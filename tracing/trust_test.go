@@ -0,0 +1,81 @@
+package tracing
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alloykh/tracer-demo/tracing/semconv"
+	"github.com/gin-gonic/gin"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+)
+
+func TestUntrustedBaggageHeaders(t *testing.T) {
+
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name       string
+		networks   []string
+		wantUser   string
+		wantTenant string
+		wantSess   string
+		wantDrop   bool
+	}{
+		{name: "untrusted", wantSess: "s1", wantDrop: true},
+		{name: "trusted", networks: []string{"203.0.113.0/24"}, wantUser: "u1", wantTenant: "t1", wantSess: "s1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			policy, err := NewTrustPolicy(TrustNetworks(tt.networks...), TrustBaggage("session"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			tracer := mocktracer.New()
+
+			var user, tenant, session string
+
+			router := gin.New()
+			router.Use(Tracer(tracer,
+				MWTrustPolicy(policy),
+				MWBaggageHeaders(map[string]string{"X-User-ID": "user", "X-Tenant-ID": "tenant", "X-Session-ID": "session"}),
+				MWIdentityBaggage("user", "tenant"),
+			))
+			router.GET("/", func(c *gin.Context) {
+				span := opentracing.SpanFromContext(c.Request.Context())
+				user, tenant, session = span.BaggageItem("user"), span.BaggageItem("tenant"), span.BaggageItem("session")
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = "203.0.113.7:41000"
+			req.Header.Set("X-User-ID", "u1")
+			req.Header.Set("X-Tenant-ID", "t1")
+			req.Header.Set("X-Session-ID", "s1")
+			router.ServeHTTP(httptest.NewRecorder(), req)
+
+			if user != tt.wantUser || tenant != tt.wantTenant || session != tt.wantSess {
+				t.Errorf("baggage user=%q tenant=%q session=%q, want %q %q %q", user, tenant, session, tt.wantUser, tt.wantTenant, tt.wantSess)
+			}
+
+			spans := tracer.FinishedSpans()
+			if len(spans) != 1 {
+				t.Fatalf("got %d spans, want 1", len(spans))
+			}
+			tags := spans[0].Tags()
+
+			if _, ok := tags["user.id"]; ok != (tt.wantUser != "") {
+				t.Errorf("user.id tag %v, want it set %v", tags["user.id"], tt.wantUser != "")
+			}
+			if _, ok := tags["tenant.id"]; ok != (tt.wantTenant != "") {
+				t.Errorf("tenant.id tag %v, want it set %v", tags["tenant.id"], tt.wantTenant != "")
+			}
+			if dropped := tags[semconv.EdgeBaggageDroppedKey] == true; dropped != tt.wantDrop {
+				t.Errorf("edge.baggage_dropped %v, want %v", tags[semconv.EdgeBaggageDroppedKey], tt.wantDrop)
+			}
+		})
+	}
+}